@@ -0,0 +1,85 @@
+package audit
+
+import "reflect"
+
+type Action string
+
+const (
+	// Baseline records the state of a task that existed before history was
+	// kept, so it can still be reverted to.
+	Baseline  Action = "baseline"
+	Created   Action = "created"
+	Updated   Action = "updated"
+	Completed Action = "completed"
+	Deleted   Action = "deleted"
+	Reverted  Action = "reverted"
+)
+
+// Snapshot is the state of a task as recorded in its history, including its
+// board rank, so that moving a task within its column is a change like any
+// other. Tags and Checklist are never nil in snapshots taken now; snapshots
+// recorded before descriptions, tags and checklists were kept have neither,
+// and reverting to them leaves those fields as they are.
+type Snapshot struct {
+	TaskName    string          `json:"task_name" bson:"task_name"`
+	DueDate     string          `json:"due_date" bson:"due_date"`
+	Completed   bool            `json:"completed" bson:"completed"`
+	Status      string          `json:"status" bson:"status"`
+	RRule       string          `json:"rrule" bson:"rrule"`
+	TimeZone    string          `json:"time_zone" bson:"time_zone"`
+	Rank        string          `json:"rank" bson:"rank"`
+	Description string          `json:"description" bson:"description"`
+	Tags        []string        `json:"tags" bson:"tags"`
+	Checklist   []ChecklistItem `json:"checklist" bson:"checklist"`
+}
+
+type ChecklistItem struct {
+	Text string `json:"text" bson:"text"`
+	Done bool   `json:"done" bson:"done"`
+}
+
+// HasContent reports whether s records the description, tags and checklist.
+func (s Snapshot) HasContent() bool {
+	return s.Tags != nil && s.Checklist != nil
+}
+
+type Change struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
+
+// Diff returns the fields that differ between two snapshots, in a fixed order.
+// Missing and empty lists are the same.
+func Diff(before, after Snapshot) []Change {
+	changes := []Change{}
+	add := func(field string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changes = append(changes, Change{Field: field, Before: a, After: b})
+		}
+	}
+	add("task_name", before.TaskName, after.TaskName)
+	add("due_date", before.DueDate, after.DueDate)
+	add("completed", before.Completed, after.Completed)
+	add("status", before.Status, after.Status)
+	add("rrule", before.RRule, after.RRule)
+	add("time_zone", before.TimeZone, after.TimeZone)
+	add("rank", before.Rank, after.Rank)
+	add("description", before.Description, after.Description)
+	if len(before.Tags) > 0 || len(after.Tags) > 0 {
+		add("tags", before.Tags, after.Tags)
+	}
+	if len(before.Checklist) > 0 || len(after.Checklist) > 0 {
+		add("checklist", before.Checklist, after.Checklist)
+	}
+	return changes
+}
+
+// UpdateAction classifies an update: completing a task is recorded as its own
+// action so it can be found without reading diffs.
+func UpdateAction(before, after Snapshot) Action {
+	if !before.Completed && after.Completed {
+		return Completed
+	}
+	return Updated
+}
@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	base := Snapshot{
+		TaskName:  "write report",
+		DueDate:   "2024-03-25T00:00:00Z",
+		Status:    "todo",
+		TimeZone:  "UTC",
+		Rank:      "V",
+		Tags:      []string{"+work"},
+		Checklist: []ChecklistItem{{Text: "outline"}},
+	}
+	tests := []struct {
+		name   string
+		change func(*Snapshot)
+		fields []string
+	}{
+		{"nothing", func(s *Snapshot) {}, nil},
+		{"name and due date", func(s *Snapshot) { s.TaskName = "write summary"; s.DueDate = "2024-03-26T00:00:00Z" }, []string{"task_name", "due_date"}},
+		{"completion", func(s *Snapshot) { s.Completed = true; s.Status = "done" }, []string{"completed", "status"}},
+		{"rank only", func(s *Snapshot) { s.Rank = "W" }, []string{"rank"}},
+		{"description", func(s *Snapshot) { s.Description = "for the board" }, []string{"description"}},
+		{"tags", func(s *Snapshot) { s.Tags = []string{"+work", "@office"} }, []string{"tags"}},
+		{"checklist item done", func(s *Snapshot) { s.Checklist = []ChecklistItem{{Text: "outline", Done: true}} }, []string{"checklist"}},
+		{"checklist cleared", func(s *Snapshot) { s.Checklist = nil }, []string{"checklist"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			after := base
+			after.Tags = append([]string(nil), base.Tags...)
+			after.Checklist = append([]ChecklistItem(nil), base.Checklist...)
+			test.change(&after)
+
+			var fields []string
+			for _, change := range Diff(base, after) {
+				fields = append(fields, change.Field)
+			}
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("Diff changed %v, want %v", fields, test.fields)
+			}
+		})
+	}
+}
+
+func TestDiffTreatsMissingListsAsEmpty(t *testing.T) {
+	// A snapshot recorded before tags and checklists were kept.
+	before := Snapshot{TaskName: "a", Status: "todo"}
+	after := Snapshot{TaskName: "a", Status: "todo", Tags: []string{}, Checklist: []ChecklistItem{}}
+	if changes := Diff(before, after); len(changes) != 0 {
+		t.Errorf("Diff = %v, want no changes", changes)
+	}
+	if before.HasContent() || !after.HasContent() {
+		t.Errorf("HasContent = %v, %v, want false, true", before.HasContent(), after.HasContent())
+	}
+}
+
+func TestUpdateAction(t *testing.T) {
+	tests := []struct {
+		before, after bool
+		want          Action
+	}{
+		{false, true, Completed},
+		{false, false, Updated},
+		{true, true, Updated},
+		{true, false, Updated},
+	}
+	for _, test := range tests {
+		got := UpdateAction(Snapshot{Completed: test.before}, Snapshot{Completed: test.after})
+		if got != test.want {
+			t.Errorf("UpdateAction(%v -> %v) = %s, want %s", test.before, test.after, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package backup
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/templates"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format names the dump format in its header. Version is bumped whenever a
+// change would make older readers misread a dump; readers accept dumps up to
+// the version they know.
+const (
+	Format  = "simple-task-manager-backup"
+	Version = 1
+)
+
+var ErrInvalid = errors.New("invalid backup")
+
+// Kinds of records, in the order they appear in a dump. A record only refers
+// to records of earlier kinds, except for tasks referring to their parent
+// task.
+const (
+	KindUser         = "user"
+	KindTask         = "task"
+	KindStatusChange = "status_change"
+	KindComment      = "comment"
+	KindAttachment   = "attachment"
+	KindBlob         = "blob"
+	KindTimeEntry    = "time_entry"
+	KindHistory      = "history"
+	KindTemplate     = "template"
+	kindEnd          = "end"
+)
+
+var kindOrder = []string{KindUser, KindTask, KindStatusChange, KindComment, KindAttachment, KindBlob, KindTimeEntry, KindHistory, KindTemplate}
+
+// Header is the first line of a dump.
+type Header struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source"`
+}
+
+// Record is any later line. The last one is of kind "end" and holds the
+// number of records of every kind, so a truncated dump is recognized.
+type Record struct {
+	Kind string          `json:"kind"`
+	Data json.RawMessage `json:"data"`
+}
+
+type trailer struct {
+	Counts map[string]int `json:"counts"`
+}
+
+// The records below are backend-neutral: IDs are strings, whatever the
+// backend that wrote them, and times are UTC.
+
+type User struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	TimeZone      string `json:"time_zone"`
+	FeedTokenHash string `json:"feed_token_hash,omitempty"`
+}
+
+type Task struct {
+	ID              string          `json:"id"`
+	UserID          string          `json:"user_id"`
+	Name            string          `json:"name"`
+	DueDate         time.Time       `json:"due_date"`
+	Completed       bool            `json:"completed"`
+	RRule           string          `json:"rrule,omitempty"`
+	TimeZone        string          `json:"time_zone"`
+	Status          string          `json:"status"`
+	StatusChangedBy *string         `json:"status_changed_by,omitempty"`
+	StatusChangedAt *time.Time      `json:"status_changed_at,omitempty"`
+	Rank            string          `json:"rank"`
+	Description     string          `json:"description,omitempty"`
+	Tags            []string        `json:"tags,omitempty"`
+	Checklist       []ChecklistItem `json:"checklist,omitempty"`
+	ParentTaskID    *string         `json:"parent_task_id,omitempty"`
+}
+
+type ChecklistItem struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+type StatusChange struct {
+	ID         string    `json:"id"`
+	TaskID     string    `json:"task_id"`
+	UserID     *string   `json:"user_id,omitempty"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+type Comment struct {
+	ID        string     `json:"id"`
+	TaskID    string     `json:"task_id"`
+	UserID    string     `json:"user_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Mentions  []string   `json:"mentions,omitempty"`
+}
+
+type Attachment struct {
+	ID          string    `json:"id"`
+	TaskID      string    `json:"task_id"`
+	UserID      string    `json:"user_id"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	BlobKey     string    `json:"blob_key"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Blob is the content of an attachment. Dumps only carry blobs when asked
+// to; otherwise the blob store is backed up on its own.
+type Blob struct {
+	Key         string `json:"key"`
+	ContentType string `json:"content_type"`
+	Content     []byte `json:"content"`
+}
+
+type TimeEntry struct {
+	ID        string     `json:"id"`
+	TaskID    string     `json:"task_id"`
+	UserID    string     `json:"user_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Note      string     `json:"note,omitempty"`
+}
+
+type History struct {
+	ID        string         `json:"id"`
+	TaskID    string         `json:"task_id"`
+	Version   int            `json:"version"`
+	Action    audit.Action   `json:"action"`
+	ActorID   *string        `json:"actor_id,omitempty"`
+	ChangedAt time.Time      `json:"changed_at"`
+	Changes   []audit.Change `json:"changes"`
+	Snapshot  audit.Snapshot `json:"snapshot"`
+}
+
+type Template struct {
+	ID         string             `json:"id"`
+	Definition templates.Template `json:"definition"`
+	CreatedBy  string             `json:"created_by"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  *time.Time         `json:"updated_at,omitempty"`
+}
+
+// Result reports a restore.
+type Result struct {
+	Source   string         `json:"source"`
+	Restored map[string]int `json:"restored"`
+}
+
+// Writer writes a dump. Records must be written in kind order.
+type Writer struct {
+	encoder *json.Encoder
+	counts  map[string]int
+}
+
+func NewWriter(out io.Writer, source string, now time.Time) (*Writer, error) {
+	writer := &Writer{encoder: json.NewEncoder(out), counts: make(map[string]int)}
+	err := writer.encoder.Encode(Header{Format: Format, Version: Version, CreatedAt: now.UTC(), Source: source})
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *Writer) Write(kind string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err = w.encoder.Encode(Record{Kind: kind, Data: encoded}); err != nil {
+		return err
+	}
+	w.counts[kind]++
+	return nil
+}
+
+// Close ends the dump. A dump without its end record is rejected on restore.
+func (w *Writer) Close() error {
+	return w.Write(kindEnd, trailer{Counts: w.counts})
+}
+
+// Reader reads a dump, checking its header, the order of its records and its
+// completeness.
+type Reader struct {
+	Header  Header
+	decoder *json.Decoder
+	counts  map[string]int
+	kind    int
+	done    bool
+}
+
+func NewReader(in io.Reader) (*Reader, error) {
+	reader := &Reader{decoder: json.NewDecoder(in), counts: make(map[string]int)}
+	if err := reader.decoder.Decode(&reader.Header); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %v", ErrInvalid, err)
+	}
+	if reader.Header.Format != Format {
+		return nil, fmt.Errorf("%w: not a task manager backup", ErrInvalid)
+	}
+	if reader.Header.Version < 1 || reader.Header.Version > Version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalid, reader.Header.Version)
+	}
+	return reader, nil
+}
+
+// Next returns the next record, or io.EOF after the end record.
+func (r *Reader) Next() (Record, error) {
+	if r.done {
+		return Record{}, io.EOF
+	}
+	var record Record
+	err := r.decoder.Decode(&record)
+	if errors.Is(err, io.EOF) {
+		return Record{}, fmt.Errorf("%w: dump is truncated", ErrInvalid)
+	}
+	if err != nil {
+		return Record{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	if record.Kind == kindEnd {
+		return Record{}, r.finish(record)
+	}
+	index := -1
+	for i, kind := range kindOrder {
+		if kind == record.Kind {
+			index = i
+		}
+	}
+	switch {
+	case index < 0:
+		return Record{}, fmt.Errorf("%w: unknown record kind %q", ErrInvalid, record.Kind)
+	case index < r.kind:
+		return Record{}, fmt.Errorf("%w: %s record after %s records", ErrInvalid, record.Kind, kindOrder[r.kind])
+	}
+	r.kind = index
+	r.counts[record.Kind]++
+	return record, nil
+}
+
+func (r *Reader) finish(record Record) error {
+	var end trailer
+	if err := json.Unmarshal(record.Data, &end); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	for _, kind := range kindOrder {
+		if end.Counts[kind] != r.counts[kind] {
+			return fmt.Errorf("%w: expected %d %s records, found %d", ErrInvalid, end.Counts[kind], kind, r.counts[kind])
+		}
+	}
+	if r.decoder.More() {
+		return fmt.Errorf("%w: data after the end record", ErrInvalid)
+	}
+	r.done = true
+	return io.EOF
+}
+
+// Counts returns how many records of every kind were read so far.
+func (r *Reader) Counts() map[string]int {
+	counts := make(map[string]int, len(r.counts))
+	for kind, count := range r.counts {
+		counts[kind] = count
+	}
+	return counts
+}
+
+// Decode unmarshals the data of a record.
+func Decode(record Record, data interface{}) error {
+	if err := json.Unmarshal(record.Data, data); err != nil {
+		return fmt.Errorf("%w: %s record: %v", ErrInvalid, record.Kind, err)
+	}
+	return nil
+}
+
+// Dangling reports a record referring to a record the dump does not hold.
+func Dangling(kind, id, field, target string) error {
+	return fmt.Errorf("%w: %s %s refers to unknown %s %q", ErrInvalid, kind, id, field, target)
+}
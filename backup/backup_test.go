@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	now := time.Date(2024, time.March, 25, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	var dump bytes.Buffer
+	writer, err := NewWriter(&dump, "sqlite", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := User{ID: "1", Name: "ada", TimeZone: "Europe/Berlin"}
+	task := Task{ID: "2", Name: "water plants", UserID: "1", Status: "todo", Tags: []string{"+home"}}
+	for _, record := range []struct {
+		kind string
+		data interface{}
+	}{{KindUser, user}, {KindTask, task}} {
+		if err = writer.Write(record.kind, record.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := NewReader(&dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Header{Format: Format, Version: Version, CreatedAt: now.UTC(), Source: "sqlite"}); reader.Header != want {
+		t.Errorf("header %+v, want %+v", reader.Header, want)
+	}
+
+	var readUser User
+	var readTask Task
+	for _, data := range []interface{}{&readUser, &readTask} {
+		record, err := reader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = Decode(record, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if readUser != user || !reflect.DeepEqual(readTask, task) {
+		t.Errorf("read back %+v and %+v, want %+v and %+v", readUser, readTask, user, task)
+	}
+	if _, err = reader.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Next after the last record returned %v, want io.EOF", err)
+	}
+	if counts := reader.Counts(); !reflect.DeepEqual(counts, map[string]int{KindUser: 1, KindTask: 1}) {
+		t.Errorf("Counts = %v", counts)
+	}
+}
+
+func TestNewReaderRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		reason string
+	}{
+		{name: "empty", header: "", reason: "reading header"},
+		{name: "not JSON", header: "PK\x03\x04", reason: "reading header"},
+		{name: "other format", header: `{"format":"tasks","version":1}`, reason: "not a task manager backup"},
+		{name: "newer version", header: `{"format":"simple-task-manager-backup","version":2}`, reason: "unsupported version 2"},
+		{name: "no version", header: `{"format":"simple-task-manager-backup"}`, reason: "unsupported version 0"},
+	}
+	for _, test := range tests {
+		_, err := NewReader(strings.NewReader(test.header))
+		if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%s: NewReader returned %v, want %q", test.name, err, test.reason)
+		}
+	}
+}
+
+func TestNextRejects(t *testing.T) {
+	const header = `{"format":"simple-task-manager-backup","version":1,"source":"sqlite"}` + "\n"
+	const user = `{"kind":"user","data":{}}` + "\n"
+	const task = `{"kind":"task","data":{}}` + "\n"
+	tests := []struct {
+		name    string
+		records string
+		reason  string
+	}{
+		{name: "truncated", records: user, reason: "dump is truncated"},
+		{name: "unknown kind", records: `{"kind":"project","data":{}}`, reason: `unknown record kind "project"`},
+		{name: "out of order", records: task + user, reason: "user record after task records"},
+		{name: "count mismatch", records: user + `{"kind":"end","data":{"counts":{"user":2}}}`, reason: "expected 2 user records, found 1"},
+		{name: "data after the end", records: user + `{"kind":"end","data":{"counts":{"user":1}}}` + "\n" + user, reason: "data after the end record"},
+		{name: "malformed record", records: `{"kind":`, reason: "invalid backup"},
+	}
+	for _, test := range tests {
+		reader, err := NewReader(strings.NewReader(header + test.records))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for err == nil {
+			_, err = reader.Next()
+		}
+		if !errors.Is(err, ErrInvalid) || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%s: Next returned %v, want %q", test.name, err, test.reason)
+		}
+	}
+}
+
+func TestDangling(t *testing.T) {
+	err := Dangling(KindTask, "2", "user", "9")
+	if !errors.Is(err, ErrInvalid) || err.Error() != `invalid backup: task 2 refers to unknown user "9"` {
+		t.Errorf("Dangling = %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+var (
+	ErrNotFound   = errors.New("blob not found")
+	ErrInvalidKey = errors.New("invalid blob key")
+	ErrTooLarge   = errors.New("file too large")
+	ErrBadType    = errors.New("file type not allowed")
+)
+
+// Store keeps attachment content. Keys are slash-separated paths created by
+// NewKey; the metadata describing a blob lives in the task backend.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Limits restricts what can be uploaded. The content type is sniffed from the
+// data rather than taken from the client.
+type Limits struct {
+	MaxSize      int64
+	AllowedTypes []string
+}
+
+func DefaultLimits() *Limits {
+	return &Limits{
+		MaxSize: 25 << 20,
+		AllowedTypes: []string{
+			"application/gzip",
+			"application/json",
+			"application/pdf",
+			"application/x-gzip",
+			"application/zip",
+			"image/gif",
+			"image/jpeg",
+			"image/png",
+			"image/webp",
+			"text/csv",
+			"text/plain",
+		},
+	}
+}
+
+// NewKey returns a fresh key below prefix.
+func NewKey(prefix string) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + "/" + hex.EncodeToString(buf), nil
+}
+
+// Upload checks r against the limits and stores it under key. It returns the
+// detected content type and the size. Nothing is stored if a limit is hit.
+func Upload(ctx context.Context, store Store, limits *Limits, key string, r io.Reader) (string, int64, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", 0, err
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !limits.allows(contentType) {
+		return "", 0, ErrBadType
+	}
+
+	content := &limitedReader{r: io.MultiReader(bytes.NewReader(head), r), remaining: limits.MaxSize}
+	size, err := store.Put(ctx, key, content, contentType)
+	if err != nil {
+		return "", 0, err
+	}
+	return contentType, size, nil
+}
+
+func (l *Limits) allows(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range l.AllowedTypes {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedReader fails with ErrTooLarge instead of silently truncating, so the
+// store discards the partial upload.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,173 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestUpload(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 100)...)
+	tests := []struct {
+		name        string
+		content     []byte
+		maxSize     int64
+		contentType string
+		err         error
+	}{
+		{name: "text", content: []byte("hello"), maxSize: 10, contentType: "text/plain; charset=utf-8"},
+		{name: "exactly the limit", content: []byte("0123456789"), maxSize: 10, contentType: "text/plain; charset=utf-8"},
+		{name: "over the limit", content: []byte("0123456789a"), maxSize: 10, err: ErrTooLarge},
+		{name: "disallowed type", content: png, maxSize: 1000, err: ErrBadType},
+		{name: "empty", content: nil, maxSize: 10, contentType: "text/plain; charset=utf-8"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewLocal(t.TempDir())
+			limits := &Limits{MaxSize: test.maxSize, AllowedTypes: []string{"text/plain"}}
+			contentType, size, err := Upload(context.Background(), store, limits, "tasks/1", bytes.NewReader(test.content))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("Upload returned error %v, want %v", err, test.err)
+			}
+
+			file, openErr := store.Open(context.Background(), "tasks/1")
+			if test.err != nil {
+				if !errors.Is(openErr, ErrNotFound) {
+					t.Errorf("failed upload left a blob behind: %v", openErr)
+				}
+				return
+			}
+			if contentType != test.contentType || size != int64(len(test.content)) {
+				t.Errorf("Upload returned %q, %d, want %q, %d", contentType, size, test.contentType, len(test.content))
+			}
+			if openErr != nil {
+				t.Fatal(openErr)
+			}
+			defer file.Close()
+			stored, _ := io.ReadAll(file)
+			if !bytes.Equal(stored, test.content) {
+				t.Errorf("stored %q, want %q", stored, test.content)
+			}
+		})
+	}
+}
+
+func TestLocalKeys(t *testing.T) {
+	tests := []struct {
+		key string
+		err error
+	}{
+		{key: "tasks/1/abc"},
+		{key: "", err: ErrInvalidKey},
+		{key: ".", err: ErrInvalidKey},
+		{key: "../outside", err: ErrInvalidKey},
+		{key: "/absolute", err: ErrInvalidKey},
+		{key: "tasks/../../outside", err: ErrInvalidKey},
+	}
+	store := NewLocal(t.TempDir())
+	for _, test := range tests {
+		_, err := store.Put(context.Background(), test.key, strings.NewReader("x"), "text/plain")
+		if !errors.Is(err, test.err) {
+			t.Errorf("Put(%q) returned error %v, want %v", test.key, err, test.err)
+		}
+	}
+}
+
+func TestLocalMissingBlob(t *testing.T) {
+	store := NewLocal(t.TempDir())
+	if _, err := store.Open(context.Background(), "tasks/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open of a missing blob returned %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(context.Background(), "tasks/missing"); err != nil {
+		t.Errorf("Delete of a missing blob returned %v", err)
+	}
+}
+
+// fakeS3 serves objects from memory, with ranges, and requires signed
+// requests.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = body
+	case http.MethodDelete:
+		if _, ok := f.objects[r.URL.Path]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		object, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(object))
+	}
+}
+
+func TestS3(t *testing.T) {
+	server := httptest.NewServer(&fakeS3{objects: make(map[string][]byte)})
+	defer server.Close()
+	store := &S3{Endpoint: server.URL, Region: "us-east-1", Bucket: "attachments", AccessKey: "access", SecretKey: "secret"}
+	ctx := context.Background()
+
+	content := "hello, attachments"
+	if size, err := store.Put(ctx, "tasks/1", strings.NewReader(content), "text/plain"); err != nil || size != int64(len(content)) {
+		t.Fatalf("Put returned %d, %v", size, err)
+	}
+
+	tests := []struct {
+		offset int64
+		whence int
+		want   string
+	}{
+		{offset: 0, whence: io.SeekStart, want: content},
+		{offset: 7, whence: io.SeekStart, want: "attachments"},
+		{offset: -5, whence: io.SeekEnd, want: "ments"},
+		{offset: 0, whence: io.SeekEnd, want: ""},
+	}
+	for _, test := range tests {
+		object, err := store.Open(ctx, "tasks/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = object.Seek(test.offset, test.whence); err != nil {
+			t.Fatal(err)
+		}
+		read, err := io.ReadAll(object)
+		object.Close()
+		if err != nil || string(read) != test.want {
+			t.Errorf("reading from %d (whence %d) returned %q, %v, want %q", test.offset, test.whence, read, err, test.want)
+		}
+	}
+
+	if err := store.Delete(ctx, "tasks/1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "tasks/1"); err != nil {
+		t.Errorf("Delete of a missing object returned %v", err)
+	}
+	if _, err := store.Open(ctx, "tasks/1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open of a deleted object returned %v, want ErrNotFound", err)
+	}
+}
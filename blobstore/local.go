@@ -0,0 +1,92 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local stores blobs as files below Dir.
+type Local struct {
+	Dir string
+}
+
+func NewLocal(dir string) *Local {
+	return &Local{Dir: dir}
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+
+	// Write to a temporary file first so a failed upload never leaves a
+	// partial blob behind.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) path(key string) (string, error) {
+	if key == "." || !fs.ValidPath(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
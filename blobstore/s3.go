@@ -0,0 +1,233 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3 stores blobs in a bucket of an S3-compatible service such as MinIO.
+// Requests use path-style addressing and are signed with Signature Version 4.
+type S3 struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) (int64, error) {
+	// S3 needs the content length up front, so the upload is spooled to a
+	// temporary file first.
+	tmp, err := os.CreateTemp("", "s3-upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, contextReader{ctx: ctx, r: r})
+	if err != nil {
+		return 0, err
+	}
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, io.NopCloser(tmp))
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return size, nil
+}
+
+func (s *S3) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodHead, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return &s3Object{store: s, ctx: ctx, key: key, size: resp.ContentLength}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return nil, ErrInvalidKey
+	}
+	u, err := url.Parse(strings.TrimRight(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u.Path += "/" + s.Bucket + "/" + key
+	u.RawPath = ""
+	return http.NewRequestWithContext(ctx, method, u.String(), body)
+}
+
+func (s *S3) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now().UTC())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	case resp.StatusCode >= 300:
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
+
+func (s *S3) sign(req *http.Request, now time.Time) {
+	const payloadHash = "UNSIGNED-PAYLOAD"
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.SecretKey)
+	for _, part := range []string{now.Format("20060102"), s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKey+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Object reads an object lazily with ranged GET requests, so seeking to the
+// start of a requested range does not download the bytes before it.
+type s3Object struct {
+	store  *S3
+	ctx    context.Context
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (o *s3Object) Read(p []byte) (int, error) {
+	if o.offset >= o.size {
+		return 0, io.EOF
+	}
+	if o.body == nil {
+		req, err := o.store.newRequest(o.ctx, http.MethodGet, o.key, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(o.offset, 10)+"-")
+		resp, err := o.store.do(req)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode != http.StatusPartialContent && o.offset > 0 {
+			resp.Body.Close()
+			return 0, fmt.Errorf("s3 GET %s: range not honored", o.key)
+		}
+		o.body = resp.Body
+	}
+
+	n, err := o.body.Read(p)
+	o.offset += int64(n)
+	return n, err
+}
+
+func (o *s3Object) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = o.offset + offset
+	case io.SeekEnd:
+		next = o.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+	if next != o.offset && o.body != nil {
+		o.body.Close()
+		o.body = nil
+	}
+	o.offset = next
+	return next, nil
+}
+
+func (o *s3Object) Close() error {
+	if o.body == nil {
+		return nil
+	}
+	err := o.body.Close()
+	o.body = nil
+	return err
+}
@@ -0,0 +1,190 @@
+package bulk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type Op string
+
+const (
+	Create   Op = "create"
+	Update   Op = "update"
+	Complete Op = "complete"
+	Delete   Op = "delete"
+)
+
+type Mode string
+
+const (
+	// Atomic applies either every operation or none of them.
+	Atomic Mode = "atomic"
+	// Partial applies every operation that succeeds and reports the others.
+	Partial Mode = "partial"
+)
+
+// MaxOperations bounds the size of a single batch.
+const MaxOperations = 500
+
+var (
+	ErrUnknownMode = errors.New("unknown bulk mode")
+	ErrUnknownOp   = errors.New("unknown bulk operation")
+)
+
+// ID is a task or user ID as sent by a client. SQLite IDs arrive as JSON
+// numbers and MongoDB IDs as strings; both are kept as text.
+type ID string
+
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*id = ID(text)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("ID must be a number or a string: %w", err)
+	}
+	*id = ID(number.String())
+	return nil
+}
+
+// Operation is one entry of a batch. Which fields are used depends on Op and
+// mirrors the single-task endpoints: create takes a user name like POST
+// /tasks and optionally tags and the status to create the task in, or
+// Completed for the completed status of the workflow; the others take a task
+// and the ID of the user it belongs to.
+type Operation struct {
+	Op        Op       `json:"op"`
+	TaskID    ID       `json:"task_id,omitempty"`
+	UserID    ID       `json:"user_id,omitempty"`
+	UserName  string   `json:"user_name,omitempty"`
+	TaskName  string   `json:"task_name,omitempty"`
+	DueDate   string   `json:"due_date,omitempty"`
+	RRule     string   `json:"rrule,omitempty"`
+	TimeZone  string   `json:"time_zone,omitempty"`
+	Status    string   `json:"status,omitempty"`
+	Completed bool     `json:"completed,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+func ParseMode(value string) (Mode, error) {
+	switch Mode(value) {
+	case "", Atomic:
+		return Atomic, nil
+	case Partial:
+		return Partial, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownMode, value)
+}
+
+// Validate checks the fields an operation needs, without looking anything
+// up.
+func (o Operation) Validate() *Failure {
+	switch o.Op {
+	case Create:
+		switch {
+		case o.UserName == "":
+			return Fail(http.StatusBadRequest, "Missing user name")
+		case o.TaskName == "":
+			return Fail(http.StatusBadRequest, "Missing task name")
+		case o.DueDate == "":
+			return Fail(http.StatusBadRequest, "Missing due date")
+		}
+		return nil
+	case Update, Complete, Delete:
+		switch {
+		case o.TaskID == "":
+			return Fail(http.StatusBadRequest, "Missing task ID")
+		case o.UserID == "":
+			return Fail(http.StatusBadRequest, "Missing user ID")
+		case o.Op == Update && o.DueDate == "" && o.Status == "":
+			return Fail(http.StatusBadRequest, "Missing due date or status")
+		}
+		return nil
+	}
+	return Fail(http.StatusBadRequest, fmt.Sprintf("%v: %q", ErrUnknownOp, o.Op))
+}
+
+// Failure is why a single operation could not be applied, with the status
+// code the corresponding single-task endpoint would have answered.
+type Failure struct {
+	Status  int
+	Message string
+}
+
+func Fail(status int, message string) *Failure {
+	return &Failure{Status: status, Message: message}
+}
+
+func (f *Failure) Error() string {
+	return f.Message
+}
+
+// FailureOf turns err into a Failure, treating anything unexpected as an
+// internal error.
+func FailureOf(err error) *Failure {
+	var failure *Failure
+	if errors.As(err, &failure) {
+		return failure
+	}
+	return Fail(http.StatusInternalServerError, "Internal server error")
+}
+
+type Result struct {
+	Index  int         `json:"index"`
+	Op     Op          `json:"op"`
+	Status int         `json:"status"`
+	TaskID interface{} `json:"task_id,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type Response struct {
+	Mode      Mode     `json:"mode"`
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	Results   []Result `json:"results"`
+}
+
+// Succeed records a successful operation.
+func (r *Response) Succeed(index int, op Op, status int, taskID interface{}) {
+	r.Results = append(r.Results, Result{Index: index, Op: op, Status: status, TaskID: taskID})
+	r.Succeeded++
+}
+
+// Fail records a failed operation.
+func (r *Response) Fail(index int, op Op, failure *Failure) {
+	r.Results = append(r.Results, Result{Index: index, Op: op, Status: failure.Status, Error: failure.Message})
+	r.Failed++
+}
+
+// Abort turns the response of an atomic batch into one where nothing was
+// applied: operations that had succeeded are reported as rolled back with
+// 424 Failed Dependency.
+func (r *Response) Abort() {
+	for i := range r.Results {
+		if r.Results[i].Error == "" {
+			r.Results[i].Status = http.StatusFailedDependency
+			r.Results[i].TaskID = nil
+			r.Results[i].Error = "Rolled back because another operation failed"
+		}
+	}
+	r.Failed = len(r.Results)
+	r.Succeeded = 0
+}
+
+// StatusCode is the HTTP status for the whole batch: 200 unless an atomic
+// batch was aborted, in which case the status of its first real failure.
+func (r *Response) StatusCode() int {
+	if r.Mode != Atomic || r.Failed == 0 {
+		return http.StatusOK
+	}
+	for _, result := range r.Results {
+		if result.Status != http.StatusFailedDependency {
+			return result.Status
+		}
+	}
+	return http.StatusConflict
+}
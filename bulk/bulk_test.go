@@ -0,0 +1,129 @@
+package bulk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestIDUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json    string
+		want    ID
+		invalid bool
+	}{
+		{json: `12`, want: "12"},
+		{json: `"65f1c0e2a4b5c6d7e8f90123"`, want: "65f1c0e2a4b5c6d7e8f90123"},
+		{json: `true`, invalid: true},
+		{json: `{}`, invalid: true},
+	}
+	for _, test := range tests {
+		var id ID
+		err := json.Unmarshal([]byte(test.json), &id)
+		if test.invalid != (err != nil) || id != test.want {
+			t.Errorf("unmarshalling %s gave %q, %v", test.json, id, err)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Mode
+		err   error
+	}{
+		{value: "", want: Atomic},
+		{value: "atomic", want: Atomic},
+		{value: "partial", want: Partial},
+		{value: "Atomic", err: ErrUnknownMode},
+		{value: "all", err: ErrUnknownMode},
+	}
+	for _, test := range tests {
+		mode, err := ParseMode(test.value)
+		if mode != test.want || !errors.Is(err, test.err) {
+			t.Errorf("ParseMode(%q) = %q, %v, want %q, %v", test.value, mode, err, test.want, test.err)
+		}
+	}
+}
+
+func TestOperationValidate(t *testing.T) {
+	tests := []struct {
+		operation Operation
+		want      string
+	}{
+		{operation: Operation{Op: Create, UserName: "ada", TaskName: "water plants", DueDate: "tomorrow"}},
+		{operation: Operation{Op: Create, TaskName: "water plants", DueDate: "tomorrow"}, want: "Missing user name"},
+		{operation: Operation{Op: Create, UserName: "ada", DueDate: "tomorrow"}, want: "Missing task name"},
+		{operation: Operation{Op: Create, UserName: "ada", TaskName: "water plants"}, want: "Missing due date"},
+		{operation: Operation{Op: Update, TaskID: "1", UserID: "1", Status: "done"}},
+		{operation: Operation{Op: Update, TaskID: "1", UserID: "1"}, want: "Missing due date or status"},
+		{operation: Operation{Op: Complete, TaskID: "1", UserID: "1"}},
+		{operation: Operation{Op: Complete, UserID: "1"}, want: "Missing task ID"},
+		{operation: Operation{Op: Delete, TaskID: "1"}, want: "Missing user ID"},
+		{operation: Operation{Op: "archive"}, want: `unknown bulk operation: "archive"`},
+	}
+	for _, test := range tests {
+		failure := test.operation.Validate()
+		switch {
+		case test.want == "" && failure != nil:
+			t.Errorf("%+v: Validate = %v", test.operation, failure)
+		case test.want != "" && (failure == nil || failure.Message != test.want || failure.Status != http.StatusBadRequest):
+			t.Errorf("%+v: Validate = %v, want %q", test.operation, failure, test.want)
+		}
+	}
+}
+
+func TestFailureOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Failure
+	}{
+		{err: Fail(http.StatusNotFound, "Task not found"), want: Failure{Status: http.StatusNotFound, Message: "Task not found"}},
+		{err: fmt.Errorf("planning: %w", Fail(http.StatusConflict, "Task changed")), want: Failure{Status: http.StatusConflict, Message: "Task changed"}},
+		{err: errors.New("disk full"), want: Failure{Status: http.StatusInternalServerError, Message: "Internal server error"}},
+	}
+	for _, test := range tests {
+		if got := FailureOf(test.err); *got != test.want {
+			t.Errorf("FailureOf(%v) = %+v, want %+v", test.err, *got, test.want)
+		}
+	}
+}
+
+func TestResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      Mode
+		failures  map[int]*Failure
+		abort     bool
+		status    int
+		succeeded int
+	}{
+		{name: "atomic, all succeeded", mode: Atomic, status: http.StatusOK, succeeded: 3},
+		{name: "partial with a failure", mode: Partial, failures: map[int]*Failure{1: Fail(http.StatusNotFound, "Task not found")}, status: http.StatusOK, succeeded: 2},
+		{name: "atomic, aborted", mode: Atomic, failures: map[int]*Failure{1: Fail(http.StatusNotFound, "Task not found")}, abort: true, status: http.StatusNotFound},
+		{name: "atomic, aborted without a cause", mode: Atomic, abort: true, status: http.StatusConflict},
+	}
+	for _, test := range tests {
+		response := Response{Mode: test.mode}
+		for i := 0; i < 3; i++ {
+			if failure, failed := test.failures[i]; failed {
+				response.Fail(i, Delete, failure)
+			} else {
+				response.Succeed(i, Delete, http.StatusOK, "1")
+			}
+		}
+		if test.abort {
+			response.Abort()
+			for _, result := range response.Results {
+				if result.TaskID != nil || result.Error == "" {
+					t.Errorf("%s: result %+v was not rolled back", test.name, result)
+				}
+			}
+		}
+		if status := response.StatusCode(); status != test.status || response.Succeeded != test.succeeded || response.Succeeded+response.Failed != 3 {
+			t.Errorf("%s: status %d with %d of %d succeeded, want %d with %d", test.name, status, response.Succeeded, len(response.Results), test.status, test.succeeded)
+		}
+	}
+}
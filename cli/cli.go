@@ -0,0 +1,333 @@
+package cli
+
+import (
+	"Simple_Task_Manager/backup"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/taskcsv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultServer = "http://localhost:8080"
+
+var errUsage = errors.New("usage")
+
+type command struct {
+	usage string
+	run   func(client *client, args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"import": {
+		usage: "import [-server URL] [-format csv|todotxt|markdown] [-user NAME] [-due DATE] [-mode partial|atomic] FILE",
+		run:   runImport,
+	},
+	"export": {
+		usage: "export [-server URL] [-format csv|todotxt|markdown] [-user-id ID] [-o FILE]",
+		run:   runExport,
+	},
+	"backup": {
+		usage: "backup [-server URL] [-blobs] [-o FILE]",
+		run:   runBackup,
+	},
+	"restore": {
+		usage: "restore [-server URL] FILE",
+		run:   runRestore,
+	},
+}
+
+// Run executes a command line and returns the exit code. Commands are
+// clients of a running server, found through -server or TASK_SERVER, so they
+// work the same with either database. TASK_ADMIN_TOKEN is sent as a bearer
+// token for the server's administration endpoints.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command %q\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+
+	server := os.Getenv("TASK_SERVER")
+	if server == "" {
+		server = defaultServer
+	}
+	err := cmd.run(&client{server: server, token: os.Getenv("TASK_ADMIN_TOKEN"), http: http.DefaultClient}, args[1:], stdout)
+	switch {
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		fmt.Fprintln(stderr, "Usage: Simple_Task_Manager "+cmd.usage)
+		return 2
+	case err != nil:
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: Simple_Task_Manager [command]")
+	fmt.Fprintln(out, "Without a command the server is started. Commands:")
+	for _, name := range []string{"import", "export", "backup", "restore"} {
+		fmt.Fprintln(out, "  "+commands[name].usage)
+	}
+}
+
+func newFlagSet(name string, client *client) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&client.server, "server", client.server, "address of the task manager")
+	return flags
+}
+
+func runImport(client *client, args []string, stdout io.Writer) error {
+	flags := newFlagSet("import", client)
+	format := flags.String("format", "", "csv, todotxt or markdown; guessed from the file name if empty")
+	user := flags.String("user", "", "user of tasks that do not name one")
+	due := flags.String("due", "", "due date of tasks without one")
+	mode := flags.String("mode", "", "partial imports every valid task, atomic all or none")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	name := flags.Arg(0)
+	var in io.Reader = os.Stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	if *format == "" {
+		*format = formatOf(name)
+	}
+
+	query := url.Values{}
+	for key, value := range map[string]string{"format": *format, "user_name": *user, "default_due": *due, "mode": *mode} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	response, err := client.do(http.MethodPost, "/api/v2/tasks/import?"+query.Encode(), in)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var result taskcsv.ImportResponse
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("reading import result: %w", err)
+	}
+	for _, row := range result.Rows {
+		if row.Error != "" {
+			fmt.Fprintf(stdout, "line %d: %s\n", row.Line, row.Error)
+		}
+	}
+	fmt.Fprintf(stdout, "Imported %d tasks, %d failed\n", result.Imported, result.Failed)
+	if result.Failed > 0 {
+		return errors.New("some tasks were not imported")
+	}
+	return nil
+}
+
+func runExport(client *client, args []string, stdout io.Writer) error {
+	flags := newFlagSet("export", client)
+	format := flags.String("format", "", "csv, todotxt or markdown; guessed from -o if empty")
+	userID := flags.String("user-id", "", "export only the tasks of this user")
+	output := flags.String("o", "-", "file to write, - for standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errUsage
+	}
+	if *format == "" {
+		*format = formatOf(*output)
+	}
+
+	query := url.Values{"format": {*format}}
+	if *userID != "" {
+		query.Set("user_id", *userID)
+	}
+	response, err := client.do(http.MethodGet, "/api/v2/tasks/export?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	out := stdout
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	_, err = io.Copy(out, response.Body)
+	return err
+}
+
+func runBackup(client *client, args []string, stdout io.Writer) error {
+	flags := newFlagSet("backup", client)
+	blobs := flags.Bool("blobs", false, "include attachment contents")
+	output := flags.String("o", "-", "file to write, - for standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errUsage
+	}
+
+	response, err := client.do(http.MethodGet, "/api/v2/admin/backup?"+url.Values{"blobs": {strconv.FormatBool(*blobs)}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if *output == "-" {
+		_, err = io.Copy(stdout, response.Body)
+		return err
+	}
+	// A dump is only kept once it is complete, so a failed backup does not
+	// overwrite a good one.
+	file, err := os.CreateTemp(filepath.Dir(*output), ".backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err = io.Copy(file, response.Body); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	if err = checkBackup(file.Name()); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), *output)
+}
+
+// checkBackup reads a dump through, which fails unless it is complete.
+func checkBackup(name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := backup.NewReader(file)
+	if err != nil {
+		return err
+	}
+	for {
+		if _, err = reader.Next(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func runRestore(client *client, args []string, stdout io.Writer) error {
+	flags := newFlagSet("restore", client)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	var in io.Reader = os.Stdin
+	if name := flags.Arg(0); name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	response, err := client.do(http.MethodPost, "/api/v2/admin/restore", in)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var result backup.Result
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("reading restore result: %w", err)
+	}
+	kinds := make([]string, 0, len(result.Restored))
+	for kind := range result.Restored {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(stdout, "%s: %d\n", kind, result.Restored[kind])
+	}
+	fmt.Fprintf(stdout, "Restored backup of a %s database\n", result.Source)
+	return nil
+}
+
+// formatOf guesses the format of a file from its name.
+func formatOf(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".md"), strings.HasSuffix(lower, ".markdown"):
+		return "markdown"
+	case strings.HasSuffix(lower, ".txt"):
+		return "todotxt"
+	}
+	return "csv"
+}
+
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// do sends a request and turns answers other than 2xx into errors, except
+// for an import's per-row report.
+func (c *client) do(method, path string, body io.Reader) (*http.Response, error) {
+	request, err := http.NewRequest(method, strings.TrimSuffix(c.server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	response, err := c.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode/100 == 2 || strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+		return response, nil
+	}
+	defer response.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	var details problem.Error
+	if response.Header.Get("Content-Type") == problem.ContentType && json.Unmarshal(message, &details) == nil {
+		message = []byte(details.Detail)
+	}
+	return nil, fmt.Errorf("%s %s: %s: %s", method, path, response.Status, strings.TrimSpace(string(message)))
+}
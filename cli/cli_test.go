@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"Simple_Task_Manager/backup"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	list := filepath.Join(dir, "todo.txt")
+	if err := os.WriteFile(list, []byte("Water plants due:tomorrow\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		contentType string
+		status      int
+		response    string
+		request     string
+		code        int
+		stdout      string
+		stderr      string
+	}{
+		{
+			name:        "import",
+			args:        []string{"import", "-user", "ada", "-mode", "partial", list},
+			contentType: "application/json",
+			status:      http.StatusOK,
+			response:    `{"mode":"partial","imported":1,"failed":0,"rows":[{"line":1,"status":201,"task_id":1}]}`,
+			request:     "POST /api/v2/tasks/import?format=todotxt&mode=partial&user_name=ada Water plants due:tomorrow\n",
+			stdout:      "Imported 1 tasks, 0 failed\n",
+		},
+		{
+			name:        "import with failures",
+			args:        []string{"import", "-format", "csv", list},
+			contentType: "application/json",
+			status:      http.StatusBadRequest,
+			response:    `{"mode":"atomic","imported":0,"failed":1,"rows":[{"line":2,"status":400,"error":"Missing user name"}]}`,
+			request:     "POST /api/v2/tasks/import?format=csv Water plants due:tomorrow\n",
+			code:        1,
+			stdout:      "line 2: Missing user name\nImported 0 tasks, 1 failed\n",
+			stderr:      "Error: some tasks were not imported\n",
+		},
+		{
+			name:        "export",
+			args:        []string{"export", "-format", "markdown", "-user-id", "1"},
+			contentType: "text/markdown",
+			status:      http.StatusOK,
+			response:    "## ada\n",
+			request:     "GET /api/v2/tasks/export?format=markdown&user_id=1 ",
+			stdout:      "## ada\n",
+		},
+		{
+			name:        "problem",
+			args:        []string{"export"},
+			contentType: "application/problem+json",
+			status:      http.StatusForbidden,
+			response:    `{"type":"about:blank","title":"Forbidden","status":403,"detail":"Admin token required","code":"forbidden"}`,
+			request:     "GET /api/v2/tasks/export?format=csv ",
+			code:        1,
+			stderr:      "Error: GET /api/v2/tasks/export?format=csv: 403 Forbidden: Admin token required\n",
+		},
+		{
+			name:   "missing file argument",
+			args:   []string{"import"},
+			code:   2,
+			stderr: "Usage: Simple_Task_Manager " + commands["import"].usage + "\n",
+		},
+		{
+			name:   "unknown flag",
+			args:   []string{"backup", "-compress"},
+			code:   1,
+			stderr: "Error: flag provided but not defined: -compress\n",
+		},
+		{
+			name: "unknown command",
+			args: []string{"serve"},
+			code: 2,
+		},
+	}
+	for _, test := range tests {
+		var request string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			request = r.Method + " " + r.URL.RequestURI() + " " + string(body)
+			w.Header().Set("Content-Type", test.contentType)
+			w.WriteHeader(test.status)
+			io.WriteString(w, test.response)
+		}))
+		t.Setenv("TASK_SERVER", server.URL)
+
+		var stdout, stderr bytes.Buffer
+		code := Run(test.args, &stdout, &stderr)
+		server.Close()
+		if code != test.code || request != test.request || stdout.String() != test.stdout {
+			t.Errorf("%s: exit code %d, request %q, output %q, want %d, %q, %q", test.name, code, request, stdout.String(), test.code, test.request, test.stdout)
+		}
+		if test.stderr != "" && stderr.String() != test.stderr {
+			t.Errorf("%s: errors %q, want %q", test.name, stderr.String(), test.stderr)
+		}
+	}
+}
+
+func TestBackupKeepsOnlyCompleteDumps(t *testing.T) {
+	var dump bytes.Buffer
+	writer, err := backup.NewWriter(&dump, "sqlite", time.Now())
+	if err == nil {
+		err = writer.Write(backup.KindUser, backup.User{ID: "1", Name: "ada"})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated := dump.String()
+	if err = writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		code     int
+		written  string
+	}{
+		{name: "complete dump", response: dump.String(), written: dump.String()},
+		{name: "truncated dump", response: truncated, code: 1, written: "previous backup"},
+		{name: "not a dump", response: "<html>", code: 1, written: "previous backup"},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, test.response)
+		}))
+		t.Setenv("TASK_SERVER", server.URL)
+
+		output := filepath.Join(t.TempDir(), "backup.jsonl.gz")
+		if err := os.WriteFile(output, []byte("previous backup"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		code := Run([]string{"backup", "-o", output}, &stdout, &stderr)
+		server.Close()
+
+		content, _ := os.ReadFile(output)
+		if code != test.code || string(content) != test.written {
+			t.Errorf("%s: exit code %d, backup %q, errors %q", test.name, code, content, stderr.String())
+		}
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	tests := []struct {
+		token         string
+		authorization string
+	}{
+		{token: "s3cret", authorization: "Bearer s3cret"},
+		{token: "", authorization: ""},
+	}
+	for _, test := range tests {
+		var authorization string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authorization = r.Header.Get("Authorization")
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		t.Setenv("TASK_SERVER", server.URL)
+		t.Setenv("TASK_ADMIN_TOKEN", test.token)
+
+		var stdout, stderr bytes.Buffer
+		Run([]string{"restore", os.DevNull}, &stdout, &stderr)
+		server.Close()
+		if authorization != test.authorization {
+			t.Errorf("TASK_ADMIN_TOKEN %q: Authorization %q, want %q", test.token, authorization, test.authorization)
+		}
+	}
+}
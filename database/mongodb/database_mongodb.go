@@ -1,10 +1,17 @@
 package databaseMongoDB
 
 import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/workflow"
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -14,9 +21,13 @@ type DBManager interface {
 	InitializeDatabase() error
 }
 
+// MongoDB creates the indexes of the MongoDB database and migrates its
+// documents. Workflow, or the default workflow if it is nil, gives the
+// statuses of tasks that predate statuses.
 type MongoDB struct {
 	ConnectionString string
 	DatabaseName     string
+	Workflow         *workflow.Workflow
 }
 
 func NewMongoDB(connectionString, databaseName string) *MongoDB {
@@ -40,7 +51,222 @@ func (db *MongoDB) OpenDatabase() (*mongo.Database, error) {
 	return database, nil
 }
 
+func (db *MongoDB) workflow() *workflow.Workflow {
+	if db.Workflow != nil {
+		return db.Workflow
+	}
+	return workflow.Default()
+}
+
 func (db *MongoDB) InitializeDatabase() error {
+	database, err := db.OpenDatabase()
+	if err != nil {
+		log.Fatalf("Error opening database connection: %v", err)
+		return err
+	}
+	defer database.Client().Disconnect(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err = convertDueDates(ctx, database.Collection("tasks")); err != nil {
+		log.Fatalf("Error converting due dates: %v", err)
+		return err
+	}
+
+	wf := db.workflow()
+	_, err = database.Collection("tasks").UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		bson.A{bson.M{"$set": bson.M{"status": bson.M{"$cond": bson.A{"$completed", string(wf.CompletedStatus()), string(wf.Initial)}}}}},
+	)
+	if err != nil {
+		log.Fatalf("Error deriving task status: %v", err)
+		return err
+	}
+
+	if err = assignRanks(ctx, database.Collection("tasks")); err != nil {
+		log.Fatalf("Error assigning board ranks: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("tasks").UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 1}},
+	)
+	if err != nil {
+		log.Fatalf("Error assigning task versions: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("tasks").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "due_date", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "rank", Value: 1}}},
+	})
+	if err != nil {
+		log.Fatalf("Error creating task indexes: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("users").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "feed_token_hash", Value: 1}},
+		Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"feed_token_hash": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		log.Fatalf("Error creating user indexes: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("comments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "task_id", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		log.Fatalf("Error creating comment indexes: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("attachments").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "task_id", Value: 1}},
+	})
+	if err != nil {
+		log.Fatalf("Error creating attachment indexes: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("time_entries").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "task_id", Value: 1}, {Key: "started_at", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "started_at", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"running": true}),
+		},
+	})
+	if err != nil {
+		log.Fatalf("Error creating time entry indexes: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("task_history").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "task_id", Value: 1}, {Key: "version", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Fatalf("Error creating task history indexes: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("idempotency_keys").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Fatalf("Error creating idempotency key indexes: %v", err)
+		return err
+	}
+
+	_, err = database.Collection("task_templates").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "name", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Fatalf("Error creating task template indexes: %v", err)
+		return err
+	}
+
 	log.Println("MongoDB initialized successfully")
 	return nil
 }
+
+// convertDueDates turns due dates stored as strings by earlier versions into
+// BSON dates, so range queries and sorting compare instants. If some due dates
+// cannot be parsed, it converts nothing and returns an error naming them, so
+// they can be fixed by hand rather than lost.
+func convertDueDates(ctx context.Context, tasks *mongo.Collection) error {
+	cursor, err := tasks.Find(ctx, bson.M{"due_date": bson.M{"$type": "string"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	type converted struct {
+		id      interface{}
+		dueDate time.Time
+	}
+	var updates []converted
+	var unparseable []string
+	for cursor.Next(ctx) {
+		var task struct {
+			ID      interface{} `bson:"_id"`
+			DueDate string      `bson:"due_date"`
+		}
+		if err = cursor.Decode(&task); err != nil {
+			return err
+		}
+		parsed, err := dates.Parse(task.DueDate, time.UTC)
+		if err != nil {
+			unparseable = append(unparseable, fmt.Sprintf("task %v: %q", task.ID, task.DueDate))
+			continue
+		}
+		updates = append(updates, converted{id: task.ID, dueDate: parsed})
+	}
+	if err = cursor.Err(); err != nil {
+		return err
+	}
+	if len(unparseable) > 0 {
+		return fmt.Errorf("unparseable due dates, fix them and restart: %s", strings.Join(unparseable, ", "))
+	}
+
+	for _, update := range updates {
+		_, err = tasks.UpdateOne(ctx, bson.M{"_id": update.id}, bson.M{"$set": bson.M{"due_date": update.dueDate}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignRanks appends tasks without a board rank to the end of their column,
+// keeping their previous relative order by ID.
+func assignRanks(ctx context.Context, tasks *mongo.Collection) error {
+	findOptions := options.Find().SetSort(bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := tasks.Find(ctx, bson.M{"rank": bson.M{"$exists": false}}, findOptions)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	last := make(map[string]string)
+	for cursor.Next(ctx) {
+		var task struct {
+			ID     interface{} `bson:"_id"`
+			Status string      `bson:"status"`
+		}
+		if err = cursor.Decode(&task); err != nil {
+			return err
+		}
+
+		previous, ok := last[task.Status]
+		if !ok {
+			var ranked struct {
+				Rank string `bson:"rank"`
+			}
+			err = tasks.FindOne(ctx,
+				bson.M{"status": task.Status, "rank": bson.M{"$exists": true}},
+				options.FindOne().SetSort(bson.D{{Key: "rank", Value: -1}}),
+			).Decode(&ranked)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+			previous = ranked.Rank
+		}
+
+		next, err := rank.After(previous)
+		if err != nil {
+			return err
+		}
+		if _, err = tasks.UpdateOne(ctx, bson.M{"_id": task.ID}, bson.M{"$set": bson.M{"rank": next}}); err != nil {
+			return err
+		}
+		last[task.Status] = next
+	}
+	return cursor.Err()
+}
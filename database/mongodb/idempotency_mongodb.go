@@ -0,0 +1,70 @@
+package databaseMongoDB
+
+import (
+	"Simple_Task_Manager/idempotency"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// IdempotencyKeys keeps idempotency keys in a collection whose TTL index on
+// expires_at deletes expired keys. The TTL monitor runs about once a minute,
+// so keys that have expired but are still there are replaced when claimed.
+type IdempotencyKeys struct {
+	Collection *mongo.Collection
+}
+
+type idempotencyKey struct {
+	Key         string      `bson:"_id"`
+	Fingerprint string      `bson:"fingerprint"`
+	Status      int         `bson:"status,omitempty"`
+	Header      http.Header `bson:"header,omitempty"`
+	Body        []byte      `bson:"body,omitempty"`
+	ExpiresAt   time.Time   `bson:"expires_at"`
+}
+
+func (s *IdempotencyKeys) Begin(key, fingerprint string, now, expires time.Time) (*idempotency.Record, error) {
+	ctx := context.Background()
+	if _, err := s.Collection.DeleteOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$lte": now}}); err != nil {
+		return nil, err
+	}
+	_, err := s.Collection.InsertOne(ctx, idempotencyKey{Key: key, Fingerprint: fingerprint, ExpiresAt: expires})
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	var existing idempotencyKey
+	if err = s.Collection.FindOne(ctx, bson.M{"_id": key}).Decode(&existing); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// Released since the insert failed; the client may retry.
+			return &idempotency.Record{Fingerprint: fingerprint}, nil
+		}
+		return nil, err
+	}
+	record := &idempotency.Record{Fingerprint: existing.Fingerprint}
+	if existing.Status != 0 {
+		record.Response = &idempotency.Response{Status: existing.Status, Header: existing.Header, Body: existing.Body}
+	}
+	return record, nil
+}
+
+func (s *IdempotencyKeys) Complete(key string, response idempotency.Response) error {
+	_, err := s.Collection.UpdateOne(context.Background(), bson.M{"_id": key}, bson.M{"$set": bson.M{
+		"status": response.Status,
+		"header": response.Header,
+		"body":   response.Body,
+	}})
+	return err
+}
+
+func (s *IdempotencyKeys) Release(key string) error {
+	_, err := s.Collection.DeleteOne(context.Background(), bson.M{"_id": key})
+	return err
+}
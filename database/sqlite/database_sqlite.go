@@ -1,9 +1,15 @@
 package databaseSqlite
 
 import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/workflow"
 	"database/sql"
+	"fmt"
 	_ "github.com/mattn/go-sqlite3"
 	"log"
+	"strings"
+	"time"
 )
 
 type DBManager interface {
@@ -11,8 +17,11 @@ type DBManager interface {
 	InitializeDatabase() error
 }
 
+// SQLiteDB creates and migrates the SQLite database. Workflow, or the default
+// workflow if it is nil, gives the statuses of tasks that predate statuses.
 type SQLiteDB struct {
 	DatabasePath string
+	Workflow     *workflow.Workflow
 }
 
 func NewSQLiteDB(databasePath string) *SQLiteDB {
@@ -62,6 +71,344 @@ func (db *SQLiteDB) InitializeDatabase() error {
 		return err
 	}
 
+	added, err := addColumns(database, "tasks", []column{
+		{Name: "rrule", Definition: "TEXT NOT NULL DEFAULT ''"},
+		{Name: "time_zone", Definition: "TEXT NOT NULL DEFAULT 'UTC'"},
+		{Name: "status", Definition: "TEXT NOT NULL DEFAULT 'todo'"},
+		{Name: "status_changed_by", Definition: "INTEGER REFERENCES users(user_id)"},
+		{Name: "status_changed_at", Definition: "DATETIME"},
+		{Name: "rank", Definition: "TEXT NOT NULL DEFAULT ''"},
+		{Name: "description", Definition: "TEXT NOT NULL DEFAULT ''"},
+		{Name: "tags", Definition: "TEXT NOT NULL DEFAULT '[]'"},
+		{Name: "checklist", Definition: "TEXT NOT NULL DEFAULT '[]'"},
+		{Name: "parent_task_id", Definition: "INTEGER REFERENCES tasks(task_id)"},
+		{Name: "version", Definition: "INTEGER NOT NULL DEFAULT 1"},
+	})
+	if err != nil {
+		log.Fatalf("Error migrating 'tasks' table: %v", err)
+		return err
+	}
+
+	if _, err = addColumns(database, "users", []column{
+		{Name: "time_zone", Definition: "TEXT NOT NULL DEFAULT 'UTC'"},
+		{Name: "feed_token_hash", Definition: "TEXT"},
+	}); err != nil {
+		log.Fatalf("Error migrating 'users' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec("CREATE UNIQUE INDEX IF NOT EXISTS users_feed_token_hash ON users(feed_token_hash)")
+	if err != nil {
+		log.Fatalf("Error creating 'users' indexes: %v", err)
+		return err
+	}
+
+	if err = normalizeDueDates(database); err != nil {
+		log.Fatalf("Error normalizing due dates: %v", err)
+		return err
+	}
+
+	if added["status"] {
+		wf := db.workflow()
+		_, err = database.Exec("UPDATE tasks SET status=CASE WHEN completed THEN ? ELSE ? END", wf.CompletedStatus(), wf.Initial)
+		if err != nil {
+			log.Fatalf("Error deriving task status: %v", err)
+			return err
+		}
+	}
+
+	if err = assignRanks(database); err != nil {
+		log.Fatalf("Error assigning board ranks: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS task_status_changes (
+            change_id INTEGER PRIMARY KEY,
+            task_id INTEGER NOT NULL,
+            user_id INTEGER,
+            from_status TEXT NOT NULL,
+            to_status TEXT NOT NULL,
+            changed_at DATETIME NOT NULL,
+            FOREIGN KEY (task_id) REFERENCES tasks(task_id),
+            FOREIGN KEY (user_id) REFERENCES users(user_id)
+        );
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'task_status_changes' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS comments (
+            comment_id INTEGER PRIMARY KEY,
+            task_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            content TEXT NOT NULL,
+            created_at DATETIME NOT NULL,
+            updated_at DATETIME,
+            FOREIGN KEY (task_id) REFERENCES tasks(task_id),
+            FOREIGN KEY (user_id) REFERENCES users(user_id)
+        );
+        CREATE INDEX IF NOT EXISTS comments_task_id ON comments(task_id, created_at);
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'comments' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS comment_mentions (
+            comment_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            PRIMARY KEY (comment_id, user_id),
+            FOREIGN KEY (comment_id) REFERENCES comments(comment_id),
+            FOREIGN KEY (user_id) REFERENCES users(user_id)
+        );
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'comment_mentions' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS attachments (
+            attachment_id INTEGER PRIMARY KEY,
+            task_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            file_name TEXT NOT NULL,
+            content_type TEXT NOT NULL,
+            size INTEGER NOT NULL,
+            blob_key TEXT NOT NULL UNIQUE,
+            created_at DATETIME NOT NULL,
+            FOREIGN KEY (task_id) REFERENCES tasks(task_id),
+            FOREIGN KEY (user_id) REFERENCES users(user_id)
+        );
+        CREATE INDEX IF NOT EXISTS attachments_task_id ON attachments(task_id);
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'attachments' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS time_entries (
+            entry_id INTEGER PRIMARY KEY,
+            task_id INTEGER NOT NULL,
+            user_id INTEGER NOT NULL,
+            started_at DATETIME NOT NULL,
+            ended_at DATETIME,
+            note TEXT NOT NULL DEFAULT '',
+            FOREIGN KEY (task_id) REFERENCES tasks(task_id),
+            FOREIGN KEY (user_id) REFERENCES users(user_id)
+        );
+        CREATE INDEX IF NOT EXISTS time_entries_task_id ON time_entries(task_id, started_at);
+        CREATE INDEX IF NOT EXISTS time_entries_user_id ON time_entries(user_id, started_at);
+        CREATE UNIQUE INDEX IF NOT EXISTS time_entries_running ON time_entries(user_id) WHERE ended_at IS NULL;
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'time_entries' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS task_history (
+            history_id INTEGER PRIMARY KEY,
+            task_id INTEGER NOT NULL,
+            version INTEGER NOT NULL,
+            action TEXT NOT NULL,
+            actor_id INTEGER,
+            changed_at DATETIME NOT NULL,
+            changes TEXT NOT NULL,
+            snapshot TEXT NOT NULL,
+            UNIQUE (task_id, version),
+            FOREIGN KEY (task_id) REFERENCES tasks(task_id),
+            FOREIGN KEY (actor_id) REFERENCES users(user_id)
+        );
+        CREATE TRIGGER IF NOT EXISTS task_history_no_update BEFORE UPDATE ON task_history
+        BEGIN
+            SELECT RAISE(ABORT, 'task_history is append-only');
+        END;
+        CREATE TRIGGER IF NOT EXISTS task_history_no_delete BEFORE DELETE ON task_history
+        BEGIN
+            SELECT RAISE(ABORT, 'task_history is append-only');
+        END;
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'task_history' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS task_templates (
+            template_id INTEGER PRIMARY KEY,
+            name TEXT NOT NULL UNIQUE,
+            definition TEXT NOT NULL,
+            created_by INTEGER NOT NULL,
+            created_at DATETIME NOT NULL,
+            updated_at DATETIME,
+            FOREIGN KEY (created_by) REFERENCES users(user_id)
+        );
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'task_templates' table: %v", err)
+		return err
+	}
+
+	_, err = database.Exec(`
+        CREATE TABLE IF NOT EXISTS idempotency_keys (
+            idempotency_key TEXT PRIMARY KEY,
+            fingerprint TEXT NOT NULL,
+            status INTEGER,
+            header TEXT,
+            body BLOB,
+            expires_at DATETIME NOT NULL
+        );
+        CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at ON idempotency_keys(expires_at);
+    `)
+	if err != nil {
+		log.Fatalf("Error creating 'idempotency_keys' table: %v", err)
+		return err
+	}
+
 	log.Println("Database created successfully")
 	return nil
 }
+
+func (db *SQLiteDB) workflow() *workflow.Workflow {
+	if db.Workflow != nil {
+		return db.Workflow
+	}
+	return workflow.Default()
+}
+
+type column struct {
+	Name       string
+	Definition string
+}
+
+// addColumns adds the columns table lacks and returns the names of those it
+// added.
+func addColumns(database *sql.DB, table string, columns []column) (map[string]bool, error) {
+	rows, err := database.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		existing[name] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	added := make(map[string]bool)
+	for _, c := range columns {
+		if existing[c.Name] {
+			continue
+		}
+		if _, err = database.Exec("ALTER TABLE " + table + " ADD COLUMN " + c.Name + " " + c.Definition); err != nil {
+			return nil, err
+		}
+		added[c.Name] = true
+	}
+	return added, nil
+}
+
+// normalizeDueDates rewrites every stored due date into dates.StorageLayout so
+// that comparisons and ORDER BY on the column follow time order. If some due
+// dates cannot be parsed, it changes nothing and returns an error naming them,
+// so they can be fixed by hand rather than lost.
+func normalizeDueDates(database *sql.DB) error {
+	rows, err := database.Query("SELECT task_id, CAST(due_date AS TEXT) FROM tasks WHERE due_date IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	updates := make(map[int]string)
+	var unparseable []string
+	for rows.Next() {
+		var taskID int
+		var value string
+		if err = rows.Scan(&taskID, &value); err != nil {
+			return err
+		}
+		parsed, err := dates.Parse(value, time.UTC)
+		if err != nil {
+			unparseable = append(unparseable, fmt.Sprintf("task %d: %q", taskID, value))
+			continue
+		}
+		if normalized := dates.Format(parsed); normalized != value {
+			updates[taskID] = normalized
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if len(unparseable) > 0 {
+		return fmt.Errorf("unparseable due dates, fix them and restart: %s", strings.Join(unparseable, ", "))
+	}
+
+	for taskID, normalized := range updates {
+		if _, err = database.Exec("UPDATE tasks SET due_date=? WHERE task_id=?", normalized, taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignRanks appends tasks without a board rank to the end of their column,
+// keeping their previous relative order by task ID.
+func assignRanks(database *sql.DB) error {
+	rows, err := database.Query("SELECT task_id, status FROM tasks WHERE rank='' ORDER BY status, task_id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type unranked struct {
+		taskID int
+		status string
+	}
+	var tasks []unranked
+	for rows.Next() {
+		var task unranked
+		if err = rows.Scan(&task.taskID, &task.status); err != nil {
+			return err
+		}
+		tasks = append(tasks, task)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	last := make(map[string]string)
+	for _, task := range tasks {
+		previous, ok := last[task.status]
+		if !ok {
+			err = database.QueryRow("SELECT COALESCE(MAX(rank), '') FROM tasks WHERE status=?", task.status).Scan(&previous)
+			if err != nil {
+				return err
+			}
+		}
+		next, err := rank.After(previous)
+		if err != nil {
+			return err
+		}
+		if _, err = database.Exec("UPDATE tasks SET rank=? WHERE task_id=?", next, task.taskID); err != nil {
+			return err
+		}
+		last[task.status] = next
+	}
+	return nil
+}
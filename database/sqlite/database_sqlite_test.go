@@ -0,0 +1,126 @@
+package databaseSqlite
+
+import (
+	"Simple_Task_Manager/workflow"
+	"path/filepath"
+	"testing"
+)
+
+// legacyDatabase returns the path of a database created before tasks had a
+// status, holding an open and a completed task.
+func legacyDatabase(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	database, err := NewSQLiteDB(path).OpenDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	for _, statement := range []string{
+		"CREATE TABLE users (user_id INTEGER PRIMARY KEY, user_name TEXT NOT NULL)",
+		"CREATE TABLE tasks (task_id INTEGER PRIMARY KEY, user_id INTEGER, task_name TEXT NOT NULL, due_date DATE, completed BOOLEAN)",
+		"INSERT INTO users(user_id, user_name) VALUES(1, 'ada')",
+		"INSERT INTO tasks(task_id, user_id, task_name, due_date, completed) VALUES(1, 1, 'open', '2024-03-25', 0)",
+		"INSERT INTO tasks(task_id, user_id, task_name, due_date, completed) VALUES(2, 1, 'closed', '2024-03-26', 1)",
+	} {
+		if _, err = database.Exec(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestInitializeDatabaseDerivesStatus(t *testing.T) {
+	custom := &workflow.Workflow{
+		Initial:     "backlog",
+		Completed:   []workflow.Status{"shipped", "dropped"},
+		Transitions: map[workflow.Status][]workflow.Status{"backlog": {"shipped", "dropped"}, "shipped": {"backlog"}, "dropped": {"backlog"}},
+	}
+	tests := []struct {
+		name         string
+		workflow     *workflow.Workflow
+		open, closed string
+	}{
+		{name: "default workflow", open: "todo", closed: "done"},
+		{name: "custom workflow", workflow: custom, open: "backlog", closed: "shipped"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &SQLiteDB{DatabasePath: legacyDatabase(t), Workflow: test.workflow}
+			if err := db.InitializeDatabase(); err != nil {
+				t.Fatal(err)
+			}
+			// A second start must leave statuses alone.
+			if err := db.InitializeDatabase(); err != nil {
+				t.Fatal(err)
+			}
+			database, err := db.OpenDatabase()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+
+			for taskID, want := range map[int]string{1: test.open, 2: test.closed} {
+				var status string
+				if err = database.QueryRow("SELECT status FROM tasks WHERE task_id=?", taskID).Scan(&status); err != nil {
+					t.Fatal(err)
+				}
+				if status != want {
+					t.Errorf("task %d has status %q, want %q", taskID, status, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeDueDates(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored []string
+		want   []string
+		ok     bool
+	}{
+		{
+			name:   "normalizes",
+			stored: []string{"2024-03-25", "2024-03-26T15:04:05Z"},
+			want:   []string{"2024-03-25T00:00:00Z", "2024-03-26T15:04:05Z"},
+			ok:     true,
+		},
+		{
+			name:   "keeps everything if one is unparseable",
+			stored: []string{"2024-03-25", "next blue moon"},
+			want:   []string{"2024-03-25", "next blue moon"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			database, err := NewSQLiteDB(filepath.Join(t.TempDir(), "dates.db")).OpenDatabase()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			if _, err = database.Exec("CREATE TABLE tasks (task_id INTEGER PRIMARY KEY, due_date TEXT)"); err != nil {
+				t.Fatal(err)
+			}
+			for i, value := range test.stored {
+				if _, err = database.Exec("INSERT INTO tasks(task_id, due_date) VALUES(?, ?)", i+1, value); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err = normalizeDueDates(database)
+			if (err == nil) != test.ok {
+				t.Fatalf("normalizeDueDates error = %v, want ok %v", err, test.ok)
+			}
+			for i, want := range test.want {
+				var got string
+				if err = database.QueryRow("SELECT due_date FROM tasks WHERE task_id=?", i+1).Scan(&got); err != nil {
+					t.Fatal(err)
+				}
+				if got != want {
+					t.Errorf("task %d has due date %q, want %q", i+1, got, want)
+				}
+			}
+		})
+	}
+}
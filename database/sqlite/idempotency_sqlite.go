@@ -0,0 +1,70 @@
+package databaseSqlite
+
+import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/idempotency"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyKeys keeps idempotency keys in the idempotency_keys table.
+// Expired keys are deleted whenever a new one is claimed.
+type IdempotencyKeys struct {
+	DB *sql.DB
+}
+
+func (s *IdempotencyKeys) Begin(key, fingerprint string, now, expires time.Time) (*idempotency.Record, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM idempotency_keys WHERE expires_at <= ?", dates.Format(now)); err != nil {
+		return nil, err
+	}
+	result, err := tx.Exec("INSERT OR IGNORE INTO idempotency_keys(idempotency_key, fingerprint, expires_at) VALUES(?, ?, ?)",
+		key, fingerprint, dates.Format(expires))
+	if err != nil {
+		return nil, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if claimed == 1 {
+		return nil, tx.Commit()
+	}
+
+	var record idempotency.Record
+	var status sql.NullInt64
+	var header sql.NullString
+	var body []byte
+	err = tx.QueryRow("SELECT fingerprint, status, header, body FROM idempotency_keys WHERE idempotency_key=?", key).
+		Scan(&record.Fingerprint, &status, &header, &body)
+	if err != nil {
+		return nil, err
+	}
+	if status.Valid {
+		record.Response = &idempotency.Response{Status: int(status.Int64), Body: body}
+		if err = json.Unmarshal([]byte(header.String), &record.Response.Header); err != nil {
+			return nil, err
+		}
+	}
+	return &record, tx.Commit()
+}
+
+func (s *IdempotencyKeys) Complete(key string, response idempotency.Response) error {
+	header, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec("UPDATE idempotency_keys SET status=?, header=?, body=? WHERE idempotency_key=?", response.Status, string(header), response.Body, key)
+	return err
+}
+
+func (s *IdempotencyKeys) Release(key string) error {
+	_, err := s.DB.Exec("DELETE FROM idempotency_keys WHERE idempotency_key=?", key)
+	return err
+}
@@ -0,0 +1,206 @@
+package databaseSqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	snapshotPrefix = "sqlite-"
+	snapshotSuffix = ".db"
+	snapshotLayout = "20060102-150405.000"
+
+	defaultKeepDaily  = 7
+	defaultKeepWeekly = 4
+)
+
+var ErrSnapshotCorrupt = errors.New("snapshot failed the integrity check")
+
+// Snapshots takes hot backups of a live database with VACUUM INTO, which
+// copies a consistent state while the server keeps writing, unlike copying
+// the file. Every copy is integrity checked before it is kept, and old ones
+// are rotated out: the newest snapshot of each of the last KeepDaily days and
+// of each of the last KeepWeekly weeks is kept.
+type Snapshots struct {
+	DB         *sql.DB
+	Dir        string
+	KeepDaily  int
+	KeepWeekly int
+	Clock      func() time.Time
+
+	mu sync.Mutex
+}
+
+type Snapshot struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *Snapshots) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock()
+	}
+	return time.Now()
+}
+
+// Take writes a new snapshot and rotates the old ones.
+func (s *Snapshots) Take() (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return Snapshot{}, err
+	}
+	createdAt := s.now().UTC()
+	name := snapshotPrefix + createdAt.Format(snapshotLayout) + snapshotSuffix
+	path := filepath.Join(s.Dir, name)
+
+	// The copy is written under a temporary name, so an interrupted or corrupt
+	// one is never taken for a snapshot.
+	temporary := path + ".tmp"
+	_ = os.Remove(temporary)
+	if _, err := s.DB.Exec("VACUUM INTO ?", temporary); err != nil {
+		_ = os.Remove(temporary)
+		return Snapshot{}, fmt.Errorf("copying database: %w", err)
+	}
+	if err := checkIntegrity(temporary); err != nil {
+		_ = os.Remove(temporary)
+		return Snapshot{}, err
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		_ = os.Remove(temporary)
+		return Snapshot{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err = s.rotate(); err != nil {
+		log.Printf("Error rotating snapshots: %v", err)
+	}
+	return Snapshot{Name: name, Size: info.Size(), CreatedAt: createdAt}, nil
+}
+
+func checkIntegrity(path string) error {
+	database, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	rows, err := database.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err = rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrSnapshotCorrupt, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// List returns the snapshots, newest first.
+func (s *Snapshots) List() ([]Snapshot, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []Snapshot{}
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, snapshotPrefix)
+		if !ok || entry.IsDir() || !strings.HasSuffix(stamp, snapshotSuffix) {
+			continue
+		}
+		createdAt, err := time.Parse(snapshotLayout, strings.TrimSuffix(stamp, snapshotSuffix))
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, Snapshot{Name: name, Size: info.Size(), CreatedAt: createdAt})
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt) })
+	return snapshots, nil
+}
+
+func (s *Snapshots) rotate() error {
+	snapshots, err := s.List()
+	if err != nil {
+		return err
+	}
+	keepDaily, keepWeekly := s.KeepDaily, s.KeepWeekly
+	if keepDaily <= 0 {
+		keepDaily = defaultKeepDaily
+	}
+	if keepWeekly <= 0 {
+		keepWeekly = defaultKeepWeekly
+	}
+
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		day := snapshot.CreatedAt.Format("2006-01-02")
+		year, number := snapshot.CreatedAt.ISOWeek()
+		week := fmt.Sprintf("%d-%02d", year, number)
+
+		keep := false
+		if !days[day] && len(days) < keepDaily {
+			days[day], keep = true, true
+		}
+		if !weeks[week] && len(weeks) < keepWeekly {
+			weeks[week], keep = true, true
+		}
+		if keep {
+			continue
+		}
+		if err = os.Remove(filepath.Join(s.Dir, snapshot.Name)); err != nil {
+			return err
+		}
+		log.Printf("Removed snapshot %s", snapshot.Name)
+	}
+	return nil
+}
+
+// Schedule takes a snapshot every interval until the process ends.
+func (s *Snapshots) Schedule(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		snapshot, err := s.Take()
+		if err != nil {
+			log.Printf("Error taking snapshot: %v", err)
+			continue
+		}
+		log.Printf("Snapshot %s taken successfully", snapshot.Name)
+	}
+}
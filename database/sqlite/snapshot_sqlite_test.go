@@ -0,0 +1,104 @@
+package databaseSqlite
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotsRotate(t *testing.T) {
+	day := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	var tenDays []time.Time
+	for i := 1; i <= 10; i++ {
+		tenDays = append(tenDays, day(time.March, i, 12))
+	}
+	tests := []struct {
+		name                  string
+		taken                 []time.Time
+		keepDaily, keepWeekly int
+		want                  []time.Time
+	}{
+		{
+			name:  "same day",
+			taken: []time.Time{day(time.March, 1, 9), day(time.March, 1, 17)},
+			want:  []time.Time{day(time.March, 1, 17)},
+		},
+		{
+			name:      "daily and weekly",
+			taken:     tenDays,
+			keepDaily: 3, keepWeekly: 2,
+			// March 4 to 10 is one ISO week, March 3 ends the one before.
+			want: []time.Time{day(time.March, 10, 12), day(time.March, 9, 12), day(time.March, 8, 12), day(time.March, 3, 12)},
+		},
+		{
+			name:  "defaults",
+			taken: tenDays,
+			want:  []time.Time{day(time.March, 10, 12), day(time.March, 9, 12), day(time.March, 8, 12), day(time.March, 7, 12), day(time.March, 6, 12), day(time.March, 5, 12), day(time.March, 4, 12), day(time.March, 3, 12)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dbManager := NewSQLiteDB(filepath.Join(t.TempDir(), "tasks.db"))
+			if err := dbManager.InitializeDatabase(); err != nil {
+				t.Fatal(err)
+			}
+			db, err := dbManager.OpenDatabase()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			var now time.Time
+			snapshots := &Snapshots{DB: db, Dir: t.TempDir(), KeepDaily: test.keepDaily, KeepWeekly: test.keepWeekly, Clock: func() time.Time { return now }}
+			if err = os.WriteFile(filepath.Join(snapshots.Dir, "notes.txt"), []byte("not a snapshot"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			for _, now = range test.taken {
+				snapshot, err := snapshots.Take()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !snapshot.CreatedAt.Equal(now) || snapshot.Size == 0 {
+					t.Errorf("Take = %+v", snapshot)
+				}
+			}
+
+			kept, err := snapshots.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var times []time.Time
+			for _, snapshot := range kept {
+				times = append(times, snapshot.CreatedAt)
+			}
+			if !reflect.DeepEqual(times, test.want) {
+				t.Errorf("kept %v, want %v", times, test.want)
+			}
+			if err = checkIntegrity(filepath.Join(snapshots.Dir, kept[0].Name)); err != nil {
+				t.Errorf("latest snapshot: %v", err)
+			}
+		})
+	}
+}
+
+func TestSnapshotsListWithoutDirectory(t *testing.T) {
+	snapshots := &Snapshots{Dir: filepath.Join(t.TempDir(), "missing")}
+	list, err := snapshots.List()
+	if err != nil || len(list) != 0 {
+		t.Errorf("List = %v, %v, want no snapshots", list, err)
+	}
+}
+
+func TestCheckIntegrityRejectsCorruptFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "corrupt.db")
+	if err := os.WriteFile(path, []byte("SQLite format 3\x00 but not really"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkIntegrity(path); err == nil {
+		t.Error("checkIntegrity accepted a corrupt file")
+	}
+}
@@ -0,0 +1,61 @@
+package dates
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StorageLayout is the normalized representation used wherever a due date is
+// persisted as text. It is always UTC, so values sort lexically in time order.
+const StorageLayout = "2006-01-02T15:04:05Z"
+
+const dateLayout = "2006-01-02"
+
+var ErrInvalidDueDate = errors.New("invalid due date")
+
+var localLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	dateLayout,
+}
+
+// Parse accepts an RFC 3339 timestamp or a plain date. Values without an
+// offset, including plain dates which resolve to midnight, are interpreted in
+// location.
+func Parse(value string, location *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, fmt.Errorf("%w: empty value", ErrInvalidDueDate)
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range localLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q is neither RFC 3339 nor YYYY-MM-DD", ErrInvalidDueDate, value)
+}
+
+// Format renders t in StorageLayout.
+func Format(t time.Time) string {
+	return t.UTC().Truncate(time.Second).Format(StorageLayout)
+}
+
+// LoadLocation resolves an IANA time zone name, treating an empty name as UTC.
+func LoadLocation(timeZone string) (*time.Location, error) {
+	if timeZone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	return location, nil
+}
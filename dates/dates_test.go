@@ -0,0 +1,82 @@
+package dates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	tests := []struct {
+		value    string
+		location *time.Location
+		want     string
+	}{
+		{"2024-03-25", time.UTC, "2024-03-25T00:00:00Z"},
+		{"2024-03-25", paris, "2024-03-24T23:00:00Z"},
+		{" 2024-07-01 09:30 ", paris, "2024-07-01T07:30:00Z"},
+		{"2024-07-01T09:30:15", time.UTC, "2024-07-01T09:30:15Z"},
+		{"2024-07-01 09:30:15", time.UTC, "2024-07-01T09:30:15Z"},
+		{"2024-07-01T09:30:00+02:00", time.UTC, "2024-07-01T07:30:00Z"},
+		{"2024-07-01T09:30:00Z", paris, "2024-07-01T09:30:00Z"},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.value, test.location)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", test.value, err)
+			continue
+		}
+		if Format(got) != test.want {
+			t.Errorf("Parse(%q, %s) = %s, want %s", test.value, test.location, Format(got), test.want)
+		}
+	}
+
+	for _, value := range []string{"", "  ", "25/03/2024", "2024-13-01", "tomorrow"} {
+		if _, err := Parse(value, time.UTC); !errors.Is(err, ErrInvalidDueDate) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidDueDate", value, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC), "2024-03-25T00:00:00Z"},
+		{time.Date(2024, 3, 25, 10, 0, 0, 999, time.UTC), "2024-03-25T10:00:00Z"},
+		{time.Date(2024, 3, 25, 10, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), "2024-03-25T08:00:00Z"},
+		{time.Time{}, "0001-01-01T00:00:00Z"},
+	}
+	for _, test := range tests {
+		if got := Format(test.t); got != test.want {
+			t.Errorf("Format(%v) = %s, want %s", test.t, got, test.want)
+		}
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	tests := []struct {
+		timeZone string
+		want     string
+		ok       bool
+	}{
+		{"", "UTC", true},
+		{"UTC", "UTC", true},
+		{"Mars/Olympus_Mons", "", false},
+	}
+	for _, test := range tests {
+		location, err := LoadLocation(test.timeZone)
+		if (err == nil) != test.ok {
+			t.Errorf("LoadLocation(%q) error = %v, want ok %v", test.timeZone, err, test.ok)
+			continue
+		}
+		if test.ok && location.String() != test.want {
+			t.Errorf("LoadLocation(%q) = %s, want %s", test.timeZone, location, test.want)
+		}
+	}
+}
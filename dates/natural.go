@@ -0,0 +1,248 @@
+package dates
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"monday":     time.Monday,
+	"tuesday":    time.Tuesday,
+	"wednesday":  time.Wednesday,
+	"thursday":   time.Thursday,
+	"friday":     time.Friday,
+	"saturday":   time.Saturday,
+	"sunday":     time.Sunday,
+	"montag":     time.Monday,
+	"dienstag":   time.Tuesday,
+	"mittwoch":   time.Wednesday,
+	"donnerstag": time.Thursday,
+	"freitag":    time.Friday,
+	"samstag":    time.Saturday,
+	"sonnabend":  time.Saturday,
+	"sonntag":    time.Sunday,
+}
+
+var unitNames = map[string]string{
+	"minute":  "minute",
+	"minutes": "minute",
+	"minuten": "minute",
+	"hour":    "hour",
+	"hours":   "hour",
+	"stunde":  "hour",
+	"stunden": "hour",
+	"day":     "day",
+	"days":    "day",
+	"tag":     "day",
+	"tage":    "day",
+	"tagen":   "day",
+	"week":    "week",
+	"weeks":   "week",
+	"woche":   "week",
+	"wochen":  "week",
+	"month":   "month",
+	"months":  "month",
+	"monat":   "month",
+	"monate":  "month",
+	"monaten": "month",
+	"year":    "year",
+	"years":   "year",
+	"jahr":    "year",
+	"jahre":   "year",
+	"jahren":  "year",
+}
+
+var oneWords = map[string]bool{
+	"a": true, "an": true, "one": true, "ein": true, "einem": true, "einer": true, "eine": true,
+}
+
+var (
+	timeOfDayPattern = regexp.MustCompile(`^(.*?)\s*(?:(?:\bat|\bum)\s+(\d{1,2})(?::(\d{2}))?\s*(am|pm|uhr)?|(\d{1,2})(?::(\d{2}))?\s*(am|pm|uhr)|(\d{1,2}):(\d{2}))$`)
+	relativePattern  = regexp.MustCompile(`^(?:in\s+|\+\s*)(\d+|[a-z]+)\s+([a-z]+)$`)
+	weekdayPattern   = regexp.MustCompile(`^(?:(?:next|this|on|coming|am|kommenden|kommende|naechsten|naechster|naechste|diesen|dieser|diese)\s+)?([a-z]+)$`)
+)
+
+// Resolve turns a due date as sent by a client into an instant. It accepts
+// everything Parse does, plus English and German phrases such as "tomorrow",
+// "next friday 5pm", "in 3 days", "+3 days at 9am", "end of month",
+// "übermorgen um 9 Uhr" or "Ende des Monats", evaluated relative to now in
+// location. Phrases without a time of day resolve to midnight, like plain
+// dates. Adding months or years keeps the day of the month where it can and
+// otherwise ends up on the last day of the month, so "in 1 month" on January
+// 31 is the end of February.
+func Resolve(value string, now time.Time, location *time.Location) (time.Time, error) {
+	if t, err := Parse(value, location); err == nil {
+		return t, nil
+	}
+
+	phrase := normalizePhrase(value)
+	now = now.In(location)
+
+	if t, ok := resolveRelative(phrase, now); ok {
+		return t.UTC(), nil
+	}
+
+	day := phrase
+	hour, minute := 0, 0
+	if match := timeOfDayPattern.FindStringSubmatch(phrase); match != nil {
+		var ok bool
+		hour, minute, ok = timeOfDay(match)
+		if !ok {
+			return time.Time{}, fmt.Errorf("%w: invalid time of day in %q", ErrInvalidDueDate, value)
+		}
+		day = match[1]
+		if day == "" {
+			day = "today"
+		}
+	}
+
+	date, ok := resolveDay(day, now)
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: %q is neither a date nor a recognized phrase", ErrInvalidDueDate, value)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, location).UTC(), nil
+}
+
+func normalizePhrase(value string) string {
+	phrase := strings.ToLower(strings.TrimSpace(value))
+	phrase = strings.NewReplacer("ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss", ",", " ", ".", " ").Replace(phrase)
+	return strings.Join(strings.Fields(phrase), " ")
+}
+
+// resolveRelative resolves a phrase like "in 3 days" or "+2 hours". Minutes and
+// hours are counted from now; longer units resolve to midnight.
+func resolveRelative(phrase string, now time.Time) (time.Time, bool) {
+	amount, unit, ok := relativeAmount(phrase)
+	if !ok {
+		return time.Time{}, false
+	}
+	switch unit {
+	case "minute":
+		return now.Add(time.Duration(amount) * time.Minute), true
+	case "hour":
+		return now.Add(time.Duration(amount) * time.Hour), true
+	}
+	date, ok := shiftDate(now, amount, unit)
+	return startOfDay(date), ok
+}
+
+func relativeAmount(phrase string) (int, string, bool) {
+	match := relativePattern.FindStringSubmatch(phrase)
+	if match == nil {
+		return 0, "", false
+	}
+	amount, err := strconv.Atoi(match[1])
+	if err != nil {
+		if !oneWords[match[1]] {
+			return 0, "", false
+		}
+		amount = 1
+	}
+	unit, ok := unitNames[match[2]]
+	return amount, unit, ok
+}
+
+// shiftDate moves now by amount days, weeks, months or years.
+func shiftDate(now time.Time, amount int, unit string) (time.Time, bool) {
+	switch unit {
+	case "day":
+		return now.AddDate(0, 0, amount), true
+	case "week":
+		return now.AddDate(0, 0, 7*amount), true
+	case "month":
+		return addMonths(now, amount), true
+	case "year":
+		return addMonths(now, 12*amount), true
+	}
+	return time.Time{}, false
+}
+
+// addMonths moves t by months, keeping its day unless the target month is
+// shorter, in which case it lands on that month's last day.
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	day := t.Day()
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func resolveDay(phrase string, now time.Time) (time.Time, bool) {
+	switch phrase {
+	case "today", "heute":
+		return now, true
+	case "tomorrow", "morgen":
+		return now.AddDate(0, 0, 1), true
+	case "day after tomorrow", "the day after tomorrow", "uebermorgen":
+		return now.AddDate(0, 0, 2), true
+	case "next week", "naechste woche", "kommende woche":
+		return now.AddDate(0, 0, 7-daysSinceMonday(now)), true
+	case "next month", "naechsten monat", "kommenden monat":
+		return time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, now.Location()), true
+	case "end of week", "end of the week", "ende der woche":
+		return now.AddDate(0, 0, 6-daysSinceMonday(now)), true
+	case "end of month", "end of the month", "ende des monats", "monatsende":
+		return time.Date(now.Year(), now.Month()+1, 0, 0, 0, 0, 0, now.Location()), true
+	case "end of year", "end of the year", "ende des jahres", "jahresende":
+		return time.Date(now.Year(), time.December, 31, 0, 0, 0, 0, now.Location()), true
+	}
+
+	// A relative phrase followed by a time of day, like "in 3 days at 5pm".
+	if amount, unit, ok := relativeAmount(phrase); ok {
+		return shiftDate(now, amount, unit)
+	}
+
+	// A bare or qualified weekday always means the next such day after today.
+	if match := weekdayPattern.FindStringSubmatch(phrase); match != nil {
+		if weekday, ok := weekdayNames[match[1]]; ok {
+			days := (int(weekday) - int(now.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return now.AddDate(0, 0, days), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func timeOfDay(match []string) (int, int, bool) {
+	hourText, minuteText, suffix := match[2], match[3], match[4]
+	if hourText == "" {
+		hourText, minuteText, suffix = match[5], match[6], match[7]
+	}
+	if hourText == "" {
+		hourText, minuteText = match[8], match[9]
+	}
+
+	hour, _ := strconv.Atoi(hourText)
+	minute := 0
+	if minuteText != "" {
+		minute, _ = strconv.Atoi(minuteText)
+	}
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+func daysSinceMonday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
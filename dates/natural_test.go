@@ -0,0 +1,107 @@
+package dates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	// A Wednesday at the end of a month, in a leap year.
+	now := time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		now   time.Time
+		want  string
+	}{
+		{"2024-03-25", now, "2024-03-25T00:00:00Z"},
+		{"today", now, "2024-01-31T00:00:00Z"},
+		{"tomorrow", now, "2024-02-01T00:00:00Z"},
+		{"Tomorrow at 9", now, "2024-02-01T09:00:00Z"},
+		{"tomorrow 5pm", now, "2024-02-01T17:00:00Z"},
+		{"17:45", now, "2024-01-31T17:45:00Z"},
+		{"friday", now, "2024-02-02T00:00:00Z"},
+		{"next friday 9am", now, "2024-02-02T09:00:00Z"},
+		{"next friday at 17:30", now, "2024-02-02T17:30:00Z"},
+		{"wednesday", now, "2024-02-07T00:00:00Z"},
+		{"next week", now, "2024-02-05T00:00:00Z"},
+		{"end of week", now, "2024-02-04T00:00:00Z"},
+		{"end of month", now, "2024-01-31T00:00:00Z"},
+		{"end of the year", now, "2024-12-31T00:00:00Z"},
+		{"next month", now, "2024-02-01T00:00:00Z"},
+		{"in 30 minutes", now, "2024-01-31T11:00:00Z"},
+		{"+2 hours", now, "2024-01-31T12:30:00Z"},
+		{"in 3 days", now, "2024-02-03T00:00:00Z"},
+		{"in 3 days at 5pm", now, "2024-02-03T17:00:00Z"},
+		{"+3 days 9am", now, "2024-02-03T09:00:00Z"},
+		{"in 2 weeks at 10:15", now, "2024-02-14T10:15:00Z"},
+		{"in a month", now, "2024-02-29T00:00:00Z"},
+		{"in one month at 8am", now, "2024-02-29T08:00:00Z"},
+		{"in 2 months", now, "2024-03-31T00:00:00Z"},
+		{"in 3 months", now, "2024-04-30T00:00:00Z"},
+		{"in 13 months", now, "2025-02-28T00:00:00Z"},
+		{"in 1 year", time.Date(2024, time.February, 29, 8, 0, 0, 0, time.UTC), "2025-02-28T00:00:00Z"},
+		{"in 4 years", time.Date(2024, time.February, 29, 8, 0, 0, 0, time.UTC), "2028-02-29T00:00:00Z"},
+		{"morgen um 9 Uhr", now, "2024-02-01T09:00:00Z"},
+		{"übermorgen um 9 Uhr", now, "2024-02-02T09:00:00Z"},
+		{"in 3 Tagen um 14 Uhr", now, "2024-02-03T14:00:00Z"},
+		{"in einem Monat", now, "2024-02-29T00:00:00Z"},
+		{"Ende des Monats", now, "2024-01-31T00:00:00Z"},
+		{"nächsten Freitag 9 Uhr", now, "2024-02-02T09:00:00Z"},
+	}
+	for _, test := range tests {
+		got, err := Resolve(test.value, test.now, time.UTC)
+		if err != nil {
+			t.Errorf("Resolve(%q) error: %v", test.value, err)
+			continue
+		}
+		if Format(got) != test.want {
+			t.Errorf("Resolve(%q) = %s, want %s", test.value, Format(got), test.want)
+		}
+	}
+}
+
+func TestResolveInLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	// Just after midnight in Berlin, before the switch to summer time, is still
+	// the previous day in UTC.
+	now := time.Date(2024, time.March, 30, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		value, want string
+	}{
+		{"today", "2024-03-30T23:00:00Z"},
+		{"tomorrow 9am", "2024-04-01T07:00:00Z"},
+		{"in 3 days at 5pm", "2024-04-03T15:00:00Z"},
+	}
+	for _, test := range tests {
+		got, err := Resolve(test.value, now, berlin)
+		if err != nil {
+			t.Errorf("Resolve(%q) error: %v", test.value, err)
+			continue
+		}
+		if Format(got) != test.want {
+			t.Errorf("Resolve(%q) = %s, want %s", test.value, Format(got), test.want)
+		}
+	}
+}
+
+func TestResolveInvalid(t *testing.T) {
+	now := time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)
+	for _, value := range []string{
+		"",
+		"someday",
+		"tomorrow at 25:00",
+		"tomorrow 13pm",
+		"in 2 hours at 5pm",
+		"in many days",
+		"in 3 fortnights",
+		"next blursday",
+	} {
+		if _, err := Resolve(value, now, time.UTC); !errors.Is(err, ErrInvalidDueDate) {
+			t.Errorf("Resolve(%q) error = %v, want ErrInvalidDueDate", value, err)
+		}
+	}
+}
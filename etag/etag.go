@@ -0,0 +1,119 @@
+package etag
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Version renders the version of a resource as a strong entity tag.
+func Version(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Condition lists the entity tags of an If-Match header. A nil Condition
+// places no condition on a request.
+type Condition []string
+
+// IfMatch returns the condition of the If-Match header of r.
+func IfMatch(r *http.Request) Condition {
+	if _, ok := r.Header["If-Match"]; !ok {
+		return nil
+	}
+	return append(Condition{}, tags(r.Header.Values("If-Match"))...)
+}
+
+// Allows reports whether a resource tagged with tag meets the condition.
+// Entity tags are compared strongly, so weak tags never match.
+func (c Condition) Allows(tag string) bool {
+	if c == nil {
+		return true
+	}
+	for _, candidate := range c {
+		if candidate == "*" || candidate == tag && !weak(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conditional answers GET and HEAD requests with 304 Not Modified when the
+// ETag the handler sets matches the If-None-Match header of the request.
+// Entity tags are compared weakly.
+func Conditional(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("If-None-Match") == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(&conditionalWriter{ResponseWriter: w, ifNoneMatch: tags(r.Header.Values("If-None-Match"))}, r)
+	})
+}
+
+type conditionalWriter struct {
+	http.ResponseWriter
+	ifNoneMatch []string
+	wroteHeader bool
+	notModified bool
+}
+
+func (w *conditionalWriter) WriteHeader(status int) {
+	if !w.wroteHeader && status == http.StatusOK && w.matches(w.Header().Get("ETag")) {
+		header := w.Header()
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.notModified = true
+		status = http.StatusNotModified
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *conditionalWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notModified {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *conditionalWriter) matches(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for _, candidate := range w.ifNoneMatch {
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *conditionalWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *conditionalWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// tags splits header values into the entity tags they list.
+func tags(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+func weak(tag string) bool {
+	return strings.HasPrefix(tag, "W/")
+}
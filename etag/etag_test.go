@@ -0,0 +1,89 @@
+package etag
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch []string
+		tag     string
+		allows  bool
+	}{
+		{name: "no header", tag: `"3"`, allows: true},
+		{name: "same version", ifMatch: []string{`"3"`}, tag: `"3"`, allows: true},
+		{name: "older version", ifMatch: []string{`"2"`}, tag: `"3"`, allows: false},
+		{name: "one of a list", ifMatch: []string{`"1", "3"`}, tag: `"3"`, allows: true},
+		{name: "one of several headers", ifMatch: []string{`"1"`, `"3"`}, tag: `"3"`, allows: true},
+		{name: "any version", ifMatch: []string{"*"}, tag: `"3"`, allows: true},
+		{name: "weak tag", ifMatch: []string{`W/"3"`}, tag: `W/"3"`, allows: false},
+		{name: "empty header", ifMatch: []string{""}, tag: `"3"`, allows: false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/tasks/7", nil)
+		for _, value := range test.ifMatch {
+			r.Header.Add("If-Match", value)
+		}
+		if allows := IfMatch(r).Allows(test.tag); allows != test.allows {
+			t.Errorf("%s: Allows(%s) = %v, want %v", test.name, test.tag, allows, test.allows)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	if tag := Version(3); tag != `"3"` {
+		t.Errorf("Version(3) = %s", tag)
+	}
+}
+
+func TestConditional(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		etag        string
+		handlerCode int
+		status      int
+	}{
+		{name: "no header", method: http.MethodGet, etag: `"3"`, status: http.StatusOK},
+		{name: "current version", method: http.MethodGet, ifNoneMatch: `"3"`, etag: `"3"`, status: http.StatusNotModified},
+		{name: "HEAD", method: http.MethodHead, ifNoneMatch: `"3"`, etag: `"3"`, status: http.StatusNotModified},
+		{name: "weak comparison", method: http.MethodGet, ifNoneMatch: `W/"3"`, etag: `"3"`, status: http.StatusNotModified},
+		{name: "one of a list", method: http.MethodGet, ifNoneMatch: `"1", "3"`, etag: `"3"`, status: http.StatusNotModified},
+		{name: "any version", method: http.MethodGet, ifNoneMatch: "*", etag: `"3"`, status: http.StatusNotModified},
+		{name: "changed version", method: http.MethodGet, ifNoneMatch: `"2"`, etag: `"3"`, status: http.StatusOK},
+		{name: "no ETag", method: http.MethodGet, ifNoneMatch: `"3"`, status: http.StatusOK},
+		{name: "not a read", method: http.MethodPut, ifNoneMatch: `"3"`, etag: `"3"`, status: http.StatusOK},
+		{name: "error", method: http.MethodGet, ifNoneMatch: `"3"`, etag: `"3"`, handlerCode: http.StatusNotFound, status: http.StatusNotFound},
+	}
+	for _, test := range tests {
+		handler := Conditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.etag != "" {
+				w.Header().Set("ETag", test.etag)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if test.handlerCode != 0 {
+				w.WriteHeader(test.handlerCode)
+			}
+			fmt.Fprint(w, `{"id":7}`)
+		}))
+		r := httptest.NewRequest(test.method, "/tasks/7", nil)
+		if test.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, r)
+
+		body, contentType := `{"id":7}`, "application/json"
+		if test.status == http.StatusNotModified {
+			body, contentType = "", ""
+		}
+		if recorder.Code != test.status || recorder.Body.String() != body || recorder.Header().Get("Content-Type") != contentType || recorder.Header().Get("ETag") != test.etag {
+			t.Errorf("%s: %d %q with Content-Type %q and ETag %q, want %d %q with %q and %q", test.name, recorder.Code, recorder.Body, recorder.Header().Get("Content-Type"), recorder.Header().Get("ETag"), test.status, body, contentType, test.etag)
+		}
+	}
+}
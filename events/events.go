@@ -0,0 +1,151 @@
+package events
+
+import (
+	"Simple_Task_Manager/audit"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// DefaultSize is how many events a Log keeps unless told otherwise.
+const DefaultSize = 1000
+
+// Types of the events a change to a task is published as.
+const (
+	TaskCreated   = "task.created"
+	TaskUpdated   = "task.updated"
+	TaskCompleted = "task.completed"
+	TaskDeleted   = "task.deleted"
+)
+
+// Event is a change to a task. Data is the task as JSON, as it was when the
+// event was published, which may include later changes; UserID and Tags
+// describe it for filtering.
+type Event struct {
+	ID     uint64
+	Type   string
+	UserID string
+	Tags   []string
+	Data   json.RawMessage
+}
+
+// TypeOf returns the type of the event a history action is published as. A
+// revert is an update; baselines are not changes and are not published.
+func TypeOf(action audit.Action) (string, bool) {
+	switch action {
+	case audit.Created:
+		return TaskCreated, true
+	case audit.Updated, audit.Reverted:
+		return TaskUpdated, true
+	case audit.Completed:
+		return TaskCompleted, true
+	case audit.Deleted:
+		return TaskDeleted, true
+	default:
+		return "", false
+	}
+}
+
+// Filter selects the events of a user's tasks, of the tasks of a project, or
+// both. A project is named by a "+project" tag, as todo.txt lists name them.
+// The zero Filter selects every event.
+type Filter struct {
+	UserID  string
+	Project string
+}
+
+func (f Filter) Matches(event Event) bool {
+	if f.UserID != "" && event.UserID != f.UserID {
+		return false
+	}
+	if f.Project == "" {
+		return true
+	}
+	project := "+" + strings.TrimPrefix(f.Project, "+")
+	for _, tag := range event.Tags {
+		if strings.EqualFold(tag, project) {
+			return true
+		}
+	}
+	return false
+}
+
+// Log keeps the latest events, numbered from 1 in the order they were
+// published, and wakes subscribers when one is added. Once full, the oldest
+// event is dropped for each new one, so a client can only resume from an
+// event that is still kept.
+type Log struct {
+	size int
+
+	mu          sync.Mutex
+	events      []Event
+	last        uint64
+	subscribers map[chan struct{}]struct{}
+}
+
+// NewLog returns a Log keeping size events, or DefaultSize if size is not
+// positive.
+func NewLog(size int) *Log {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Log{size: size, subscribers: make(map[chan struct{}]struct{})}
+}
+
+// Publish numbers event, adds it to the log and wakes the subscribers.
+func (l *Log) Publish(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.last++
+	event.ID = l.last
+	if len(l.events) == l.size {
+		copy(l.events, l.events[1:])
+		l.events = l.events[:len(l.events)-1]
+	}
+	l.events = append(l.events, event)
+	for notify := range l.subscribers {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Last returns the ID of the latest event, or 0 if there is none.
+func (l *Log) Last() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// Since returns the events after the one with ID id. It reports false if
+// some of them are no longer kept, or if id was never published, as after a
+// restart.
+func (l *Log) Since(id uint64) ([]Event, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if id > l.last {
+		return nil, false
+	}
+	missed := int(l.last - id)
+	if missed > len(l.events) {
+		return nil, false
+	}
+	return append([]Event(nil), l.events[len(l.events)-missed:]...), true
+}
+
+// Subscribe returns a channel that receives a value whenever events have been
+// published since it last did, and a function that ends the subscription.
+func (l *Log) Subscribe() (<-chan struct{}, func()) {
+	notify := make(chan struct{}, 1)
+	l.mu.Lock()
+	l.subscribers[notify] = struct{}{}
+	l.mu.Unlock()
+	return notify, func() {
+		l.mu.Lock()
+		delete(l.subscribers, notify)
+		l.mu.Unlock()
+	}
+}
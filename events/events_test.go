@@ -0,0 +1,172 @@
+package events
+
+import (
+	"Simple_Task_Manager/audit"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestLogSince(t *testing.T) {
+	l := NewLog(3)
+	for i := 0; i < 5; i++ {
+		l.Publish(Event{Type: TaskUpdated})
+	}
+	tests := []struct {
+		since uint64
+		ids   []uint64
+		ok    bool
+	}{
+		{since: 5, ok: true},
+		{since: 4, ids: []uint64{5}, ok: true},
+		{since: 2, ids: []uint64{3, 4, 5}, ok: true},
+		{since: 1, ok: false},
+		{since: 6, ok: false},
+	}
+	for _, test := range tests {
+		events, ok := l.Since(test.since)
+		var ids []uint64
+		for _, event := range events {
+			ids = append(ids, event.ID)
+		}
+		if ok != test.ok || !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("Since(%d) = %v, %v, want %v, %v", test.since, ids, ok, test.ids, test.ok)
+		}
+	}
+	if last := l.Last(); last != 5 {
+		t.Errorf("Last = %d, want 5", last)
+	}
+}
+
+func TestLogSubscribe(t *testing.T) {
+	l := NewLog(0)
+	notify, unsubscribe := l.Subscribe()
+	l.Publish(Event{})
+	l.Publish(Event{})
+	select {
+	case <-notify:
+	default:
+		t.Fatal("subscriber was not woken")
+	}
+	select {
+	case <-notify:
+		t.Error("subscriber was woken once per event")
+	default:
+	}
+
+	unsubscribe()
+	l.Publish(Event{})
+	select {
+	case <-notify:
+		t.Error("subscriber was woken after unsubscribing")
+	default:
+	}
+}
+
+func TestFilterMatches(t *testing.T) {
+	event := Event{UserID: "1", Tags: []string{"priority:high", "+Home"}}
+	tests := []struct {
+		name    string
+		filter  Filter
+		matches bool
+	}{
+		{name: "zero filter", filter: Filter{}, matches: true},
+		{name: "same user", filter: Filter{UserID: "1"}, matches: true},
+		{name: "other user", filter: Filter{UserID: "2"}, matches: false},
+		{name: "project", filter: Filter{Project: "home"}, matches: true},
+		{name: "project with plus", filter: Filter{Project: "+HOME"}, matches: true},
+		{name: "other project", filter: Filter{Project: "garden"}, matches: false},
+		{name: "tag that is not a project", filter: Filter{Project: "priority:high"}, matches: false},
+		{name: "user and project", filter: Filter{UserID: "2", Project: "home"}, matches: false},
+	}
+	for _, test := range tests {
+		if matches := test.filter.Matches(event); matches != test.matches {
+			t.Errorf("%s: Matches = %v, want %v", test.name, matches, test.matches)
+		}
+	}
+}
+
+func TestTypeOf(t *testing.T) {
+	tests := []struct {
+		action    audit.Action
+		eventType string
+		published bool
+	}{
+		{action: audit.Created, eventType: TaskCreated, published: true},
+		{action: audit.Updated, eventType: TaskUpdated, published: true},
+		{action: audit.Reverted, eventType: TaskUpdated, published: true},
+		{action: audit.Completed, eventType: TaskCompleted, published: true},
+		{action: audit.Deleted, eventType: TaskDeleted, published: true},
+		{action: audit.Baseline},
+	}
+	for _, test := range tests {
+		if eventType, published := TypeOf(test.action); eventType != test.eventType || published != test.published {
+			t.Errorf("TypeOf(%v) = %q, %v, want %q, %v", test.action, eventType, published, test.eventType, test.published)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	l := NewLog(3)
+	l.Publish(Event{Type: TaskCreated, UserID: "1", Data: []byte(`{"id":1}`)})
+	l.Publish(Event{Type: TaskCreated, UserID: "2", Data: []byte(`{"id":2}`)})
+	l.Publish(Event{Type: TaskUpdated, UserID: "1", Data: []byte(`{"id":1}`)})
+	l.Publish(Event{Type: TaskDeleted, UserID: "1", Data: []byte(`{"id":1}`)})
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		filter      Filter
+		status      int
+		stream      string
+	}{
+		{name: "new client", status: http.StatusOK},
+		{
+			name:        "resuming",
+			lastEventID: "2",
+			status:      http.StatusOK,
+			stream:      "id: 3\nevent: task.updated\ndata: {\"id\":1}\n\nid: 4\nevent: task.deleted\ndata: {\"id\":1}\n\n",
+		},
+		{
+			name:        "resuming with a filter",
+			lastEventID: "2",
+			filter:      Filter{UserID: "2"},
+			status:      http.StatusOK,
+		},
+		{
+			name:        "missed dropped events",
+			lastEventID: "0",
+			status:      http.StatusOK,
+			stream:      "id: 4\nevent: reset\ndata: {}\n\n",
+		},
+		{name: "invalid Last-Event-ID", lastEventID: "latest", status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		// The client has already gone away, so Serve returns after sending
+		// what the client missed.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		r := httptest.NewRequest(http.MethodGet, "/events", nil).WithContext(ctx)
+		if test.lastEventID != "" {
+			r.Header.Set("Last-Event-ID", test.lastEventID)
+		}
+		recorder := httptest.NewRecorder()
+		Serve(recorder, r, l, test.filter)
+
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, want %d", test.name, recorder.Code, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		if contentType := recorder.Header().Get("Content-Type"); contentType != "text/event-stream" {
+			t.Errorf("%s: Content-Type %q", test.name, contentType)
+		}
+		if recorder.Body.String() != test.stream {
+			t.Errorf("%s: stream\n%q\nwant\n%q", test.name, recorder.Body, test.stream)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"Simple_Task_Manager/problem"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KeepAlive is how often an idle stream sends a comment, so proxies do not
+// close it.
+const KeepAlive = 15 * time.Second
+
+// Serve streams the events of l that match filter to the client as
+// Server-Sent Events, until the client goes away. A client reconnecting with
+// a Last-Event-ID header is sent the events it missed first; if some of them
+// are no longer kept, it is sent a reset event instead and should reload what
+// it shows. A new client only gets events published after it connected.
+func Serve(w http.ResponseWriter, r *http.Request, l *Log, filter Filter) {
+	last := l.Last()
+	if value := r.Header.Get("Last-Event-ID"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			log.Println("Invalid Last-Event-ID:", err)
+			problem.Write(w, problem.BadRequest("invalid_last_event_id", "Invalid Last-Event-ID header"))
+			return
+		}
+		last = id
+	}
+
+	notify, unsubscribe := l.Subscribe()
+	defer unsubscribe()
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(w)
+	keepAlive := time.NewTicker(KeepAlive)
+	defer keepAlive.Stop()
+	log.Println("Event stream opened")
+	for {
+		var err error
+		last, err = send(w, l, filter, last)
+		if err == nil {
+			err = controller.Flush()
+		}
+		if err != nil {
+			log.Printf("Error writing event stream: %v", err)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			log.Println("Event stream closed")
+			return
+		case <-notify:
+		case <-keepAlive.C:
+			if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				log.Printf("Error writing event stream: %v", err)
+				return
+			}
+		}
+	}
+}
+
+// send writes the events after last that match filter and returns the ID of
+// the latest event it has seen.
+func send(w http.ResponseWriter, l *Log, filter Filter, last uint64) (uint64, error) {
+	events, ok := l.Since(last)
+	if !ok {
+		last = l.Last()
+		_, err := fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", last)
+		return last, err
+	}
+	for _, event := range events {
+		last = event.ID
+		if !filter.Matches(event) {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data); err != nil {
+			return last, err
+		}
+	}
+	return last, nil
+}
@@ -0,0 +1,255 @@
+package ical
+
+import (
+	"Simple_Task_Manager/workflow"
+	"bufio"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	productID      = "-//Simple Task Manager//Task Feed//EN"
+	dateTimeLayout = "20060102T150405"
+	maxLineLength  = 75
+)
+
+var ErrUnknownComponent = errors.New("unknown calendar component")
+
+// Component is the kind of calendar entry tasks are published as. Clients
+// that ignore to-dos, like most web calendars, can subscribe to events.
+type Component string
+
+const (
+	Todo  Component = "VTODO"
+	Event Component = "VEVENT"
+)
+
+func ParseComponent(value string) (Component, error) {
+	switch strings.ToUpper(value) {
+	case "", "VTODO", "TODO":
+		return Todo, nil
+	case "VEVENT", "EVENT":
+		return Event, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownComponent, value)
+}
+
+type State string
+
+const (
+	NeedsAction State = "NEEDS-ACTION"
+	InProcess   State = "IN-PROCESS"
+	Completed   State = "COMPLETED"
+	Cancelled   State = "CANCELLED"
+)
+
+// StateOf maps a workflow status onto the states iCalendar knows.
+func StateOf(wf *workflow.Workflow, status workflow.Status) State {
+	switch {
+	case status == workflow.Cancelled:
+		return Cancelled
+	case wf.IsCompleted(status):
+		return Completed
+	case status == wf.Initial:
+		return NeedsAction
+	}
+	return InProcess
+}
+
+// PriorityOf reads the priority of a task from a "priority:high", "medium",
+// "low" or "priority:1" to "priority:9" tag. Zero means undefined.
+func PriorityOf(tags []string) int {
+	for _, tag := range tags {
+		value, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(tag)), "priority:")
+		if !ok {
+			continue
+		}
+		switch value {
+		case "high":
+			return 1
+		case "medium":
+			return 5
+		case "low":
+			return 9
+		}
+		if priority, err := strconv.Atoi(value); err == nil && priority >= 1 && priority <= 9 {
+			return priority
+		}
+	}
+	return 0
+}
+
+// Item is a task as published in a feed. Due is shown on the wall clock of
+// TimeZone, which recurrences are evaluated in.
+type Item struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         time.Time
+	TimeZone    string
+	RRule       string
+	State       State
+	CompletedAt *time.Time
+	Priority    int
+	Categories  []string
+}
+
+type Calendar struct {
+	Name      string
+	Component Component
+	Items     []Item
+}
+
+// Write renders calendar as an iCalendar (RFC 5545) document stamped with now.
+func Write(out io.Writer, calendar Calendar, now time.Time) error {
+	w := &writer{out: bufio.NewWriter(out)}
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + productID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	if calendar.Name != "" {
+		w.line("X-WR-CALNAME:" + escape(calendar.Name))
+	}
+	w.line("REFRESH-INTERVAL;VALUE=DURATION:PT1H")
+	w.line("X-PUBLISHED-TTL:PT1H")
+	for _, item := range calendar.Items {
+		w.item(calendar.Component, item, now)
+	}
+	w.line("END:VCALENDAR")
+	if w.err != nil {
+		return w.err
+	}
+	return w.out.Flush()
+}
+
+type writer struct {
+	out *bufio.Writer
+	err error
+}
+
+func (w *writer) item(component Component, item Item, now time.Time) {
+	w.line("BEGIN:" + string(component))
+	w.line("UID:" + escape(item.UID))
+	w.line("DTSTAMP:" + utc(now))
+
+	summary := item.Summary
+	if component == Event && item.State == Completed {
+		// Events have no completion, so it is shown in the title.
+		summary = "✓ " + summary
+	}
+	w.line("SUMMARY:" + escape(summary))
+	if item.Description != "" {
+		w.line("DESCRIPTION:" + escape(item.Description))
+	}
+
+	due := dateTime(item.Due, item.TimeZone)
+	if component == Todo {
+		if item.RRule != "" {
+			// A recurring to-do needs a start to anchor its occurrences.
+			w.line("DTSTART" + due)
+		}
+		w.line("DUE" + due)
+		if item.State != "" {
+			w.line("STATUS:" + string(item.State))
+		}
+		if item.State == Completed {
+			w.line("PERCENT-COMPLETE:100")
+			if item.CompletedAt != nil {
+				w.line("COMPLETED:" + utc(*item.CompletedAt))
+			}
+		}
+	} else {
+		w.line("DTSTART" + due)
+		w.line("DTEND" + due)
+		w.line("TRANSP:TRANSPARENT")
+		if item.State == Cancelled {
+			w.line("STATUS:CANCELLED")
+		} else {
+			w.line("STATUS:CONFIRMED")
+		}
+	}
+
+	if item.RRule != "" {
+		w.line("RRULE:" + item.RRule)
+	}
+	if item.Priority > 0 {
+		w.line("PRIORITY:" + strconv.Itoa(item.Priority))
+	}
+	if len(item.Categories) > 0 {
+		categories := make([]string, 0, len(item.Categories))
+		for _, category := range item.Categories {
+			categories = append(categories, escape(category))
+		}
+		w.line("CATEGORIES:" + strings.Join(categories, ","))
+	}
+	w.line("END:" + string(component))
+}
+
+// line writes a content line, folded after 75 octets without splitting a
+// UTF-8 sequence.
+func (w *writer) line(content string) {
+	if w.err != nil {
+		return
+	}
+	var folded strings.Builder
+	length := 0
+	for _, r := range content {
+		size := utf8.RuneLen(r)
+		if length+size > maxLineLength {
+			folded.WriteString("\r\n ")
+			length = 1
+		}
+		folded.WriteRune(r)
+		length += size
+	}
+	folded.WriteString("\r\n")
+	_, w.err = w.out.WriteString(folded.String())
+}
+
+// dateTime renders the value of a DUE, DTSTART or DTEND property including
+// its parameters. Times outside UTC carry their IANA zone name as TZID, which
+// calendar clients resolve without a VTIMEZONE definition.
+func dateTime(t time.Time, timeZone string) string {
+	if timeZone != "" && timeZone != "UTC" {
+		if location, err := time.LoadLocation(timeZone); err == nil {
+			return ";TZID=" + timeZone + ":" + t.In(location).Format(dateTimeLayout)
+		}
+	}
+	return ":" + utc(t)
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format(dateTimeLayout) + "Z"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+func escape(text string) string {
+	return escaper.Replace(text)
+}
+
+// NewToken returns a random feed token and the hash to store for it. Only
+// the hash is kept, so a leaked database does not expose subscribable URLs.
+func NewToken() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	return token, HashToken(token), nil
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
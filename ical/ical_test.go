@@ -0,0 +1,200 @@
+package ical
+
+import (
+	"Simple_Task_Manager/workflow"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseComponent(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Component
+		err   error
+	}{
+		{value: "", want: Todo},
+		{value: "todo", want: Todo},
+		{value: "VTODO", want: Todo},
+		{value: "event", want: Event},
+		{value: "VEVENT", want: Event},
+		{value: "journal", err: ErrUnknownComponent},
+	}
+	for _, test := range tests {
+		component, err := ParseComponent(test.value)
+		if component != test.want || !errors.Is(err, test.err) {
+			t.Errorf("ParseComponent(%q) = %q, %v, want %q, %v", test.value, component, err, test.want, test.err)
+		}
+	}
+}
+
+func TestStateOf(t *testing.T) {
+	wf := workflow.Default()
+	tests := []struct {
+		status workflow.Status
+		want   State
+	}{
+		{status: wf.Initial, want: NeedsAction},
+		{status: "in-progress", want: InProcess},
+		{status: "in-review", want: InProcess},
+		{status: wf.CompletedStatus(), want: Completed},
+		{status: workflow.Cancelled, want: Cancelled},
+	}
+	for _, test := range tests {
+		if got := StateOf(wf, test.status); got != test.want {
+			t.Errorf("StateOf(%q) = %q, want %q", test.status, got, test.want)
+		}
+	}
+}
+
+func TestPriorityOf(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want int
+	}{
+		{tags: nil, want: 0},
+		{tags: []string{"+home"}, want: 0},
+		{tags: []string{"priority:high"}, want: 1},
+		{tags: []string{" Priority:Medium "}, want: 5},
+		{tags: []string{"priority:low"}, want: 9},
+		{tags: []string{"priority:3"}, want: 3},
+		{tags: []string{"priority:0", "priority:10", "priority:urgent"}, want: 0},
+		{tags: []string{"priority:urgent", "priority:2"}, want: 2},
+	}
+	for _, test := range tests {
+		if got := PriorityOf(test.tags); got != test.want {
+			t.Errorf("PriorityOf(%q) = %d, want %d", test.tags, got, test.want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2024, time.March, 25, 12, 0, 0, 0, time.UTC)
+	due := time.Date(2024, time.March, 26, 8, 0, 0, 0, time.UTC)
+	completed := now
+	tests := []struct {
+		name      string
+		component Component
+		item      Item
+		want      []string
+		absent    []string
+	}{
+		{
+			name:      "to-do in a time zone",
+			component: Todo,
+			item:      Item{UID: "task-1@tasks", Summary: "water plants", Due: due, TimeZone: "Europe/Berlin", State: NeedsAction, Priority: 1},
+			want:      []string{"BEGIN:VTODO", "UID:task-1@tasks", "DTSTAMP:20240325T120000Z", "DUE;TZID=Europe/Berlin:20240326T090000", "STATUS:NEEDS-ACTION", "PRIORITY:1"},
+			absent:    []string{"DTSTART", "PERCENT-COMPLETE"},
+		},
+		{
+			name:      "recurring completed to-do",
+			component: Todo,
+			item:      Item{UID: "task-2", Summary: "water plants", Due: due, TimeZone: "UTC", RRule: "FREQ=WEEKLY", State: Completed, CompletedAt: &completed},
+			want:      []string{"DTSTART:20240326T080000Z", "DUE:20240326T080000Z", "RRULE:FREQ=WEEKLY", "PERCENT-COMPLETE:100", "COMPLETED:20240325T120000Z"},
+		},
+		{
+			name:      "completed event",
+			component: Event,
+			item:      Item{UID: "task-3", Summary: "water plants", Due: due, State: Completed, Categories: []string{"home", "garden, front"}},
+			want:      []string{"BEGIN:VEVENT", "SUMMARY:✓ water plants", "DTSTART:20240326T080000Z", "DTEND:20240326T080000Z", "STATUS:CONFIRMED", `CATEGORIES:home,garden\, front`},
+			absent:    []string{"DUE", "COMPLETED"},
+		},
+		{
+			name:      "cancelled event",
+			component: Event,
+			item:      Item{UID: "task-4", Summary: "water plants", Due: due, State: Cancelled},
+			want:      []string{"STATUS:CANCELLED"},
+		},
+		{
+			name:      "escaped text",
+			component: Todo,
+			item:      Item{UID: "task-5", Summary: `a; b, c\d`, Description: "line one\nline two", Due: due},
+			want:      []string{`SUMMARY:a\; b\, c\\d`, `DESCRIPTION:line one\nline two`},
+			absent:    []string{"STATUS"},
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		calendar := Calendar{Name: "ada's tasks", Component: test.component, Items: []Item{test.item}}
+		if err := Write(&buf, calendar, now); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n")
+		if lines[0] != "BEGIN:VCALENDAR" || lines[len(lines)-1] != "END:VCALENDAR" {
+			t.Errorf("%s: not a calendar:\n%s", test.name, buf.String())
+		}
+		for _, want := range test.want {
+			if !contains(lines, want) {
+				t.Errorf("%s: missing %q in\n%s", test.name, want, buf.String())
+			}
+		}
+		for _, absent := range test.absent {
+			for _, line := range lines {
+				if strings.HasPrefix(line, absent) {
+					t.Errorf("%s: unexpected %q", test.name, line)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteFoldsLongLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary string
+	}{
+		{name: "ASCII", summary: strings.Repeat("a", 200)},
+		{name: "multi-byte", summary: strings.Repeat("ü", 100)},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		calendar := Calendar{Component: Todo, Items: []Item{{UID: "1", Summary: test.summary}}}
+		if err := Write(&buf, calendar, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(buf.String(), "\r\n") {
+			if len(line) > maxLineLength {
+				t.Errorf("%s: line of %d octets: %q", test.name, len(line), line)
+			}
+		}
+		unfolded := strings.ReplaceAll(buf.String(), "\r\n ", "")
+		if !strings.Contains(unfolded, "SUMMARY:"+test.summary+"\r\n") {
+			t.Errorf("%s: summary does not unfold to the original", test.name)
+		}
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	token, hash, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		token string
+		match bool
+	}{
+		{token: token, match: true},
+		{token: other, match: false},
+		{token: "", match: false},
+	}
+	for _, test := range tests {
+		if (HashToken(test.token) == hash) != test.match {
+			t.Errorf("HashToken(%q) matching the stored hash: %v, want %v", test.token, !test.match, test.match)
+		}
+	}
+}
+
+func contains(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,273 @@
+package idempotency
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/route"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Header is the request header carrying an idempotency key.
+const Header = "Idempotency-Key"
+
+// MaxKeyLength bounds the length of an idempotency key.
+const MaxKeyLength = 255
+
+// DefaultWindow is how long a key is remembered unless Keys says otherwise.
+const DefaultWindow = 24 * time.Hour
+
+// DefaultMaxBodySize bounds the body of a request with a key unless Keys says
+// otherwise. It leaves room for the largest attachment upload.
+const DefaultMaxBodySize = 32 << 20
+
+// Response is a response recorded for an idempotency key.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is what a Store remembers of a key: the fingerprint of the request
+// that first used it and, once that request has been answered, the response.
+type Record struct {
+	Fingerprint string
+	Response    *Response
+}
+
+// Store remembers idempotency keys until they expire.
+type Store interface {
+	// Begin claims key for a request with fingerprint, unless a record of the
+	// key that has not expired at now exists, which it returns instead. A
+	// claimed key expires at expires.
+	Begin(key, fingerprint string, now, expires time.Time) (*Record, error)
+	// Complete records the response to the request that claimed key.
+	Complete(key string, response Response) error
+	// Release forgets key, so that a retry runs the request again.
+	Release(key string) error
+}
+
+// Keys makes mutating requests that carry an Idempotency-Key header safe to
+// retry. The first request with a key is served and its response recorded
+// for Window; retries with the same key and request get the recorded
+// response, marked by an Idempotent-Replayed header, without running the
+// request again. A key reused for a different request is rejected with 422,
+// and a retry while the first request is still running with 409. Responses
+// with a 5xx status are not recorded, so a retry after a failure runs the
+// request again.
+//
+// Keys are scoped to the API version, user_id, method and path of the
+// request, so callers cannot see each other's responses by guessing a key.
+// The body is spooled to a temporary file while it is fingerprinted, and one
+// larger than MaxBodySize is rejected with 413.
+type Keys struct {
+	Store       Store
+	Window      time.Duration
+	MaxBodySize int64
+	Clock       func() time.Time
+}
+
+func (k *Keys) now() time.Time {
+	if k.Clock != nil {
+		return k.Clock()
+	}
+	return time.Now()
+}
+
+func (k *Keys) window() time.Duration {
+	if k.Window > 0 {
+		return k.Window
+	}
+	return DefaultWindow
+}
+
+func (k *Keys) maxBodySize() int64 {
+	if k.MaxBodySize > 0 {
+		return k.MaxBodySize
+	}
+	return DefaultMaxBodySize
+}
+
+// Handler serves handler with idempotency keys. A nil Keys serves handler as
+// is.
+func (k *Keys) Handler(handler http.Handler) http.Handler {
+	if k == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || !mutating(r.Method) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > MaxKeyLength {
+			log.Println("Idempotency key too long")
+			problem.Write(w, problem.BadRequest("invalid_idempotency_key", "Idempotency key too long"))
+			return
+		}
+
+		body, err := os.CreateTemp("", "idempotent-body-*")
+		if err != nil {
+			log.Printf("Error spooling request body: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		defer discard(body)
+		fingerprint := newFingerprint(r)
+		if err = spool(body, io.TeeReader(http.MaxBytesReader(w, r.Body, k.maxBodySize()), fingerprint)); err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				log.Println("Request body too large:", err)
+				problem.Write(w, problem.New(http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large"))
+			} else {
+				log.Printf("Error reading request body: %v", err)
+				problem.Write(w, problem.BadRequest("invalid_body", "Invalid request body"))
+			}
+			return
+		}
+		r.Body = body
+
+		key = scoped(r, key)
+		now := k.now()
+		sum := hex.EncodeToString(fingerprint.Sum(nil))
+		record, err := k.Store.Begin(key, sum, now, now.Add(k.window()))
+		if err != nil {
+			log.Printf("Error claiming idempotency key: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		if record != nil {
+			replay(w, record, sum)
+			return
+		}
+
+		recorder := &recordingWriter{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if !completed {
+				if err := k.Store.Release(key); err != nil {
+					log.Printf("Error releasing idempotency key: %v", err)
+				}
+			}
+		}()
+		handler.ServeHTTP(recorder, r)
+
+		if recorder.response.Status == 0 {
+			recorder.WriteHeader(http.StatusOK)
+		}
+		if recorder.response.Status >= http.StatusInternalServerError {
+			return
+		}
+		if err := k.Store.Complete(key, recorder.response); err != nil {
+			log.Printf("Error recording idempotent response: %v", err)
+			return
+		}
+		completed = true
+	})
+}
+
+// replay answers a retry of the request record was made for.
+func replay(w http.ResponseWriter, record *Record, fingerprint string) {
+	switch {
+	case record.Fingerprint != fingerprint:
+		log.Println("Idempotency key reused for a different request")
+		problem.Write(w, problem.New(http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency key was used for a different request"))
+	case record.Response == nil:
+		log.Println("Request with idempotency key still in progress")
+		problem.Write(w, problem.Conflict("request_in_progress", "A request with this idempotency key is still in progress"))
+	default:
+		header := w.Header()
+		for name, values := range record.Response.Header {
+			header[name] = values
+		}
+		header.Set("Idempotent-Replayed", "true")
+		w.WriteHeader(record.Response.Status)
+		_, _ = w.Write(record.Response.Body)
+		log.Println("Replayed idempotent response")
+	}
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// newFingerprint starts identifying a request by its API version, method and
+// target; the body is written to it as it is read.
+func newFingerprint(r *http.Request) hash.Hash {
+	return hashOf(route.VersionOf(r), r.Method, r.URL.Path, r.URL.RawQuery)
+}
+
+// scoped returns the key under which a request's idempotency key is stored.
+func scoped(r *http.Request, key string) string {
+	return hex.EncodeToString(hashOf(route.VersionOf(r), r.URL.Query().Get("user_id"), r.Method, r.URL.Path, key).Sum(nil))
+}
+
+func hashOf(parts ...string) hash.Hash {
+	digest := sha256.New()
+	for _, part := range parts {
+		digest.Write([]byte(part))
+		digest.Write([]byte{0})
+	}
+	return digest
+}
+
+// spool copies body to file and rewinds it, so that the request can read its
+// body again without holding it in memory.
+func spool(file *os.File, body io.Reader) error {
+	if _, err := io.Copy(file, body); err != nil {
+		return err
+	}
+	_, err := file.Seek(0, io.SeekStart)
+	return err
+}
+
+func discard(file *os.File) {
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		log.Printf("Error removing spooled request body: %v", err)
+	}
+}
+
+// recordingWriter passes a response on while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	response Response
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if w.response.Status == 0 {
+		w.response.Status = status
+		w.response.Header = w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	if w.response.Status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.response.Body = append(w.response.Body, data...)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) Flush() {
+	if w.response.Status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
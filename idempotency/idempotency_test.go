@@ -0,0 +1,180 @@
+package idempotency
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryStore keeps keys in memory.
+type memoryStore struct {
+	mu      sync.Mutex
+	records map[string]*Record
+}
+
+func (s *memoryStore) Begin(key, fingerprint string, now, expires time.Time) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		return record, nil
+	}
+	s.records[key] = &Record{Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (s *memoryStore) Complete(key string, response Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key].Response = &response
+	return nil
+}
+
+func (s *memoryStore) Release(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+type request struct {
+	method, target, key, body string
+}
+
+func TestHandler(t *testing.T) {
+	first := request{http.MethodPost, "/tasks?user_id=1", "abc", `{"title":"a"}`}
+	tests := []struct {
+		name     string
+		requests []request
+		status   []int
+		runs     int
+		replayed bool
+	}{
+		{
+			name:     "retry is replayed",
+			requests: []request{first, first},
+			status:   []int{http.StatusCreated, http.StatusCreated},
+			runs:     1,
+			replayed: true,
+		},
+		{
+			name:     "key reused for another body",
+			requests: []request{first, {http.MethodPost, "/tasks?user_id=1", "abc", `{"title":"b"}`}},
+			status:   []int{http.StatusCreated, http.StatusUnprocessableEntity},
+			runs:     1,
+		},
+		{
+			name:     "key of another user",
+			requests: []request{first, {http.MethodPost, "/tasks?user_id=2", "abc", `{"title":"a"}`}},
+			status:   []int{http.StatusCreated, http.StatusCreated},
+			runs:     2,
+		},
+		{
+			name:     "key on another path",
+			requests: []request{first, {http.MethodPost, "/comments?user_id=1", "abc", `{"title":"a"}`}},
+			status:   []int{http.StatusCreated, http.StatusCreated},
+			runs:     2,
+		},
+		{
+			name:     "without key",
+			requests: []request{{http.MethodPost, "/tasks", "", "{}"}, {http.MethodPost, "/tasks", "", "{}"}},
+			status:   []int{http.StatusCreated, http.StatusCreated},
+			runs:     2,
+		},
+		{
+			name:     "safe method",
+			requests: []request{{http.MethodGet, "/tasks", "abc", ""}, {http.MethodGet, "/tasks", "abc", ""}},
+			status:   []int{http.StatusCreated, http.StatusCreated},
+			runs:     2,
+		},
+		{
+			name:     "failure is not recorded",
+			requests: []request{{http.MethodPost, "/fail", "abc", "{}"}, {http.MethodPost, "/fail", "abc", "{}"}},
+			status:   []int{http.StatusInternalServerError, http.StatusInternalServerError},
+			runs:     2,
+		},
+		{
+			name:     "key too long",
+			requests: []request{{http.MethodPost, "/tasks", strings.Repeat("k", MaxKeyLength+1), "{}"}},
+			status:   []int{http.StatusBadRequest},
+		},
+		{
+			name:     "body too large",
+			requests: []request{{http.MethodPost, "/tasks", "abc", strings.Repeat("x", 65)}},
+			status:   []int{http.StatusRequestEntityTooLarge},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runs := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Errorf("reading body: %v", err)
+				}
+				if r.URL.Path == "/fail" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte(strconv.Itoa(runs) + ":" + string(body)))
+			})
+			keys := &Keys{Store: &memoryStore{records: map[string]*Record{}}, MaxBodySize: 64}
+			served := keys.Handler(handler)
+
+			var bodies []string
+			var replayed bool
+			for i, req := range test.requests {
+				r := httptest.NewRequest(req.method, req.target, strings.NewReader(req.body))
+				if req.key != "" {
+					r.Header.Set(Header, req.key)
+				}
+				w := httptest.NewRecorder()
+				served.ServeHTTP(w, r)
+				if w.Code != test.status[i] {
+					t.Errorf("request %d: status %d, want %d", i, w.Code, test.status[i])
+				}
+				bodies = append(bodies, w.Body.String())
+				replayed = w.Header().Get("Idempotent-Replayed") == "true"
+			}
+			if runs != test.runs {
+				t.Errorf("handler ran %d times, want %d", runs, test.runs)
+			}
+			if replayed != test.replayed {
+				t.Errorf("last response replayed = %v, want %v", replayed, test.replayed)
+			}
+			if test.replayed && bodies[len(bodies)-1] != bodies[0] {
+				t.Errorf("replayed body %q, want %q", bodies[len(bodies)-1], bodies[0])
+			}
+		})
+	}
+}
+
+func TestHandlerInProgress(t *testing.T) {
+	store := &memoryStore{records: map[string]*Record{}}
+	keys := &Keys{Store: store}
+	var served http.Handler
+	served = keys.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		retry := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader("{}"))
+		retry.Header.Set(Header, "abc")
+		recorder := httptest.NewRecorder()
+		served.ServeHTTP(recorder, retry)
+		if recorder.Code != http.StatusConflict {
+			t.Errorf("retry while in progress: status %d, want %d", recorder.Code, http.StatusConflict)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	r := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader("{}"))
+	r.Header.Set(Header, "abc")
+	w := httptest.NewRecorder()
+	served.ServeHTTP(w, r)
+	if w.Code != http.StatusCreated {
+		t.Errorf("status %d, want %d", w.Code, http.StatusCreated)
+	}
+}
@@ -1,17 +1,31 @@
 package main
 
 import (
+	"Simple_Task_Manager/blobstore"
+	"Simple_Task_Manager/cli"
 	databaseMongoDB "Simple_Task_Manager/database/mongodb"
 	databaseSqlite "Simple_Task_Manager/database/sqlite"
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/idempotency"
+	"Simple_Task_Manager/route"
 	routerMongoDB "Simple_Task_Manager/router/mongodb"
 	routerSqlite "Simple_Task_Manager/router/sqlite"
 	taskManagerMongoDB "Simple_Task_Manager/task_manager/mongodb"
 	taskManagerSqlite "Simple_Task_Manager/task_manager/sqlite"
+	"Simple_Task_Manager/workflow"
+	"database/sql"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	const databaseType = 2
 
 	switch databaseType {
@@ -26,7 +40,9 @@ func main() {
 }
 
 func sqlite() {
+	wf := loadWorkflow()
 	var dbManager = databaseSqlite.NewSQLiteDB("./database/sqlite/sqlite.db")
+	dbManager.Workflow = wf
 
 	database, err := dbManager.OpenDatabase()
 	if err != nil {
@@ -39,17 +55,21 @@ func sqlite() {
 		log.Fatalf("Error initializing the database: %v", err)
 	}
 
-	app := &taskManagerSqlite.App{DB: database}
+	snapshots := loadSnapshots(database)
+	app := &taskManagerSqlite.App{DB: database, Workflow: wf, Blobs: loadBlobStore(), Snapshots: snapshots, Events: loadEvents()}
+	go app.RelayEvents(eventRelayInterval)
 
-	taskApp := &routerSqlite.App{TaskManager: app}
+	taskApp := &routerSqlite.App{TaskManager: app, Workflow: wf, Idempotency: loadIdempotencyKeys(&databaseSqlite.IdempotencyKeys{DB: database}), AdminToken: adminToken()}
 
-	http.HandleFunc("/tasks", taskApp.HandleTasks)
+	http.Handle("/", taskApp.Routes(apiV1()))
 
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
 
 func mongodb() {
+	wf := loadWorkflow()
 	var dbManager = databaseMongoDB.NewMongoDB("mongodb://localhost:27017", "task_manager")
+	dbManager.Workflow = wf
 	database, err := dbManager.OpenDatabase()
 	if err != nil {
 		log.Fatalf("Error opening database connection: %v", err)
@@ -59,10 +79,159 @@ func mongodb() {
 		log.Fatalf("Error initializing the database: %v", err)
 	}
 
-	app := &taskManagerMongoDB.App{DB: database}
-	routerApp := &routerMongoDB.App{TaskManager: app}
+	app := &taskManagerMongoDB.App{
+		DB:            database,
+		Users:         database.Collection("users"),
+		Tasks:         database.Collection("tasks"),
+		StatusChanges: database.Collection("task_status_changes"),
+		Comments:      database.Collection("comments"),
+		Attachments:   database.Collection("attachments"),
+		TimeEntries:   database.Collection("time_entries"),
+		History:       database.Collection("task_history"),
+		Templates:     database.Collection("task_templates"),
+		Workflow:      wf,
+		Blobs:         loadBlobStore(),
+		Events:        loadEvents(),
+	}
+	routerApp := &routerMongoDB.App{
+		TaskManager: app,
+		Workflow:    wf,
+		Idempotency: loadIdempotencyKeys(&databaseMongoDB.IdempotencyKeys{Collection: database.Collection("idempotency_keys")}),
+		AdminToken:  adminToken(),
+	}
 
-	http.HandleFunc("/tasks", routerApp.HandleTasks)
+	http.Handle("/", routerApp.Routes(apiV1()))
 
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
+
+func loadWorkflow() *workflow.Workflow {
+	path := os.Getenv("TASK_WORKFLOW")
+	if path == "" {
+		return workflow.Default()
+	}
+
+	wf, err := workflow.Load(path)
+	if err != nil {
+		log.Fatalf("Error loading workflow %s: %v", path, err)
+	}
+	return wf
+}
+
+// loadSnapshots configures hot backups of the SQLite database from the
+// TASK_SNAPSHOT_* variables and schedules them, daily unless
+// TASK_SNAPSHOT_INTERVAL says otherwise; an interval of 0 only takes them on
+// demand.
+func loadSnapshots(database *sql.DB) *databaseSqlite.Snapshots {
+	dir := os.Getenv("TASK_SNAPSHOT_DIR")
+	if dir == "" {
+		dir = "./database/sqlite/snapshots"
+	}
+	snapshots := &databaseSqlite.Snapshots{
+		DB:         database,
+		Dir:        dir,
+		KeepDaily:  envInt("TASK_SNAPSHOT_KEEP_DAILY"),
+		KeepWeekly: envInt("TASK_SNAPSHOT_KEEP_WEEKLY"),
+	}
+
+	interval := 24 * time.Hour
+	if value := os.Getenv("TASK_SNAPSHOT_INTERVAL"); value != "" {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil {
+			log.Fatalf("Invalid TASK_SNAPSHOT_INTERVAL %q: %v", value, err)
+		}
+	}
+	if interval > 0 {
+		go snapshots.Schedule(interval)
+	}
+	return snapshots
+}
+
+// loadIdempotencyKeys remembers idempotency keys in store for
+// TASK_IDEMPOTENCY_WINDOW, a day unless it says otherwise.
+func loadIdempotencyKeys(store idempotency.Store) *idempotency.Keys {
+	keys := &idempotency.Keys{Store: store, Window: idempotency.DefaultWindow}
+	if value := os.Getenv("TASK_IDEMPOTENCY_WINDOW"); value != "" {
+		window, err := time.ParseDuration(value)
+		if err != nil || window <= 0 {
+			log.Fatalf("Invalid TASK_IDEMPOTENCY_WINDOW %q", value)
+		}
+		keys.Window = window
+	}
+	return keys
+}
+
+// adminToken is the bearer token clients need for the administration
+// endpoints, which dump and replace the whole database. They are not served
+// at all unless TASK_ADMIN_TOKEN sets one.
+func adminToken() string {
+	token := os.Getenv("TASK_ADMIN_TOKEN")
+	if token == "" {
+		log.Println("TASK_ADMIN_TOKEN is not set, the administration endpoints are disabled")
+	}
+	return token
+}
+
+// eventRelayInterval is how often changes committed to the SQLite database are
+// published to the event stream.
+const eventRelayInterval = 250 * time.Millisecond
+
+// loadEvents keeps the latest TASK_EVENT_LOG_SIZE events for clients of the
+// event stream to resume from, events.DefaultSize unless it says otherwise.
+func loadEvents() *events.Log {
+	return events.NewLog(envInt("TASK_EVENT_LOG_SIZE"))
+}
+
+// v1Deprecated is when v2 was introduced and v1 became deprecated.
+var v1Deprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// apiV1 describes the deprecated first API version. TASK_API_V1_SUNSET
+// (YYYY-MM-DD) announces the date it will be removed.
+func apiV1() route.Version {
+	v1 := route.Version{Name: "v1", Deprecated: v1Deprecated}
+	if value := os.Getenv("TASK_API_V1_SUNSET"); value != "" {
+		sunset, err := time.Parse(time.DateOnly, value)
+		if err != nil {
+			log.Fatalf("Invalid TASK_API_V1_SUNSET %q: %v", value, err)
+		}
+		v1.Sunset = sunset
+	}
+	return v1
+}
+
+func envInt(name string) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid %s %q: %v", name, value, err)
+	}
+	return number
+}
+
+// loadBlobStore keeps attachments in the S3-compatible bucket configured by
+// TASK_S3_* variables, or on the local filesystem otherwise.
+func loadBlobStore() blobstore.Store {
+	endpoint := os.Getenv("TASK_S3_ENDPOINT")
+	if endpoint == "" {
+		dir := os.Getenv("TASK_ATTACHMENTS_DIR")
+		if dir == "" {
+			dir = "./attachments"
+		}
+		return blobstore.NewLocal(dir)
+	}
+
+	region := os.Getenv("TASK_S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &blobstore.S3{
+		Endpoint:  endpoint,
+		Region:    region,
+		Bucket:    os.Getenv("TASK_S3_BUCKET"),
+		AccessKey: os.Getenv("TASK_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("TASK_S3_SECRET_KEY"),
+	}
+}
@@ -0,0 +1,57 @@
+package mentions
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A mention is either @[Full Name] or @name. Inside Markdown code spans and
+// fenced code blocks an @ is literal text, not a mention.
+var (
+	mentionPattern   = regexp.MustCompile(`(?:^|[^\w@])@(?:\[([^\]\n]+)\]|([\p{L}\p{N}_.\-]+))`)
+	codeBlockPattern = regexp.MustCompile("(?ms)^(```|~~~).*?^(```|~~~)[ \t]*$")
+	codeSpanPattern  = regexp.MustCompile("`+[^`\n]*`+")
+)
+
+// Extract returns the distinct mention keys in Markdown content, in order of
+// first appearance. Keys are normalized with Key.
+func Extract(content string) []string {
+	content = codeBlockPattern.ReplaceAllString(content, "")
+	content = codeSpanPattern.ReplaceAllString(content, "")
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := match[1]
+		if name == "" {
+			name = strings.TrimRight(match[2], ".-")
+		}
+		key := Key(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Key normalizes a user name for matching mentions: case and whitespace are
+// ignored, so @johndoe and @[John Doe] both match the user "John Doe", and
+// @émile matches "Émile". Databases fold case for ASCII only, so names are
+// matched with Matches in Go rather than in queries.
+func Key(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+// Matches reports whether one of keys, as returned by Extract, mentions the
+// user called name.
+func Matches(keys []string, name string) bool {
+	key := Key(name)
+	for _, candidate := range keys {
+		if candidate == key {
+			return true
+		}
+	}
+	return false
+}
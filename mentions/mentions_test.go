@@ -0,0 +1,51 @@
+package mentions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"no mentions here", nil},
+		{"@ada please review", []string{"ada"}},
+		{"ping @[John Doe] and @johndoe", []string{"johndoe"}},
+		{"@Ada, @bob. and @carol-", []string{"ada", "bob", "carol"}},
+		{"merci @Émile et @[Zoë Ångström]", []string{"émile", "zoëångström"}},
+		{"mail ada@example.com", nil},
+		{"@@ada", nil},
+		{"see `@ada` but not in code", nil},
+		{"```\n@ada\n```\n@bob", []string{"bob"}},
+		{"~~~go\n@ada\n~~~\n", nil},
+		{"(@ada)", []string{"ada"}},
+		{"@[  ]", nil},
+	}
+	for _, test := range tests {
+		if got := Extract(test.content); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Extract(%q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		content, name string
+		want          bool
+	}{
+		{"@johndoe", "John Doe", true},
+		{"@[john  doe]", "John\tDoe", true},
+		{"@émile", "Émile", true},
+		{"@ÉMILE", "émile", true},
+		{"@[Zoë Ångström]", "ZOË ÅNGSTRÖM", true},
+		{"@emile", "Émile", false},
+		{"@john", "John Doe", false},
+	}
+	for _, test := range tests {
+		if got := Matches(Extract(test.content), test.name); got != test.want {
+			t.Errorf("Matches(Extract(%q), %q) = %v, want %v", test.content, test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package openapi
+
+import (
+	_ "embed"
+	"log"
+	"net/http"
+)
+
+// spec is the OpenAPI 3.1 description of the current API version. It lists
+// the paths relative to /api/v2; the deprecated v1 serves the same paths
+// with the response shapes it had before.
+//
+//go:embed openapi.json
+var spec []byte
+
+// docs renders the spec with Redoc 2.1.5, which is loaded from a CDN. The
+// version is pinned so that the page does not change under us.
+//
+//go:embed docs.html
+var docs []byte
+
+// ServeSpec sends the OpenAPI document.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	serve(w, "application/json", spec)
+}
+
+// ServeDocs sends a page presenting the OpenAPI document for people.
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	serve(w, "text/html; charset=utf-8", docs)
+}
+
+func serve(w http.ResponseWriter, contentType string, content []byte) {
+	w.Header().Set("Content-Type", contentType)
+	if _, err := w.Write(content); err != nil {
+		log.Printf("Error writing response body: %v", err)
+	}
+}
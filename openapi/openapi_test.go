@@ -0,0 +1,348 @@
+package openapi_test
+
+import (
+	"Simple_Task_Manager/blobstore"
+	databaseSqlite "Simple_Task_Manager/database/sqlite"
+	"Simple_Task_Manager/route"
+	routerMongoDB "Simple_Task_Manager/router/mongodb"
+	routerSqlite "Simple_Task_Manager/router/sqlite"
+	taskManagerSqlite "Simple_Task_Manager/task_manager/sqlite"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestResponsesMatchSpec drives the SQLite router through a session and checks
+// every request and response against the spec it serves.
+func TestResponsesMatchSpec(t *testing.T) {
+	handler := newTestRouter(t)
+	spec := loadSpec(t, handler)
+
+	tests := []struct {
+		method, path, body string
+		status             int
+	}{
+		{method: http.MethodPost, path: "/tasks", body: `{"user_name":"ada","task_name":"water plants","due_date":"2024-03-25","time_zone":"UTC"}`, status: http.StatusCreated},
+		{method: http.MethodPost, path: "/tasks", body: `{"user_name":"ada"}`, status: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/tasks", status: http.StatusOK},
+		{method: http.MethodGet, path: "/tasks/1", status: http.StatusOK},
+		{method: http.MethodGet, path: "/tasks/999", status: http.StatusNotFound},
+		{method: http.MethodPatch, path: "/tasks/1?user_id=1", body: `{"status":"in-progress"}`, status: http.StatusOK},
+		{method: http.MethodPatch, path: "/tasks/1?user_id=1", body: `{"status":"someday"}`, status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/tasks/1/comments?user_id=1", body: `{"content":"thanks @ada"}`, status: http.StatusCreated},
+		{method: http.MethodGet, path: "/tasks/1/comments?limit=10", status: http.StatusOK},
+		{method: http.MethodGet, path: "/tasks/1/comments?limit=0", status: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/board?user_id=1", status: http.StatusOK},
+		{method: http.MethodPost, path: "/tasks/1/move?user_id=1", body: `{"status":"done"}`, status: http.StatusOK},
+		{method: http.MethodGet, path: "/tasks/1/history", status: http.StatusOK},
+		{method: http.MethodPost, path: "/tasks/bulk?mode=partial", body: `{"operations":[{"op":"create","user_name":"ada","task_name":"repot","due_date":"tomorrow"},{"op":"delete","task_id":999,"user_id":1}]}`, status: http.StatusOK},
+		{method: http.MethodPost, path: "/tasks/bulk", body: `{"operations":[{"op":"delete","task_id":999,"user_id":1}]}`, status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/templates", status: http.StatusOK},
+		{method: http.MethodGet, path: "/time/totals?user_id=1", status: http.StatusOK},
+		{method: http.MethodDelete, path: "/tasks/1?user_id=1", status: http.StatusNoContent},
+	}
+	for _, test := range tests {
+		name := test.method + " " + test.path
+		operation, err := spec.operation(test.method, strings.SplitN(test.path, "?", 2)[0])
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		// Requests that are meant to be rejected need not match the spec.
+		if test.body != "" && test.status < http.StatusBadRequest {
+			for _, problem := range spec.checkRequest(operation, test.body) {
+				t.Errorf("%s: request %s", name, problem)
+			}
+		}
+
+		request := httptest.NewRequest(test.method, "/api/v2"+test.path, strings.NewReader(test.body))
+		if test.body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, want %d: %s", name, recorder.Code, test.status, recorder.Body)
+			continue
+		}
+		for _, problem := range spec.checkResponse(operation, recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body.Bytes()) {
+			t.Errorf("%s: response %s", name, problem)
+		}
+	}
+}
+
+// TestRoutersServeSpec checks that both routers serve every operation of the
+// spec, apart from paths whose x-backends leave their backend out, which they
+// must not serve at all.
+func TestRoutersServeSpec(t *testing.T) {
+	v1 := route.Version{Name: "v1"}
+	routers := map[string]http.Handler{
+		"sqlite":  (&routerSqlite.App{AdminToken: "s3cret"}).Routes(v1),
+		"mongodb": (&routerMongoDB.App{AdminToken: "s3cret"}).Routes(v1),
+	}
+	spec := loadSpec(t, routers["sqlite"])
+	for backend, handler := range routers {
+		for template, item := range object(spec["paths"]) {
+			path := template
+			for strings.Contains(path, "{") {
+				path = path[:strings.Index(path, "{")] + "1" + path[strings.Index(path, "}")+1:]
+			}
+			// The router answers OPTIONS itself, so no handler runs.
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodOptions, "/api/v2"+path, nil))
+
+			backends := array(object(item)["x-backends"])
+			if backends != nil && !contains(backends, backend) {
+				if recorder.Code != http.StatusNotFound {
+					t.Errorf("%s: serves %s, which the spec leaves to %v", backend, template, backends)
+				}
+				continue
+			}
+			allowed := ", " + recorder.Header().Get("Allow") + ", "
+			for method := range object(item) {
+				if method == "parameters" || strings.HasPrefix(method, "x-") {
+					continue
+				}
+				if !strings.Contains(allowed, ", "+strings.ToUpper(method)+", ") {
+					t.Errorf("%s: does not serve %s %s", backend, strings.ToUpper(method), template)
+				}
+			}
+		}
+	}
+}
+
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	dbManager := databaseSqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "tasks.db"))
+	if err := dbManager.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := dbManager.OpenDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	app := &taskManagerSqlite.App{DB: db, Blobs: blobstore.NewLocal(t.TempDir())}
+	router := &routerSqlite.App{TaskManager: app}
+	return router.Routes(route.Version{Name: "v1", Deprecated: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)})
+}
+
+// document is a decoded OpenAPI document.
+type document map[string]interface{}
+
+func loadSpec(t *testing.T, handler http.Handler) document {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var spec document
+	if err := json.Unmarshal(recorder.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("invalid spec: %v", err)
+	}
+	return spec
+}
+
+// operation returns the operation of the spec serving method on path. Paths
+// without parameters take precedence, as they do in the router.
+func (d document) operation(method, path string) (map[string]interface{}, error) {
+	var found map[string]interface{}
+	fewest := math.MaxInt
+	segments := strings.Split(path, "/")
+	for template, item := range object(d["paths"]) {
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		params := 0
+		for i, part := range parts {
+			if strings.HasPrefix(part, "{") {
+				params++
+			} else if part != segments[i] {
+				params = -1
+				break
+			}
+		}
+		operation := object(object(item)[strings.ToLower(method)])
+		if params >= 0 && operation != nil && params < fewest {
+			found, fewest = operation, params
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("not in the spec")
+	}
+	return found, nil
+}
+
+func (d document) checkRequest(operation map[string]interface{}, body string) []string {
+	schema := object(object(object(d.resolve(operation["requestBody"])["content"])["application/json"])["schema"])
+	if schema == nil {
+		return []string{"has a body the spec does not describe"}
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(body), &value); err != nil {
+		return []string{err.Error()}
+	}
+	return d.check(schema, value, "body")
+}
+
+func (d document) checkResponse(operation map[string]interface{}, status int, contentType string, body []byte) []string {
+	responses := object(operation["responses"])
+	response, ok := responses[strconv.Itoa(status)]
+	if !ok {
+		response = responses["default"]
+	}
+	content := object(d.resolve(response)["content"])
+	if len(content) == 0 {
+		if len(body) > 0 {
+			return []string{"has a body the spec does not describe"}
+		}
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	media, ok := content[mediaType]
+	if !ok {
+		return []string{fmt.Sprintf("Content-Type %q is not in the spec", contentType)}
+	}
+	schema := object(object(media)["schema"])
+	if schema == nil {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{err.Error()}
+	}
+	return d.check(schema, value, "body")
+}
+
+// check validates value against schema and describes every mismatch. It
+// knows the keywords the spec uses to describe shapes, and ignores the
+// others, such as format and maxLength.
+func (d document) check(schema map[string]interface{}, value interface{}, at string) []string {
+	schema = d.resolve(schema)
+	var problems []string
+	for _, sub := range array(schema["allOf"]) {
+		problems = append(problems, d.check(object(sub), value, at)...)
+	}
+	if oneOf := array(schema["oneOf"]); oneOf != nil {
+		matches := 0
+		for _, sub := range oneOf {
+			if len(d.check(object(sub), value, at)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			problems = append(problems, fmt.Sprintf("%s matches %d of the oneOf schemas", at, matches))
+		}
+	}
+	if enum := array(schema["enum"]); enum != nil && !contains(enum, value) {
+		problems = append(problems, fmt.Sprintf("%s is %v, not one of %v", at, value, enum))
+	}
+	if types, ok := schema["type"]; ok && !typeMatches(types, value) {
+		return append(problems, fmt.Sprintf("%s is %s, want %v", at, typeOf(value), types))
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		properties := object(schema["properties"])
+		for _, name := range array(schema["required"]) {
+			if _, ok := value[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s lacks %s", at, name))
+			}
+		}
+		for name, property := range value {
+			if sub, ok := properties[name]; ok {
+				problems = append(problems, d.check(object(sub), property, at+"."+name)...)
+			} else if additional, ok := schema["additionalProperties"]; ok {
+				if additional == false {
+					problems = append(problems, fmt.Sprintf("%s has %s, which the spec does not describe", at, name))
+				} else if sub := object(additional); sub != nil {
+					problems = append(problems, d.check(sub, property, at+"."+name)...)
+				}
+			}
+		}
+	case []interface{}:
+		if items := object(schema["items"]); items != nil {
+			for i, item := range value {
+				problems = append(problems, d.check(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	}
+	return problems
+}
+
+// resolve follows the $ref of node, if it has one, within the document.
+func (d document) resolve(node interface{}) map[string]interface{} {
+	resolved := object(node)
+	for resolved != nil {
+		ref, ok := resolved["$ref"].(string)
+		if !ok {
+			return resolved
+		}
+		var target interface{} = map[string]interface{}(d)
+		for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			target = object(target)[name]
+		}
+		resolved = object(target)
+	}
+	return resolved
+}
+
+func typeMatches(types, value interface{}) bool {
+	actual := typeOf(value)
+	if names, ok := types.([]interface{}); ok {
+		for _, name := range names {
+			if name == actual || name == "number" && actual == "integer" {
+				return true
+			}
+		}
+		return false
+	}
+	return types == actual || types == "number" && actual == "integer"
+}
+
+func typeOf(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func object(node interface{}) map[string]interface{} {
+	value, _ := node.(map[string]interface{})
+	return value
+}
+
+func array(node interface{}) []interface{} {
+	value, _ := node.([]interface{})
+	return value
+}
@@ -0,0 +1,68 @@
+package problem
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// PlainText serves handler to clients written before problem details, which
+// expect errors as the plain-text message http.Error sends. The detail of a
+// problem is sent that way instead.
+func PlainText(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &plainTextWriter{ResponseWriter: w}
+		handler.ServeHTTP(writer, r)
+		writer.finish()
+	})
+}
+
+type plainTextWriter struct {
+	http.ResponseWriter
+	status  int
+	problem *bytes.Buffer
+}
+
+func (w *plainTextWriter) WriteHeader(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if w.Header().Get("Content-Type") == ContentType {
+		w.problem = &bytes.Buffer{}
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *plainTextWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.problem != nil {
+		return w.problem.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *plainTextWriter) Flush() {
+	if w.problem == nil {
+		http.NewResponseController(w.ResponseWriter).Flush()
+	}
+}
+
+func (w *plainTextWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *plainTextWriter) finish() {
+	if w.problem == nil {
+		return
+	}
+	var problem Error
+	if err := json.Unmarshal(w.problem.Bytes(), &problem); err != nil {
+		problem.Detail = http.StatusText(w.status)
+	}
+	w.Header().Del("Content-Type")
+	http.Error(w.ResponseWriter, problem.Detail, w.status)
+}
@@ -0,0 +1,54 @@
+package problem
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPlainText(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "problem",
+			handler:     func(w http.ResponseWriter, r *http.Request) { Write(w, NotFound("task_not_found", "Task not found")) },
+			status:      http.StatusNotFound,
+			contentType: "text/plain; charset=utf-8",
+			body:        "Task not found\n",
+		},
+		{
+			name: "malformed problem",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", ContentType)
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte("{"))
+			},
+			status:      http.StatusBadGateway,
+			contentType: "text/plain; charset=utf-8",
+			body:        "Bad Gateway\n",
+		},
+		{
+			name: "success",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"task_id":1}`))
+			},
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"task_id":1}`,
+		},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		PlainText(test.handler).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+		if recorder.Code != test.status || recorder.Header().Get("Content-Type") != test.contentType || recorder.Body.String() != test.body {
+			t.Errorf("%s: %d %q %q, want %d %q %q", test.name, recorder.Code, recorder.Header().Get("Content-Type"), recorder.Body,
+				test.status, test.contentType, test.body)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const ContentType = "application/problem+json"
+
+// Error is an error reported to the client as RFC 7807 problem details. Code
+// is a stable, machine-readable name for the problem, so clients need not
+// match Detail, which is meant for people and may change. Type is derived
+// from Code.
+type Error struct {
+	Type   string  `json:"type"`
+	Title  string  `json:"title"`
+	Status int     `json:"status"`
+	Detail string  `json:"detail,omitempty"`
+	Code   string  `json:"code"`
+	Errors []Field `json:"errors,omitempty"`
+}
+
+// Field is a problem with one field of a request.
+type Field struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+var (
+	ErrInternal         = Internal("Internal server error")
+	ErrMethodNotAllowed = New(http.StatusMethodNotAllowed, "method_not_allowed", "Method not allowed")
+	ErrNotFound         = New(http.StatusNotFound, "not_found", "Not found")
+)
+
+func New(status int, code, detail string) *Error {
+	return &Error{Type: "/problems/" + code, Title: http.StatusText(status), Status: status, Detail: detail, Code: code}
+}
+
+// Internal reports a failure of the server. Its detail only says what failed,
+// never why.
+func Internal(detail string) *Error {
+	return New(http.StatusInternalServerError, "internal_error", detail)
+}
+
+func BadRequest(code, detail string) *Error {
+	return New(http.StatusBadRequest, code, detail)
+}
+
+func Forbidden(code, detail string) *Error {
+	return New(http.StatusForbidden, code, detail)
+}
+
+func NotFound(code, detail string) *Error {
+	return New(http.StatusNotFound, code, detail)
+}
+
+func Conflict(code, detail string) *Error {
+	return New(http.StatusConflict, code, detail)
+}
+
+// Invalid reports fields of a request that are missing or malformed.
+func Invalid(detail string, fields ...Field) *Error {
+	err := BadRequest("invalid_request", detail)
+	err.Errors = fields
+	return err
+}
+
+// InvalidFields reports several invalid fields at once, with their messages
+// joined as the detail.
+func InvalidFields(fields ...Field) *Error {
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = field.Message
+	}
+	return Invalid(strings.Join(messages, "; "), fields...)
+}
+
+// Required reports a missing field.
+func Required(field, detail string) *Error {
+	return Invalid(detail, Field{Field: field, Code: "required", Message: detail})
+}
+
+// Malformed reports a field whose value cannot be used.
+func Malformed(field, detail string) *Error {
+	return Invalid(detail, Field{Field: field, Code: "invalid", Message: detail})
+}
+
+// MissingParameter and InvalidParameter report query parameters.
+func MissingParameter(name string) *Error {
+	return Required(name, "Missing "+name+" parameter")
+}
+
+func InvalidParameter(name string) *Error {
+	return Malformed(name, "Invalid "+name+" parameter")
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// Write sends err as problem details. Errors other than *Error are not shown
+// to the client, which gets an internal server error instead.
+func Write(w http.ResponseWriter, err error) {
+	var problem *Error
+	if !errors.As(err, &problem) {
+		problem = ErrInternal
+	}
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Type", ContentType)
+	header.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	_ = json.NewEncoder(w).Encode(problem)
+}
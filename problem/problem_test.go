@@ -0,0 +1,64 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Error
+	}{
+		{
+			name: "problem",
+			err:  NotFound("task_not_found", "Task not found"),
+			want: Error{Type: "/problems/task_not_found", Title: "Not Found", Status: http.StatusNotFound, Detail: "Task not found", Code: "task_not_found"},
+		},
+		{
+			name: "wrapped problem",
+			err:  fmt.Errorf("loading task: %w", Conflict("version_mismatch", "Task was changed")),
+			want: Error{Type: "/problems/version_mismatch", Title: "Conflict", Status: http.StatusConflict, Detail: "Task was changed", Code: "version_mismatch"},
+		},
+		{
+			name: "missing parameter",
+			err:  MissingParameter("user_id"),
+			want: Error{Type: "/problems/invalid_request", Title: "Bad Request", Status: http.StatusBadRequest, Detail: "Missing user_id parameter", Code: "invalid_request",
+				Errors: []Field{{Field: "user_id", Code: "required", Message: "Missing user_id parameter"}}},
+		},
+		{
+			name: "invalid fields",
+			err:  InvalidFields(Field{Field: "due_date", Code: "invalid", Message: "Invalid due date"}, Field{Field: "status", Code: "invalid", Message: "Unknown status"}),
+			want: Error{Type: "/problems/invalid_request", Title: "Bad Request", Status: http.StatusBadRequest, Detail: "Invalid due date; Unknown status", Code: "invalid_request",
+				Errors: []Field{{Field: "due_date", Code: "invalid", Message: "Invalid due date"}, {Field: "status", Code: "invalid", Message: "Unknown status"}}},
+		},
+		{
+			name: "other error",
+			err:  errors.New("database is locked"),
+			want: Error{Type: "/problems/internal_error", Title: "Internal Server Error", Status: http.StatusInternalServerError, Detail: "Internal server error", Code: "internal_error"},
+		},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		recorder.Header().Set("Content-Length", "12")
+		Write(recorder, test.err)
+
+		var got Error
+		if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if recorder.Code != test.want.Status || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: wrote %d %+v, want %+v", test.name, recorder.Code, got, test.want)
+		}
+		header := recorder.Header()
+		if header.Get("Content-Type") != ContentType || header.Get("X-Content-Type-Options") != "nosniff" || header.Get("Content-Length") != "" {
+			t.Errorf("%s: headers %v", test.name, header)
+		}
+	}
+}
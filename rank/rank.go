@@ -0,0 +1,101 @@
+package rank
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Ranks are fractional indexes: base-62 strings that compare in byte order and
+// never end in the smallest digit, so another rank always fits between two
+// different ones. Moving an item only rewrites that item's rank.
+const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalidRank = errors.New("invalid rank")
+
+// Between returns a rank that sorts strictly after a and strictly before b.
+// An empty a means the start of the list, an empty b its end.
+func Between(a, b string) (string, error) {
+	if err := validate(a); err != nil {
+		return "", err
+	}
+	if err := validate(b); err != nil {
+		return "", err
+	}
+	if b != "" && a >= b {
+		return "", fmt.Errorf("%w: %q is not before %q", ErrInvalidRank, a, b)
+	}
+	return midpoint(a, b), nil
+}
+
+// After returns a rank that sorts after a, for appending to a list whose last
+// rank is a. It increments the last digit of a that is not the largest,
+// dropping the digits after it, so appending keeps ranks short; only a rank
+// made of largest digits alone is extended.
+func After(a string) (string, error) {
+	if err := validate(a); err != nil {
+		return "", err
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		if d := strings.IndexByte(digits, a[i]); d < len(digits)-1 {
+			return a[:i] + string(digits[d+1]), nil
+		}
+	}
+	return a + midpoint("", ""), nil
+}
+
+func midpoint(a, b string) string {
+	if b != "" {
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if len(a) > n {
+				rest = a[n:]
+			}
+			return b[:n] + midpoint(rest, b[n:])
+		}
+	}
+
+	low := 0
+	if a != "" {
+		low = strings.IndexByte(digits, a[0])
+	}
+	high := len(digits)
+	if b != "" {
+		high = strings.IndexByte(digits, b[0])
+	}
+	if high-low > 1 {
+		return string(digits[(low+high+1)/2])
+	}
+	if b != "" && len(b) > 1 {
+		return b[:1]
+	}
+
+	rest := ""
+	if len(a) > 1 {
+		rest = a[1:]
+	}
+	return string(digits[low]) + midpoint(rest, "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return digits[0]
+}
+
+func validate(r string) error {
+	for i := 0; i < len(r); i++ {
+		if strings.IndexByte(digits, r[i]) < 0 {
+			return fmt.Errorf("%w: %q contains %q", ErrInvalidRank, r, r[i])
+		}
+	}
+	if strings.HasSuffix(r, digits[:1]) {
+		return fmt.Errorf("%w: %q ends in %q", ErrInvalidRank, r, digits[0])
+	}
+	return nil
+}
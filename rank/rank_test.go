@@ -0,0 +1,94 @@
+package rank
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"", ""},
+		{"", "V"},
+		{"V", ""},
+		{"A", "B"},
+		{"A", "A1"},
+		{"A1", "A2"},
+		{"Az", "B"},
+		{"", "01"},
+		{"y", "z"},
+		{"zzz", ""},
+	}
+	for _, test := range tests {
+		got, err := Between(test.a, test.b)
+		if err != nil {
+			t.Errorf("Between(%q, %q) error: %v", test.a, test.b, err)
+			continue
+		}
+		if got <= test.a || (test.b != "" && got >= test.b) {
+			t.Errorf("Between(%q, %q) = %q, not between them", test.a, test.b, got)
+		}
+		if err := validate(got); err != nil {
+			t.Errorf("Between(%q, %q) = %q: %v", test.a, test.b, got, err)
+		}
+	}
+}
+
+func TestBetweenInvalid(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"B", "A"},
+		{"A", "A"},
+		{"A0", ""},
+		{"", "A-"},
+	}
+	for _, test := range tests {
+		if _, err := Between(test.a, test.b); !errors.Is(err, ErrInvalidRank) {
+			t.Errorf("Between(%q, %q) error = %v, want ErrInvalidRank", test.a, test.b, err)
+		}
+	}
+}
+
+func TestAfter(t *testing.T) {
+	tests := []struct {
+		a, want string
+	}{
+		{"", "V"},
+		{"V", "W"},
+		{"9", "A"},
+		{"Z", "a"},
+		{"A5", "A6"},
+		{"Az", "B"},
+		{"Azz", "B"},
+		{"z", "zV"},
+		{"zz", "zzV"},
+	}
+	for _, test := range tests {
+		got, err := After(test.a)
+		if err != nil || got != test.want {
+			t.Errorf("After(%q) = %q, %v, want %q", test.a, got, err, test.want)
+		}
+	}
+	if _, err := After("A0"); !errors.Is(err, ErrInvalidRank) {
+		t.Errorf("After(%q) error = %v, want ErrInvalidRank", "A0", err)
+	}
+}
+
+func TestAfterStaysShort(t *testing.T) {
+	r := ""
+	for i := 0; i < 1000; i++ {
+		next, err := After(r)
+		if err != nil {
+			t.Fatalf("After(%q) error: %v", r, err)
+		}
+		if next <= r {
+			t.Fatalf("After(%q) = %q, not after it", r, next)
+		}
+		r = next
+	}
+	if len(r) > 40 {
+		t.Errorf("rank after 1000 appends is %d digits long", len(r))
+	}
+}
@@ -0,0 +1,321 @@
+package recurrence
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+const (
+	untilLayout    = "20060102T150405Z"
+	untilDayLayout = "20060102"
+)
+
+// searchLimit bounds the number of candidate days inspected when looking for
+// the next occurrence, so a rule that can never match does not loop forever.
+const searchLimit = 366 * 8
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule is the subset of an iCalendar RRULE (RFC 5545) supported by the task
+// manager: FREQ, INTERVAL, COUNT, UNTIL, BYDAY and BYMONTHDAY.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []time.Weekday
+	ByMonthDay []int
+}
+
+func Parse(rule string) (*Rule, error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRule)
+	}
+
+	r := &Rule{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if !found || value == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRule, part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRule, value)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("%w: invalid INTERVAL %q", ErrInvalidRule, value)
+			}
+			r.Interval = interval
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("%w: invalid COUNT %q", ErrInvalidRule, value)
+			}
+			r.Count = count
+		case "UNTIL":
+			until, err := time.Parse(untilLayout, value)
+			if err != nil {
+				until, err = time.Parse(untilDayLayout, value)
+				if err != nil {
+					return nil, fmt.Errorf("%w: invalid UNTIL %q", ErrInvalidRule, value)
+				}
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRule, day)
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				monthDay, err := strconv.Atoi(day)
+				if err != nil || monthDay < 1 || monthDay > 31 {
+					return nil, fmt.Errorf("%w: invalid BYMONTHDAY %q", ErrInvalidRule, day)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, monthDay)
+			}
+		case "WKST":
+			if strings.ToUpper(value) != "MO" {
+				return nil, fmt.Errorf("%w: only WKST=MO is supported", ErrInvalidRule)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %q", ErrInvalidRule, key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, fmt.Errorf("%w: missing FREQ", ErrInvalidRule)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL are mutually exclusive", ErrInvalidRule)
+	}
+	return r, nil
+}
+
+// String renders the rule back into RRULE syntax.
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilLayout))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			for name, day := range weekdays {
+				if day == weekday {
+					days = append(days, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence strictly after current. Occurrences keep
+// the wall-clock time of current in its location, so a task due at 09:00
+// Europe/Berlin stays at 09:00 across DST changes. The second return value is
+// false once the rule is exhausted.
+func (r *Rule) Next(current time.Time) (time.Time, bool) {
+	if r.Count == 1 {
+		return time.Time{}, false
+	}
+
+	var next time.Time
+	var found bool
+	switch r.Freq {
+	case Daily:
+		next, found = r.nextDaily(current)
+	case Weekly:
+		next, found = r.nextWeekly(current)
+	case Monthly:
+		next, found = r.nextMonthly(current)
+	case Yearly:
+		next, found = r.nextYearly(current)
+	}
+	if !found {
+		return time.Time{}, false
+	}
+	if !r.Until.IsZero() && next.After(r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// Advance returns the rule that applies to the occurrence following the
+// current one. Only COUNT changes, since it counts the remaining occurrences.
+func (r *Rule) Advance() *Rule {
+	next := *r
+	if next.Count > 1 {
+		next.Count--
+	}
+	return &next
+}
+
+func (r *Rule) nextDaily(current time.Time) (time.Time, bool) {
+	if len(r.ByDay) == 0 {
+		return onDay(current, current.Year(), current.Month(), current.Day()+r.Interval), true
+	}
+	for i := 1; i <= searchLimit; i++ {
+		candidate := onDay(current, current.Year(), current.Month(), current.Day()+i)
+		if i%r.Interval == 0 && r.matchesWeekday(candidate) {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r *Rule) nextWeekly(current time.Time) (time.Time, bool) {
+	if len(r.ByDay) == 0 {
+		return onDay(current, current.Year(), current.Month(), current.Day()+7*r.Interval), true
+	}
+	startWeek := startOfWeek(current)
+	for i := 1; i <= searchLimit; i++ {
+		candidate := onDay(current, current.Year(), current.Month(), current.Day()+i)
+		weeks := daysBetween(startWeek, startOfWeek(candidate)) / 7
+		if weeks%r.Interval == 0 && r.matchesWeekday(candidate) {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r *Rule) nextMonthly(current time.Time) (time.Time, bool) {
+	monthDays := r.ByMonthDay
+	if len(monthDays) == 0 {
+		monthDays = []int{current.Day()}
+	}
+	for i := 1; i <= searchLimit; i++ {
+		candidate := onDay(current, current.Year(), current.Month(), current.Day()+i)
+		months := (candidate.Year()-current.Year())*12 + int(candidate.Month()-current.Month())
+		if months%r.Interval != 0 || !containsInt(monthDays, candidate.Day()) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !r.matchesWeekday(candidate) {
+			continue
+		}
+		return candidate, true
+	}
+	return time.Time{}, false
+}
+
+func (r *Rule) nextYearly(current time.Time) (time.Time, bool) {
+	for year := current.Year() + r.Interval; year <= current.Year()+searchLimit/366*r.Interval; year += r.Interval {
+		candidate := onDay(current, year, current.Month(), current.Day())
+		// Skip years in which the day does not exist, e.g. 29 February.
+		if candidate.Day() == current.Day() {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	for _, weekday := range r.ByDay {
+		if t.Weekday() == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func onDay(clock time.Time, year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), clock.Location())
+}
+
+func startOfWeek(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that a rule and time zone pair can be used for a recurring
+// task.
+func Validate(rule, timeZone string) error {
+	if _, err := Parse(rule); err != nil {
+		return err
+	}
+	if _, err := time.LoadLocation(timeZone); err != nil {
+		return fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+	return nil
+}
+
+// NextDueDate resolves the due date of the occurrence that follows dueDate,
+// evaluating the rule on the wall clock of timeZone. It also returns the rule
+// to store on the next occurrence, and false once the rule is exhausted.
+func NextDueDate(rule, timeZone string, dueDate time.Time) (time.Time, string, bool, error) {
+	r, err := Parse(rule)
+	if err != nil {
+		return time.Time{}, "", false, err
+	}
+	location, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return time.Time{}, "", false, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+	}
+
+	next, ok := r.Next(dueDate.In(location))
+	if !ok {
+		return time.Time{}, "", false, nil
+	}
+	return next.UTC(), r.Advance().String(), true, nil
+}
@@ -0,0 +1,100 @@
+package recurrence
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule, want string
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly;interval=2;byday=mo,fr", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15;COUNT=3", "FREQ=MONTHLY;COUNT=3;BYMONTHDAY=1,15"},
+		{"FREQ=YEARLY;UNTIL=20301231", "FREQ=YEARLY;UNTIL=20301231T235959Z"},
+		{"FREQ=DAILY;INTERVAL=1;WKST=MO", "FREQ=DAILY"},
+	}
+	for _, test := range tests {
+		r, err := Parse(test.rule)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", test.rule, err)
+			continue
+		}
+		if got := r.String(); got != test.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", test.rule, got, test.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"RRULE:",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20300101",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=WEEKLY;WKST=SU",
+		"FREQ=DAILY;BYHOUR=9",
+		"FREQ",
+	} {
+		if _, err := Parse(rule); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidRule", rule, err)
+		}
+	}
+}
+
+func TestNextDueDate(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	tests := []struct {
+		name     string
+		rule     string
+		timeZone string
+		due      string
+		want     string
+		wantRule string
+	}{
+		{"daily", "FREQ=DAILY", "UTC", "2024-03-25T09:00:00Z", "2024-03-26T09:00:00Z", "FREQ=DAILY"},
+		{"every other day", "FREQ=DAILY;INTERVAL=2", "UTC", "2024-02-28T09:00:00Z", "2024-03-01T09:00:00Z", "FREQ=DAILY;INTERVAL=2"},
+		{"weekdays from friday", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", "UTC", "2024-03-22T09:00:00Z", "2024-03-25T09:00:00Z", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR"},
+		{"weekly", "FREQ=WEEKLY", "UTC", "2024-03-25T09:00:00Z", "2024-04-01T09:00:00Z", "FREQ=WEEKLY"},
+		{"biweekly on monday and thursday", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", "UTC", "2024-03-28T09:00:00Z", "2024-04-08T09:00:00Z", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"monthly on the 31st skips short months", "FREQ=MONTHLY", "UTC", "2024-01-31T09:00:00Z", "2024-03-31T09:00:00Z", "FREQ=MONTHLY"},
+		{"monthly on listed days", "FREQ=MONTHLY;BYMONTHDAY=1,15", "UTC", "2024-03-15T09:00:00Z", "2024-04-01T09:00:00Z", "FREQ=MONTHLY;BYMONTHDAY=1,15"},
+		{"yearly on leap day", "FREQ=YEARLY", "UTC", "2024-02-29T09:00:00Z", "2028-02-29T09:00:00Z", "FREQ=YEARLY"},
+		{"count counts down", "FREQ=DAILY;COUNT=3", "UTC", "2024-03-25T09:00:00Z", "2024-03-26T09:00:00Z", "FREQ=DAILY;COUNT=2"},
+		{"keeps wall clock across DST", "FREQ=DAILY", "Europe/Berlin", "2024-03-30T08:00:00Z", "2024-03-31T07:00:00Z", "FREQ=DAILY"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			due, _ := time.Parse(time.RFC3339, test.due)
+			next, rule, ok, err := NextDueDate(test.rule, test.timeZone, due)
+			if err != nil || !ok {
+				t.Fatalf("NextDueDate = %v, %v", ok, err)
+			}
+			if got := next.Format(time.RFC3339); got != test.want || rule != test.wantRule {
+				t.Errorf("NextDueDate = %s, %q, want %s, %q", got, rule, test.want, test.wantRule)
+			}
+		})
+	}
+}
+
+func TestNextDueDateExhausted(t *testing.T) {
+	due := time.Date(2024, 3, 25, 9, 0, 0, 0, time.UTC)
+	for _, rule := range []string{"FREQ=DAILY;COUNT=1", "FREQ=DAILY;UNTIL=20240325", "FREQ=WEEKLY;UNTIL=20240331T000000Z"} {
+		if _, _, ok, err := NextDueDate(rule, "UTC", due); ok || err != nil {
+			t.Errorf("NextDueDate(%q) = %v, %v, want exhausted", rule, ok, err)
+		}
+	}
+	if _, _, _, err := NextDueDate("FREQ=DAILY", "Mars/Olympus_Mons", due); err == nil {
+		t.Errorf("NextDueDate with an unknown time zone succeeded")
+	}
+}
@@ -0,0 +1,77 @@
+package respond
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Created sends a resource a request created, with the path it can be
+// retrieved from in the Location header. Clients served by Legacy get the
+// plain-text message instead.
+func Created(w http.ResponseWriter, location string, resource interface{}, message string) {
+	w.Header().Set("Location", location)
+	send(w, http.StatusCreated, resource, message)
+}
+
+// Updated sends a resource as it is after a request changed it.
+func Updated(w http.ResponseWriter, resource interface{}, message string) {
+	send(w, http.StatusOK, resource, message)
+}
+
+// Deleted answers a request that removed a resource with no content.
+func Deleted(w http.ResponseWriter, message string) {
+	if legacy(w) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(message))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func send(w http.ResponseWriter, status int, resource interface{}, message string) {
+	if legacy(w) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(message))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resource); err != nil {
+		log.Printf("Error encoding response to JSON: %v", err)
+	}
+}
+
+// Legacy serves handler to clients written before responses carried the
+// resources they created or changed. Those expect a plain-text message such
+// as "Task created successfully" and 200 for deletions.
+func Legacy(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(legacyWriter{w}, r)
+	})
+}
+
+type legacyWriter struct {
+	http.ResponseWriter
+}
+
+func (w legacyWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w legacyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func legacy(w http.ResponseWriter) bool {
+	for {
+		if _, ok := w.(legacyWriter); ok {
+			return true
+		}
+		wrapper, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = wrapper.Unwrap()
+	}
+}
@@ -0,0 +1,115 @@
+package respond
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponses(t *testing.T) {
+	type task struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		status      int
+		contentType string
+		location    string
+		body        string
+	}{
+		{
+			name: "created",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				Created(w, "/tasks/7", task{7, "water plants"}, "Task created successfully")
+			},
+			status:      http.StatusCreated,
+			contentType: "application/json",
+			location:    "/tasks/7",
+			body:        `{"id":7,"name":"water plants"}` + "\n",
+		},
+		{
+			name: "updated",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				Updated(w, task{7, "repot cactus"}, "Task updated successfully")
+			},
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        `{"id":7,"name":"repot cactus"}` + "\n",
+		},
+		{
+			name:    "deleted",
+			handler: func(w http.ResponseWriter, r *http.Request) { Deleted(w, "Task deleted successfully") },
+			status:  http.StatusNoContent,
+		},
+		{
+			name:    "unencodable",
+			handler: func(w http.ResponseWriter, r *http.Request) { Updated(w, func() {}, "Task updated successfully") },
+			status:  http.StatusOK, contentType: "application/json",
+		},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		test.handler(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != test.status || recorder.Header().Get("Content-Type") != test.contentType || recorder.Header().Get("Location") != test.location || recorder.Body.String() != test.body {
+			t.Errorf("%s: %d %q %q %q, want %d %q %q %q", test.name, recorder.Code, recorder.Header().Get("Content-Type"), recorder.Header().Get("Location"), recorder.Body, test.status, test.contentType, test.location, test.body)
+		}
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		status   int
+		location string
+		body     string
+	}{
+		{
+			name: "created",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				Created(w, "/tasks/7", struct{}{}, "Task created successfully")
+			},
+			status:   http.StatusCreated,
+			location: "/tasks/7",
+			body:     "Task created successfully",
+		},
+		{
+			name:    "updated",
+			handler: func(w http.ResponseWriter, r *http.Request) { Updated(w, struct{}{}, "Task updated successfully") },
+			status:  http.StatusOK,
+			body:    "Task updated successfully",
+		},
+		{
+			name:    "deleted",
+			handler: func(w http.ResponseWriter, r *http.Request) { Deleted(w, "Task deleted successfully") },
+			status:  http.StatusOK,
+			body:    "Task deleted successfully",
+		},
+		{
+			name: "wrapped writer",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				Deleted(wrapper{w}, "Task deleted successfully")
+			},
+			status: http.StatusOK,
+			body:   "Task deleted successfully",
+		},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		Legacy(test.handler).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != test.status || recorder.Header().Get("Content-Type") == "application/json" || recorder.Header().Get("Location") != test.location || recorder.Body.String() != test.body {
+			t.Errorf("%s: %d %q %q, want %d %q %q", test.name, recorder.Code, recorder.Header().Get("Location"), recorder.Body, test.status, test.location, test.body)
+		}
+	}
+}
+
+// wrapper stands in for middleware that wraps the writer Legacy passes on.
+type wrapper struct {
+	http.ResponseWriter
+}
+
+func (w wrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,318 @@
+package route
+
+import (
+	"Simple_Task_Manager/problem"
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type (
+	paramsKey struct{}
+	baseKey   struct{}
+)
+
+// Router dispatches requests on their path and method. Patterns are paths
+// whose segments may be parameters such as "/tasks/{task_id}/comments"; a
+// literal segment takes precedence over a parameter, so "/tasks/bulk" wins
+// over "/tasks/{task_id}". A path that matches with another method is
+// answered with 405 and an Allow header, HEAD is served by the GET handler
+// and OPTIONS lists the allowed methods.
+type Router struct {
+	routes []*route
+	mounts []mount
+}
+
+type mount struct {
+	prefix  string
+	handler http.Handler
+}
+
+type route struct {
+	pattern  string
+	segments []string
+	handlers map[string]http.Handler
+}
+
+func New() *Router {
+	return &Router{}
+}
+
+// Handle registers handler for pattern and the given methods.
+func (rt *Router) Handle(pattern string, handler http.Handler, methods ...string) {
+	var target *route
+	for _, existing := range rt.routes {
+		if existing.pattern == pattern {
+			target = existing
+		}
+	}
+	if target == nil {
+		target = &route{pattern: pattern, segments: split(pattern), handlers: make(map[string]http.Handler)}
+		rt.routes = append(rt.routes, target)
+	}
+	for _, method := range methods {
+		target.handlers[method] = handler
+	}
+}
+
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc, methods ...string) {
+	rt.Handle(pattern, handler, methods...)
+}
+
+// Mount serves every path under prefix with handler, which sees the path
+// without the prefix and whose Location headers get it back. Routes take
+// precedence over mounts, and longer prefixes over shorter ones; an empty
+// prefix catches every other path.
+func (rt *Router) Mount(prefix string, handler http.Handler) {
+	rt.mounts = append(rt.mounts, mount{prefix: "/" + strings.Trim(prefix, "/"), handler: handler})
+	sort.SliceStable(rt.mounts, func(i, j int) bool { return len(rt.mounts[i].prefix) > len(rt.mounts[j].prefix) })
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	matched, params := rt.match(split(r.URL.Path))
+	if matched == nil {
+		for _, m := range rt.mounts {
+			rest, ok := strings.CutPrefix(r.URL.Path, m.prefix)
+			if m.prefix == "/" {
+				rest, ok = r.URL.Path, true
+			}
+			if !ok || (rest != "" && rest[0] != '/') {
+				continue
+			}
+			prefix := strings.TrimSuffix(m.prefix, "/")
+			r = r.Clone(context.WithValue(r.Context(), baseKey{}, Base(r)+prefix))
+			r.URL.Path, r.URL.RawPath = "/"+strings.TrimPrefix(rest, "/"), ""
+			m.handler.ServeHTTP(&mountedWriter{ResponseWriter: w, prefix: prefix}, r)
+			return
+		}
+	}
+	if matched == nil {
+		log.Printf("No route for %s", r.URL.Path)
+		problem.Write(w, problem.ErrNotFound)
+		return
+	}
+
+	handler := matched.handlers[r.Method]
+	if handler == nil && r.Method == http.MethodHead && matched.handlers[http.MethodGet] != nil {
+		// Handlers check the method themselves, so they see a GET; the server
+		// still leaves the body out of the response to the HEAD.
+		handler = matched.handlers[http.MethodGet]
+		r = r.Clone(r.Context())
+		r.Method = http.MethodGet
+	}
+	if handler == nil {
+		w.Header().Set("Allow", strings.Join(matched.allowed(), ", "))
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	if len(params) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// mountedWriter prefixes absolute paths in Location headers with the prefix
+// a handler was mounted under.
+type mountedWriter struct {
+	http.ResponseWriter
+	prefix      string
+	wroteHeader bool
+}
+
+func (w *mountedWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		location := w.Header().Get("Location")
+		if strings.HasPrefix(location, "/") && !strings.HasPrefix(location, "//") {
+			w.Header().Set("Location", w.prefix+location)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *mountedWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *mountedWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *mountedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// match returns the most specific route matching the path segments.
+func (rt *Router) match(segments []string) (*route, map[string]string) {
+	var best *route
+	var bestParams map[string]string
+	for _, candidate := range rt.routes {
+		params, ok := candidate.match(segments)
+		if !ok {
+			continue
+		}
+		if best == nil || candidate.moreSpecific(best) {
+			best, bestParams = candidate, params
+		}
+	}
+	return best, bestParams
+}
+
+func (r *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(r.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, segment := range r.segments {
+		if name, ok := parameter(segment); ok {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[name] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// moreSpecific reports whether r has a literal segment where other has a
+// parameter, at the first segment where they differ in kind.
+func (r *route) moreSpecific(other *route) bool {
+	for i, segment := range r.segments {
+		_, isParam := parameter(segment)
+		_, otherIsParam := parameter(other.segments[i])
+		if isParam != otherIsParam {
+			return otherIsParam
+		}
+	}
+	return false
+}
+
+func (r *route) allowed() []string {
+	var methods []string
+	for method := range r.handlers {
+		methods = append(methods, method)
+	}
+	if r.handlers[http.MethodGet] != nil && r.handlers[http.MethodHead] == nil {
+		methods = append(methods, http.MethodHead)
+	}
+	methods = append(methods, http.MethodOptions)
+	sort.Strings(methods)
+	return methods
+}
+
+func parameter(segment string) (string, bool) {
+	if len(segment) > 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// split breaks a path into its segments, ignoring a trailing slash.
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// Base returns the prefixes the request was mounted under, such as /api/v2,
+// for links to other resources of the same API version.
+func Base(r *http.Request) string {
+	prefix, _ := r.Context().Value(baseKey{}).(string)
+	return prefix
+}
+
+// Param returns a path parameter of the route that matched r.
+func Param(r *http.Request, name string) string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
+
+// AsQuery passes the path parameters on to handler as query parameters, so
+// a handler written for the query-string form serves a resource path too.
+// Path parameters replace query parameters of the same name.
+func AsQuery(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+		if len(params) > 0 {
+			query := r.URL.Query()
+			for name, value := range params {
+				query.Set(name, value)
+			}
+			r = r.Clone(r.Context())
+			r.URL.RawQuery = query.Encode()
+		}
+		handler(w, r)
+	}
+}
+
+// Deprecated marks responses to the query-string form of an endpoint, which
+// is kept for existing clients. successors are the patterns of the resource
+// paths replacing it; the first one whose parameters are all in the query is
+// filled in and linked. Requests none of them fits, such as a plain list of
+// tasks, are not deprecated and pass unmarked.
+func Deprecated(handler http.HandlerFunc, successors ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		for _, successor := range successors {
+			if link, ok := fill(successor, query); ok {
+				// A deprecated API version has already said since when.
+				if w.Header().Get("Deprecation") == "" {
+					w.Header().Set("Deprecation", "true")
+				}
+				w.Header().Add("Link", "<"+Base(r)+link+`>; rel="successor-version"`)
+				break
+			}
+		}
+		handler(w, r)
+	}
+}
+
+// RequireToken serves handler only to requests carrying token as a bearer
+// token in their Authorization header and answers the others with 401.
+func RequireToken(token string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			problem.Write(w, problem.New(http.StatusUnauthorized, "unauthorized", "A valid admin token is required"))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func fill(pattern string, query url.Values) (string, bool) {
+	segments := split(pattern)
+	for i, segment := range segments {
+		if name, ok := parameter(segment); ok {
+			value := query.Get(name)
+			if value == "" {
+				return "", false
+			}
+			segments[i] = url.PathEscape(value)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), true
+}
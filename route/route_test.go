@@ -0,0 +1,151 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestRouter() *Router {
+	task := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s task %s", r.Method, Param(r, "task_id"))
+	}
+	comments := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "comments of %s", Param(r, "task_id"))
+	}
+	text := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, body) }
+	}
+
+	v2 := New()
+	v2.HandleFunc("/tasks/{task_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/tasks/"+Param(r, "task_id"))
+		w.WriteHeader(http.StatusCreated)
+	}, http.MethodPost)
+
+	router := New()
+	router.HandleFunc("/tasks", text("tasks"), http.MethodGet, http.MethodPost)
+	router.HandleFunc("/tasks/{task_id}", task, http.MethodGet)
+	router.HandleFunc("/tasks/{task_id}", task, http.MethodPatch)
+	router.HandleFunc("/tasks/{task_id}/comments", comments, http.MethodGet)
+	router.HandleFunc("/tasks/bulk", text("bulk"), http.MethodPost)
+	router.Mount("/api/v2", v2)
+	return router
+}
+
+func TestRouter(t *testing.T) {
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+		allow        string
+		location     string
+	}{
+		{method: http.MethodGet, path: "/tasks", status: http.StatusOK, body: "tasks"},
+		{method: http.MethodGet, path: "/tasks/", status: http.StatusOK, body: "tasks"},
+		{method: http.MethodGet, path: "/tasks/7", status: http.StatusOK, body: "GET task 7"},
+		{method: http.MethodPatch, path: "/tasks/7", status: http.StatusOK, body: "PATCH task 7"},
+		{method: http.MethodHead, path: "/tasks/7", status: http.StatusOK, body: "GET task 7"},
+		{method: http.MethodGet, path: "/tasks/7/comments", status: http.StatusOK, body: "comments of 7"},
+		{method: http.MethodPost, path: "/tasks/bulk", status: http.StatusOK, body: "bulk"},
+		{method: http.MethodGet, path: "/tasks/bulk", status: http.StatusMethodNotAllowed, allow: "OPTIONS, POST"},
+		{method: http.MethodDelete, path: "/tasks/7", status: http.StatusMethodNotAllowed, allow: "GET, HEAD, OPTIONS, PATCH"},
+		{method: http.MethodOptions, path: "/tasks/7", status: http.StatusNoContent, allow: "GET, HEAD, OPTIONS, PATCH"},
+		{method: http.MethodGet, path: "/projects", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/tasks/7/history", status: http.StatusNotFound},
+		{method: http.MethodPost, path: "/api/v2/tasks/7", status: http.StatusCreated, location: "/api/v2/tasks/7"},
+		{method: http.MethodPost, path: "/api/v2x/tasks/7", status: http.StatusNotFound},
+		{method: http.MethodGet, path: "/api/v2/tasks", status: http.StatusNotFound},
+	}
+	router := newTestRouter()
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+		name := test.method + " " + test.path
+		if recorder.Code != test.status {
+			t.Errorf("%s: status %d, want %d", name, recorder.Code, test.status)
+		}
+		if test.body != "" && recorder.Body.String() != test.body {
+			t.Errorf("%s: body %q, want %q", name, recorder.Body, test.body)
+		}
+		if allow := recorder.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s: Allow %q, want %q", name, allow, test.allow)
+		}
+		if location := recorder.Header().Get("Location"); location != test.location {
+			t.Errorf("%s: Location %q, want %q", name, location, test.location)
+		}
+	}
+}
+
+func TestAsQuery(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/tasks/7/comments", want: "task_id=7"},
+		{path: "/tasks/7/comments?limit=5", want: "limit=5&task_id=7"},
+		{path: "/tasks/7/comments?task_id=8", want: "task_id=7"},
+	}
+	router := New()
+	router.HandleFunc("/tasks/{task_id}/comments", AsQuery(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.URL.RawQuery)
+	}), http.MethodGet)
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if recorder.Body.String() != test.want {
+			t.Errorf("%s: handler saw query %q, want %q", test.path, recorder.Body, test.want)
+		}
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	tests := []struct {
+		path        string
+		deprecation string
+		link        string
+	}{
+		{path: "/api/v1/tasks/comments?comment_id=3", deprecation: "true", link: `</api/v1/comments/3>; rel="successor-version"`},
+		{path: "/api/v1/tasks/comments?task_id=a%2Fb", deprecation: "true", link: `</api/v1/tasks/a%2Fb/comments>; rel="successor-version"`},
+		{path: "/api/v1/tasks/comments", deprecation: "", link: ""},
+	}
+	v1 := New()
+	v1.HandleFunc("/tasks/comments", Deprecated(func(w http.ResponseWriter, r *http.Request) {}, "/comments/{comment_id}", "/tasks/{task_id}/comments"), http.MethodGet)
+	router := New()
+	router.Mount("/api/v1", v1)
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if deprecation, link := recorder.Header().Get("Deprecation"), recorder.Header().Get("Link"); deprecation != test.deprecation || link != test.link {
+			t.Errorf("%s: Deprecation %q and Link %q, want %q and %q", test.path, deprecation, link, test.deprecation, test.link)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	tests := []struct {
+		authorization string
+		status        int
+	}{
+		{authorization: "Bearer s3cret", status: http.StatusOK},
+		{authorization: "Bearer wrong", status: http.StatusUnauthorized},
+		{authorization: "s3cret", status: http.StatusUnauthorized},
+		{authorization: "", status: http.StatusUnauthorized},
+	}
+	handler := RequireToken("s3cret", func(w http.ResponseWriter, r *http.Request) {})
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/admin/backup", nil)
+		if test.authorization != "" {
+			r.Header.Set("Authorization", test.authorization)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, r)
+		if recorder.Code != test.status {
+			t.Errorf("Authorization %q: status %d, want %d", test.authorization, recorder.Code, test.status)
+		}
+		if test.status == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("Authorization %q: no WWW-Authenticate challenge", test.authorization)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package route
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Version describes an API version mounted under its own prefix. A version
+// stays current while Deprecated is zero; once deprecated, its responses
+// announce since when in a Deprecation header (RFC 9745) and, if known, the
+// date it stops working in a Sunset header (RFC 8594).
+type Version struct {
+	Name       string
+	Deprecated time.Time
+	Sunset     time.Time
+}
+
+type versionKey struct{}
+
+// Versioned serves handler as version.
+func Versioned(version Version, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !version.Deprecated.IsZero() {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(version.Deprecated.Unix(), 10))
+		}
+		if !version.Sunset.IsZero() {
+			w.Header().Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+		}
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, version.Name)))
+	})
+}
+
+// VersionOf returns the name of the API version serving r.
+func VersionOf(r *http.Request) string {
+	name, _ := r.Context().Value(versionKey{}).(string)
+	return name
+}
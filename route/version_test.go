@@ -0,0 +1,66 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVersioned(t *testing.T) {
+	deprecated := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.FixedZone("CEST", 2*3600))
+	tests := []struct {
+		name        string
+		version     Version
+		deprecation string
+		sunset      string
+	}{
+		{name: "current", version: Version{Name: "v2"}},
+		{name: "deprecated", version: Version{Name: "v1", Deprecated: deprecated}, deprecation: "@1792368000"},
+		{name: "with sunset", version: Version{Name: "v1", Deprecated: deprecated, Sunset: sunset}, deprecation: "@1792368000", sunset: "Tue, 29 Jun 2027 22:00:00 GMT"},
+	}
+	for _, test := range tests {
+		var seen string
+		handler := Versioned(test.version, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = VersionOf(r)
+		}))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+		if seen != test.version.Name {
+			t.Errorf("%s: handler saw version %q, want %q", test.name, seen, test.version.Name)
+		}
+		if deprecation := recorder.Header().Get("Deprecation"); deprecation != test.deprecation {
+			t.Errorf("%s: Deprecation %q, want %q", test.name, deprecation, test.deprecation)
+		}
+		if sunset := recorder.Header().Get("Sunset"); sunset != test.sunset {
+			t.Errorf("%s: Sunset %q, want %q", test.name, sunset, test.sunset)
+		}
+	}
+}
+
+func TestDeprecatedKeepsVersionDeprecation(t *testing.T) {
+	tests := []struct {
+		name        string
+		version     Version
+		deprecation string
+	}{
+		{name: "current version", version: Version{Name: "v2"}, deprecation: "true"},
+		{name: "deprecated version", version: Version{Name: "v1", Deprecated: time.Unix(1792368000, 0)}, deprecation: "@1792368000"},
+	}
+	for _, test := range tests {
+		router := New()
+		router.HandleFunc("/users", Deprecated(func(w http.ResponseWriter, r *http.Request) {}, "/users/{user_id}"), http.MethodGet)
+		recorder := httptest.NewRecorder()
+		Versioned(test.version, router).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users?user_id=1", nil))
+		if deprecation := recorder.Header().Get("Deprecation"); deprecation != test.deprecation {
+			t.Errorf("%s: Deprecation %q, want %q", test.name, deprecation, test.deprecation)
+		}
+	}
+}
+
+func TestVersionOfUnversionedRequest(t *testing.T) {
+	if version := VersionOf(httptest.NewRequest(http.MethodGet, "/tasks", nil)); version != "" {
+		t.Errorf("VersionOf = %q, want none", version)
+	}
+}
@@ -0,0 +1,58 @@
+package routerMongoDB
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/validate"
+	"Simple_Task_Manager/workflow"
+	"log"
+	"net/http"
+)
+
+func (app *App) HandleBoard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	app.TaskManager.GetBoard(w, r.URL.Query().Get("user_id"))
+}
+
+func (app *App) HandleBoardMove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	taskIDStr := query.Get("task_id")
+	userIDStr := query.Get("user_id")
+	if taskIDStr == "" || userIDStr == "" {
+		log.Println("Missing task_id or user_id parameter")
+		problem.Write(w, problem.BadRequest("missing_parameters", "Missing task_id or user_id parameter"))
+		return
+	}
+
+	requestBody := moveTaskRequest{workflow: app.workflow()}
+	if err := validate.Decode(w, r, &requestBody); err != nil {
+		log.Println("Invalid request body:", err)
+		problem.Write(w, err)
+		return
+	}
+
+	app.TaskManager.MoveTask(w, taskIDStr, userIDStr, requestBody.Status, requestBody.AfterTaskID, requestBody.BeforeTaskID)
+}
+
+// moveTaskRequest is the body of a move on the board.
+type moveTaskRequest struct {
+	Status       string `json:"status" validate:"required"`
+	AfterTaskID  string `json:"after_task_id"`
+	BeforeTaskID string `json:"before_task_id"`
+
+	workflow *workflow.Workflow
+}
+
+func (body *moveTaskRequest) Validate() []problem.Field {
+	return validate.Status(body.workflow, "status", body.Status)
+}
@@ -0,0 +1,40 @@
+package routerMongoDB
+
+import (
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/validate"
+	"log"
+	"net/http"
+)
+
+func (app *App) HandleBulkTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	mode, err := bulk.ParseMode(r.URL.Query().Get("mode"))
+	if err != nil {
+		log.Println("Invalid mode parameter:", err)
+		problem.Write(w, problem.InvalidParameter("mode"))
+		return
+	}
+
+	var requestBody struct {
+		Operations []bulk.Operation `json:"operations" validate:"required"`
+	}
+	if err := validate.Decode(w, r, &requestBody); err != nil {
+		log.Println("Invalid request body:", err)
+		problem.Write(w, err)
+		return
+	}
+
+	if len(requestBody.Operations) > bulk.MaxOperations {
+		log.Println("Too many operations in request body")
+		problem.Write(w, problem.New(http.StatusRequestEntityTooLarge, "too_many_operations", "Too many operations in request body"))
+		return
+	}
+	app.TaskManager.BulkTasks(w, mode, requestBody.Operations)
+}
@@ -0,0 +1,122 @@
+package routerMongoDB
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/validate"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func (app *App) HandleComments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		taskID, ok := requireParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		limit, offset, err := parsePagination(query)
+		if err != nil {
+			log.Println("Invalid pagination parameters:", err)
+			problem.Write(w, err)
+			return
+		}
+		app.TaskManager.GetComments(w, taskID, limit, offset)
+	case http.MethodPost:
+		taskID, ok := requireParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		content, ok := decodeCommentContent(w, r)
+		if !ok {
+			return
+		}
+		app.TaskManager.CreateComment(w, taskID, userID, content)
+	case http.MethodPatch:
+		commentID, ok := requireParam(w, query, "comment_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		content, ok := decodeCommentContent(w, r)
+		if !ok {
+			return
+		}
+		app.TaskManager.UpdateComment(w, commentID, userID, content)
+	case http.MethodDelete:
+		commentID, ok := requireParam(w, query, "comment_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.DeleteComment(w, commentID, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+func requireParam(w http.ResponseWriter, query url.Values, name string) (string, bool) {
+	value := query.Get(name)
+	if value == "" {
+		log.Printf("Missing %s parameter", name)
+		problem.Write(w, problem.MissingParameter(name))
+		return "", false
+	}
+	return value, true
+}
+
+// parsePagination reads the limit and offset parameters, reporting each one
+// that is invalid as a problem with that parameter.
+func parsePagination(query url.Values) (int, int, error) {
+	limit, offset := defaultPageSize, 0
+	var fields []problem.Field
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageSize {
+			fields = append(fields, problem.Field{Field: "limit", Code: "invalid", Message: fmt.Sprintf("limit must be an integer between 1 and %d", maxPageSize)})
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			fields = append(fields, problem.Field{Field: "offset", Code: "invalid", Message: "offset must be a non-negative integer"})
+		}
+	}
+	if len(fields) > 0 {
+		return 0, 0, problem.InvalidFields(fields...)
+	}
+	return limit, offset, nil
+}
+
+func decodeCommentContent(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var requestBody struct {
+		Content string `json:"content" validate:"required,max=10000"`
+	}
+	if err := validate.Decode(w, r, &requestBody); err != nil {
+		log.Println("Invalid request body:", err)
+		problem.Write(w, err)
+		return "", false
+	}
+
+	return requestBody.Content, true
+}
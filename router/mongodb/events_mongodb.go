@@ -0,0 +1,21 @@
+package routerMongoDB
+
+import (
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/problem"
+	"log"
+	"net/http"
+)
+
+// HandleEvents streams task changes as Server-Sent Events, optionally only
+// those of a user's tasks or of a project's.
+func (app *App) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	app.TaskManager.StreamEvents(w, r, events.Filter{UserID: query.Get("user_id"), Project: query.Get("project")})
+}
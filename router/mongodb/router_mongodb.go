@@ -1,25 +1,118 @@
 package routerMongoDB
 
 import (
-	"encoding/json"
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/etag"
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/ical"
+	"Simple_Task_Manager/idempotency"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/route"
+	"Simple_Task_Manager/taskcsv"
+	"Simple_Task_Manager/templates"
+	"Simple_Task_Manager/validate"
+	"Simple_Task_Manager/workflow"
+	"io"
 	"log"
 	"net/http"
 )
 
 type TaskHandler interface {
+	Routes(v1 route.Version) *route.Router
 	HandleTasks(w http.ResponseWriter, r *http.Request)
+	HandleUsers(w http.ResponseWriter, r *http.Request)
+	HandleBoard(w http.ResponseWriter, r *http.Request)
+	HandleBoardMove(w http.ResponseWriter, r *http.Request)
+	HandleComments(w http.ResponseWriter, r *http.Request)
+	HandleAttachments(w http.ResponseWriter, r *http.Request)
+	HandleTimer(w http.ResponseWriter, r *http.Request)
+	HandleTimeEntries(w http.ResponseWriter, r *http.Request)
+	HandleTimeTotals(w http.ResponseWriter, r *http.Request)
+	HandleTaskHistory(w http.ResponseWriter, r *http.Request)
+	HandleTaskRevert(w http.ResponseWriter, r *http.Request)
+	HandleTemplates(w http.ResponseWriter, r *http.Request)
+	HandleTemplateInstantiate(w http.ResponseWriter, r *http.Request)
+	HandleBulkTasks(w http.ResponseWriter, r *http.Request)
+	HandleTaskExport(w http.ResponseWriter, r *http.Request)
+	HandleEvents(w http.ResponseWriter, r *http.Request)
+	HandleTaskImport(w http.ResponseWriter, r *http.Request)
+	HandleFeedToken(w http.ResponseWriter, r *http.Request)
+	HandleCalendarFeed(w http.ResponseWriter, r *http.Request)
+	HandleBackup(w http.ResponseWriter, r *http.Request)
+	HandleRestore(w http.ResponseWriter, r *http.Request)
 }
 
 type TaskManager interface {
 	GetTaskByID(w http.ResponseWriter, taskID, userID string)
-	UpdateTask(w http.ResponseWriter, taskID, userID string)
-	DeleteTask(w http.ResponseWriter, taskID, userID string)
-	GetTasks(w http.ResponseWriter)
-	CreateTask(w http.ResponseWriter, userName, taskName, dueDate string)
+	UpdateTask(w http.ResponseWriter, taskID, userID, dueDate, status string, ifMatch etag.Condition)
+	DeleteTask(w http.ResponseWriter, taskID, userID string, ifMatch etag.Condition)
+	GetTasks(w http.ResponseWriter, r *http.Request)
+	CreateTask(w http.ResponseWriter, userName, taskName, dueDate, rrule, timeZone string)
+	GetUserByID(w http.ResponseWriter, userID string)
+	UpdateUserTimeZone(w http.ResponseWriter, userID, timeZone string)
+	GetBoard(w http.ResponseWriter, userID string)
+	MoveTask(w http.ResponseWriter, taskID, userID, status, afterTaskID, beforeTaskID string)
+	GetComments(w http.ResponseWriter, taskID string, limit, offset int)
+	CreateComment(w http.ResponseWriter, taskID, userID, content string)
+	UpdateComment(w http.ResponseWriter, commentID, userID, content string)
+	DeleteComment(w http.ResponseWriter, commentID, userID string)
+	GetAttachments(w http.ResponseWriter, taskID string)
+	CreateAttachment(w http.ResponseWriter, taskID, userID, fileName string, content io.Reader)
+	GetAttachment(w http.ResponseWriter, r *http.Request, attachmentID string)
+	DeleteAttachment(w http.ResponseWriter, attachmentID, userID string)
+	StartTimer(w http.ResponseWriter, taskID, userID string)
+	StopTimer(w http.ResponseWriter, userID string)
+	GetRunningTimer(w http.ResponseWriter, userID string)
+	CreateTimeEntry(w http.ResponseWriter, taskID, userID, startedAt, endedAt, note string)
+	DeleteTimeEntry(w http.ResponseWriter, entryID, userID string)
+	GetTimeEntries(w http.ResponseWriter, taskID, userID, from, to, format string)
+	GetTimeTotals(w http.ResponseWriter, groupBy, taskID, userID, from, to string)
+	GetTaskHistory(w http.ResponseWriter, taskID string)
+	RevertTask(w http.ResponseWriter, taskID, userID string, version int)
+	GetTemplates(w http.ResponseWriter, templateID string)
+	CreateTemplate(w http.ResponseWriter, userID string, template templates.Template)
+	UpdateTemplate(w http.ResponseWriter, templateID, userID string, template templates.Template)
+	DeleteTemplate(w http.ResponseWriter, templateID, userID string)
+	InstantiateTemplate(w http.ResponseWriter, templateID, userID string, instances []map[string]string)
+	BulkTasks(w http.ResponseWriter, mode bulk.Mode, operations []bulk.Operation)
+	ExportTasks(w http.ResponseWriter, format string, userID string)
+	StreamEvents(w http.ResponseWriter, r *http.Request, filter events.Filter)
+	ImportTasks(w http.ResponseWriter, mode bulk.Mode, rows []taskcsv.ImportRow)
+	CreateFeedToken(w http.ResponseWriter, userID string, feedPath string)
+	DeleteFeedToken(w http.ResponseWriter, userID string)
+	GetCalendarFeed(w http.ResponseWriter, token string, component ical.Component)
+	Backup(w http.ResponseWriter, includeBlobs bool)
+	Restore(w http.ResponseWriter, body io.Reader)
 }
 
+// App serves the API. Request bodies naming a status are checked against
+// Workflow, or the default workflow if it is nil, which should be the one the
+// TaskManager uses. The administration endpoints are only served if
+// AdminToken is set, to requests carrying it as a bearer token.
 type App struct {
 	TaskManager TaskManager
+	Idempotency *idempotency.Keys
+	Workflow    *workflow.Workflow
+	AdminToken  string
+}
+
+func (app *App) workflow() *workflow.Workflow {
+	if app.Workflow != nil {
+		return app.Workflow
+	}
+	return workflow.Default()
+}
+
+// updateTaskRequest is the body of a task update.
+type updateTaskRequest struct {
+	DueDate string `json:"due_date" validate:"date"`
+	Status  string `json:"status"`
+
+	workflow *workflow.Workflow
+}
+
+func (body *updateTaskRequest) Validate() []problem.Field {
+	return validate.Status(body.workflow, "status", body.Status)
 }
 
 func (app *App) HandleTasks(w http.ResponseWriter, r *http.Request) {
@@ -35,48 +128,79 @@ func (app *App) HandleTasks(w http.ResponseWriter, r *http.Request) {
 			userIDStr := vars.Get("user_id")
 			if userIDStr == "" {
 				log.Println("Missing user_id parameter")
-				http.Error(w, "Missing user_id parameter", http.StatusBadRequest)
+				problem.Write(w, problem.MissingParameter("user_id"))
 				return
 			}
-			app.TaskManager.UpdateTask(w, taskIDStr, userIDStr)
+			requestBody := updateTaskRequest{workflow: app.workflow()}
+			if err := validate.Decode(w, r, &requestBody); err != nil {
+				log.Println("Invalid request body:", err)
+				problem.Write(w, err)
+				return
+			}
+
+			app.TaskManager.UpdateTask(w, taskIDStr, userIDStr, requestBody.DueDate, requestBody.Status, etag.IfMatch(r))
 		case http.MethodDelete:
 			userIDStr := vars.Get("user_id")
 			if userIDStr == "" {
 				log.Println("Missing user_id parameter")
-				http.Error(w, "Missing user_id parameter", http.StatusBadRequest)
+				problem.Write(w, problem.MissingParameter("user_id"))
 				return
 			}
-			app.TaskManager.DeleteTask(w, taskIDStr, userIDStr)
+			app.TaskManager.DeleteTask(w, taskIDStr, userIDStr, etag.IfMatch(r))
 		default:
 			log.Printf("Method %s not allowed", r.Method)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			problem.Write(w, problem.ErrMethodNotAllowed)
 		}
 	} else {
 		switch r.Method {
 		case http.MethodGet:
-			app.TaskManager.GetTasks(w)
+			app.TaskManager.GetTasks(w, r)
 		case http.MethodPost:
 			var requestBody struct {
-				UserName string `json:"user_name"`
-				TaskName string `json:"task_name"`
-				DueDate  string `json:"due_date"`
+				UserName string `json:"user_name" validate:"required,max=100"`
+				TaskName string `json:"task_name" validate:"required,max=500"`
+				DueDate  string `json:"due_date" validate:"required,date"`
+				RRule    string `json:"rrule" validate:"max=500"`
+				TimeZone string `json:"time_zone" validate:"timezone"`
 			}
-			if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-				log.Println("Error decoding request body:", err)
-				http.Error(w, "Invalid request body", http.StatusBadRequest)
+			if err := validate.Decode(w, r, &requestBody); err != nil {
+				log.Println("Invalid request body:", err)
+				problem.Write(w, err)
 				return
 			}
-			defer r.Body.Close()
 
-			if requestBody.UserName == "" || requestBody.TaskName == "" || requestBody.DueDate == "" {
-				log.Println("Missing required parameters")
-				http.Error(w, "Missing required parameters", http.StatusBadRequest)
-				return
-			}
-			app.TaskManager.CreateTask(w, requestBody.UserName, requestBody.TaskName, requestBody.DueDate)
+			app.TaskManager.CreateTask(w, requestBody.UserName, requestBody.TaskName, requestBody.DueDate, requestBody.RRule, requestBody.TimeZone)
 		default:
 			log.Printf("Method %s not allowed", r.Method)
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			problem.Write(w, problem.ErrMethodNotAllowed)
+		}
+	}
+}
+
+func (app *App) HandleUsers(w http.ResponseWriter, r *http.Request) {
+	userIDStr := r.URL.Query().Get("user_id")
+	if userIDStr == "" {
+		log.Println("Missing user_id parameter")
+		problem.Write(w, problem.MissingParameter("user_id"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		app.TaskManager.GetUserByID(w, userIDStr)
+	case http.MethodPatch:
+		var requestBody struct {
+			TimeZone string `json:"time_zone" validate:"required,timezone"`
 		}
+		if err := validate.Decode(w, r, &requestBody); err != nil {
+			log.Println("Invalid request body:", err)
+			problem.Write(w, err)
+			return
+		}
+
+		app.TaskManager.UpdateUserTimeZone(w, userIDStr, requestBody.TimeZone)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
 	}
 }
@@ -0,0 +1,128 @@
+package routerMongoDB
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/templates"
+	"Simple_Task_Manager/validate"
+	"log"
+	"net/http"
+)
+
+// maxInstances bounds how many task trees a single instantiate call creates.
+const maxInstances = 100
+
+func (app *App) HandleTemplates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		app.TaskManager.GetTemplates(w, query.Get("template_id"))
+	case http.MethodPost:
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		template, ok := decodeTemplate(w, r)
+		if !ok {
+			return
+		}
+		app.TaskManager.CreateTemplate(w, userID, template)
+	case http.MethodPut:
+		templateID, ok := requireParam(w, query, "template_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		template, ok := decodeTemplate(w, r)
+		if !ok {
+			return
+		}
+		app.TaskManager.UpdateTemplate(w, templateID, userID, template)
+	case http.MethodDelete:
+		templateID, ok := requireParam(w, query, "template_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.DeleteTemplate(w, templateID, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+func (app *App) HandleTemplateInstantiate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	templateID, ok := requireParam(w, query, "template_id")
+	if !ok {
+		return
+	}
+	userID, ok := requireParam(w, query, "user_id")
+	if !ok {
+		return
+	}
+	instances, ok := decodeInstances(w, r)
+	if !ok {
+		return
+	}
+	app.TaskManager.InstantiateTemplate(w, templateID, userID, instances)
+}
+
+func decodeTemplate(w http.ResponseWriter, r *http.Request) (templates.Template, bool) {
+	var template templates.Template
+	if err := validate.Decode(w, r, &template); err != nil {
+		log.Println("Invalid request body:", err)
+		problem.Write(w, err)
+		return templates.Template{}, false
+	}
+	return template, true
+}
+
+// decodeInstances reads the variables for each task tree to create. Variables
+// shared by every instance go into "variables"; "instances" lists the
+// per-instance ones. Without instances a single tree is created.
+func decodeInstances(w http.ResponseWriter, r *http.Request) ([]map[string]string, bool) {
+	var requestBody struct {
+		Variables map[string]string   `json:"variables"`
+		Instances []map[string]string `json:"instances"`
+	}
+	if err := validate.Decode(w, r, &requestBody); err != nil {
+		log.Println("Invalid request body:", err)
+		problem.Write(w, err)
+		return nil, false
+	}
+
+	if len(requestBody.Instances) == 0 {
+		requestBody.Instances = []map[string]string{{}}
+	}
+	if len(requestBody.Instances) > maxInstances {
+		log.Println("Too many instances in request body")
+		problem.Write(w, problem.Invalid("Too many instances in request body", problem.Field{Field: "instances", Code: "too_many", Message: "Too many instances in request body"}))
+		return nil, false
+	}
+
+	instances := make([]map[string]string, 0, len(requestBody.Instances))
+	for _, own := range requestBody.Instances {
+		variables := make(map[string]string, len(requestBody.Variables)+len(own))
+		for name, value := range requestBody.Variables {
+			variables[name] = value
+		}
+		for name, value := range own {
+			variables[name] = value
+		}
+		instances = append(instances, variables)
+	}
+	return instances, true
+}
@@ -0,0 +1,93 @@
+package routerMongoDB
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/validate"
+	"log"
+	"net/http"
+)
+
+func (app *App) HandleTimer(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.GetRunningTimer(w, userID)
+	case http.MethodPost:
+		taskID, ok := requireParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.StartTimer(w, taskID, userID)
+	case http.MethodDelete:
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.StopTimer(w, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+func (app *App) HandleTimeEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		app.TaskManager.GetTimeEntries(w, query.Get("task_id"), query.Get("user_id"), query.Get("from"), query.Get("to"), query.Get("format"))
+	case http.MethodPost:
+		taskID, ok := requireParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		var requestBody struct {
+			StartedAt string `json:"started_at" validate:"required,datetime"`
+			EndedAt   string `json:"ended_at" validate:"required,datetime"`
+			Note      string `json:"note" validate:"max=1000"`
+		}
+		if err := validate.Decode(w, r, &requestBody); err != nil {
+			log.Println("Invalid request body:", err)
+			problem.Write(w, err)
+			return
+		}
+		app.TaskManager.CreateTimeEntry(w, taskID, userID, requestBody.StartedAt, requestBody.EndedAt, requestBody.Note)
+	case http.MethodDelete:
+		entryID, ok := requireParam(w, query, "entry_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.DeleteTimeEntry(w, entryID, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+func (app *App) HandleTimeTotals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	app.TaskManager.GetTimeTotals(w, query.Get("group_by"), query.Get("task_id"), query.Get("user_id"), query.Get("from"), query.Get("to"))
+}
@@ -0,0 +1,85 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/problem"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+func (app *App) HandleAttachments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		if query.Get("attachment_id") != "" {
+			attachmentID, ok := requireIntParam(w, query, "attachment_id")
+			if !ok {
+				return
+			}
+			app.TaskManager.GetAttachment(w, r, attachmentID)
+			return
+		}
+		taskID, ok := requireIntParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.GetAttachments(w, taskID)
+	case http.MethodPost:
+		taskID, ok := requireIntParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		fileName, content, ok := openUploadedFile(w, r)
+		if !ok {
+			return
+		}
+		app.TaskManager.CreateAttachment(w, taskID, userID, fileName, content)
+	case http.MethodDelete:
+		attachmentID, ok := requireIntParam(w, query, "attachment_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.DeleteAttachment(w, attachmentID, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+// openUploadedFile returns the "file" part of a multipart upload. The part is
+// streamed, so the size limit is enforced while the content is stored.
+func openUploadedFile(w http.ResponseWriter, r *http.Request) (string, io.Reader, bool) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		log.Println("Error reading multipart body:", err)
+		problem.Write(w, problem.BadRequest("multipart_expected", "Expected multipart/form-data body"))
+		return "", nil, false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			log.Println("Missing file part")
+			problem.Write(w, problem.Required("file", "Missing file part"))
+			return "", nil, false
+		}
+		if err != nil {
+			log.Println("Error reading multipart body:", err)
+			problem.Write(w, problem.BadRequest("invalid_body", "Invalid request body"))
+			return "", nil, false
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			return part.FileName(), part, true
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/problem"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// HandleBackup streams a dump of the whole database. With blobs=true the
+// attachment contents are included as well.
+func (app *App) HandleBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	includeBlobs := false
+	if value := r.URL.Query().Get("blobs"); value != "" {
+		var err error
+		if includeBlobs, err = strconv.ParseBool(value); err != nil {
+			log.Println("Invalid blobs parameter:", err)
+			problem.Write(w, problem.InvalidParameter("blobs"))
+			return
+		}
+	}
+	app.TaskManager.Backup(w, includeBlobs)
+}
+
+// HandleRestore loads a dump from the request body into an empty database.
+func (app *App) HandleRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+	app.TaskManager.Restore(w, r.Body)
+}
@@ -0,0 +1,50 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/route"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// adminManager answers backups and snapshot lists and nothing else.
+type adminManager struct {
+	TaskManager
+}
+
+func (adminManager) Backup(w http.ResponseWriter, includeBlobs bool) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (adminManager) GetSnapshots(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestAdministrationNeedsAdminToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		adminToken    string
+		authorization string
+		status        int
+	}{
+		{name: "no admin token configured", authorization: "Bearer ", status: http.StatusNotFound},
+		{name: "without a token", adminToken: "s3cret", status: http.StatusUnauthorized},
+		{name: "with the wrong token", adminToken: "s3cret", authorization: "Bearer guess", status: http.StatusUnauthorized},
+		{name: "with the admin token", adminToken: "s3cret", authorization: "Bearer s3cret", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		app := &App{TaskManager: adminManager{}, AdminToken: test.adminToken}
+		handler := app.Routes(route.Version{Name: "v1"})
+		for _, path := range []string{"/api/v2/admin/backup", "/admin/backup", "/api/v2/admin/snapshots", "/admin/snapshots"} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if test.authorization != "" {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+			if recorder.Code != test.status {
+				t.Errorf("%s: GET %s answered %d, want %d", test.name, path, recorder.Code, test.status)
+			}
+		}
+	}
+}
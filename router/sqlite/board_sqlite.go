@@ -0,0 +1,74 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/validate"
+	"Simple_Task_Manager/workflow"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+func (app *App) HandleBoard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	var userID int
+	if userIDStr := r.URL.Query().Get("user_id"); userIDStr != "" {
+		var err error
+		userID, err = strconv.Atoi(userIDStr)
+		if err != nil {
+			log.Println("Invalid user_id parameter:", err)
+			problem.Write(w, problem.InvalidParameter("user_id"))
+			return
+		}
+	}
+	app.TaskManager.GetBoard(w, userID)
+}
+
+func (app *App) HandleBoardMove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	taskID, err := strconv.Atoi(query.Get("task_id"))
+	if err != nil {
+		log.Println("Invalid task_id parameter:", err)
+		problem.Write(w, problem.InvalidParameter("task_id"))
+		return
+	}
+	userID, err := strconv.Atoi(query.Get("user_id"))
+	if err != nil {
+		log.Println("Invalid user_id parameter:", err)
+		problem.Write(w, problem.InvalidParameter("user_id"))
+		return
+	}
+
+	requestBody := moveTaskRequest{workflow: app.workflow()}
+	if err := validate.Decode(w, r, &requestBody); err != nil {
+		log.Println("Invalid request body:", err)
+		problem.Write(w, err)
+		return
+	}
+
+	app.TaskManager.MoveTask(w, taskID, userID, requestBody.Status, requestBody.AfterTaskID, requestBody.BeforeTaskID)
+}
+
+// moveTaskRequest is the body of a move on the board.
+type moveTaskRequest struct {
+	Status       string `json:"status" validate:"required"`
+	AfterTaskID  int    `json:"after_task_id"`
+	BeforeTaskID int    `json:"before_task_id"`
+
+	workflow *workflow.Workflow
+}
+
+func (body *moveTaskRequest) Validate() []problem.Field {
+	return validate.Status(body.workflow, "status", body.Status)
+}
@@ -0,0 +1,53 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/ical"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/route"
+	"log"
+	"net/http"
+)
+
+// HandleFeedToken issues (POST) or revokes (DELETE) the token of a user's
+// calendar feed.
+func (app *App) HandleFeedToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := requireIntParam(w, r.URL.Query(), "user_id")
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		app.TaskManager.CreateFeedToken(w, userID, route.Base(r)+"/calendar")
+	case http.MethodDelete:
+		app.TaskManager.DeleteFeedToken(w, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+// HandleCalendarFeed serves the feed calendar clients subscribe to. The token
+// is the only credential, since clients cannot send other ones.
+func (app *App) HandleCalendarFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	token := query.Get("token")
+	if token == "" {
+		log.Println("Missing token parameter")
+		problem.Write(w, problem.MissingParameter("token"))
+		return
+	}
+	component, err := ical.ParseComponent(query.Get("component"))
+	if err != nil {
+		log.Println("Invalid component parameter:", err)
+		problem.Write(w, problem.InvalidParameter("component"))
+		return
+	}
+	app.TaskManager.GetCalendarFeed(w, token, component)
+}
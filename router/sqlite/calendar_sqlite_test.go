@@ -0,0 +1,37 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/route"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// feedManager answers feed tokens with the feed path it was given.
+type feedManager struct {
+	TaskManager
+}
+
+func (feedManager) CreateFeedToken(w http.ResponseWriter, userID int, feedPath string) {
+	io.WriteString(w, feedPath)
+}
+
+func TestFeedTokenLinksFeedOfSameVersion(t *testing.T) {
+	tests := []struct {
+		path     string
+		feedPath string
+	}{
+		{path: "/api/v2/users/1/calendar-token", feedPath: "/api/v2/calendar"},
+		{path: "/api/v1/users/1/calendar-token", feedPath: "/api/v1/calendar"},
+		{path: "/users/1/calendar-token", feedPath: "/calendar"},
+	}
+	handler := (&App{TaskManager: feedManager{}}).Routes(route.Version{Name: "v1"})
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, test.path, nil))
+		if recorder.Body.String() != test.feedPath {
+			t.Errorf("POST %s: feed path %q, want %q", test.path, recorder.Body, test.feedPath)
+		}
+	}
+}
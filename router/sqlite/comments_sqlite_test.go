@@ -0,0 +1,48 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/problem"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query         string
+		limit, offset int
+		invalid       []string
+	}{
+		{query: "", limit: defaultPageSize, offset: 0},
+		{query: "limit=5&offset=10", limit: 5, offset: 10},
+		{query: "limit=100", limit: 100, offset: 0},
+		{query: "limit=0", invalid: []string{"limit"}},
+		{query: "limit=101", invalid: []string{"limit"}},
+		{query: "limit=ten", invalid: []string{"limit"}},
+		{query: "offset=-1", invalid: []string{"offset"}},
+		{query: "limit=x&offset=y", invalid: []string{"limit", "offset"}},
+	}
+	for _, test := range tests {
+		query, _ := url.ParseQuery(test.query)
+		limit, offset, err := parsePagination(query)
+		if test.invalid == nil {
+			if err != nil || limit != test.limit || offset != test.offset {
+				t.Errorf("parsePagination(%q) = %d, %d, %v, want %d, %d", test.query, limit, offset, err, test.limit, test.offset)
+			}
+			continue
+		}
+		var invalid *problem.Error
+		if !errors.As(err, &invalid) {
+			t.Errorf("parsePagination(%q) error = %v, want a problem", test.query, err)
+			continue
+		}
+		var fields []string
+		for _, field := range invalid.Errors {
+			fields = append(fields, field.Field)
+		}
+		if !reflect.DeepEqual(fields, test.invalid) {
+			t.Errorf("parsePagination(%q) reported %v, want %v", test.query, fields, test.invalid)
+		}
+	}
+}
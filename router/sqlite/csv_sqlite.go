@@ -0,0 +1,103 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/taskcsv"
+	"Simple_Task_Manager/todolist"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+)
+
+func (app *App) HandleTaskExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "csv" && !todolist.ValidFormat(format) {
+		log.Println("Invalid format:", format)
+		problem.Write(w, problem.InvalidParameter("format"))
+		return
+	}
+	userID, ok := optionalIntParam(w, r.URL.Query(), "user_id")
+	if !ok {
+		return
+	}
+	app.TaskManager.ExportTasks(w, format, userID)
+}
+
+// HandleTaskImport reads a CSV file or a todolist format, sent as the "file"
+// part of a multipart upload or as the request body, and imports its rows as
+// tasks. CSV columns are mapped to fields by name unless given as
+// map=Header:field parameters; lists take the user_name and default_due of
+// tasks that do not name them. The mode defaults to partial, importing every
+// valid row.
+func (app *App) HandleTaskImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format != "" && format != "csv" && !todolist.ValidFormat(format) {
+		log.Println("Invalid format:", format)
+		problem.Write(w, problem.InvalidParameter("format"))
+		return
+	}
+	mode := bulk.Partial
+	if query.Get("mode") != "" {
+		var err error
+		if mode, err = bulk.ParseMode(query.Get("mode")); err != nil {
+			log.Println("Invalid mode parameter:", err)
+			problem.Write(w, problem.InvalidParameter("mode"))
+			return
+		}
+	}
+	mapping, err := taskcsv.ParseMapping(query["map"])
+	if err != nil {
+		log.Println("Invalid map parameter:", err)
+		problem.Write(w, problem.InvalidParameter("map"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, taskcsv.MaxImportBytes)
+	defer r.Body.Close()
+	var content io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		var ok bool
+		if _, content, ok = openUploadedFile(w, r); !ok {
+			return
+		}
+	}
+
+	var rows []taskcsv.ImportRow
+	if todolist.ValidFormat(format) {
+		rows, err = todolist.Read(content, format, todolist.Defaults{UserName: query.Get("user_name"), DueDate: query.Get("default_due")})
+	} else {
+		rows, err = taskcsv.ReadImport(content, mapping)
+	}
+	if err != nil {
+		log.Println("Error reading import:", err)
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			problem.Write(w, problem.New(http.StatusRequestEntityTooLarge, "file_too_large", "File too large"))
+		case errors.Is(err, taskcsv.ErrTooManyRows):
+			problem.Write(w, problem.New(http.StatusRequestEntityTooLarge, "too_many_rows", "Too many rows"))
+		case errors.Is(err, taskcsv.ErrMissingColumns), errors.Is(err, taskcsv.ErrEmpty):
+			problem.Write(w, problem.Malformed("file", "Invalid file: "+err.Error()))
+		default:
+			problem.Write(w, problem.BadRequest("invalid_file", "Invalid file"))
+		}
+		return
+	}
+	app.TaskManager.ImportTasks(w, mode, rows)
+}
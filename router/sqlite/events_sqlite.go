@@ -0,0 +1,30 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/problem"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// HandleEvents streams task changes as Server-Sent Events, optionally only
+// those of a user's tasks or of a project's.
+func (app *App) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	userID, ok := optionalIntParam(w, query, "user_id")
+	if !ok {
+		return
+	}
+	filter := events.Filter{Project: query.Get("project")}
+	if userID != 0 {
+		filter.UserID = strconv.Itoa(userID)
+	}
+	app.TaskManager.StreamEvents(w, r, filter)
+}
@@ -0,0 +1,51 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/validate"
+	"log"
+	"net/http"
+)
+
+func (app *App) HandleTaskHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	taskID, ok := requireIntParam(w, r.URL.Query(), "task_id")
+	if !ok {
+		return
+	}
+	app.TaskManager.GetTaskHistory(w, taskID)
+}
+
+func (app *App) HandleTaskRevert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	taskID, ok := requireIntParam(w, query, "task_id")
+	if !ok {
+		return
+	}
+	userID, ok := requireIntParam(w, query, "user_id")
+	if !ok {
+		return
+	}
+
+	var requestBody struct {
+		Version int `json:"version" validate:"required,min=1"`
+	}
+	if err := validate.Decode(w, r, &requestBody); err != nil {
+		log.Println("Invalid request body:", err)
+		problem.Write(w, err)
+		return
+	}
+
+	app.TaskManager.RevertTask(w, taskID, userID, requestBody.Version)
+}
@@ -0,0 +1,96 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/etag"
+	"Simple_Task_Manager/openapi"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/route"
+	"net/http"
+)
+
+// Routes mounts every API version under /api/<version>. Unversioned paths
+// are served as v1, so clients written before versioning keep working; v1 is
+// deprecated and announces so in its responses. Response shapes introduced
+// since are turned back into the v1 ones: problem details into plain-text
+// errors, and created or changed resources into plain-text messages. GET
+// requests for a representation the client already has, going by its ETag,
+// are answered with 304 Not Modified in every version, and retries of
+// mutating requests carrying an Idempotency-Key get the original response.
+// The API description is served outside the versions, at /openapi.json and
+// /docs.
+func (app *App) Routes(v1 route.Version) *route.Router {
+	router := route.New()
+	router.HandleFunc("/openapi.json", openapi.ServeSpec, http.MethodGet)
+	router.HandleFunc("/docs", openapi.ServeDocs, http.MethodGet)
+	router.Mount("/api/v2", route.Versioned(route.Version{Name: "v2"}, etag.Conditional(app.Idempotency.Handler(app.resources(false)))))
+	legacy := route.Versioned(v1, etag.Conditional(problem.PlainText(respond.Legacy(app.Idempotency.Handler(app.resources(true))))))
+	router.Mount("/api/v1", legacy)
+	router.Mount("", legacy)
+	return router
+}
+
+// resources maps the resource paths onto the handlers. The handlers read
+// their IDs from the query, so path parameters are passed on as query
+// parameters. With legacy set, the query-string form of each endpoint is
+// served as well, with a Deprecation header and a link to the path replacing
+// it.
+func (app *App) resources(legacy bool) *route.Router {
+	router := route.New()
+
+	router.HandleFunc("/tasks", app.HandleTasks, http.MethodGet, http.MethodPost)
+	router.HandleFunc("/tasks/{task_id}", route.AsQuery(app.HandleTasks), http.MethodGet, http.MethodPatch, http.MethodDelete)
+	router.HandleFunc("/tasks/bulk", app.HandleBulkTasks, http.MethodPost)
+	router.HandleFunc("/tasks/export", app.HandleTaskExport, http.MethodGet)
+	router.HandleFunc("/tasks/import", app.HandleTaskImport, http.MethodPost)
+	router.HandleFunc("/events", app.HandleEvents, http.MethodGet)
+
+	router.HandleFunc("/tasks/{task_id}/comments", route.AsQuery(app.HandleComments), http.MethodGet, http.MethodPost)
+	router.HandleFunc("/comments/{comment_id}", route.AsQuery(app.HandleComments), http.MethodPatch, http.MethodDelete)
+
+	router.HandleFunc("/tasks/{task_id}/attachments", route.AsQuery(app.HandleAttachments), http.MethodGet, http.MethodPost)
+	router.HandleFunc("/attachments/{attachment_id}", route.AsQuery(app.HandleAttachments), http.MethodGet, http.MethodDelete)
+
+	router.HandleFunc("/tasks/{task_id}/history", route.AsQuery(app.HandleTaskHistory), http.MethodGet)
+	router.HandleFunc("/tasks/{task_id}/revert", route.AsQuery(app.HandleTaskRevert), http.MethodPost)
+
+	router.HandleFunc("/board", app.HandleBoard, http.MethodGet)
+	router.HandleFunc("/tasks/{task_id}/move", route.AsQuery(app.HandleBoardMove), http.MethodPost)
+
+	router.HandleFunc("/users/{user_id}", route.AsQuery(app.HandleUsers), http.MethodGet, http.MethodPatch)
+	router.HandleFunc("/users/{user_id}/calendar-token", route.AsQuery(app.HandleFeedToken), http.MethodPost, http.MethodDelete)
+	router.HandleFunc("/calendar", app.HandleCalendarFeed, http.MethodGet)
+
+	router.HandleFunc("/users/{user_id}/timer", route.AsQuery(app.HandleTimer), http.MethodGet, http.MethodPost, http.MethodDelete)
+	router.HandleFunc("/time/entries", app.HandleTimeEntries, http.MethodGet, http.MethodPost)
+	router.HandleFunc("/time/entries/{entry_id}", route.AsQuery(app.HandleTimeEntries), http.MethodDelete)
+	router.HandleFunc("/tasks/{task_id}/time-entries", route.AsQuery(app.HandleTimeEntries), http.MethodGet, http.MethodPost)
+	router.HandleFunc("/time/totals", app.HandleTimeTotals, http.MethodGet)
+
+	router.HandleFunc("/templates", app.HandleTemplates, http.MethodGet, http.MethodPost)
+	router.HandleFunc("/templates/{template_id}", route.AsQuery(app.HandleTemplates), http.MethodGet, http.MethodPut, http.MethodDelete)
+	router.HandleFunc("/templates/{template_id}/instantiate", route.AsQuery(app.HandleTemplateInstantiate), http.MethodPost)
+
+	if app.AdminToken != "" {
+		router.HandleFunc("/admin/backup", route.RequireToken(app.AdminToken, app.HandleBackup), http.MethodGet)
+		router.HandleFunc("/admin/restore", route.RequireToken(app.AdminToken, app.HandleRestore), http.MethodPost)
+		router.HandleFunc("/admin/snapshots", route.RequireToken(app.AdminToken, app.HandleSnapshots), http.MethodGet, http.MethodPost)
+	}
+
+	if legacy {
+		router.HandleFunc("/tasks", route.Deprecated(app.HandleTasks, "/tasks/{task_id}"), http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete)
+		router.HandleFunc("/tasks/comments", route.Deprecated(app.HandleComments, "/comments/{comment_id}", "/tasks/{task_id}/comments"), http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete)
+		router.HandleFunc("/tasks/attachments", route.Deprecated(app.HandleAttachments, "/attachments/{attachment_id}", "/tasks/{task_id}/attachments"), http.MethodGet, http.MethodPost, http.MethodDelete)
+		router.HandleFunc("/tasks/history", route.Deprecated(app.HandleTaskHistory, "/tasks/{task_id}/history"), http.MethodGet)
+		router.HandleFunc("/tasks/revert", route.Deprecated(app.HandleTaskRevert, "/tasks/{task_id}/revert"), http.MethodPost)
+		router.HandleFunc("/board/move", route.Deprecated(app.HandleBoardMove, "/tasks/{task_id}/move"), http.MethodPost)
+		router.HandleFunc("/users", route.Deprecated(app.HandleUsers, "/users/{user_id}"), http.MethodGet, http.MethodPatch)
+		router.HandleFunc("/users/calendar", route.Deprecated(app.HandleFeedToken, "/users/{user_id}/calendar-token"), http.MethodPost, http.MethodDelete)
+		router.HandleFunc("/time/timer", route.Deprecated(app.HandleTimer, "/users/{user_id}/timer"), http.MethodGet, http.MethodPost, http.MethodDelete)
+		router.HandleFunc("/time/entries", route.Deprecated(app.HandleTimeEntries, "/time/entries/{entry_id}"), http.MethodGet, http.MethodPost, http.MethodDelete)
+		router.HandleFunc("/templates", route.Deprecated(app.HandleTemplates, "/templates/{template_id}"), http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete)
+		router.HandleFunc("/templates/instantiate", route.Deprecated(app.HandleTemplateInstantiate, "/templates/{template_id}/instantiate"), http.MethodPost)
+	}
+
+	return router
+}
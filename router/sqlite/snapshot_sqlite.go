@@ -0,0 +1,21 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/problem"
+	"log"
+	"net/http"
+)
+
+// HandleSnapshots lists (GET) or takes (POST) hot backups of the database
+// file.
+func (app *App) HandleSnapshots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		app.TaskManager.GetSnapshots(w)
+	case http.MethodPost:
+		app.TaskManager.CreateSnapshot(w)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
@@ -0,0 +1,125 @@
+package routerSqlite
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/validate"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func (app *App) HandleTimer(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		userID, ok := requireIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.GetRunningTimer(w, userID)
+	case http.MethodPost:
+		taskID, ok := requireIntParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.StartTimer(w, taskID, userID)
+	case http.MethodDelete:
+		userID, ok := requireIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.StopTimer(w, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+func (app *App) HandleTimeEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	switch r.Method {
+	case http.MethodGet:
+		taskID, ok := optionalIntParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		userID, ok := optionalIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.GetTimeEntries(w, taskID, userID, query.Get("from"), query.Get("to"), query.Get("format"))
+	case http.MethodPost:
+		taskID, ok := requireIntParam(w, query, "task_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		var requestBody struct {
+			StartedAt string `json:"started_at" validate:"required,datetime"`
+			EndedAt   string `json:"ended_at" validate:"required,datetime"`
+			Note      string `json:"note" validate:"max=1000"`
+		}
+		if err := validate.Decode(w, r, &requestBody); err != nil {
+			log.Println("Invalid request body:", err)
+			problem.Write(w, err)
+			return
+		}
+		app.TaskManager.CreateTimeEntry(w, taskID, userID, requestBody.StartedAt, requestBody.EndedAt, requestBody.Note)
+	case http.MethodDelete:
+		entryID, ok := requireIntParam(w, query, "entry_id")
+		if !ok {
+			return
+		}
+		userID, ok := requireIntParam(w, query, "user_id")
+		if !ok {
+			return
+		}
+		app.TaskManager.DeleteTimeEntry(w, entryID, userID)
+	default:
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+	}
+}
+
+func (app *App) HandleTimeTotals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		log.Printf("Method %s not allowed", r.Method)
+		problem.Write(w, problem.ErrMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	taskID, ok := optionalIntParam(w, query, "task_id")
+	if !ok {
+		return
+	}
+	userID, ok := optionalIntParam(w, query, "user_id")
+	if !ok {
+		return
+	}
+	app.TaskManager.GetTimeTotals(w, query.Get("group_by"), taskID, userID, query.Get("from"), query.Get("to"))
+}
+
+func optionalIntParam(w http.ResponseWriter, query url.Values, name string) (int, bool) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s parameter: %v", name, err)
+		problem.Write(w, problem.InvalidParameter(name))
+		return 0, false
+	}
+	return id, true
+}
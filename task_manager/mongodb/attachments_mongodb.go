@@ -0,0 +1,306 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/blobstore"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const maxFileNameLength = 255
+
+type Attachment struct {
+	AttachmentID primitive.ObjectID `json:"attachment_id" bson:"_id"`
+	TaskID       primitive.ObjectID `json:"task_id" bson:"task_id"`
+	UserID       primitive.ObjectID `json:"user_id" bson:"user_id"`
+	FileName     string             `json:"file_name" bson:"file_name"`
+	ContentType  string             `json:"content_type" bson:"content_type"`
+	Size         int64              `json:"size" bson:"size"`
+	BlobKey      string             `json:"-" bson:"blob_key"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+}
+
+func (app *App) blobs() blobstore.Store {
+	if app.Blobs != nil {
+		return app.Blobs
+	}
+	return blobstore.NewLocal("attachments")
+}
+
+func (app *App) attachmentLimits() *blobstore.Limits {
+	if app.AttachmentLimits != nil {
+		return app.AttachmentLimits
+	}
+	return blobstore.DefaultLimits()
+}
+
+func (app *App) GetAttachments(w http.ResponseWriter, taskID string) {
+	taskObjectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+
+	if !app.taskExists(w, taskObjectID) {
+		return
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := app.Attachments.Find(context.Background(), bson.M{"task_id": taskObjectID}, findOptions)
+	if err != nil {
+		log.Printf("Error querying attachments from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	attachments := []Attachment{}
+	if err = cursor.All(context.Background(), &attachments); err != nil {
+		log.Printf("Error decoding attachments: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(attachments)
+	if err != nil {
+		log.Printf("Error encoding attachments to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Attachments gathered successfully")
+}
+
+func (app *App) CreateAttachment(w http.ResponseWriter, taskID, userID, fileName string, content io.Reader) {
+	taskObjectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+	fileName = cleanFileName(fileName)
+	if fileName == "" {
+		log.Println("Missing file name")
+		problem.Write(w, problem.Required("file", "Missing file name"))
+		return
+	}
+
+	if !app.taskExists(w, taskObjectID) {
+		return
+	}
+	userCount, err := app.Users.CountDocuments(context.Background(), bson.M{"_id": userObjectID})
+	if err != nil {
+		log.Printf("Error checking user existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if userCount == 0 {
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	key, err := blobstore.NewKey("tasks/" + taskObjectID.Hex())
+	if err != nil {
+		log.Printf("Error generating blob key: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	contentType, size, err := blobstore.Upload(context.Background(), app.blobs(), app.attachmentLimits(), key, content)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	attachment := Attachment{
+		AttachmentID: primitive.NewObjectID(),
+		TaskID:       taskObjectID,
+		UserID:       userObjectID,
+		FileName:     fileName,
+		ContentType:  contentType,
+		Size:         size,
+		BlobKey:      key,
+		CreatedAt:    app.now().UTC(),
+	}
+
+	_, err = app.Attachments.InsertOne(context.Background(), attachment)
+	if err != nil {
+		log.Printf("Error inserting attachment: %v", err)
+		app.deleteBlobs([]string{key})
+		problem.Write(w, problem.Internal("Error inserting attachment"))
+		return
+	}
+
+	log.Println("Attachment uploaded successfully")
+	respond.Created(w, "/attachments/"+attachment.AttachmentID.Hex(), attachment, "Attachment uploaded successfully")
+}
+
+// GetAttachment streams the attachment content. Range requests are answered
+// with partial content.
+func (app *App) GetAttachment(w http.ResponseWriter, r *http.Request, attachmentID string) {
+	objectID, err := primitive.ObjectIDFromHex(attachmentID)
+	if err != nil {
+		log.Println("Invalid attachment ID:", err)
+		problem.Write(w, problem.Malformed("attachment_id", "Invalid attachment ID"))
+		return
+	}
+
+	var attachment Attachment
+	err = app.Attachments.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&attachment)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("Attachment not found")
+		problem.Write(w, problem.NotFound("attachment_not_found", "Attachment not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving attachment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	serveAttachment(w, r, app.blobs(), attachment.BlobKey, attachment.FileName, attachment.ContentType, attachment.CreatedAt)
+}
+
+func (app *App) DeleteAttachment(w http.ResponseWriter, attachmentID, userID string) {
+	objectID, err := primitive.ObjectIDFromHex(attachmentID)
+	if err != nil {
+		log.Println("Invalid attachment ID:", err)
+		problem.Write(w, problem.Malformed("attachment_id", "Invalid attachment ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	var attachment Attachment
+	err = app.Attachments.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&attachment)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("Attachment not found")
+		problem.Write(w, problem.NotFound("attachment_not_found", "Attachment not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving attachment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	case attachment.UserID != userObjectID:
+		log.Println("User is not the uploader of the attachment")
+		problem.Write(w, problem.Forbidden("not_uploader", "Only the uploader can delete an attachment"))
+		return
+	}
+
+	_, err = app.Attachments.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	if err != nil {
+		log.Printf("Error deleting attachment: %v", err)
+		problem.Write(w, problem.Internal("Error deleting attachment"))
+		return
+	}
+	app.deleteBlobs([]string{attachment.BlobKey})
+
+	log.Println("Attachment deleted successfully")
+	respond.Deleted(w, "Attachment deleted successfully")
+}
+
+// purgeAttachments removes the attachment documents of a task and then their
+// content.
+func (app *App) purgeAttachments(taskID primitive.ObjectID) error {
+	var attachments []Attachment
+	cursor, err := app.Attachments.Find(context.Background(), bson.M{"task_id": taskID})
+	if err != nil {
+		return err
+	}
+	if err = cursor.All(context.Background(), &attachments); err != nil {
+		return err
+	}
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(attachments))
+	ids := make([]primitive.ObjectID, 0, len(attachments))
+	for _, attachment := range attachments {
+		keys = append(keys, attachment.BlobKey)
+		ids = append(ids, attachment.AttachmentID)
+	}
+	if _, err = app.Attachments.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	app.deleteBlobs(keys)
+	return nil
+}
+
+// deleteBlobs removes blobs whose metadata is already gone. Failures only
+// leave orphaned content behind, so they are logged rather than reported.
+func (app *App) deleteBlobs(keys []string) {
+	for _, key := range keys {
+		if err := app.blobs().Delete(context.Background(), key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+}
+
+func cleanFileName(fileName string) string {
+	fileName = path.Base(strings.ReplaceAll(strings.TrimSpace(fileName), "\\", "/"))
+	if fileName == "." || fileName == "/" || len(fileName) > maxFileNameLength {
+		return ""
+	}
+	return fileName
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, blobstore.ErrTooLarge), errors.As(err, &maxBytesErr):
+		log.Println("Attachment too large:", err)
+		problem.Write(w, problem.New(http.StatusRequestEntityTooLarge, "file_too_large", "File too large"))
+	case errors.Is(err, blobstore.ErrBadType):
+		log.Println("Attachment type not allowed:", err)
+		problem.Write(w, problem.New(http.StatusUnsupportedMediaType, "file_type_not_allowed", "File type not allowed"))
+	default:
+		log.Printf("Error storing attachment: %v", err)
+		problem.Write(w, problem.Internal("Error storing attachment"))
+	}
+}
+
+func serveAttachment(w http.ResponseWriter, r *http.Request, store blobstore.Store, key, fileName, contentType string, modTime time.Time) {
+	content, err := store.Open(r.Context(), key)
+	if err != nil {
+		log.Printf("Error opening blob %s: %v", key, err)
+		if errors.Is(err, blobstore.ErrNotFound) {
+			problem.Write(w, problem.NotFound("attachment_content_not_found", "Attachment content not found"))
+			return
+		}
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, fileName, modTime, content)
+	log.Println("Attachment downloaded successfully")
+}
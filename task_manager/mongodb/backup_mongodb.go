@@ -0,0 +1,630 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/backup"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/workflow"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// restoreBatchSize bounds the documents inserted at once during a restore.
+const restoreBatchSize = 1000
+
+// backupUser is a user with the hash of its calendar feed token, which the
+// User type leaves out.
+type backupUser struct {
+	UserID        primitive.ObjectID `bson:"_id"`
+	UserName      string             `bson:"user_name"`
+	TimeZone      string             `bson:"time_zone"`
+	FeedTokenHash string             `bson:"feed_token_hash,omitempty"`
+}
+
+// Backup streams a dump of the whole database. On a replica set all
+// collections are read from one snapshot, so the dump is consistent even
+// while the server keeps writing; a standalone server cannot provide one and
+// is read collection by collection. Blobs are only included when asked for.
+func (app *App) Backup(w http.ResponseWriter, includeBlobs bool) {
+	ctx, end := app.snapshotContext()
+	defer end()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="backup-`+app.now().UTC().Format("20060102-150405")+`.jsonl"`)
+	writer, err := backup.NewWriter(w, "mongodb", app.now())
+	if err != nil {
+		log.Printf("Error writing backup: %v", err)
+		return
+	}
+
+	var blobs []backup.Blob
+	dumps := []func(context.Context, *backup.Writer) error{
+		app.dumpUsers,
+		app.dumpTasks,
+		app.dumpStatusChanges,
+		app.dumpComments,
+		func(ctx context.Context, writer *backup.Writer) error {
+			var err error
+			blobs, err = app.dumpAttachments(ctx, writer)
+			return err
+		},
+		func(ctx context.Context, writer *backup.Writer) error {
+			if !includeBlobs {
+				return nil
+			}
+			return app.dumpBlobs(writer, blobs)
+		},
+		app.dumpTimeEntries,
+		app.dumpHistory,
+		app.dumpTemplates,
+	}
+	for _, dump := range dumps {
+		if err = dump(ctx, writer); err != nil {
+			log.Printf("Error writing backup: %v", err)
+			return
+		}
+	}
+	if err = writer.Close(); err != nil {
+		log.Printf("Error writing backup: %v", err)
+		return
+	}
+	log.Println("Backup written successfully")
+}
+
+// snapshotContext returns a context reading from a snapshot session, or a
+// plain one when the server does not support snapshot reads.
+func (app *App) snapshotContext() (context.Context, func()) {
+	session, err := app.DB.Client().StartSession(options.Session().SetSnapshot(true))
+	if err != nil {
+		log.Printf("Backup without snapshot: %v", err)
+		return context.Background(), func() {}
+	}
+	ctx := mongo.NewSessionContext(context.Background(), session)
+	if err = app.Users.FindOne(ctx, bson.M{}).Err(); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		session.EndSession(context.Background())
+		log.Printf("Backup without snapshot: %v", err)
+		return context.Background(), func() {}
+	}
+	return ctx, func() { session.EndSession(context.Background()) }
+}
+
+// dumpCollection writes a record of kind for every document of collection,
+// decoded into a fresh value by decode.
+func dumpCollection(ctx context.Context, writer *backup.Writer, collection *mongo.Collection, kind string, decode func(cursor *mongo.Cursor) (interface{}, error)) error {
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		record, err := decode(cursor)
+		if err != nil {
+			return err
+		}
+		if err = writer.Write(kind, record); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func (app *App) dumpUsers(ctx context.Context, writer *backup.Writer) error {
+	return dumpCollection(ctx, writer, app.Users, backup.KindUser, func(cursor *mongo.Cursor) (interface{}, error) {
+		var user backupUser
+		err := cursor.Decode(&user)
+		return backup.User{ID: user.UserID.Hex(), Name: user.UserName, TimeZone: user.TimeZone, FeedTokenHash: user.FeedTokenHash}, err
+	})
+}
+
+func (app *App) dumpTasks(ctx context.Context, writer *backup.Writer) error {
+	return dumpCollection(ctx, writer, app.Tasks, backup.KindTask, func(cursor *mongo.Cursor) (interface{}, error) {
+		var task Task
+		if err := cursor.Decode(&task); err != nil {
+			return nil, err
+		}
+		record := backup.Task{
+			ID:              task.TaskID.Hex(),
+			UserID:          task.UserID.Hex(),
+			Name:            task.TaskName,
+			DueDate:         task.DueDate.UTC(),
+			Completed:       task.Completed,
+			RRule:           task.RRule,
+			TimeZone:        task.TimeZone,
+			Status:          string(task.Status),
+			StatusChangedBy: optionalHex(task.StatusChangedBy),
+			StatusChangedAt: optionalUTC(task.StatusChangedAt),
+			Rank:            task.Rank,
+			Description:     task.Description,
+			Tags:            task.Tags,
+			ParentTaskID:    optionalHex(task.ParentTaskID),
+		}
+		for _, item := range task.Checklist {
+			record.Checklist = append(record.Checklist, backup.ChecklistItem{Text: item.Text, Done: item.Done})
+		}
+		return record, nil
+	})
+}
+
+func (app *App) dumpStatusChanges(ctx context.Context, writer *backup.Writer) error {
+	return dumpCollection(ctx, writer, app.StatusChanges, backup.KindStatusChange, func(cursor *mongo.Cursor) (interface{}, error) {
+		var change StatusChange
+		err := cursor.Decode(&change)
+		record := backup.StatusChange{ID: change.ChangeID.Hex(), TaskID: change.TaskID.Hex(), FromStatus: string(change.FromStatus), ToStatus: string(change.ToStatus), ChangedAt: change.ChangedAt.UTC()}
+		if !change.UserID.IsZero() {
+			record.UserID = optionalHex(&change.UserID)
+		}
+		return record, err
+	})
+}
+
+func (app *App) dumpComments(ctx context.Context, writer *backup.Writer) error {
+	return dumpCollection(ctx, writer, app.Comments, backup.KindComment, func(cursor *mongo.Cursor) (interface{}, error) {
+		var comment Comment
+		err := cursor.Decode(&comment)
+		record := backup.Comment{ID: comment.CommentID.Hex(), TaskID: comment.TaskID.Hex(), UserID: comment.UserID.Hex(), Content: comment.Content, CreatedAt: comment.CreatedAt.UTC(), UpdatedAt: optionalUTC(comment.UpdatedAt)}
+		for _, mention := range comment.Mentions {
+			record.Mentions = append(record.Mentions, mention.UserID.Hex())
+		}
+		return record, err
+	})
+}
+
+// dumpAttachments returns the blobs of the attachments, without content.
+func (app *App) dumpAttachments(ctx context.Context, writer *backup.Writer) ([]backup.Blob, error) {
+	var blobs []backup.Blob
+	err := dumpCollection(ctx, writer, app.Attachments, backup.KindAttachment, func(cursor *mongo.Cursor) (interface{}, error) {
+		var attachment Attachment
+		err := cursor.Decode(&attachment)
+		blobs = append(blobs, backup.Blob{Key: attachment.BlobKey, ContentType: attachment.ContentType})
+		return backup.Attachment{
+			ID:          attachment.AttachmentID.Hex(),
+			TaskID:      attachment.TaskID.Hex(),
+			UserID:      attachment.UserID.Hex(),
+			FileName:    attachment.FileName,
+			ContentType: attachment.ContentType,
+			Size:        attachment.Size,
+			BlobKey:     attachment.BlobKey,
+			CreatedAt:   attachment.CreatedAt.UTC(),
+		}, err
+	})
+	return blobs, err
+}
+
+// dumpBlobs reads blobs one at a time, so only one is held in memory.
+func (app *App) dumpBlobs(writer *backup.Writer, blobs []backup.Blob) error {
+	for _, blob := range blobs {
+		content, err := app.blobs().Open(context.Background(), blob.Key)
+		if err != nil {
+			return fmt.Errorf("opening blob %s: %w", blob.Key, err)
+		}
+		blob.Content, err = io.ReadAll(content)
+		content.Close()
+		if err != nil {
+			return fmt.Errorf("reading blob %s: %w", blob.Key, err)
+		}
+		if err = writer.Write(backup.KindBlob, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *App) dumpTimeEntries(ctx context.Context, writer *backup.Writer) error {
+	return dumpCollection(ctx, writer, app.TimeEntries, backup.KindTimeEntry, func(cursor *mongo.Cursor) (interface{}, error) {
+		var entry TimeEntry
+		err := cursor.Decode(&entry)
+		return backup.TimeEntry{ID: entry.EntryID.Hex(), TaskID: entry.TaskID.Hex(), UserID: entry.UserID.Hex(), StartedAt: entry.StartedAt.UTC(), EndedAt: optionalUTC(entry.EndedAt), Note: entry.Note}, err
+	})
+}
+
+func (app *App) dumpHistory(ctx context.Context, writer *backup.Writer) error {
+	return dumpCollection(ctx, writer, app.History, backup.KindHistory, func(cursor *mongo.Cursor) (interface{}, error) {
+		var entry HistoryEntry
+		err := cursor.Decode(&entry)
+		return backup.History{
+			ID:        entry.HistoryID.Hex(),
+			TaskID:    entry.TaskID.Hex(),
+			Version:   entry.Version,
+			Action:    entry.Action,
+			ActorID:   optionalHex(entry.ActorID),
+			ChangedAt: entry.ChangedAt.UTC(),
+			Changes:   entry.Changes,
+			Snapshot:  entry.Snapshot,
+		}, err
+	})
+}
+
+func (app *App) dumpTemplates(ctx context.Context, writer *backup.Writer) error {
+	return dumpCollection(ctx, writer, app.Templates, backup.KindTemplate, func(cursor *mongo.Cursor) (interface{}, error) {
+		var template TaskTemplate
+		err := cursor.Decode(&template)
+		return backup.Template{
+			ID:         template.TemplateID.Hex(),
+			Definition: template.Template,
+			CreatedBy:  template.CreatedBy.Hex(),
+			CreatedAt:  template.CreatedAt.UTC(),
+			UpdatedAt:  optionalUTC(template.UpdatedAt),
+		}, err
+	})
+}
+
+func optionalHex(id *primitive.ObjectID) *string {
+	if id == nil {
+		return nil
+	}
+	value := id.Hex()
+	return &value
+}
+
+func optionalUTC(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	value := t.UTC()
+	return &value
+}
+
+// Restore loads a dump into the database, which must be empty. MongoDB
+// cannot insert that much in one transaction, so a restore that fails
+// halfway empties the collections again. IDs of a MongoDB dump are kept; the
+// IDs of other dumps are replaced by new ones, references included.
+func (app *App) Restore(w http.ResponseWriter, body io.Reader) {
+	reader, err := backup.NewReader(body)
+	if err != nil {
+		log.Printf("Error reading backup: %v", err)
+		problem.Write(w, problem.BadRequest("invalid_backup", "Restore failed: "+err.Error()))
+		return
+	}
+
+	collections := app.restoredCollections()
+	for _, collection := range collections {
+		count, err := collection.CountDocuments(context.Background(), bson.M{}, options.Count().SetLimit(1))
+		if err != nil {
+			log.Printf("Error checking database contents: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		if count > 0 {
+			log.Println("Restore refused: database is not empty")
+			problem.Write(w, problem.Conflict("database_not_empty", "Database is not empty"))
+			return
+		}
+	}
+
+	restorer := &mongoRestorer{
+		app:       app,
+		users:     make(map[string]primitive.ObjectID),
+		userNames: make(map[primitive.ObjectID]string),
+		tasks:     make(map[string]primitive.ObjectID),
+		defined:   make(map[string]bool),
+		pending:   make(map[string]string),
+		blobKeys:  make(map[string]bool),
+	}
+	err = restorer.run(reader)
+	if err != nil {
+		for _, collection := range collections {
+			if _, cleanupErr := collection.DeleteMany(context.Background(), bson.M{}); cleanupErr != nil {
+				log.Printf("Error cleaning up after failed restore: %v", cleanupErr)
+			}
+		}
+		app.deleteBlobs(restorer.stored)
+		log.Printf("Error restoring backup: %v", err)
+		if errors.Is(err, backup.ErrInvalid) {
+			problem.Write(w, problem.BadRequest("invalid_backup", "Restore failed: "+err.Error()))
+			return
+		}
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(backup.Result{Source: reader.Header.Source, Restored: reader.Counts()})
+	if err != nil {
+		log.Printf("Error encoding restore result to JSON: %v", err)
+		return
+	}
+	log.Println("Backup restored successfully")
+}
+
+func (app *App) restoredCollections() []*mongo.Collection {
+	return []*mongo.Collection{app.Users, app.Tasks, app.StatusChanges, app.Comments, app.Attachments, app.TimeEntries, app.History, app.Templates}
+}
+
+type mongoRestorer struct {
+	app       *App
+	users     map[string]primitive.ObjectID
+	userNames map[primitive.ObjectID]string
+	// Tasks may be referenced as parents before they are restored, so their
+	// IDs are assigned on first sight; pending maps those parents to a
+	// subtask until they turn up.
+	tasks    map[string]primitive.ObjectID
+	defined  map[string]bool
+	pending  map[string]string
+	blobKeys map[string]bool
+	stored   []string
+
+	batch      []interface{}
+	collection *mongo.Collection
+}
+
+func (r *mongoRestorer) run(reader *backup.Reader) error {
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = r.restore(record); err != nil {
+			return err
+		}
+	}
+	if err := r.flush(); err != nil {
+		return err
+	}
+	for parent, subtask := range r.pending {
+		if !r.defined[parent] {
+			return backup.Dangling("task", subtask, "parent task", parent)
+		}
+	}
+	return nil
+}
+
+// add queues a document for collection, inserting the queue when it is full
+// or holds documents of another collection.
+func (r *mongoRestorer) add(collection *mongo.Collection, document interface{}) error {
+	if r.collection != collection || len(r.batch) == restoreBatchSize {
+		if err := r.flush(); err != nil {
+			return err
+		}
+	}
+	r.collection = collection
+	r.batch = append(r.batch, document)
+	return nil
+}
+
+func (r *mongoRestorer) flush() error {
+	if len(r.batch) == 0 {
+		return nil
+	}
+	_, err := r.collection.InsertMany(context.Background(), r.batch)
+	r.batch = nil
+	if mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("%w: duplicate record: %v", backup.ErrInvalid, err)
+	}
+	return err
+}
+
+// newID keeps the ID of a MongoDB dump and replaces any other one.
+func newID(source string) primitive.ObjectID {
+	if id, err := primitive.ObjectIDFromHex(source); err == nil {
+		return id
+	}
+	return primitive.NewObjectID()
+}
+
+func (r *mongoRestorer) user(kind, id, target string) (primitive.ObjectID, error) {
+	userID, ok := r.users[target]
+	if !ok {
+		return primitive.NilObjectID, backup.Dangling(kind, id, "user", target)
+	}
+	return userID, nil
+}
+
+func (r *mongoRestorer) task(kind, id, target string) (primitive.ObjectID, error) {
+	taskID, ok := r.tasks[target]
+	if !ok || !r.defined[target] {
+		return primitive.NilObjectID, backup.Dangling(kind, id, "task", target)
+	}
+	return taskID, nil
+}
+
+func (r *mongoRestorer) taskID(source string) primitive.ObjectID {
+	id, ok := r.tasks[source]
+	if !ok {
+		id = newID(source)
+		r.tasks[source] = id
+	}
+	return id
+}
+
+func (r *mongoRestorer) restore(record backup.Record) error {
+	app := r.app
+	switch record.Kind {
+	case backup.KindUser:
+		var user backup.User
+		if err := backup.Decode(record, &user); err != nil {
+			return err
+		}
+		if _, ok := r.users[user.ID]; ok {
+			return fmt.Errorf("%w: duplicate user ID %q", backup.ErrInvalid, user.ID)
+		}
+		id := newID(user.ID)
+		r.users[user.ID], r.userNames[id] = id, user.Name
+		return r.add(app.Users, backupUser{UserID: id, UserName: user.Name, TimeZone: user.TimeZone, FeedTokenHash: user.FeedTokenHash})
+
+	case backup.KindTask:
+		var task backup.Task
+		if err := backup.Decode(record, &task); err != nil {
+			return err
+		}
+		if r.defined[task.ID] {
+			return fmt.Errorf("%w: duplicate task ID %q", backup.ErrInvalid, task.ID)
+		}
+		r.defined[task.ID] = true
+		userID, err := r.user("task", task.ID, task.UserID)
+		if err != nil {
+			return err
+		}
+		restored := Task{
+			TaskID:          r.taskID(task.ID),
+			TaskName:        task.Name,
+			DueDate:         task.DueDate,
+			Completed:       task.Completed,
+			UserID:          userID,
+			RRule:           task.RRule,
+			TimeZone:        task.TimeZone,
+			Status:          workflow.Status(task.Status),
+			StatusChangedAt: task.StatusChangedAt,
+			Rank:            task.Rank,
+			Description:     task.Description,
+			Tags:            task.Tags,
+			Version:         1,
+		}
+		if task.StatusChangedBy != nil {
+			changedBy, err := r.user("task", task.ID, *task.StatusChangedBy)
+			if err != nil {
+				return err
+			}
+			restored.StatusChangedBy = &changedBy
+		}
+		if task.ParentTaskID != nil {
+			if !r.defined[*task.ParentTaskID] {
+				r.pending[*task.ParentTaskID] = task.ID
+			}
+			parentID := r.taskID(*task.ParentTaskID)
+			restored.ParentTaskID = &parentID
+		}
+		for _, item := range task.Checklist {
+			restored.Checklist = append(restored.Checklist, ChecklistItem{Text: item.Text, Done: item.Done})
+		}
+		return r.add(app.Tasks, restored)
+
+	case backup.KindStatusChange:
+		var change backup.StatusChange
+		if err := backup.Decode(record, &change); err != nil {
+			return err
+		}
+		taskID, err := r.task("status change", change.ID, change.TaskID)
+		if err != nil {
+			return err
+		}
+		restored := StatusChange{ChangeID: newID(change.ID), TaskID: taskID, FromStatus: workflow.Status(change.FromStatus), ToStatus: workflow.Status(change.ToStatus), ChangedAt: change.ChangedAt}
+		if change.UserID != nil {
+			if restored.UserID, err = r.user("status change", change.ID, *change.UserID); err != nil {
+				return err
+			}
+		}
+		return r.add(app.StatusChanges, restored)
+
+	case backup.KindComment:
+		var comment backup.Comment
+		if err := backup.Decode(record, &comment); err != nil {
+			return err
+		}
+		taskID, err := r.task("comment", comment.ID, comment.TaskID)
+		if err != nil {
+			return err
+		}
+		userID, err := r.user("comment", comment.ID, comment.UserID)
+		if err != nil {
+			return err
+		}
+		restored := Comment{CommentID: newID(comment.ID), TaskID: taskID, UserID: userID, AuthorName: r.userNames[userID], Content: comment.Content, CreatedAt: comment.CreatedAt, UpdatedAt: comment.UpdatedAt, Mentions: []Mention{}}
+		for _, mention := range comment.Mentions {
+			mentionedID, err := r.user("comment", comment.ID, mention)
+			if err != nil {
+				return err
+			}
+			restored.Mentions = append(restored.Mentions, Mention{UserID: mentionedID, UserName: r.userNames[mentionedID]})
+		}
+		return r.add(app.Comments, restored)
+
+	case backup.KindAttachment:
+		var attachment backup.Attachment
+		if err := backup.Decode(record, &attachment); err != nil {
+			return err
+		}
+		taskID, err := r.task("attachment", attachment.ID, attachment.TaskID)
+		if err != nil {
+			return err
+		}
+		userID, err := r.user("attachment", attachment.ID, attachment.UserID)
+		if err != nil {
+			return err
+		}
+		r.blobKeys[attachment.BlobKey] = true
+		return r.add(app.Attachments, Attachment{
+			AttachmentID: newID(attachment.ID),
+			TaskID:       taskID,
+			UserID:       userID,
+			FileName:     attachment.FileName,
+			ContentType:  attachment.ContentType,
+			Size:         attachment.Size,
+			BlobKey:      attachment.BlobKey,
+			CreatedAt:    attachment.CreatedAt,
+		})
+
+	case backup.KindBlob:
+		var blob backup.Blob
+		if err := backup.Decode(record, &blob); err != nil {
+			return err
+		}
+		// Only the blobs of restored attachments are written, so a dump cannot
+		// put anything else into the store.
+		if !r.blobKeys[blob.Key] {
+			return backup.Dangling("blob", blob.Key, "attachment", blob.Key)
+		}
+		if _, err := app.blobs().Put(context.Background(), blob.Key, bytes.NewReader(blob.Content), blob.ContentType); err != nil {
+			return err
+		}
+		r.stored = append(r.stored, blob.Key)
+
+	case backup.KindTimeEntry:
+		var entry backup.TimeEntry
+		if err := backup.Decode(record, &entry); err != nil {
+			return err
+		}
+		taskID, err := r.task("time entry", entry.ID, entry.TaskID)
+		if err != nil {
+			return err
+		}
+		userID, err := r.user("time entry", entry.ID, entry.UserID)
+		if err != nil {
+			return err
+		}
+		return r.add(app.TimeEntries, TimeEntry{EntryID: newID(entry.ID), TaskID: taskID, UserID: userID, StartedAt: entry.StartedAt, EndedAt: entry.EndedAt, Note: entry.Note, Running: entry.EndedAt == nil})
+
+	case backup.KindHistory:
+		var entry backup.History
+		if err := backup.Decode(record, &entry); err != nil {
+			return err
+		}
+		taskID, err := r.task("history entry", entry.ID, entry.TaskID)
+		if err != nil {
+			return err
+		}
+		restored := HistoryEntry{HistoryID: newID(entry.ID), TaskID: taskID, Version: entry.Version, Action: entry.Action, ChangedAt: entry.ChangedAt, Changes: entry.Changes, Snapshot: entry.Snapshot}
+		if entry.ActorID != nil {
+			actorID, err := r.user("history entry", entry.ID, *entry.ActorID)
+			if err != nil {
+				return err
+			}
+			restored.ActorID = &actorID
+		}
+		return r.add(app.History, restored)
+
+	case backup.KindTemplate:
+		var template backup.Template
+		if err := backup.Decode(record, &template); err != nil {
+			return err
+		}
+		createdBy, err := r.user("template", template.ID, template.CreatedBy)
+		if err != nil {
+			return err
+		}
+		return r.add(app.Templates, TaskTemplate{TemplateID: newID(template.ID), Template: template.Definition, CreatedBy: createdBy, CreatedAt: template.CreatedAt, UpdatedAt: template.UpdatedAt})
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/workflow"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+type BoardColumn struct {
+	Status   workflow.Status `json:"status"`
+	WIPLimit int             `json:"wip_limit,omitempty"`
+	Tasks    []Task          `json:"tasks"`
+}
+
+type Board struct {
+	Columns []BoardColumn `json:"columns"`
+}
+
+// notDeleted matches the due date of tasks that have not been deleted. Deleted
+// tasks are kept without one; they stay in their column, but are left off the
+// board and do not count toward its WIP limit.
+var notDeleted = bson.M{"$ne": time.Time{}}
+
+var (
+	errWIPLimitReached = errors.New("WIP limit reached")
+	errInvalidNeighbor = errors.New("neighbor task is not in the target column")
+)
+
+// wipLimitUnsupported answers a move into a column with a WIP limit that
+// failed with errTransactionsUnsupported.
+var wipLimitUnsupported = problem.New(http.StatusNotImplemented, "wip_limit_unsupported",
+	"WIP limits need a MongoDB replica set or sharded cluster")
+
+func (app *App) GetBoard(w http.ResponseWriter, userID string) {
+	filter := bson.M{"due_date": notDeleted}
+	if userID != "" {
+		userObjectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			log.Println("Invalid user ID:", err)
+			problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+			return
+		}
+		filter["user_id"] = userObjectID
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "rank", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := app.Tasks.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		log.Printf("Error querying tasks from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	byStatus := make(map[workflow.Status][]Task)
+	for cursor.Next(context.Background()) {
+		var task Task
+		if err = cursor.Decode(&task); err != nil {
+			log.Printf("Error decoding task: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		byStatus[task.Status] = append(byStatus[task.Status], task)
+	}
+	if err = cursor.Err(); err != nil {
+		log.Printf("Error iterating over task cursor: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	var board Board
+	for _, column := range app.workflow().BoardColumns() {
+		tasks := byStatus[column.Status]
+		if tasks == nil {
+			tasks = []Task{}
+		}
+		board.Columns = append(board.Columns, BoardColumn{
+			Status:   column.Status,
+			WIPLimit: column.WIPLimit,
+			Tasks:    tasks,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(board)
+	if err != nil {
+		log.Printf("Error encoding board to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Board retrieved successfully")
+}
+
+// MoveTask places a task into the column for status, between afterTaskID and
+// beforeTaskID. Either neighbor may be empty; without neighbors the task goes
+// to the end of the column. An empty status keeps the current column.
+func (app *App) MoveTask(w http.ResponseWriter, taskID, userID, status, afterTaskID, beforeTaskID string) {
+	objectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	var task Task
+	err = app.Tasks.FindOne(context.Background(), bson.M{"_id": objectID, "user_id": userObjectID}).Decode(&task)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Println("Task assignment not found or user does not have permission")
+			problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+			return
+		}
+		log.Printf("Error checking task assignment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	before := snapshotOf(task)
+
+	target := workflow.Status(status)
+	if target == "" {
+		target = task.Status
+	}
+
+	// Checking the WIP limit is only safe in the transaction that moves the
+	// task, which a standalone server cannot run.
+	limited := target != task.Status && app.workflow().WIPLimit(target) > 0
+	var next *Task
+	err = app.inTransaction(limited, func(ctx context.Context) error {
+		boardRank, err := app.placeTask(ctx, task, target, afterTaskID, beforeTaskID)
+		if err != nil {
+			return err
+		}
+		if target != task.Status {
+			next, err = app.changeStatus(ctx, task, userObjectID, target, boardRank)
+			return err
+		}
+
+		// The filter on the version makes a concurrent change of the same
+		// task fail instead of silently overwriting it.
+		result, err := app.Tasks.UpdateOne(ctx,
+			bson.M{"_id": task.TaskID, "version": task.Version},
+			bson.M{"$set": bson.M{"rank": boardRank}, "$inc": bson.M{"version": 1}})
+		if err == nil && result.MatchedCount == 0 {
+			err = errConcurrentStatusChange
+		}
+		return err
+	})
+	if err == nil && next != nil {
+		err = app.recordOccurrence(*next, userObjectID)
+	}
+	if err == nil {
+		err = app.recordHistory(objectID, userObjectID, audit.Updated, &before)
+	}
+	switch {
+	case errors.Is(err, workflow.ErrUnknownStatus):
+		log.Println("Invalid status:", err)
+		problem.Write(w, problem.Malformed("status", "Unknown status"))
+		return
+	case errors.Is(err, workflow.ErrInvalidTransition):
+		log.Println("Invalid status transition:", err)
+		problem.Write(w, problem.Conflict("status_transition_not_allowed", "Status transition not allowed"))
+		return
+	case errors.Is(err, errWIPLimitReached):
+		log.Println("WIP limit reached:", err)
+		problem.Write(w, problem.Conflict("wip_limit_reached", "WIP limit reached for column"))
+		return
+	case errors.Is(err, errTransactionsUnsupported):
+		log.Println("Error moving task:", err)
+		problem.Write(w, wipLimitUnsupported)
+		return
+	case errors.Is(err, errConcurrentStatusChange):
+		log.Println("Concurrent move:", err)
+		problem.Write(w, problem.Conflict("concurrent_change", "Task changed concurrently"))
+		return
+	case errors.Is(err, errInvalidNeighbor), errors.Is(err, rank.ErrInvalidRank):
+		log.Println("Invalid position:", err)
+		problem.Write(w, problem.Malformed("position", "Invalid position"))
+		return
+	case err != nil:
+		log.Printf("Error moving task: %v", err)
+		problem.Write(w, problem.Internal("Error moving task"))
+		return
+	}
+
+	app.updated(w, objectID, "Task moved successfully")
+}
+
+// placeTask checks the move against the workflow and the WIP limit of the
+// target column and returns the rank that puts the task between its neighbors.
+// The WIP limit holds only if ctx is a transaction the move is written in.
+func (app *App) placeTask(ctx context.Context, task Task, target workflow.Status, afterTaskID, beforeTaskID string) (string, error) {
+	wf := app.workflow()
+	if err := wf.CheckTransition(task.Status, target); err != nil {
+		return "", err
+	}
+
+	if limit := wf.WIPLimit(target); limit > 0 && target != task.Status {
+		// Every move into the column writes its document, so concurrent
+		// transactions moving tasks into it conflict and are retried one
+		// after the other instead of all counting the same tasks.
+		_, err := app.DB.Collection("board_columns").UpdateOne(ctx,
+			bson.M{"_id": target}, bson.M{"$inc": bson.M{"moves": 1}}, options.Update().SetUpsert(true))
+		if err != nil {
+			return "", err
+		}
+		count, err := app.Tasks.CountDocuments(ctx, bson.M{"status": target, "due_date": notDeleted})
+		if err != nil {
+			return "", err
+		}
+		if count >= int64(limit) {
+			return "", errWIPLimitReached
+		}
+	}
+
+	after, err := app.neighborRank(ctx, afterTaskID, task.TaskID, target)
+	if err != nil {
+		return "", err
+	}
+	before, err := app.neighborRank(ctx, beforeTaskID, task.TaskID, target)
+	if err != nil {
+		return "", err
+	}
+
+	others := bson.M{"status": target, "_id": bson.M{"$ne": task.TaskID}}
+	switch {
+	case afterTaskID != "" && beforeTaskID == "":
+		others["rank"] = bson.M{"$gt": after}
+		before, err = app.boundaryRank(ctx, others, 1)
+	case afterTaskID == "" && beforeTaskID != "":
+		others["rank"] = bson.M{"$lt": before}
+		after, err = app.boundaryRank(ctx, others, -1)
+	case afterTaskID == "" && beforeTaskID == "":
+		after, err = app.boundaryRank(ctx, others, -1)
+	}
+	if err != nil {
+		return "", err
+	}
+	return rank.Between(after, before)
+}
+
+// boundaryRank returns the lowest (direction 1) or highest (direction -1) rank
+// among tasks matching filter, or an empty rank if there are none.
+func (app *App) boundaryRank(ctx context.Context, filter bson.M, direction int) (string, error) {
+	var boundary Task
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "rank", Value: direction}})
+	err := app.Tasks.FindOne(ctx, filter, findOptions).Decode(&boundary)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", err
+	}
+	return boundary.Rank, nil
+}
+
+func (app *App) neighborRank(ctx context.Context, neighborID string, taskID primitive.ObjectID, target workflow.Status) (string, error) {
+	if neighborID == "" {
+		return "", nil
+	}
+	objectID, err := primitive.ObjectIDFromHex(neighborID)
+	if err != nil || objectID == taskID {
+		return "", errInvalidNeighbor
+	}
+
+	var neighbor Task
+	err = app.Tasks.FindOne(ctx, bson.M{"_id": objectID}).Decode(&neighbor)
+	if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && neighbor.Status != target) {
+		return "", errInvalidNeighbor
+	}
+	return neighbor.Rank, err
+}
@@ -0,0 +1,120 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/workflow"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBoardLeavesOutDeletedTasks(t *testing.T) {
+	tests := []struct {
+		name       string
+		deleteTask bool
+		code       int
+		todo       []string
+	}{
+		{name: "task in the full column", code: http.StatusConflict, todo: []string{"water plants"}},
+		{name: "deleted task in the full column", deleteTask: true, code: http.StatusOK, todo: []string{"repot cactus"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApp(t)
+			requireTransactions(t, app)
+			app.Workflow = workflow.Default()
+			app.Workflow.Columns[0] = workflow.Column{Status: workflow.Todo, WIPLimit: 1}
+			task := addTask(t, app, "")
+			other := addTask(t, app, "")
+			_, err := app.Tasks.UpdateOne(context.Background(), bson.M{"_id": other.TaskID},
+				bson.M{"$set": bson.M{"task_name": "repot cactus", "status": workflow.InProgress}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.deleteTask {
+				recorder := httptest.NewRecorder()
+				app.DeleteTask(recorder, task.TaskID.Hex(), task.UserID.Hex(), nil)
+				if recorder.Code != http.StatusNoContent {
+					t.Fatalf("DeleteTask: %d %s", recorder.Code, recorder.Body)
+				}
+			}
+
+			recorder := httptest.NewRecorder()
+			app.MoveTask(recorder, other.TaskID.Hex(), other.UserID.Hex(), string(workflow.Todo), "", "")
+			if recorder.Code != test.code {
+				t.Errorf("MoveTask: %d %s, want %d", recorder.Code, recorder.Body, test.code)
+			}
+
+			recorder = httptest.NewRecorder()
+			app.GetBoard(recorder, "")
+			var board Board
+			if err = json.NewDecoder(recorder.Body).Decode(&board); err != nil {
+				t.Fatal(err)
+			}
+			var todo []string
+			for _, task := range board.Columns[0].Tasks {
+				todo = append(todo, task.TaskName)
+			}
+			if !reflect.DeepEqual(todo, test.todo) {
+				t.Errorf("to do: %q, want %q", todo, test.todo)
+			}
+		})
+	}
+}
+
+func TestMoveTaskKeepsWIPLimit(t *testing.T) {
+	app := newTestApp(t)
+	app.Workflow = workflow.Default()
+	app.Workflow.Columns[1] = workflow.Column{Status: workflow.InProgress, WIPLimit: 2}
+	supported, err := app.supportsTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tasks []Task
+	for i := 0; i < 6; i++ {
+		tasks = append(tasks, addTask(t, app, ""))
+	}
+	codes := make([]int, len(tasks))
+	var wg sync.WaitGroup
+	for i, task := range tasks {
+		wg.Add(1)
+		go func(i int, task Task) {
+			defer wg.Done()
+			recorder := httptest.NewRecorder()
+			app.MoveTask(recorder, task.TaskID.Hex(), task.UserID.Hex(), string(workflow.InProgress), "", "")
+			codes[i] = recorder.Code
+		}(i, task)
+	}
+	wg.Wait()
+
+	moved := 0
+	for _, code := range codes {
+		switch {
+		case code == http.StatusOK:
+			moved++
+		case !supported && code != http.StatusNotImplemented, supported && code != http.StatusConflict:
+			t.Errorf("MoveTask answered %d", code)
+		}
+	}
+	inProgress, err := app.Tasks.CountDocuments(context.Background(), bson.M{"status": workflow.InProgress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := app.StatusChanges.CountDocuments(context.Background(), bson.M{"to_status": workflow.InProgress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := int64(2)
+	if !supported {
+		want = 0
+	}
+	if int64(moved) != want || inProgress != want || changes != want {
+		t.Errorf("%d moves succeeded, %d tasks and %d status changes in progress, want %d", moved, inProgress, changes, want)
+	}
+}
@@ -0,0 +1,563 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/recurrence"
+	"Simple_Task_Manager/workflow"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+// bulkWrite is an operation of a batch that passed validation, together with
+// what has to be recorded once it has been written.
+type bulkWrite struct {
+	index     int
+	op        bulk.Op
+	status    int
+	taskID    primitive.ObjectID
+	actorID   primitive.ObjectID
+	model     mongo.WriteModel
+	applied   bson.M
+	task      Task
+	target    workflow.Status
+	changedAt time.Time
+}
+
+// errTransactionsUnsupported is returned for an atomic batch when the server
+// is a standalone one, which does not support transactions.
+var errTransactionsUnsupported = errors.New("transactions are not supported by this MongoDB deployment")
+
+// atomicModeUnsupported answers an atomic batch or import that failed with
+// errTransactionsUnsupported.
+var atomicModeUnsupported = problem.New(http.StatusNotImplemented, "atomic_mode_unsupported",
+	"Atomic mode needs a MongoDB replica set or sharded cluster; use mode=partial")
+
+// errRolledBack aborts the transaction of an atomic batch in which a write
+// failed.
+var errRolledBack = errors.New("bulk write rolled back")
+
+func (app *App) BulkTasks(w http.ResponseWriter, mode bulk.Mode, operations []bulk.Operation) {
+	response, err := app.runBulk(mode, operations)
+	if errors.Is(err, errTransactionsUnsupported) {
+		log.Println("Error applying bulk operations:", err)
+		problem.Write(w, atomicModeUnsupported)
+		return
+	}
+	if err != nil {
+		log.Printf("Error applying bulk operations: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	writeBulkResponse(w, response)
+}
+
+// runBulk validates every operation up front and then sends all of them to
+// MongoDB as one bulk write. In atomic mode nothing is written unless every
+// operation is valid, and the write runs in a transaction that is aborted if
+// any of it fails, so it needs a replica set or a sharded cluster. In partial
+// mode the valid operations are written unordered.
+func (app *App) runBulk(mode bulk.Mode, operations []bulk.Operation) (bulk.Response, error) {
+	response := bulk.Response{Mode: mode, Results: []bulk.Result{}}
+	if mode == bulk.Atomic {
+		supported, err := app.supportsTransactions()
+		if err != nil {
+			return response, err
+		}
+		if !supported {
+			return response, errTransactionsUnsupported
+		}
+	}
+
+	writes, newUsers, failures, err := app.planBulk(operations)
+	if err != nil {
+		return response, err
+	}
+
+	if mode == bulk.Atomic && len(failures) > 0 {
+		for i, operation := range operations {
+			if failure, failed := failures[i]; failed {
+				response.Fail(i, operation.Op, failure)
+			} else {
+				response.Succeed(i, operation.Op, 0, nil)
+			}
+		}
+		response.Abort()
+		return response, nil
+	}
+
+	if mode == bulk.Atomic {
+		err = app.writeAtomically(writes, newUsers, failures)
+	} else {
+		err = app.writeBulk(context.Background(), mode, writes, newUsers, failures)
+	}
+	if err != nil {
+		return response, err
+	}
+
+	rolledBack := mode == bulk.Atomic && len(failures) > 0
+	for _, write := range writes {
+		if _, failed := failures[write.index]; failed || rolledBack {
+			continue
+		}
+		if err := app.recordBulkWrite(write); err != nil {
+			log.Printf("Error recording bulk operation %d: %v", write.index, err)
+			failures[write.index] = bulk.Fail(http.StatusInternalServerError, "Internal server error")
+		}
+	}
+
+	written := make(map[int]bulkWrite, len(writes))
+	for _, write := range writes {
+		written[write.index] = write
+	}
+	for i, operation := range operations {
+		if failure, failed := failures[i]; failed {
+			response.Fail(i, operation.Op, failure)
+			continue
+		}
+		response.Succeed(i, operation.Op, written[i].status, written[i].taskID.Hex())
+	}
+	if rolledBack {
+		response.Abort()
+	}
+	return response, nil
+}
+
+// supportsTransactions reports whether the server is a replica set member or
+// a mongos, the deployments MongoDB supports transactions on.
+func (app *App) supportsTransactions() (bool, error) {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := app.DB.Client().Database("admin").RunCommand(context.Background(), bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		return false, err
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid", nil
+}
+
+// inTransaction runs fn in a transaction, retried from scratch after a
+// transient error. A standalone server has no transactions, so there fn runs
+// on its own and its writes are applied one by one, unless required is set, in
+// which case errTransactionsUnsupported is returned without running it.
+func (app *App) inTransaction(required bool, fn func(ctx context.Context) error) error {
+	supported, err := app.supportsTransactions()
+	if err != nil {
+		return err
+	}
+	if !supported {
+		if required {
+			return errTransactionsUnsupported
+		}
+		return fn(context.Background())
+	}
+
+	session, err := app.DB.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+	_, err = session.WithTransaction(context.Background(), func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+// writeAtomically writes the new users and the writes of an atomic batch in
+// one transaction, and aborts it if any write fails. The failures are added
+// to failures either way.
+func (app *App) writeAtomically(writes []bulkWrite, newUsers []interface{}, failures map[int]*bulk.Failure) error {
+	session, err := app.DB.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	var attempt map[int]*bulk.Failure
+	_, err = session.WithTransaction(context.Background(), func(ctx mongo.SessionContext) (interface{}, error) {
+		// The transaction is retried from scratch after a transient error.
+		attempt = make(map[int]*bulk.Failure)
+		if err := app.writeBulk(ctx, bulk.Atomic, writes, newUsers, attempt); err != nil {
+			return nil, err
+		}
+		if len(attempt) > 0 {
+			return nil, errRolledBack
+		}
+		return nil, nil
+	})
+	for index, failure := range attempt {
+		failures[index] = failure
+	}
+	if errors.Is(err, errRolledBack) {
+		return nil
+	}
+	return err
+}
+
+// writeBulk inserts the new users and sends the writes as one bulk write.
+func (app *App) writeBulk(ctx context.Context, mode bulk.Mode, writes []bulkWrite, newUsers []interface{}, failures map[int]*bulk.Failure) error {
+	if len(newUsers) > 0 {
+		if _, err := app.Users.InsertMany(ctx, newUsers); err != nil {
+			return err
+		}
+	}
+	return app.executeBulk(ctx, mode, writes, failures)
+}
+
+func writeBulkResponse(w http.ResponseWriter, response bulk.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode())
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding bulk response to JSON: %v", err)
+		return
+	}
+	log.Printf("Bulk operations applied: %d succeeded, %d failed", response.Succeeded, response.Failed)
+}
+
+// planBulk validates operations against the current tasks and users, fetched
+// with one query each, and turns the valid ones into writes. Users that
+// create operations refer to by an unknown name are returned to be inserted.
+func (app *App) planBulk(operations []bulk.Operation) ([]bulkWrite, []interface{}, map[int]*bulk.Failure, error) {
+	failures := make(map[int]*bulk.Failure)
+	taskIDs := make(map[int]primitive.ObjectID)
+	actorIDs := make(map[int]primitive.ObjectID)
+	var ids []primitive.ObjectID
+	var names []string
+	for i, operation := range operations {
+		if failure := operation.Validate(); failure != nil {
+			failures[i] = failure
+			continue
+		}
+		if operation.Op == bulk.Create {
+			names = append(names, operation.UserName)
+			continue
+		}
+		taskID, err := primitive.ObjectIDFromHex(string(operation.TaskID))
+		if err != nil {
+			failures[i] = bulk.Fail(http.StatusBadRequest, "Invalid task ID")
+			continue
+		}
+		actorID, err := primitive.ObjectIDFromHex(string(operation.UserID))
+		if err != nil {
+			failures[i] = bulk.Fail(http.StatusBadRequest, "Invalid user ID")
+			continue
+		}
+		taskIDs[i], actorIDs[i] = taskID, actorID
+		ids = append(ids, taskID)
+	}
+
+	tasks := make(map[primitive.ObjectID]Task)
+	if len(ids) > 0 {
+		var found []Task
+		cursor, err := app.Tasks.Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}})
+		if err == nil {
+			err = cursor.All(context.Background(), &found)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, task := range found {
+			tasks[task.TaskID] = task
+		}
+	}
+
+	users := make(map[string]User)
+	if len(names) > 0 {
+		var found []User
+		cursor, err := app.Users.Find(context.Background(), bson.M{"user_name": bson.M{"$in": names}})
+		if err == nil {
+			err = cursor.All(context.Background(), &found)
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, user := range found {
+			users[user.UserName] = user
+		}
+	}
+
+	ranks := make(map[workflow.Status]string)
+	nextRank := func(status workflow.Status) (string, error) {
+		var next string
+		var err error
+		if last, ok := ranks[status]; ok {
+			next, err = rank.After(last)
+		} else {
+			next, err = app.lastRank(context.Background(), status)
+		}
+		ranks[status] = next
+		return next, err
+	}
+
+	var writes []bulkWrite
+	var newUsers []interface{}
+	planned := make(map[primitive.ObjectID]bool)
+	for i, operation := range operations {
+		if _, failed := failures[i]; failed {
+			continue
+		}
+
+		var write bulkWrite
+		var err error
+		if operation.Op == bulk.Create {
+			user, known := users[operation.UserName]
+			if !known {
+				user = User{UserID: primitive.NewObjectID(), UserName: operation.UserName, TimeZone: "UTC"}
+			}
+			write, err = app.planCreate(operation, user, nextRank)
+			if err == nil && !known {
+				users[user.UserName] = user
+				newUsers = append(newUsers, user)
+			}
+		} else {
+			task, found := tasks[taskIDs[i]]
+			switch {
+			case !found || task.UserID != actorIDs[i]:
+				err = bulk.Fail(http.StatusNotFound, "Task assignment not found or user does not have permission")
+			case planned[task.TaskID]:
+				err = bulk.Fail(http.StatusConflict, "Task appears more than once in the batch")
+			default:
+				planned[task.TaskID] = true
+				write, err = app.planChange(operation, task, actorIDs[i], nextRank)
+			}
+		}
+
+		if err != nil {
+			failures[i] = bulk.FailureOf(err)
+			if failures[i].Status == http.StatusInternalServerError {
+				log.Printf("Error preparing bulk operation %d: %v", i, err)
+			}
+			continue
+		}
+		write.index = i
+		write.op = operation.Op
+		writes = append(writes, write)
+	}
+	return writes, newUsers, failures, nil
+}
+
+func (app *App) planCreate(operation bulk.Operation, user User, nextRank func(workflow.Status) (string, error)) (bulkWrite, error) {
+	timeZone := operation.TimeZone
+	if timeZone == "" {
+		timeZone = user.TimeZone
+	}
+	location, err := dates.LoadLocation(timeZone)
+	if err != nil {
+		return bulkWrite{}, bulk.Fail(http.StatusBadRequest, "Invalid time zone")
+	}
+	due, err := dates.Resolve(operation.DueDate, app.now(), location)
+	if err != nil {
+		return bulkWrite{}, bulk.Fail(http.StatusBadRequest, "Invalid due date")
+	}
+	if operation.RRule != "" {
+		if err := recurrence.Validate(operation.RRule, timeZone); err != nil {
+			return bulkWrite{}, bulk.Fail(http.StatusBadRequest, "Invalid recurrence rule")
+		}
+	}
+
+	wf := app.workflow()
+	status := wf.Initial
+	if operation.Completed {
+		status = wf.CompletedStatus()
+	}
+	if operation.Status != "" {
+		status = workflow.Status(operation.Status)
+		if !wf.Valid(status) {
+			return bulkWrite{}, bulk.Fail(http.StatusBadRequest, "Unknown status")
+		}
+	}
+	boardRank, err := nextRank(status)
+	if err != nil {
+		return bulkWrite{}, err
+	}
+	createdAt := app.now().UTC()
+	task := Task{
+		TaskID:          primitive.NewObjectID(),
+		TaskName:        operation.TaskName,
+		DueDate:         due,
+		Completed:       wf.IsCompleted(status),
+		UserID:          user.UserID,
+		RRule:           operation.RRule,
+		TimeZone:        timeZone,
+		Status:          status,
+		StatusChangedBy: &user.UserID,
+		StatusChangedAt: &createdAt,
+		Rank:            boardRank,
+		Tags:            operation.Tags,
+		Version:         1,
+	}
+	return bulkWrite{
+		status:  http.StatusCreated,
+		taskID:  task.TaskID,
+		actorID: user.UserID,
+		model:   mongo.NewInsertOneModel().SetDocument(task),
+		task:    task,
+	}, nil
+}
+
+func (app *App) planChange(operation bulk.Operation, task Task, actorID primitive.ObjectID, nextRank func(workflow.Status) (string, error)) (bulkWrite, error) {
+	write := bulkWrite{status: http.StatusOK, taskID: task.TaskID, actorID: actorID, task: task}
+	wf := app.workflow()
+
+	set := bson.M{}
+	filter := bson.M{"_id": task.TaskID, "user_id": actorID}
+	if operation.Op == bulk.Delete {
+		set = bson.M{
+			"task_name": "X",
+			"due_date":  time.Time{},
+			"completed": false,
+			"status":    wf.Initial,
+		}
+	} else {
+		target := workflow.Status(operation.Status)
+		if operation.Op == bulk.Complete {
+			target = wf.CompletedStatus()
+		}
+		if target != "" {
+			if err := wf.CheckTransition(task.Status, target); err != nil {
+				if errors.Is(err, workflow.ErrUnknownStatus) {
+					return bulkWrite{}, bulk.Fail(http.StatusBadRequest, "Unknown status")
+				}
+				return bulkWrite{}, bulk.Fail(http.StatusConflict, "Status transition not allowed")
+			}
+		}
+
+		if operation.Op == bulk.Update && operation.DueDate != "" {
+			location, err := dates.LoadLocation(task.TimeZone)
+			if err != nil {
+				return bulkWrite{}, err
+			}
+			due, err := dates.Resolve(operation.DueDate, app.now(), location)
+			if err != nil {
+				return bulkWrite{}, bulk.Fail(http.StatusBadRequest, "Invalid due date")
+			}
+			set["due_date"] = due
+		}
+
+		if target != "" && target != task.Status {
+			boardRank, err := nextRank(target)
+			if err != nil {
+				return bulkWrite{}, err
+			}
+			write.target = target
+			write.changedAt = app.now().UTC()
+			set["status"] = target
+			set["completed"] = wf.IsCompleted(target)
+			set["status_changed_by"] = actorID
+			set["status_changed_at"] = write.changedAt
+			set["rank"] = boardRank
+			// As in changeStatus, filtering on the previous status keeps two
+			// concurrent completions from both scheduling a next occurrence.
+			filter["status"] = task.Status
+			filter["rank"] = task.Rank
+		}
+	}
+
+	if len(set) == 0 {
+		return write, nil
+	}
+	write.model = mongo.NewUpdateOneModel().SetFilter(filter).SetUpdate(bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+	write.applied = bson.M{"_id": task.TaskID}
+	for field, value := range set {
+		write.applied[field] = value
+	}
+	return write, nil
+}
+
+// executeBulk sends the writes as one bulk write and adds a failure for every
+// write that was not applied. An atomic batch is written in order, so that it
+// stops at its first failure.
+func (app *App) executeBulk(ctx context.Context, mode bulk.Mode, writes []bulkWrite, failures map[int]*bulk.Failure) error {
+	var models []mongo.WriteModel
+	var modelWrites []bulkWrite
+	for _, write := range writes {
+		if write.model != nil {
+			models = append(models, write.model)
+			modelWrites = append(modelWrites, write)
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	result, err := app.Tasks.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(mode == bulk.Atomic))
+	var bulkErr mongo.BulkWriteException
+	if err != nil && !errors.As(err, &bulkErr) {
+		return err
+	}
+
+	for _, writeErr := range bulkErr.WriteErrors {
+		log.Printf("Error writing bulk operation %d: %v", modelWrites[writeErr.Index].index, writeErr)
+		failures[modelWrites[writeErr.Index].index] = bulk.Fail(http.StatusInternalServerError, "Internal server error")
+	}
+	if mode == bulk.Atomic && len(bulkErr.WriteErrors) > 0 {
+		return nil
+	}
+
+	var updates []bulkWrite
+	for _, write := range modelWrites {
+		if _, failed := failures[write.index]; failed {
+			continue
+		}
+		if write.applied != nil {
+			updates = append(updates, write)
+		}
+	}
+
+	// Updates filtered on a status that changed in the meantime match
+	// nothing. Only then is it worth finding out which ones they were.
+	if result != nil && int(result.MatchedCount) >= len(updates) {
+		return nil
+	}
+	for _, write := range updates {
+		count, err := app.Tasks.CountDocuments(ctx, write.applied)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			failures[write.index] = bulk.Fail(http.StatusConflict, "Task changed concurrently")
+		}
+	}
+	return nil
+}
+
+// recordBulkWrite does for an applied write what the single-task endpoints do
+// after theirs: record status changes and history, schedule recurrences and
+// purge attachments of deleted tasks.
+func (app *App) recordBulkWrite(write bulkWrite) error {
+	if write.op == bulk.Create {
+		return app.recordHistory(write.taskID, write.actorID, audit.Created, nil)
+	}
+
+	before := snapshotOf(write.task)
+	if write.op == bulk.Delete {
+		if err := app.recordHistory(write.taskID, write.actorID, audit.Deleted, &before); err != nil {
+			return err
+		}
+		return app.purgeAttachments(write.taskID)
+	}
+
+	if write.target != "" {
+		task := write.task
+		if due, ok := write.applied["due_date"].(time.Time); ok {
+			task.DueDate = due
+		}
+		if err := app.afterStatusChange(task, write.actorID, write.target, write.changedAt); err != nil {
+			return err
+		}
+	}
+	return app.recordHistory(write.taskID, write.actorID, audit.Updated, &before)
+}
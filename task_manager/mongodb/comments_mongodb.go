@@ -0,0 +1,280 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/mentions"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Comment struct {
+	CommentID  primitive.ObjectID `json:"comment_id" bson:"_id"`
+	TaskID     primitive.ObjectID `json:"task_id" bson:"task_id"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	AuthorName string             `json:"author_name" bson:"author_name"`
+	Content    string             `json:"content" bson:"content"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt  *time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+	Mentions   []Mention          `json:"mentions" bson:"mentions"`
+}
+
+type Mention struct {
+	UserID   primitive.ObjectID `json:"user_id" bson:"user_id"`
+	UserName string             `json:"user_name" bson:"user_name"`
+}
+
+func (app *App) GetComments(w http.ResponseWriter, taskID string, limit, offset int) {
+	taskObjectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+
+	if !app.taskExists(w, taskObjectID) {
+		return
+	}
+
+	filter := bson.M{"task_id": taskObjectID}
+	total, err := app.Comments.CountDocuments(context.Background(), filter)
+	if err != nil {
+		log.Printf("Error counting comments: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit))
+	cursor, err := app.Comments.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		log.Printf("Error querying comments from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	comments := []Comment{}
+	if err = cursor.All(context.Background(), &comments); err != nil {
+		log.Printf("Error decoding comments: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	for i := range comments {
+		if comments[i].Mentions == nil {
+			comments[i].Mentions = []Mention{}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.FormatInt(total, 10))
+	err = json.NewEncoder(w).Encode(comments)
+	if err != nil {
+		log.Printf("Error encoding comments to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Comments gathered successfully")
+}
+
+func (app *App) CreateComment(w http.ResponseWriter, taskID, userID, content string) {
+	taskObjectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+	if !app.taskExists(w, taskObjectID) {
+		return
+	}
+
+	var author User
+	err = app.Users.FindOne(context.Background(), bson.M{"_id": userObjectID}).Decode(&author)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Println("User not found")
+			problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+			return
+		}
+		log.Printf("Error retrieving user: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	resolved, err := app.resolveMentions(content)
+	if err != nil {
+		log.Printf("Error resolving mentions: %v", err)
+		problem.Write(w, problem.Internal("Error inserting comment"))
+		return
+	}
+
+	comment := Comment{
+		CommentID:  primitive.NewObjectID(),
+		TaskID:     taskObjectID,
+		UserID:     author.UserID,
+		AuthorName: author.UserName,
+		Content:    content,
+		CreatedAt:  app.now().UTC(),
+		Mentions:   resolved,
+	}
+
+	_, err = app.Comments.InsertOne(context.Background(), comment)
+	if err != nil {
+		log.Printf("Error inserting comment: %v", err)
+		problem.Write(w, problem.Internal("Error inserting comment"))
+		return
+	}
+
+	log.Println("Comment created successfully")
+	respond.Created(w, "/comments/"+comment.CommentID.Hex(), comment, "Comment created successfully")
+}
+
+func (app *App) UpdateComment(w http.ResponseWriter, commentID, userID, content string) {
+	commentObjectID, userObjectID, ok := app.checkCommentAuthor(w, commentID, userID)
+	if !ok {
+		return
+	}
+	resolved, err := app.resolveMentions(content)
+	if err != nil {
+		log.Printf("Error resolving mentions: %v", err)
+		problem.Write(w, problem.Internal("Error updating comment"))
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"content":    content,
+			"updated_at": app.now().UTC(),
+			"mentions":   resolved,
+		},
+	}
+
+	_, err = app.Comments.UpdateOne(context.Background(), bson.M{"_id": commentObjectID, "user_id": userObjectID}, update)
+	if err != nil {
+		log.Printf("Error updating comment: %v", err)
+		problem.Write(w, problem.Internal("Error updating comment"))
+		return
+	}
+
+	var comment Comment
+	if err = app.Comments.FindOne(context.Background(), bson.M{"_id": commentObjectID}).Decode(&comment); err != nil {
+		log.Printf("Error retrieving updated comment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Comment updated successfully")
+	respond.Updated(w, comment, "Comment updated successfully")
+}
+
+func (app *App) DeleteComment(w http.ResponseWriter, commentID, userID string) {
+	commentObjectID, userObjectID, ok := app.checkCommentAuthor(w, commentID, userID)
+	if !ok {
+		return
+	}
+
+	_, err := app.Comments.DeleteOne(context.Background(), bson.M{"_id": commentObjectID, "user_id": userObjectID})
+	if err != nil {
+		log.Printf("Error deleting comment: %v", err)
+		problem.Write(w, problem.Internal("Error deleting comment"))
+		return
+	}
+
+	log.Println("Comment deleted successfully")
+	respond.Deleted(w, "Comment deleted successfully")
+}
+
+func (app *App) taskExists(w http.ResponseWriter, taskID primitive.ObjectID) bool {
+	count, err := app.Tasks.CountDocuments(context.Background(), bson.M{"_id": taskID})
+	if err != nil {
+		log.Printf("Error checking task existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return false
+	}
+	if count == 0 {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return false
+	}
+	return true
+}
+
+// checkCommentAuthor writes an error response and returns false unless the
+// comment exists and was written by userID.
+func (app *App) checkCommentAuthor(w http.ResponseWriter, commentID, userID string) (primitive.ObjectID, primitive.ObjectID, bool) {
+	commentObjectID, err := primitive.ObjectIDFromHex(commentID)
+	if err != nil {
+		log.Println("Invalid comment ID:", err)
+		problem.Write(w, problem.Malformed("comment_id", "Invalid comment ID"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	var comment Comment
+	err = app.Comments.FindOne(context.Background(), bson.M{"_id": commentObjectID}).Decode(&comment)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("Comment not found")
+		problem.Write(w, problem.NotFound("comment_not_found", "Comment not found"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	case err != nil:
+		log.Printf("Error retrieving comment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	case comment.UserID != userObjectID:
+		log.Println("User is not the author of the comment")
+		problem.Write(w, problem.Forbidden("not_author", "Only the author can change a comment"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return commentObjectID, userObjectID, true
+}
+
+// resolveMentions resolves the @mentions in content against the users
+// collection. Mentions of unknown users stay plain text.
+func (app *App) resolveMentions(content string) ([]Mention, error) {
+	resolved := []Mention{}
+	keys := mentions.Extract(content)
+	if len(keys) == 0 {
+		return resolved, nil
+	}
+
+	findOptions := options.Find().
+		SetProjection(bson.M{"user_name": 1}).
+		SetSort(bson.D{{Key: "user_name", Value: 1}})
+	cursor, err := app.Users.Find(context.Background(), bson.M{}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var user User
+		if err = cursor.Decode(&user); err != nil {
+			return nil, err
+		}
+		if mentions.Matches(keys, user.UserName) {
+			resolved = append(resolved, Mention{UserID: user.UserID, UserName: user.UserName})
+		}
+	}
+	return resolved, cursor.Err()
+}
@@ -0,0 +1,135 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/taskcsv"
+	"Simple_Task_Manager/todolist"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+)
+
+// ExportTasks streams the tasks as CSV or as a todolist format, those of one
+// user if userID is not empty. Rows are written while the cursor is read, so
+// an error after the first rows can only end the response early.
+func (app *App) ExportTasks(w http.ResponseWriter, format string, userID string) {
+	filter := bson.M{}
+	if userID != "" {
+		objectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			log.Println("Invalid user ID:", err)
+			problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+			return
+		}
+		filter["user_id"] = objectID
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.M{"from": app.Users.Name(), "localField": "user_id", "foreignField": "_id", "as": "user"}}},
+		{{Key: "$set", Value: bson.M{"user_name": bson.M{"$arrayElemAt": bson.A{"$user.user_name", 0}}}}},
+		{{Key: "$project", Value: bson.M{"user": 0}}},
+	}
+	cursor, err := app.Tasks.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		log.Printf("Error querying tasks from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer cursor.Close(context.Background())
+
+	writer, err := newExportWriter(w, format)
+	if err != nil {
+		log.Printf("Error writing task export: %v", err)
+		return
+	}
+
+	exported := 0
+	for cursor.Next(context.Background()) {
+		var task struct {
+			Task     `bson:",inline"`
+			UserName string `bson:"user_name"`
+		}
+		if err = cursor.Decode(&task); err != nil {
+			log.Printf("Error decoding task: %v", err)
+			return
+		}
+		err = writer.Write(taskcsv.Row{
+			TaskID:      task.TaskID.Hex(),
+			TaskName:    task.TaskName,
+			UserID:      task.UserID.Hex(),
+			UserName:    task.UserName,
+			Status:      string(task.Status),
+			Completed:   task.Completed,
+			DueDate:     task.DueDate,
+			TimeZone:    task.TimeZone,
+			RRule:       task.RRule,
+			Description: task.Description,
+			Tags:        task.Tags,
+		})
+		if err != nil {
+			log.Printf("Error writing task export: %v", err)
+			return
+		}
+		exported++
+	}
+	if err = cursor.Err(); err != nil {
+		log.Printf("Error iterating over tasks: %v", err)
+		return
+	}
+	if err = writer.Flush(); err != nil {
+		log.Printf("Error writing task export: %v", err)
+		return
+	}
+	log.Printf("Tasks exported successfully: %d tasks", exported)
+}
+
+func newExportWriter(w http.ResponseWriter, format string) (taskcsv.RowWriter, error) {
+	if todolist.ValidFormat(format) {
+		w.Header().Set("Content-Type", todolist.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+todolist.FileName(format)+`"`)
+		return todolist.NewWriter(w, format), nil
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+	return taskcsv.NewWriter(w)
+}
+
+// ImportTasks creates the tasks of an import like a batch of create
+// operations. In atomic mode nothing is imported if any row is invalid.
+func (app *App) ImportTasks(w http.ResponseWriter, mode bulk.Mode, rows []taskcsv.ImportRow) {
+	response := bulk.Response{Mode: mode}
+	if mode == bulk.Partial || !taskcsv.Failed(rows) {
+		var err error
+		response, err = app.runBulk(mode, taskcsv.Operations(rows))
+		if errors.Is(err, errTransactionsUnsupported) {
+			log.Println("Error importing tasks:", err)
+			problem.Write(w, atomicModeUnsupported)
+			return
+		}
+		if err != nil {
+			log.Printf("Error importing tasks: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+	}
+	writeImportResponse(w, taskcsv.Merge(rows, response))
+}
+
+func writeImportResponse(w http.ResponseWriter, response taskcsv.ImportResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode())
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding import response to JSON: %v", err)
+		return
+	}
+	log.Printf("Tasks imported: %d imported, %d failed", response.Imported, response.Failed)
+}
@@ -0,0 +1,19 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/problem"
+	"log"
+	"net/http"
+)
+
+// StreamEvents streams the changes to tasks matching filter as Server-Sent
+// Events.
+func (app *App) StreamEvents(w http.ResponseWriter, r *http.Request, filter events.Filter) {
+	if app.Events == nil {
+		log.Println("Events are not configured")
+		problem.Write(w, problem.NotFound("events_not_configured", "Events are not configured"))
+		return
+	}
+	events.Serve(w, r, app.Events, filter)
+}
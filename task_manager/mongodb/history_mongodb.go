@@ -0,0 +1,312 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/workflow"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+type HistoryEntry struct {
+	HistoryID primitive.ObjectID  `json:"-" bson:"_id"`
+	TaskID    primitive.ObjectID  `json:"-" bson:"task_id"`
+	Version   int                 `json:"version" bson:"version"`
+	Action    audit.Action        `json:"action" bson:"action"`
+	ActorID   *primitive.ObjectID `json:"actor_id" bson:"actor_id,omitempty"`
+	ChangedAt time.Time           `json:"changed_at" bson:"changed_at"`
+	Changes   []audit.Change      `json:"changes" bson:"changes"`
+	Snapshot  audit.Snapshot      `json:"snapshot" bson:"snapshot"`
+}
+
+var errUnrestorableStatus = errors.New("status of the version is not part of the workflow")
+
+// maxHistoryAttempts bounds the retries when two writers pick the same
+// version number; the unique index on (task_id, version) rejects the loser.
+const maxHistoryAttempts = 3
+
+func snapshotOf(task Task) audit.Snapshot {
+	snapshot := audit.Snapshot{
+		TaskName:    task.TaskName,
+		DueDate:     dates.Format(task.DueDate),
+		Completed:   task.Completed,
+		Status:      string(task.Status),
+		RRule:       task.RRule,
+		TimeZone:    task.TimeZone,
+		Rank:        task.Rank,
+		Description: task.Description,
+		Tags:        append([]string{}, task.Tags...),
+		Checklist:   []audit.ChecklistItem{},
+	}
+	for _, item := range task.Checklist {
+		snapshot.Checklist = append(snapshot.Checklist, audit.ChecklistItem{Text: item.Text, Done: item.Done})
+	}
+	return snapshot
+}
+
+// recordHistory appends the current state of a task to its history. before is
+// the state prior to the change, or nil for a new task. Tasks created before
+// history was kept get a baseline version first, so their original state can
+// still be restored. Changes that leave the snapshot untouched are not
+// recorded, and an update that completes the task is recorded as completion.
+// Recorded changes are published to the event stream.
+func (app *App) recordHistory(taskID, actorID primitive.ObjectID, action audit.Action, before *audit.Snapshot) error {
+	var task Task
+	if err := app.Tasks.FindOne(context.Background(), bson.M{"_id": taskID}).Decode(&task); err != nil {
+		return err
+	}
+	after := snapshotOf(task)
+
+	var recorded audit.Action
+	var err error
+	for attempt := 0; attempt < maxHistoryAttempts; attempt++ {
+		recorded, err = app.appendHistory(taskID, actorID, action, before, after)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return app.publish(task, recorded)
+}
+
+// appendHistory adds the versions recording a change and returns the action
+// it was recorded as, or an empty action if it was not recorded.
+func (app *App) appendHistory(taskID, actorID primitive.ObjectID, action audit.Action, before *audit.Snapshot, after audit.Snapshot) (audit.Action, error) {
+	var latest HistoryEntry
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})
+	err := app.History.FindOne(context.Background(), bson.M{"task_id": taskID}, findOptions).Decode(&latest)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", err
+	}
+	version := latest.Version
+
+	previous := audit.Snapshot{}
+	var entries []interface{}
+	if before != nil {
+		previous = *before
+		if version == 0 {
+			version++
+			entries = append(entries, app.historyEntry(taskID, version, audit.Baseline, primitive.NilObjectID, []audit.Change{}, previous))
+		}
+	}
+
+	changes := audit.Diff(previous, after)
+	if len(changes) == 0 && action != audit.Created {
+		return "", nil
+	}
+	if action == audit.Updated {
+		action = audit.UpdateAction(previous, after)
+	}
+	entries = append(entries, app.historyEntry(taskID, version+1, action, actorID, changes, after))
+
+	if _, err = app.History.InsertMany(context.Background(), entries); err != nil {
+		return "", err
+	}
+	return action, nil
+}
+
+// publish sends the change recorded as action to the event stream, if there
+// is one.
+func (app *App) publish(task Task, action audit.Action) error {
+	eventType, ok := events.TypeOf(action)
+	if app.Events == nil || !ok {
+		return nil
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	app.Events.Publish(events.Event{Type: eventType, UserID: task.UserID.Hex(), Tags: task.Tags, Data: data})
+	return nil
+}
+
+func (app *App) historyEntry(taskID primitive.ObjectID, version int, action audit.Action, actorID primitive.ObjectID, changes []audit.Change, snapshot audit.Snapshot) HistoryEntry {
+	entry := HistoryEntry{
+		HistoryID: primitive.NewObjectID(),
+		TaskID:    taskID,
+		Version:   version,
+		Action:    action,
+		ChangedAt: app.now().UTC(),
+		Changes:   changes,
+		Snapshot:  snapshot,
+	}
+	if !actorID.IsZero() {
+		entry.ActorID = &actorID
+	}
+	return entry
+}
+
+func (app *App) GetTaskHistory(w http.ResponseWriter, taskID string) {
+	objectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+
+	if !app.taskExists(w, objectID) {
+		return
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "version", Value: 1}})
+	cursor, err := app.History.Find(context.Background(), bson.M{"task_id": objectID}, findOptions)
+	if err != nil {
+		log.Printf("Error querying task history from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	history := []HistoryEntry{}
+	if err = cursor.All(context.Background(), &history); err != nil {
+		log.Printf("Error decoding task history: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(history)
+	if err != nil {
+		log.Printf("Error encoding task history to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Task history gathered successfully")
+}
+
+// RevertTask restores the state a task had at version. The revert itself is
+// appended to the history, so it can be undone the same way.
+func (app *App) RevertTask(w http.ResponseWriter, taskID, userID string, version int) {
+	objectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	var task Task
+	err = app.Tasks.FindOne(context.Background(), bson.M{"_id": objectID, "user_id": userObjectID}).Decode(&task)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("Task assignment not found or user does not have permission")
+		problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+		return
+	case err != nil:
+		log.Printf("Error checking task assignment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	var entry HistoryEntry
+	err = app.History.FindOne(context.Background(), bson.M{"task_id": objectID, "version": version}).Decode(&entry)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("Task version not found")
+		problem.Write(w, problem.NotFound("version_not_found", "Version not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving task version: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	before := snapshotOf(task)
+	err = app.restoreSnapshot(task, userObjectID, entry.Snapshot)
+	if err == nil {
+		err = app.recordHistory(objectID, userObjectID, audit.Reverted, &before)
+	}
+	switch {
+	case errors.Is(err, errUnrestorableStatus):
+		log.Println("Cannot revert task:", err)
+		problem.Write(w, problem.Conflict("version_not_restorable", "Version cannot be restored with the current workflow"))
+		return
+	case errors.Is(err, errConcurrentStatusChange):
+		log.Println("Concurrent change while reverting:", err)
+		problem.Write(w, problem.Conflict("concurrent_change", "Task changed concurrently"))
+		return
+	case err != nil:
+		log.Printf("Error reverting task: %v", err)
+		problem.Write(w, problem.Internal("Error reverting task"))
+		return
+	}
+
+	app.updated(w, objectID, "Task reverted successfully")
+}
+
+// restoreSnapshot writes target over task. A restored status bypasses the
+// transition rules, since a revert undoes changes rather than making one, but
+// it must still exist in the workflow. The rank is not restored: the task
+// keeps its place, or goes to the end of the column it returns to.
+func (app *App) restoreSnapshot(task Task, actorID primitive.ObjectID, target audit.Snapshot) error {
+	wf := app.workflow()
+	status := workflow.Status(target.Status)
+	if !wf.Valid(status) {
+		return errUnrestorableStatus
+	}
+	due, err := dates.Parse(target.DueDate, time.UTC)
+	if err != nil {
+		return err
+	}
+
+	set := bson.M{
+		"task_name": target.TaskName,
+		"due_date":  due,
+		"completed": wf.IsCompleted(status),
+		"status":    status,
+		"rrule":     target.RRule,
+		"time_zone": target.TimeZone,
+	}
+	if target.HasContent() {
+		set["description"] = target.Description
+		set["tags"] = target.Tags
+		set["checklist"] = target.Checklist
+	}
+	changedAt := app.now().UTC()
+	if status != task.Status {
+		boardRank, err := app.lastRank(context.Background(), status)
+		if err != nil {
+			return err
+		}
+		set["rank"] = boardRank
+		set["status_changed_by"] = actorID
+		set["status_changed_at"] = changedAt
+	}
+
+	result, err := app.Tasks.UpdateOne(context.Background(), bson.M{"_id": task.TaskID, "version": task.Version}, bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errConcurrentStatusChange
+	}
+	if status == task.Status {
+		return nil
+	}
+
+	_, err = app.StatusChanges.InsertOne(context.Background(), StatusChange{
+		ChangeID:   primitive.NewObjectID(),
+		TaskID:     task.TaskID,
+		UserID:     actorID,
+		FromStatus: task.Status,
+		ToStatus:   status,
+		ChangedAt:  changedAt,
+	})
+	return err
+}
@@ -1,211 +1,722 @@
 package taskManagerMongoDB
 
 import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/blobstore"
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/etag"
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/ical"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/recurrence"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/taskcsv"
+	"Simple_Task_Manager/templates"
+	"Simple_Task_Manager/workflow"
 	"context"
 	"encoding/json"
 	"errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
 type TaskManager interface {
 	GetTaskByID(w http.ResponseWriter, taskID, userID string)
-	UpdateTask(w http.ResponseWriter, taskID, userID string)
-	DeleteTask(w http.ResponseWriter, taskID, userID string)
-	GetTasks(w http.ResponseWriter)
-	CreateTask(w http.ResponseWriter, userName, taskName, dueDate string)
+	UpdateTask(w http.ResponseWriter, taskID, userID, dueDate, status string, ifMatch etag.Condition)
+	DeleteTask(w http.ResponseWriter, taskID, userID string, ifMatch etag.Condition)
+	GetTasks(w http.ResponseWriter, r *http.Request)
+	CreateTask(w http.ResponseWriter, userName, taskName, dueDate, rrule, timeZone string)
+	GetUserByID(w http.ResponseWriter, userID string)
+	UpdateUserTimeZone(w http.ResponseWriter, userID, timeZone string)
+	GetBoard(w http.ResponseWriter, userID string)
+	MoveTask(w http.ResponseWriter, taskID, userID, status, afterTaskID, beforeTaskID string)
+	GetComments(w http.ResponseWriter, taskID string, limit, offset int)
+	CreateComment(w http.ResponseWriter, taskID, userID, content string)
+	UpdateComment(w http.ResponseWriter, commentID, userID, content string)
+	DeleteComment(w http.ResponseWriter, commentID, userID string)
+	GetAttachments(w http.ResponseWriter, taskID string)
+	CreateAttachment(w http.ResponseWriter, taskID, userID, fileName string, content io.Reader)
+	GetAttachment(w http.ResponseWriter, r *http.Request, attachmentID string)
+	DeleteAttachment(w http.ResponseWriter, attachmentID, userID string)
+	StartTimer(w http.ResponseWriter, taskID, userID string)
+	StopTimer(w http.ResponseWriter, userID string)
+	GetRunningTimer(w http.ResponseWriter, userID string)
+	CreateTimeEntry(w http.ResponseWriter, taskID, userID, startedAt, endedAt, note string)
+	DeleteTimeEntry(w http.ResponseWriter, entryID, userID string)
+	GetTimeEntries(w http.ResponseWriter, taskID, userID, from, to, format string)
+	GetTimeTotals(w http.ResponseWriter, groupBy, taskID, userID, from, to string)
+	GetTaskHistory(w http.ResponseWriter, taskID string)
+	RevertTask(w http.ResponseWriter, taskID, userID string, version int)
+	GetTemplates(w http.ResponseWriter, templateID string)
+	CreateTemplate(w http.ResponseWriter, userID string, template templates.Template)
+	UpdateTemplate(w http.ResponseWriter, templateID, userID string, template templates.Template)
+	DeleteTemplate(w http.ResponseWriter, templateID, userID string)
+	InstantiateTemplate(w http.ResponseWriter, templateID, userID string, instances []map[string]string)
+	BulkTasks(w http.ResponseWriter, mode bulk.Mode, operations []bulk.Operation)
+	ExportTasks(w http.ResponseWriter, format string, userID string)
+	StreamEvents(w http.ResponseWriter, r *http.Request, filter events.Filter)
+	ImportTasks(w http.ResponseWriter, mode bulk.Mode, rows []taskcsv.ImportRow)
+	CreateFeedToken(w http.ResponseWriter, userID string, feedPath string)
+	DeleteFeedToken(w http.ResponseWriter, userID string)
+	GetCalendarFeed(w http.ResponseWriter, token string, component ical.Component)
+	Backup(w http.ResponseWriter, includeBlobs bool)
+	Restore(w http.ResponseWriter, body io.Reader)
 }
 
 type App struct {
-	DB    *mongo.Database
-	Users *mongo.Collection
-	Tasks *mongo.Collection
+	DB               *mongo.Database
+	Users            *mongo.Collection
+	Tasks            *mongo.Collection
+	StatusChanges    *mongo.Collection
+	Comments         *mongo.Collection
+	Attachments      *mongo.Collection
+	TimeEntries      *mongo.Collection
+	History          *mongo.Collection
+	Templates        *mongo.Collection
+	Clock            func() time.Time
+	Workflow         *workflow.Workflow
+	Blobs            blobstore.Store
+	AttachmentLimits *blobstore.Limits
+	Events           *events.Log
 }
 
 type Task struct {
-	TaskID    primitive.ObjectID `json:"task_id" bson:"_id"`
-	TaskName  string             `json:"task_name" bson:"task_name"`
-	DueDate   string             `json:"due_date" bson:"due_date"`
-	Completed bool               `json:"completed" bson:"completed"`
-	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	TaskID          primitive.ObjectID  `json:"task_id" bson:"_id"`
+	TaskName        string              `json:"task_name" bson:"task_name"`
+	DueDate         time.Time           `json:"due_date" bson:"due_date"`
+	Completed       bool                `json:"completed" bson:"completed"`
+	UserID          primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	RRule           string              `json:"rrule,omitempty" bson:"rrule,omitempty"`
+	TimeZone        string              `json:"time_zone,omitempty" bson:"time_zone,omitempty"`
+	Status          workflow.Status     `json:"status" bson:"status"`
+	StatusChangedBy *primitive.ObjectID `json:"status_changed_by,omitempty" bson:"status_changed_by,omitempty"`
+	StatusChangedAt *time.Time          `json:"status_changed_at,omitempty" bson:"status_changed_at,omitempty"`
+	Rank            string              `json:"rank" bson:"rank"`
+	Description     string              `json:"description,omitempty" bson:"description,omitempty"`
+	Tags            []string            `json:"tags,omitempty" bson:"tags,omitempty"`
+	Checklist       []ChecklistItem     `json:"checklist,omitempty" bson:"checklist,omitempty"`
+	ParentTaskID    *primitive.ObjectID `json:"parent_task_id,omitempty" bson:"parent_task_id,omitempty"`
+	Version         int                 `json:"version" bson:"version"`
 }
 
+type ChecklistItem struct {
+	Text string `json:"text" bson:"text"`
+	Done bool   `json:"done" bson:"done"`
+}
+
+type StatusChange struct {
+	ChangeID   primitive.ObjectID `json:"change_id" bson:"_id"`
+	TaskID     primitive.ObjectID `json:"task_id" bson:"task_id"`
+	UserID     primitive.ObjectID `json:"user_id" bson:"user_id"`
+	FromStatus workflow.Status    `json:"from_status" bson:"from_status"`
+	ToStatus   workflow.Status    `json:"to_status" bson:"to_status"`
+	ChangedAt  time.Time          `json:"changed_at" bson:"changed_at"`
+}
+
+var errConcurrentStatusChange = errors.New("task status changed concurrently")
+
+var errVersionChanged = errors.New("task changed since it was read")
+
+var errPreconditionFailed = problem.New(http.StatusPreconditionFailed, "precondition_failed", "Task changed since the version in If-Match")
+
 type User struct {
 	UserID   primitive.ObjectID `json:"user_id" bson:"_id"`
 	UserName string             `json:"user_name" bson:"user_name"`
+	TimeZone string             `json:"time_zone" bson:"time_zone"`
+}
+
+func (app *App) now() time.Time {
+	if app.Clock != nil {
+		return app.Clock()
+	}
+	return time.Now()
+}
+
+func (app *App) workflow() *workflow.Workflow {
+	if app.Workflow != nil {
+		return app.Workflow
+	}
+	return workflow.Default()
 }
 
 func (app *App) GetTaskByID(w http.ResponseWriter, taskID, userID string) {
 	objectID, err := primitive.ObjectIDFromHex(taskID)
 	if err != nil {
 		log.Println("Invalid task ID:", err)
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
 		return
 	}
+	filter := bson.M{"_id": objectID}
+	if userID != "" {
+		userObjectID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			log.Println("Invalid user ID:", err)
+			problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+			return
+		}
+		filter["user_id"] = userObjectID
+	}
 
 	taskCollection := app.Tasks
-	filter := bson.M{"_id": objectID, "user_id": userID}
 
 	var task Task
 	err = taskCollection.FindOne(context.Background(), filter).Decode(&task)
 	if err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			log.Println("Task not found")
-			http.Error(w, "Task not found", http.StatusNotFound)
+			problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
 			return
 		}
 		log.Printf("Error retrieving task: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag.Version(task.Version))
 	err = json.NewEncoder(w).Encode(task)
 	if err != nil {
 		log.Printf("Error encoding task to JSON: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 	log.Println("Task retrieved successfully")
 }
 
-func (app *App) UpdateTask(w http.ResponseWriter, taskID, userID string) {
+func (app *App) UpdateTask(w http.ResponseWriter, taskID, userID, dueDate, status string, ifMatch etag.Condition) {
 	objectID, err := primitive.ObjectIDFromHex(taskID)
 	if err != nil {
 		log.Println("Invalid task ID:", err)
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
 		return
 	}
 
 	taskCollection := app.Tasks
-	filter := bson.M{"_id": objectID, "user_id": userID}
+	filter := bson.M{"_id": objectID, "user_id": userObjectID}
 
-	update := bson.M{
-		"$set": bson.M{
-			"completed": true,
-		},
+	var task Task
+	err = taskCollection.FindOne(context.Background(), filter).Decode(&task)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Println("Task assignment not found or user does not have permission")
+			problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+			return
+		}
+		log.Printf("Error checking task assignment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !ifMatch.Allows(etag.Version(task.Version)) {
+		log.Println("Task version does not match If-Match")
+		problem.Write(w, errPreconditionFailed)
+		return
 	}
 
-	_, err = taskCollection.UpdateOne(context.Background(), filter, update)
-	if err != nil {
-		log.Printf("Error updating task: %v", err)
-		http.Error(w, "Error updating task", http.StatusInternalServerError)
+	before := snapshotOf(task)
+
+	// A PATCH without a body completes the task, as it always has.
+	target := workflow.Status(status)
+	if dueDate == "" && status == "" {
+		target = app.workflow().CompletedStatus()
+	}
+	if target != "" {
+		if err = app.workflow().CheckTransition(task.Status, target); err != nil {
+			if errors.Is(err, workflow.ErrUnknownStatus) {
+				log.Println("Invalid status:", err)
+				problem.Write(w, problem.Malformed("status", "Unknown status"))
+				return
+			}
+			log.Println("Invalid status transition:", err)
+			problem.Write(w, problem.Conflict("status_transition_not_allowed", "Status transition not allowed"))
+			return
+		}
+	}
+
+	// The new due date and status are written together, so a request that
+	// loses the race for the version changes nothing.
+	set := bson.M{}
+	var due time.Time
+	if dueDate != "" {
+		location, err := dates.LoadLocation(task.TimeZone)
+		if err != nil {
+			log.Printf("Error loading task time zone: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		due, err = dates.Resolve(dueDate, app.now(), location)
+		if err != nil {
+			log.Println("Invalid due date:", err)
+			problem.Write(w, problem.Malformed("due_date", "Invalid due date"))
+			return
+		}
+		set["due_date"] = due
+	}
+
+	statusChanged := target != "" && target != task.Status
+	var changedAt time.Time
+	if statusChanged {
+		if changedAt, err = app.setStatus(context.Background(), set, userObjectID, target, ""); err != nil {
+			log.Printf("Error computing board rank: %v", err)
+			problem.Write(w, problem.Internal("Error updating task"))
+			return
+		}
+	}
+
+	if len(set) > 0 {
+		// Filtering on the version makes the check against If-Match atomic
+		// with the change, and keeps two concurrent completions from both
+		// scheduling a next occurrence.
+		result, err := taskCollection.UpdateOne(context.Background(),
+			bson.M{"_id": objectID, "version": task.Version},
+			bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+		if err != nil {
+			log.Printf("Error updating task: %v", err)
+			problem.Write(w, problem.Internal("Error updating task"))
+			return
+		}
+		if result.ModifiedCount == 0 {
+			versionChanged(w, ifMatch)
+			return
+		}
+	}
+
+	if statusChanged {
+		if dueDate != "" {
+			task.DueDate = due
+		}
+		if err = app.afterStatusChange(task, userObjectID, target, changedAt); err != nil {
+			log.Printf("Error changing task status: %v", err)
+			problem.Write(w, problem.Internal("Error updating task"))
+			return
+		}
+	}
+
+	if err = app.recordHistory(objectID, userObjectID, audit.Updated, &before); err != nil {
+		log.Printf("Error recording task history: %v", err)
+		problem.Write(w, problem.Internal("Error updating task"))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	log.Println("Task updated successfully")
-	_, _ = w.Write([]byte("Task updated successfully"))
+	if dueDate != "" {
+		w.Header().Set("X-Resolved-Due-Date", dates.Format(due))
+	}
+	app.updated(w, objectID, "Task updated successfully")
+}
+
+// changeStatus moves task to status on behalf of actorID and records the
+// change. The task is placed at boardRank in its new column, or at the end if
+// boardRank is empty. The transition must already have been checked against
+// the workflow. When a recurring task gets completed, the next occurrence is
+// scheduled and returned, for recordOccurrence to record once ctx is done.
+func (app *App) changeStatus(ctx context.Context, task Task, actorID primitive.ObjectID, status workflow.Status, boardRank string) (*Task, error) {
+	if task.Status == status {
+		return nil, nil
+	}
+
+	set := bson.M{}
+	changedAt, err := app.setStatus(ctx, set, actorID, status, boardRank)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filtering on the version the task was read at makes the transition
+	// atomic, so two concurrent completions cannot both schedule a next
+	// occurrence.
+	result, err := app.Tasks.UpdateOne(ctx,
+		bson.M{"_id": task.TaskID, "version": task.Version},
+		bson.M{"$set": set, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		return nil, err
+	}
+	if result.ModifiedCount == 0 {
+		return nil, errConcurrentStatusChange
+	}
+	return app.recordStatusChange(ctx, task, actorID, status, changedAt)
+}
+
+// setStatus adds the fields that move a task to status on behalf of actorID
+// to set, and returns when the change happens. The task is placed at
+// boardRank in its new column, or at the end if boardRank is empty.
+func (app *App) setStatus(ctx context.Context, set bson.M, actorID primitive.ObjectID, status workflow.Status, boardRank string) (time.Time, error) {
+	if boardRank == "" {
+		var err error
+		if boardRank, err = app.lastRank(ctx, status); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	changedAt := app.now().UTC()
+	set["status"] = status
+	set["completed"] = app.workflow().IsCompleted(status)
+	set["status_changed_by"] = actorID
+	set["status_changed_at"] = changedAt
+	set["rank"] = boardRank
+	return changedAt, nil
 }
 
-func (app *App) DeleteTask(w http.ResponseWriter, taskID, userID string) {
+// afterStatusChange records a status change that has been written to task and
+// schedules the next occurrence when a recurring task got completed.
+func (app *App) afterStatusChange(task Task, actorID primitive.ObjectID, status workflow.Status, changedAt time.Time) error {
+	next, err := app.recordStatusChange(context.Background(), task, actorID, status, changedAt)
+	if err != nil || next == nil {
+		return err
+	}
+	return app.recordOccurrence(*next, actorID)
+}
+
+// recordStatusChange records a status change that has been written to task.
+// When a recurring task got completed, it inserts the next occurrence and
+// returns it.
+func (app *App) recordStatusChange(ctx context.Context, task Task, actorID primitive.ObjectID, status workflow.Status, changedAt time.Time) (*Task, error) {
+	wf := app.workflow()
+	_, err := app.StatusChanges.InsertOne(ctx, StatusChange{
+		ChangeID:   primitive.NewObjectID(),
+		TaskID:     task.TaskID,
+		UserID:     actorID,
+		FromStatus: task.Status,
+		ToStatus:   status,
+		ChangedAt:  changedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if task.RRule == "" || wf.IsCompleted(task.Status) || !wf.IsCompleted(status) {
+		return nil, nil
+	}
+	nextDueDate, nextRRule, ok, err := recurrence.NextDueDate(task.RRule, task.TimeZone, task.DueDate)
+	if err != nil || !ok {
+		return nil, err
+	}
+	nextRank, err := app.lastRank(ctx, wf.Initial)
+	if err != nil {
+		return nil, err
+	}
+	next := Task{
+		TaskID:      primitive.NewObjectID(),
+		TaskName:    task.TaskName,
+		DueDate:     nextDueDate,
+		Completed:   false,
+		UserID:      task.UserID,
+		RRule:       nextRRule,
+		TimeZone:    task.TimeZone,
+		Status:      wf.Initial,
+		Rank:        nextRank,
+		Description: task.Description,
+		Tags:        task.Tags,
+		Version:     1,
+	}
+	if _, err = app.Tasks.InsertOne(ctx, next); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// recordOccurrence adds the creation of the next occurrence of a recurring
+// task to its history.
+func (app *App) recordOccurrence(next Task, actorID primitive.ObjectID) error {
+	if err := app.recordHistory(next.TaskID, actorID, audit.Created, nil); err != nil {
+		return err
+	}
+	log.Printf("Next occurrence scheduled for %s", dates.Format(next.DueDate))
+	return nil
+}
+
+// lastRank returns a board rank after every task currently in the column for
+// status.
+func (app *App) lastRank(ctx context.Context, status workflow.Status) (string, error) {
+	var last Task
+	findOptions := options.FindOne().SetSort(bson.D{{Key: "rank", Value: -1}})
+	err := app.Tasks.FindOne(ctx, bson.M{"status": status}, findOptions).Decode(&last)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return "", err
+	}
+	return rank.After(last.Rank)
+}
+
+func (app *App) DeleteTask(w http.ResponseWriter, taskID, userID string, ifMatch etag.Condition) {
 	objectID, err := primitive.ObjectIDFromHex(taskID)
 	if err != nil {
 		log.Println("Invalid task ID:", err)
-		http.Error(w, "Invalid task ID", http.StatusBadRequest)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
 		return
 	}
 
 	taskCollection := app.Tasks
-	filter := bson.M{"_id": objectID, "user_id": userID}
+	filter := bson.M{"_id": objectID, "user_id": userObjectID}
+
+	var task Task
+	err = taskCollection.FindOne(context.Background(), filter).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		log.Println("Task assignment not found or user does not have permission")
+		problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error checking task assignment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !ifMatch.Allows(etag.Version(task.Version)) {
+		log.Println("Task version does not match If-Match")
+		problem.Write(w, errPreconditionFailed)
+		return
+	}
 
 	update := bson.M{
 		"$set": bson.M{
 			"task_name": "X",
-			"due_date":  "0001-01-01T00:00:00Z",
+			"due_date":  time.Time{},
 			"completed": false,
+			"status":    app.workflow().Initial,
 		},
+		"$inc": bson.M{"version": 1},
 	}
 
-	_, err = taskCollection.UpdateOne(context.Background(), filter, update)
+	filter["version"] = task.Version
+	err = taskCollection.FindOneAndUpdate(context.Background(), filter, update).Decode(&task)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		versionChanged(w, ifMatch)
+		return
+	}
 	if err != nil {
 		log.Printf("Error anonymizing task: %v", err)
-		http.Error(w, "Error anonymizing task", http.StatusInternalServerError)
+		problem.Write(w, problem.Internal("Error anonymizing task"))
+		return
+	}
+
+	before := snapshotOf(task)
+	if err = app.recordHistory(objectID, userObjectID, audit.Deleted, &before); err != nil {
+		log.Printf("Error recording task history: %v", err)
+		problem.Write(w, problem.Internal("Error anonymizing task"))
+		return
+	}
+
+	if err = app.purgeAttachments(objectID); err != nil {
+		log.Printf("Error purging attachments: %v", err)
+		problem.Write(w, problem.Internal("Error anonymizing task"))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
 	log.Println("Task content anonymized successfully")
-	_, _ = w.Write([]byte("Task anonymized successfully"))
+	respond.Deleted(w, "Task anonymized successfully")
 }
 
-func (app *App) GetTasks(w http.ResponseWriter) {
-	taskCollection := app.Tasks
-	cursor, err := taskCollection.Find(context.Background(), bson.M{})
-	if err != nil {
-		log.Printf("Error querying tasks from database: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+// updated answers a request that changed a task with the task as it is now.
+func (app *App) updated(w http.ResponseWriter, taskID primitive.ObjectID, message string) {
+	var task Task
+	if err := app.Tasks.FindOne(context.Background(), bson.M{"_id": taskID}).Decode(&task); err != nil {
+		log.Printf("Error retrieving changed task: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
+	log.Println(message)
+	w.Header().Set("ETag", etag.Version(task.Version))
+	respond.Updated(w, task, message)
+}
+
+// versionChanged answers a request whose change lost the race for the version
+// of a task: with 412 Precondition Failed if the request was conditional on
+// the version, and 409 Conflict otherwise.
+func versionChanged(w http.ResponseWriter, ifMatch etag.Condition) {
+	if ifMatch != nil {
+		log.Println("Task changed since it was read")
+		problem.Write(w, errPreconditionFailed)
+		return
+	}
+	log.Println("Concurrent task change")
+	problem.Write(w, problem.Conflict("concurrent_change", "Task changed concurrently"))
+}
+
+// findTasks returns the tasks matching filter.
+func (app *App) findTasks(filter bson.M, findOptions *options.FindOptions) ([]Task, error) {
+	cursor, err := app.Tasks.Find(context.Background(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
 
 	var tasks []Task
 	for cursor.Next(context.Background()) {
 		var task Task
-		err := cursor.Decode(&task)
+		if err = cursor.Decode(&task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, cursor.Err()
+}
+
+func (app *App) GetTasks(w http.ResponseWriter, r *http.Request) {
+	filter := bson.M{}
+	dueDateFilter := bson.M{}
+	for _, bound := range []struct {
+		param    string
+		operator string
+	}{
+		{"due_after", "$gte"},
+		{"due_before", "$lt"},
+	} {
+		value := r.URL.Query().Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := dates.Parse(value, time.UTC)
 		if err != nil {
-			log.Printf("Error decoding task: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Invalid %s parameter: %v", bound.param, err)
+			problem.Write(w, problem.InvalidParameter(bound.param))
 			return
 		}
-		tasks = append(tasks, task)
+		dueDateFilter[bound.operator] = parsed
+	}
+	if len(dueDateFilter) > 0 {
+		filter["due_date"] = dueDateFilter
 	}
 
-	err = cursor.Err()
+	findOptions := options.Find()
+	switch r.URL.Query().Get("sort") {
+	case "":
+		findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+	case "due_date":
+		findOptions.SetSort(bson.D{{Key: "due_date", Value: 1}, {Key: "_id", Value: 1}})
+	case "-due_date":
+		findOptions.SetSort(bson.D{{Key: "due_date", Value: -1}, {Key: "_id", Value: 1}})
+	default:
+		log.Println("Invalid sort parameter")
+		problem.Write(w, problem.InvalidParameter("sort"))
+		return
+	}
+
+	tasks, err := app.findTasks(filter, findOptions)
 	if err != nil {
-		log.Printf("Error iterating over task cursor: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error querying tasks from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
 	responseBody, err := json.Marshal(tasks)
 	if err != nil {
 		log.Printf("Error encoding tasks to JSON: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	_, err = w.Write(responseBody)
 	if err != nil {
 		log.Printf("Error writing response body: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
 	log.Println("Tasks gathered successfully")
 }
 
-func (app *App) CreateTask(w http.ResponseWriter, userName, taskName, dueDate string) {
-	user := User{
-		UserID:   primitive.NewObjectID(),
-		UserName: userName,
+func (app *App) CreateTask(w http.ResponseWriter, userName, taskName, dueDate, rrule, timeZone string) {
+	var user User
+	userExists := true
+	err := app.Users.FindOne(context.Background(), bson.M{"user_name": userName}).Decode(&user)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		userExists = false
+		user = User{UserID: primitive.NewObjectID(), UserName: userName, TimeZone: "UTC"}
+	case err != nil:
+		log.Printf("Error checking user existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if timeZone == "" {
+		timeZone = user.TimeZone
+	}
+	if timeZone == "" {
+		timeZone = "UTC"
+	}
+	location, err := dates.LoadLocation(timeZone)
+	if err != nil {
+		log.Println("Invalid time zone:", err)
+		problem.Write(w, problem.Malformed("time_zone", "Invalid time zone"))
+		return
+	}
+	due, err := dates.Resolve(dueDate, app.now(), location)
+	if err != nil {
+		log.Println("Invalid due date:", err)
+		problem.Write(w, problem.Malformed("due_date", "Invalid due date"))
+		return
+	}
+	if rrule != "" {
+		if err := recurrence.Validate(rrule, timeZone); err != nil {
+			log.Println("Invalid recurrence:", err)
+			problem.Write(w, problem.Malformed("rrule", "Invalid recurrence rule"))
+			return
+		}
+	}
+
+	if !userExists {
+		if _, err = app.Users.InsertOne(context.Background(), user); err != nil {
+			log.Printf("Error creating new user: %v", err)
+			problem.Write(w, problem.Internal("Error creating new user"))
+			return
+		}
 	}
 
-	_, err := app.Users.InsertOne(context.Background(), user)
+	boardRank, err := app.lastRank(context.Background(), app.workflow().Initial)
 	if err != nil {
-		log.Printf("Error creating new user: %v", err)
-		http.Error(w, "Error creating new user", http.StatusInternalServerError)
+		log.Printf("Error computing board rank: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
+	createdAt := app.now().UTC()
 	task := Task{
-		TaskID:    primitive.NewObjectID(),
-		TaskName:  taskName,
-		DueDate:   dueDate,
-		Completed: false,
-		UserID:    user.UserID,
+		TaskID:          primitive.NewObjectID(),
+		TaskName:        taskName,
+		DueDate:         due,
+		Completed:       false,
+		UserID:          user.UserID,
+		RRule:           rrule,
+		TimeZone:        timeZone,
+		Status:          app.workflow().Initial,
+		StatusChangedBy: &user.UserID,
+		StatusChangedAt: &createdAt,
+		Rank:            boardRank,
+		Version:         1,
 	}
 
 	_, err = app.Tasks.InsertOne(context.Background(), task)
 	if err != nil {
 		log.Printf("Error inserting task: %v", err)
-		http.Error(w, "Error inserting task", http.StatusInternalServerError)
+		problem.Write(w, problem.Internal("Error inserting task"))
+		return
+	}
+
+	if err = app.recordHistory(task.TaskID, user.UserID, audit.Created, nil); err != nil {
+		log.Printf("Error recording task history: %v", err)
+		problem.Write(w, problem.Internal("Error inserting task"))
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("X-Resolved-Due-Date", dates.Format(due))
 	log.Println("Task created successfully")
-	_, _ = w.Write([]byte("Task created successfully"))
+	respond.Created(w, "/tasks/"+task.TaskID.Hex(), task, "Task created successfully")
 }
@@ -0,0 +1,154 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/blobstore"
+	databaseMongoDB "Simple_Task_Manager/database/mongodb"
+	"Simple_Task_Manager/workflow"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestApp returns an App on a fresh database of the MongoDB server at
+// TASK_TEST_MONGODB_URI, dropped after the test. The test is skipped if the
+// variable is not set or the server does not answer.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	uri := os.Getenv("TASK_TEST_MONGODB_URI")
+	if uri == "" {
+		t.Skip("TASK_TEST_MONGODB_URI is not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err == nil {
+		err = client.Ping(ctx, nil)
+	}
+	if err != nil {
+		t.Skipf("MongoDB at %s is not available: %v", uri, err)
+	}
+
+	name := fmt.Sprintf("task_manager_test_%d", time.Now().UnixNano())
+	if err = databaseMongoDB.NewMongoDB(uri, name).InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(name)
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+
+	now := time.Date(2024, time.March, 25, 12, 0, 0, 0, time.UTC)
+	return &App{
+		DB:            db,
+		Users:         db.Collection("users"),
+		Tasks:         db.Collection("tasks"),
+		StatusChanges: db.Collection("task_status_changes"),
+		Comments:      db.Collection("comments"),
+		Attachments:   db.Collection("attachments"),
+		TimeEntries:   db.Collection("time_entries"),
+		History:       db.Collection("task_history"),
+		Templates:     db.Collection("task_templates"),
+		Clock:         func() time.Time { return now },
+		Blobs:         blobstore.NewLocal(t.TempDir()),
+	}
+}
+
+// requireTransactions skips the test unless the server supports transactions.
+func requireTransactions(t *testing.T, app *App) {
+	t.Helper()
+	supported, err := app.supportsTransactions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !supported {
+		t.Skip("MongoDB server is standalone and has no transactions")
+	}
+}
+
+// addTask creates a task due on March 25 for the user ada.
+func addTask(t *testing.T, app *App, rrule string) Task {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	app.CreateTask(recorder, "ada", "water plants", "2024-03-25", rrule, "UTC")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("CreateTask: %d %s", recorder.Code, recorder.Body)
+	}
+	var task Task
+	if err := json.NewDecoder(recorder.Body).Decode(&task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestUpdateTaskWritesDueDateAndStatusTogether(t *testing.T) {
+	tests := []struct {
+		name       string
+		concurrent bool
+		code       int
+		dueDate    time.Time
+		status     workflow.Status
+		tasks      int64
+	}{
+		{
+			name:    "alone",
+			code:    http.StatusOK,
+			dueDate: time.Date(2024, time.March, 28, 0, 0, 0, 0, time.UTC),
+			status:  workflow.Done,
+			tasks:   2,
+		},
+		{
+			name:       "losing to a concurrent change",
+			concurrent: true,
+			code:       http.StatusConflict,
+			dueDate:    time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC),
+			status:     workflow.Todo,
+			tasks:      1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApp(t)
+			task := addTask(t, app, "FREQ=DAILY")
+
+			// The clock is read after the task, so changing the task from it
+			// stands in for another request writing in between.
+			now := app.now()
+			app.Clock = func() time.Time {
+				if test.concurrent {
+					if _, err := app.Tasks.UpdateOne(context.Background(), bson.M{"_id": task.TaskID}, bson.M{"$inc": bson.M{"version": 1}}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				return now
+			}
+
+			recorder := httptest.NewRecorder()
+			app.UpdateTask(recorder, task.TaskID.Hex(), task.UserID.Hex(), "2024-03-28", string(workflow.Done), nil)
+
+			var stored Task
+			if err := app.Tasks.FindOne(context.Background(), bson.M{"_id": task.TaskID}).Decode(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != test.code || !stored.DueDate.Equal(test.dueDate) || stored.Status != test.status {
+				t.Errorf("UpdateTask answered %d and left the task due %v in %q, want %d, %v and %q", recorder.Code, stored.DueDate, stored.Status, test.code, test.dueDate, test.status)
+			}
+			tasks, err := app.Tasks.CountDocuments(context.Background(), bson.M{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tasks != test.tasks {
+				t.Errorf("%d tasks, want %d", tasks, test.tasks)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/templates"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"net/http"
+	"time"
+)
+
+type TaskTemplate struct {
+	TemplateID         primitive.ObjectID `json:"template_id" bson:"_id"`
+	templates.Template `bson:",inline"`
+	Variables          []string           `json:"variables" bson:"-"`
+	CreatedBy          primitive.ObjectID `json:"created_by" bson:"created_by"`
+	CreatedAt          time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt          *time.Time         `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+func (app *App) GetTemplates(w http.ResponseWriter, templateID string) {
+	var result interface{}
+	if templateID != "" {
+		objectID, err := primitive.ObjectIDFromHex(templateID)
+		if err != nil {
+			log.Println("Invalid template ID:", err)
+			problem.Write(w, problem.Malformed("template_id", "Invalid template ID"))
+			return
+		}
+		template, ok := app.findTemplate(w, objectID)
+		if !ok {
+			return
+		}
+		result = template
+	} else {
+		findOptions := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+		cursor, err := app.Templates.Find(context.Background(), bson.M{}, findOptions)
+		if err != nil {
+			log.Printf("Error querying templates from database: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+
+		list := []TaskTemplate{}
+		if err = cursor.All(context.Background(), &list); err != nil {
+			log.Printf("Error decoding templates: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		for i := range list {
+			list[i].Variables = list[i].Template.Variables()
+		}
+		result = list
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(result)
+	if err != nil {
+		log.Printf("Error encoding templates to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Templates gathered successfully")
+}
+
+func (app *App) CreateTemplate(w http.ResponseWriter, userID string, template templates.Template) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+	if !validTemplate(w, template) {
+		return
+	}
+	if _, ok := app.findUser(w, userObjectID); !ok {
+		return
+	}
+
+	created := TaskTemplate{
+		TemplateID: primitive.NewObjectID(),
+		Template:   template,
+		CreatedBy:  userObjectID,
+		CreatedAt:  app.now().UTC(),
+	}
+	_, err = app.Templates.InsertOne(context.Background(), created)
+	if mongo.IsDuplicateKeyError(err) {
+		log.Println("Template name already taken:", template.Name)
+		problem.Write(w, problem.Conflict("template_exists", "A template with this name already exists"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error inserting template: %v", err)
+		problem.Write(w, problem.Internal("Error inserting template"))
+		return
+	}
+
+	created.Variables = template.Variables()
+	log.Println("Template created successfully")
+	respond.Created(w, "/templates/"+created.TemplateID.Hex(), created, "Template created successfully")
+}
+
+func (app *App) UpdateTemplate(w http.ResponseWriter, templateID, userID string, template templates.Template) {
+	if !validTemplate(w, template) {
+		return
+	}
+	templateObjectID, _, ok := app.checkTemplateOwner(w, templateID, userID)
+	if !ok {
+		return
+	}
+
+	updatedAt := app.now().UTC()
+	_, err := app.Templates.UpdateOne(context.Background(), bson.M{"_id": templateObjectID}, bson.M{"$set": bson.M{
+		"name":        template.Name,
+		"task_name":   template.TaskName,
+		"description": template.Description,
+		"tags":        template.Tags,
+		"checklist":   template.Checklist,
+		"due_in":      template.DueIn,
+		"subtasks":    template.Subtasks,
+		"updated_at":  updatedAt,
+	}})
+	if mongo.IsDuplicateKeyError(err) {
+		log.Println("Template name already taken:", template.Name)
+		problem.Write(w, problem.Conflict("template_exists", "A template with this name already exists"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating template: %v", err)
+		problem.Write(w, problem.Internal("Error updating template"))
+		return
+	}
+
+	updated, ok := app.findTemplate(w, templateObjectID)
+	if !ok {
+		return
+	}
+	log.Println("Template updated successfully")
+	respond.Updated(w, updated, "Template updated successfully")
+}
+
+func (app *App) DeleteTemplate(w http.ResponseWriter, templateID, userID string) {
+	templateObjectID, _, ok := app.checkTemplateOwner(w, templateID, userID)
+	if !ok {
+		return
+	}
+
+	_, err := app.Templates.DeleteOne(context.Background(), bson.M{"_id": templateObjectID})
+	if err != nil {
+		log.Printf("Error deleting template: %v", err)
+		problem.Write(w, problem.Internal("Error deleting template"))
+		return
+	}
+
+	log.Println("Template deleted successfully")
+	respond.Deleted(w, "Template deleted successfully")
+}
+
+// InstantiateTemplate creates one task tree per entry of instances, each with
+// its own variables, for userID. The tasks are inserted with a single write;
+// if that fails part way, the tasks already written are removed again.
+func (app *App) InstantiateTemplate(w http.ResponseWriter, templateID, userID string, instances []map[string]string) {
+	templateObjectID, err := primitive.ObjectIDFromHex(templateID)
+	if err != nil {
+		log.Println("Invalid template ID:", err)
+		problem.Write(w, problem.Malformed("template_id", "Invalid template ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	template, ok := app.findTemplate(w, templateObjectID)
+	if !ok {
+		return
+	}
+	user, ok := app.findUser(w, userObjectID)
+	if !ok {
+		return
+	}
+	location, err := dates.LoadLocation(user.TimeZone)
+	if err != nil {
+		log.Printf("Error loading user time zone: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	now := app.now()
+	expanded := make([]templates.Instance, 0, len(instances))
+	for _, variables := range instances {
+		instance, err := template.Instantiate(variables, now, location)
+		if err != nil {
+			log.Println("Cannot instantiate template:", err)
+			code := "invalid_template"
+			if errors.Is(err, templates.ErrMissingVariable) {
+				code = "missing_variable"
+			}
+			problem.Write(w, problem.BadRequest(code, err.Error()))
+			return
+		}
+		expanded = append(expanded, instance)
+	}
+
+	created, err := app.instanceTasks(expanded, user)
+	if err == nil {
+		err = app.insertInstanceTasks(created)
+	}
+	if err != nil {
+		log.Printf("Error inserting tasks from template: %v", err)
+		problem.Write(w, problem.Internal("Error inserting task"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(created)
+	if err != nil {
+		log.Printf("Error encoding tasks to JSON: %v", err)
+		return
+	}
+	log.Printf("Template instantiated into %d tasks", len(created))
+}
+
+// instanceTasks turns expanded instances into tasks, each parent followed by
+// its subtasks, ranked one after another at the end of the initial column.
+func (app *App) instanceTasks(instances []templates.Instance, user User) ([]Task, error) {
+	status := app.workflow().Initial
+	boardRank, err := app.lastRank(context.Background(), status)
+	if err != nil {
+		return nil, err
+	}
+	createdAt := app.now().UTC()
+
+	tasks := []Task{}
+	var add func(instance templates.Instance, parentID *primitive.ObjectID) (primitive.ObjectID, error)
+	add = func(instance templates.Instance, parentID *primitive.ObjectID) (primitive.ObjectID, error) {
+		task := Task{
+			TaskID:          primitive.NewObjectID(),
+			TaskName:        instance.TaskName,
+			DueDate:         instance.DueDate,
+			Completed:       false,
+			UserID:          user.UserID,
+			TimeZone:        user.TimeZone,
+			Status:          status,
+			StatusChangedBy: &user.UserID,
+			StatusChangedAt: &createdAt,
+			Rank:            boardRank,
+			Description:     instance.Description,
+			Tags:            instance.Tags,
+			ParentTaskID:    parentID,
+			Version:         1,
+		}
+		for _, text := range instance.Checklist {
+			task.Checklist = append(task.Checklist, ChecklistItem{Text: text})
+		}
+		tasks = append(tasks, task)
+
+		var err error
+		boardRank, err = rank.After(boardRank)
+		return task.TaskID, err
+	}
+
+	for _, instance := range instances {
+		parentID, err := add(instance, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, subtask := range instance.Subtasks {
+			if _, err = add(subtask, &parentID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return tasks, nil
+}
+
+func (app *App) insertInstanceTasks(tasks []Task) error {
+	documents := make([]interface{}, 0, len(tasks))
+	taskIDs := make([]primitive.ObjectID, 0, len(tasks))
+	for _, task := range tasks {
+		documents = append(documents, task)
+		taskIDs = append(taskIDs, task.TaskID)
+	}
+
+	_, err := app.Tasks.InsertMany(context.Background(), documents)
+	if err == nil {
+		for _, task := range tasks {
+			if err = app.recordHistory(task.TaskID, task.UserID, audit.Created, nil); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		if _, cleanupErr := app.Tasks.DeleteMany(context.Background(), bson.M{"_id": bson.M{"$in": taskIDs}}); cleanupErr != nil {
+			log.Printf("Error removing partially created tasks: %v", cleanupErr)
+		}
+		if _, cleanupErr := app.History.DeleteMany(context.Background(), bson.M{"task_id": bson.M{"$in": taskIDs}}); cleanupErr != nil {
+			log.Printf("Error removing history of partially created tasks: %v", cleanupErr)
+		}
+		return err
+	}
+	return nil
+}
+
+func (app *App) findTemplate(w http.ResponseWriter, templateID primitive.ObjectID) (TaskTemplate, bool) {
+	var template TaskTemplate
+	err := app.Templates.FindOne(context.Background(), bson.M{"_id": templateID}).Decode(&template)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("Template not found")
+		problem.Write(w, problem.NotFound("template_not_found", "Template not found"))
+		return TaskTemplate{}, false
+	case err != nil:
+		log.Printf("Error retrieving template: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return TaskTemplate{}, false
+	}
+	template.Variables = template.Template.Variables()
+	return template, true
+}
+
+// checkTemplateOwner writes an error response and returns false unless the
+// template exists and was created by userID.
+func (app *App) checkTemplateOwner(w http.ResponseWriter, templateID, userID string) (primitive.ObjectID, primitive.ObjectID, bool) {
+	templateObjectID, err := primitive.ObjectIDFromHex(templateID)
+	if err != nil {
+		log.Println("Invalid template ID:", err)
+		problem.Write(w, problem.Malformed("template_id", "Invalid template ID"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+
+	template, ok := app.findTemplate(w, templateObjectID)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	if template.CreatedBy != userObjectID {
+		log.Println("User is not the creator of the template")
+		problem.Write(w, problem.Forbidden("not_creator", "Only the creator can change this template"))
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return templateObjectID, userObjectID, true
+}
+
+func validTemplate(w http.ResponseWriter, template templates.Template) bool {
+	if err := template.Validate(); err != nil {
+		log.Println("Invalid template:", err)
+		problem.Write(w, problem.BadRequest("invalid_template", err.Error()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,491 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/timesheet"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// TimeEntry is a tracked span of work. Running is only set while the timer
+// runs; a unique partial index on it allows one running timer per user.
+type TimeEntry struct {
+	EntryID   primitive.ObjectID `json:"entry_id" bson:"_id"`
+	TaskID    primitive.ObjectID `json:"task_id" bson:"task_id"`
+	TaskName  string             `json:"task_name" bson:"task_name,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	UserName  string             `json:"user_name" bson:"user_name,omitempty"`
+	StartedAt time.Time          `json:"started_at" bson:"started_at"`
+	EndedAt   *time.Time         `json:"ended_at" bson:"ended_at,omitempty"`
+	Note      string             `json:"note" bson:"note"`
+	Running   bool               `json:"-" bson:"running,omitempty"`
+	Seconds   int64              `json:"seconds" bson:"-"`
+}
+
+type TimeTotal struct {
+	ID      primitive.ObjectID `json:"id"`
+	Name    string             `json:"name"`
+	Seconds int64              `json:"seconds"`
+	Hours   float64            `json:"hours"`
+}
+
+type TimeTotals struct {
+	GroupBy      string      `json:"group_by"`
+	Totals       []TimeTotal `json:"totals"`
+	TotalSeconds int64       `json:"total_seconds"`
+	TotalHours   float64     `json:"total_hours"`
+}
+
+func (app *App) StartTimer(w http.ResponseWriter, taskID, userID string) {
+	taskObjectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	if !app.taskExists(w, taskObjectID) {
+		return
+	}
+	if _, ok := app.findUser(w, userObjectID); !ok {
+		return
+	}
+
+	entry := TimeEntry{
+		EntryID:   primitive.NewObjectID(),
+		TaskID:    taskObjectID,
+		UserID:    userObjectID,
+		StartedAt: app.now().UTC(),
+		Running:   true,
+	}
+	_, err = app.TimeEntries.InsertOne(context.Background(), entry)
+	if mongo.IsDuplicateKeyError(err) {
+		log.Println("Timer already running for user")
+		problem.Write(w, problem.Conflict("timer_running", "A timer is already running for this user"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting timer: %v", err)
+		problem.Write(w, problem.Internal("Error starting timer"))
+		return
+	}
+
+	saved, err := app.getTimeEntry(entry.EntryID)
+	if err != nil {
+		log.Printf("Error retrieving started timer: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Timer started successfully")
+	respond.Created(w, "/users/"+userObjectID.Hex()+"/timer", saved, "Timer started successfully")
+}
+
+func (app *App) getTimeEntry(entryID primitive.ObjectID) (TimeEntry, error) {
+	entries, err := app.findTimeEntries(bson.M{"_id": entryID}, time.Time{}, time.Time{})
+	if err != nil {
+		return TimeEntry{}, err
+	}
+	if len(entries) == 0 {
+		return TimeEntry{}, mongo.ErrNoDocuments
+	}
+	return entries[0], nil
+}
+
+func (app *App) StopTimer(w http.ResponseWriter, userID string) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"ended_at": app.now().UTC()},
+		"$unset": bson.M{"running": ""},
+	}
+	var entry TimeEntry
+	err = app.TimeEntries.FindOneAndUpdate(context.Background(), bson.M{"user_id": userObjectID, "running": true}, update).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		log.Println("No running timer")
+		problem.Write(w, problem.NotFound("no_running_timer", "No running timer"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error stopping timer: %v", err)
+		problem.Write(w, problem.Internal("Error stopping timer"))
+		return
+	}
+
+	saved, err := app.getTimeEntry(entry.EntryID)
+	if err != nil {
+		log.Printf("Error retrieving stopped timer: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Timer stopped successfully")
+	respond.Updated(w, saved, "Timer stopped successfully")
+}
+
+func (app *App) GetRunningTimer(w http.ResponseWriter, userID string) {
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	entries, err := app.findTimeEntries(bson.M{"user_id": userObjectID, "running": true}, time.Time{}, time.Time{})
+	if err != nil {
+		log.Printf("Error retrieving running timer: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if len(entries) == 0 {
+		log.Println("No running timer")
+		problem.Write(w, problem.NotFound("no_running_timer", "No running timer"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(entries[0])
+	if err != nil {
+		log.Printf("Error encoding timer to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Running timer retrieved successfully")
+}
+
+func (app *App) CreateTimeEntry(w http.ResponseWriter, taskID, userID, startedAt, endedAt, note string) {
+	taskObjectID, err := primitive.ObjectIDFromHex(taskID)
+	if err != nil {
+		log.Println("Invalid task ID:", err)
+		problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+	if !app.taskExists(w, taskObjectID) {
+		return
+	}
+	user, ok := app.findUser(w, userObjectID)
+	if !ok {
+		return
+	}
+
+	location, err := dates.LoadLocation(user.TimeZone)
+	if err != nil {
+		log.Printf("Invalid stored time zone %q: %v", user.TimeZone, err)
+		location = time.UTC
+	}
+	start, err := dates.Parse(startedAt, location)
+	if err != nil {
+		log.Println("Invalid started_at:", err)
+		problem.Write(w, problem.Malformed("started_at", "Invalid started_at"))
+		return
+	}
+	end, err := dates.Parse(endedAt, location)
+	if err != nil {
+		log.Println("Invalid ended_at:", err)
+		problem.Write(w, problem.Malformed("ended_at", "Invalid ended_at"))
+		return
+	}
+	if err = timesheet.ValidateEntry(start, end); err != nil {
+		log.Println("Invalid time entry:", err)
+		problem.Write(w, problem.Malformed("ended_at", "Time entry must end after it starts"))
+		return
+	}
+
+	entry := TimeEntry{
+		EntryID:   primitive.NewObjectID(),
+		TaskID:    taskObjectID,
+		UserID:    userObjectID,
+		StartedAt: start,
+		EndedAt:   &end,
+		Note:      note,
+	}
+	_, err = app.TimeEntries.InsertOne(context.Background(), entry)
+	if err != nil {
+		log.Printf("Error inserting time entry: %v", err)
+		problem.Write(w, problem.Internal("Error inserting time entry"))
+		return
+	}
+
+	saved, err := app.getTimeEntry(entry.EntryID)
+	if err != nil {
+		log.Printf("Error retrieving created time entry: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Time entry created successfully")
+	respond.Created(w, "/time/entries/"+entry.EntryID.Hex(), saved, "Time entry created successfully")
+}
+
+func (app *App) DeleteTimeEntry(w http.ResponseWriter, entryID, userID string) {
+	objectID, err := primitive.ObjectIDFromHex(entryID)
+	if err != nil {
+		log.Println("Invalid entry ID:", err)
+		problem.Write(w, problem.Malformed("entry_id", "Invalid entry ID"))
+		return
+	}
+	userObjectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	var entry TimeEntry
+	err = app.TimeEntries.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&entry)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("Time entry not found")
+		problem.Write(w, problem.NotFound("time_entry_not_found", "Time entry not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving time entry: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	case entry.UserID != userObjectID:
+		log.Println("User does not own the time entry")
+		problem.Write(w, problem.Forbidden("not_owner", "Only the owner can delete a time entry"))
+		return
+	}
+
+	_, err = app.TimeEntries.DeleteOne(context.Background(), bson.M{"_id": objectID})
+	if err != nil {
+		log.Printf("Error deleting time entry: %v", err)
+		problem.Write(w, problem.Internal("Error deleting time entry"))
+		return
+	}
+
+	log.Println("Time entry deleted successfully")
+	respond.Deleted(w, "Time entry deleted successfully")
+}
+
+// GetTimeEntries lists the entries overlapping [from, to). Durations are
+// clipped to the range. With format "csv" the entries are exported as a
+// timesheet.
+func (app *App) GetTimeEntries(w http.ResponseWriter, taskID, userID, from, to, format string) {
+	if format != "" && format != "json" && format != "csv" {
+		log.Println("Invalid format:", format)
+		problem.Write(w, problem.InvalidParameter("format"))
+		return
+	}
+	filter, rangeStart, rangeEnd, ok := timeEntryFilter(w, taskID, userID, from, to)
+	if !ok {
+		return
+	}
+
+	entries, err := app.findTimeEntries(filter, rangeStart, rangeEnd)
+	if err != nil {
+		log.Printf("Error querying time entries from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if format == "csv" {
+		rows := make([]timesheet.Row, 0, len(entries))
+		for _, entry := range entries {
+			rows = append(rows, timesheet.Row{
+				EntryID:   entry.EntryID.Hex(),
+				TaskID:    entry.TaskID.Hex(),
+				TaskName:  entry.TaskName,
+				UserID:    entry.UserID.Hex(),
+				UserName:  entry.UserName,
+				StartedAt: entry.StartedAt,
+				EndedAt:   entry.EndedAt,
+				Note:      entry.Note,
+				Duration:  time.Duration(entry.Seconds) * time.Second,
+			})
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="timesheet.csv"`)
+		if err = timesheet.WriteCSV(w, rows); err != nil {
+			log.Printf("Error writing timesheet: %v", err)
+			return
+		}
+		log.Println("Timesheet exported successfully")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(entries)
+	if err != nil {
+		log.Printf("Error encoding time entries to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Time entries gathered successfully")
+}
+
+// GetTimeTotals sums the tracked time in [from, to) per task or per user.
+func (app *App) GetTimeTotals(w http.ResponseWriter, groupBy, taskID, userID, from, to string) {
+	if groupBy == "" {
+		groupBy = "task"
+	}
+	if groupBy != "task" && groupBy != "user" {
+		log.Println("Invalid group_by:", groupBy)
+		problem.Write(w, problem.InvalidParameter("group_by"))
+		return
+	}
+	filter, rangeStart, rangeEnd, ok := timeEntryFilter(w, taskID, userID, from, to)
+	if !ok {
+		return
+	}
+
+	entries, err := app.findTimeEntries(filter, rangeStart, rangeEnd)
+	if err != nil {
+		log.Printf("Error querying time entries from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	totals := TimeTotals{GroupBy: groupBy, Totals: []TimeTotal{}}
+	byID := make(map[primitive.ObjectID]int)
+	for _, entry := range entries {
+		id, name := entry.TaskID, entry.TaskName
+		if groupBy == "user" {
+			id, name = entry.UserID, entry.UserName
+		}
+		index, seen := byID[id]
+		if !seen {
+			index = len(totals.Totals)
+			byID[id] = index
+			totals.Totals = append(totals.Totals, TimeTotal{ID: id, Name: name})
+		}
+		totals.Totals[index].Seconds += entry.Seconds
+		totals.TotalSeconds += entry.Seconds
+	}
+	for i := range totals.Totals {
+		totals.Totals[i].Hours = timesheet.Hours(time.Duration(totals.Totals[i].Seconds) * time.Second)
+	}
+	sort.Slice(totals.Totals, func(i, j int) bool { return totals.Totals[i].ID.Hex() < totals.Totals[j].ID.Hex() })
+	totals.TotalHours = timesheet.Hours(time.Duration(totals.TotalSeconds) * time.Second)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(totals)
+	if err != nil {
+		log.Printf("Error encoding time totals to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Time totals gathered successfully")
+}
+
+func (app *App) findUser(w http.ResponseWriter, userID primitive.ObjectID) (User, bool) {
+	var user User
+	err := app.Users.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return User{}, false
+	case err != nil:
+		log.Printf("Error retrieving user: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return User{}, false
+	}
+	return user, true
+}
+
+// findTimeEntries returns the matching entries with task and user names, and
+// their durations clipped to [from, to).
+func (app *App) findTimeEntries(filter bson.M, from, to time.Time) ([]TimeEntry, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sort", Value: bson.D{{Key: "started_at", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$lookup", Value: bson.M{"from": app.Tasks.Name(), "localField": "task_id", "foreignField": "_id", "as": "task"}}},
+		{{Key: "$lookup", Value: bson.M{"from": app.Users.Name(), "localField": "user_id", "foreignField": "_id", "as": "user"}}},
+		{{Key: "$set", Value: bson.M{
+			"task_name": bson.M{"$arrayElemAt": bson.A{"$task.task_name", 0}},
+			"user_name": bson.M{"$arrayElemAt": bson.A{"$user.user_name", 0}},
+		}}},
+		{{Key: "$project", Value: bson.M{"task": 0, "user": 0}}},
+	}
+	cursor, err := app.TimeEntries.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []TimeEntry{}
+	if err = cursor.All(context.Background(), &entries); err != nil {
+		return nil, err
+	}
+	now := app.now()
+	for i := range entries {
+		entries[i].Seconds = int64(timesheet.Duration(entries[i].StartedAt, entries[i].EndedAt, from, to, now).Seconds())
+	}
+	return entries, nil
+}
+
+func timeEntryFilter(w http.ResponseWriter, taskID, userID, from, to string) (bson.M, time.Time, time.Time, bool) {
+	filter := bson.M{}
+	for _, param := range []struct {
+		name  string
+		field string
+		value string
+	}{
+		{"task_id", "task_id", taskID},
+		{"user_id", "user_id", userID},
+	} {
+		if param.value == "" {
+			continue
+		}
+		objectID, err := primitive.ObjectIDFromHex(param.value)
+		if err != nil {
+			log.Printf("Invalid %s parameter: %v", param.name, err)
+			problem.Write(w, problem.InvalidParameter(param.name))
+			return nil, time.Time{}, time.Time{}, false
+		}
+		filter[param.field] = objectID
+	}
+
+	var rangeStart, rangeEnd time.Time
+	var err error
+	if from != "" {
+		if rangeStart, err = dates.Parse(from, time.UTC); err != nil {
+			log.Println("Invalid from parameter:", err)
+			problem.Write(w, problem.InvalidParameter("from"))
+			return nil, time.Time{}, time.Time{}, false
+		}
+		filter["$or"] = bson.A{bson.M{"running": true}, bson.M{"ended_at": bson.M{"$gt": rangeStart}}}
+	}
+	if to != "" {
+		if rangeEnd, err = dates.Parse(to, time.UTC); err != nil {
+			log.Println("Invalid to parameter:", err)
+			problem.Write(w, problem.InvalidParameter("to"))
+			return nil, time.Time{}, time.Time{}, false
+		}
+		filter["started_at"] = bson.M{"$lt": rangeEnd}
+	}
+	if !rangeStart.IsZero() && !rangeEnd.IsZero() && !rangeEnd.After(rangeStart) {
+		log.Println("Empty time range")
+		problem.Write(w, problem.Malformed("to", "The to parameter must be after from"))
+		return nil, time.Time{}, time.Time{}, false
+	}
+	return filter, rangeStart, rangeEnd, true
+}
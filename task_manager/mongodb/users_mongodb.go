@@ -0,0 +1,85 @@
+package taskManagerMongoDB
+
+import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"net/http"
+)
+
+func (app *App) GetUserByID(w http.ResponseWriter, userID string) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+
+	var user User
+	err = app.Users.FindOne(context.Background(), bson.M{"_id": objectID}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			log.Println("User not found")
+			problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+			return
+		}
+		log.Printf("Error retrieving user: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(user)
+	if err != nil {
+		log.Printf("Error encoding user to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("User retrieved successfully")
+}
+
+func (app *App) UpdateUserTimeZone(w http.ResponseWriter, userID, timeZone string) {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		log.Println("Invalid user ID:", err)
+		problem.Write(w, problem.Malformed("user_id", "Invalid user ID"))
+		return
+	}
+	if _, err := dates.LoadLocation(timeZone); err != nil || timeZone == "" {
+		log.Println("Invalid time zone:", timeZone)
+		problem.Write(w, problem.Malformed("time_zone", "Invalid time zone"))
+		return
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"time_zone": timeZone,
+		},
+	}
+
+	result, err := app.Users.UpdateOne(context.Background(), bson.M{"_id": objectID}, update)
+	if err != nil {
+		log.Printf("Error updating user: %v", err)
+		problem.Write(w, problem.Internal("Error updating user"))
+		return
+	}
+	if result.MatchedCount == 0 {
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	user, ok := app.findUser(w, objectID)
+	if !ok {
+		return
+	}
+	log.Println("User updated successfully")
+	respond.Updated(w, user, "User updated successfully")
+}
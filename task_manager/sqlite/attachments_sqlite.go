@@ -0,0 +1,295 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/blobstore"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxFileNameLength = 255
+
+type Attachment struct {
+	AttachmentID int       `json:"attachment_id"`
+	TaskID       int       `json:"task_id"`
+	UserID       int       `json:"user_id"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (app *App) blobs() blobstore.Store {
+	if app.Blobs != nil {
+		return app.Blobs
+	}
+	return blobstore.NewLocal("attachments")
+}
+
+func (app *App) attachmentLimits() *blobstore.Limits {
+	if app.AttachmentLimits != nil {
+		return app.AttachmentLimits
+	}
+	return blobstore.DefaultLimits()
+}
+
+func (app *App) GetAttachments(w http.ResponseWriter, taskID int) {
+	var taskExists bool
+	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=?)", taskID).Scan(&taskExists)
+	if err != nil {
+		log.Printf("Error checking task existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !taskExists {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return
+	}
+
+	rows, err := app.DB.Query("SELECT attachment_id, task_id, user_id, file_name, content_type, size, created_at FROM attachments WHERE task_id=? ORDER BY created_at, attachment_id", taskID)
+	if err != nil {
+		log.Printf("Error querying attachments from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		var attachment Attachment
+		if err = scanAttachment(rows, &attachment); err != nil {
+			log.Printf("Error scanning attachment row: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("Error iterating over attachment rows: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(attachments)
+	if err != nil {
+		log.Printf("Error encoding attachments to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Attachments gathered successfully")
+}
+
+func (app *App) CreateAttachment(w http.ResponseWriter, taskID, userID int, fileName string, content io.Reader) {
+	fileName = cleanFileName(fileName)
+	if fileName == "" {
+		log.Println("Missing file name")
+		problem.Write(w, problem.Required("file", "Missing file name"))
+		return
+	}
+
+	var taskExists, userExists bool
+	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=?), EXISTS(SELECT 1 FROM users WHERE user_id=?)", taskID, userID).Scan(&taskExists, &userExists)
+	if err != nil {
+		log.Printf("Error checking task and user existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !taskExists {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return
+	}
+	if !userExists {
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	key, err := blobstore.NewKey("tasks/" + strconv.Itoa(taskID))
+	if err != nil {
+		log.Printf("Error generating blob key: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	contentType, size, err := blobstore.Upload(context.Background(), app.blobs(), app.attachmentLimits(), key, content)
+	if err != nil {
+		writeUploadError(w, err)
+		return
+	}
+
+	result, err := app.DB.Exec("INSERT INTO attachments(task_id, user_id, file_name, content_type, size, blob_key, created_at) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		taskID, userID, fileName, contentType, size, key, dates.Format(app.now()))
+	if err != nil {
+		log.Printf("Error inserting attachment: %v", err)
+		app.deleteBlobs([]string{key})
+		problem.Write(w, problem.Internal("Error inserting attachment"))
+		return
+	}
+
+	attachmentID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting last inserted ID: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	var attachment Attachment
+	err = scanAttachment(app.DB.QueryRow("SELECT attachment_id, task_id, user_id, file_name, content_type, size, created_at FROM attachments WHERE attachment_id=?", attachmentID), &attachment)
+	if err != nil {
+		log.Printf("Error retrieving created attachment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Attachment uploaded successfully")
+	respond.Created(w, "/attachments/"+strconv.FormatInt(attachmentID, 10), attachment, "Attachment uploaded successfully")
+}
+
+// GetAttachment streams the attachment content. Range requests are answered
+// with partial content.
+func (app *App) GetAttachment(w http.ResponseWriter, r *http.Request, attachmentID int) {
+	var attachment Attachment
+	var key string
+	err := scanAttachment(app.DB.QueryRow("SELECT attachment_id, task_id, user_id, file_name, content_type, size, created_at, blob_key FROM attachments WHERE attachment_id=?", attachmentID), &attachment, &key)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Attachment not found")
+		problem.Write(w, problem.NotFound("attachment_not_found", "Attachment not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving attachment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	serveAttachment(w, r, app.blobs(), key, attachment.FileName, attachment.ContentType, attachment.CreatedAt)
+}
+
+func (app *App) DeleteAttachment(w http.ResponseWriter, attachmentID, userID int) {
+	var uploaderID int
+	var key string
+	err := app.DB.QueryRow("SELECT user_id, blob_key FROM attachments WHERE attachment_id=?", attachmentID).Scan(&uploaderID, &key)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Attachment not found")
+		problem.Write(w, problem.NotFound("attachment_not_found", "Attachment not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving attachment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	case uploaderID != userID:
+		log.Println("User is not the uploader of the attachment")
+		problem.Write(w, problem.Forbidden("not_uploader", "Only the uploader can delete an attachment"))
+		return
+	}
+
+	_, err = app.DB.Exec("DELETE FROM attachments WHERE attachment_id=?", attachmentID)
+	if err != nil {
+		log.Printf("Error deleting attachment: %v", err)
+		problem.Write(w, problem.Internal("Error deleting attachment"))
+		return
+	}
+	app.deleteBlobs([]string{key})
+
+	log.Println("Attachment deleted successfully")
+	respond.Deleted(w, "Attachment deleted successfully")
+}
+
+func scanAttachment(row rowScanner, attachment *Attachment, extra ...interface{}) error {
+	dest := []interface{}{&attachment.AttachmentID, &attachment.TaskID, &attachment.UserID, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.CreatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// purgeAttachments removes the attachment rows of a task and returns the blob
+// keys, which the caller deletes once the transaction has committed.
+func purgeAttachments(tx *sql.Tx, taskID int) ([]string, error) {
+	rows, err := tx.Query("SELECT blob_key FROM attachments WHERE task_id=?", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err = rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	_, err = tx.Exec("DELETE FROM attachments WHERE task_id=?", taskID)
+	return keys, err
+}
+
+// deleteBlobs removes blobs whose metadata is already gone. Failures only
+// leave orphaned content behind, so they are logged rather than reported.
+func (app *App) deleteBlobs(keys []string) {
+	for _, key := range keys {
+		if err := app.blobs().Delete(context.Background(), key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+}
+
+func cleanFileName(fileName string) string {
+	fileName = path.Base(strings.ReplaceAll(strings.TrimSpace(fileName), "\\", "/"))
+	if fileName == "." || fileName == "/" || len(fileName) > maxFileNameLength {
+		return ""
+	}
+	return fileName
+}
+
+func writeUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, blobstore.ErrTooLarge), errors.As(err, &maxBytesErr):
+		log.Println("Attachment too large:", err)
+		problem.Write(w, problem.New(http.StatusRequestEntityTooLarge, "file_too_large", "File too large"))
+	case errors.Is(err, blobstore.ErrBadType):
+		log.Println("Attachment type not allowed:", err)
+		problem.Write(w, problem.New(http.StatusUnsupportedMediaType, "file_type_not_allowed", "File type not allowed"))
+	default:
+		log.Printf("Error storing attachment: %v", err)
+		problem.Write(w, problem.Internal("Error storing attachment"))
+	}
+}
+
+func serveAttachment(w http.ResponseWriter, r *http.Request, store blobstore.Store, key, fileName, contentType string, modTime time.Time) {
+	content, err := store.Open(r.Context(), key)
+	if err != nil {
+		log.Printf("Error opening blob %s: %v", key, err)
+		if errors.Is(err, blobstore.ErrNotFound) {
+			problem.Write(w, problem.NotFound("attachment_content_not_found", "Attachment content not found"))
+			return
+		}
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, fileName, modTime, content)
+	log.Println("Attachment downloaded successfully")
+}
@@ -0,0 +1,641 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/backup"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backup streams a dump of the whole database. The dump is read from a copy
+// taken with VACUUM INTO, so it is consistent even while the server keeps
+// writing, and writers are not locked out while a slow client downloads it.
+// Blobs are only included when asked for.
+func (app *App) Backup(w http.ResponseWriter, includeBlobs bool) {
+	copied, remove, err := app.copyDatabase()
+	if err != nil {
+		log.Printf("Error copying database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer remove()
+
+	tx, err := copied.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="backup-`+app.now().UTC().Format("20060102-150405")+`.jsonl"`)
+	writer, err := backup.NewWriter(w, "sqlite", app.now())
+	if err != nil {
+		log.Printf("Error writing backup: %v", err)
+		return
+	}
+
+	var blobs []backup.Blob
+	dumps := []func(*sql.Tx, *backup.Writer) error{
+		dumpUsers,
+		dumpTasks,
+		dumpStatusChanges,
+		dumpComments,
+		func(tx *sql.Tx, writer *backup.Writer) error {
+			var err error
+			blobs, err = dumpAttachments(tx, writer)
+			return err
+		},
+		func(tx *sql.Tx, writer *backup.Writer) error {
+			if !includeBlobs {
+				return nil
+			}
+			return app.dumpBlobs(writer, blobs)
+		},
+		dumpTimeEntries,
+		dumpHistory,
+		dumpTemplates,
+	}
+	for _, dump := range dumps {
+		if err = dump(tx, writer); err != nil {
+			log.Printf("Error writing backup: %v", err)
+			return
+		}
+	}
+	if err = writer.Close(); err != nil {
+		log.Printf("Error writing backup: %v", err)
+		return
+	}
+	log.Println("Backup written successfully")
+}
+
+// copyDatabase copies the database to a temporary file and opens the copy
+// read-only. The database is only read while it is copied. remove closes the
+// copy and deletes it.
+func (app *App) copyDatabase() (*sql.DB, func(), error) {
+	dir, err := os.MkdirTemp("", "task-backup-")
+	if err != nil {
+		return nil, nil, err
+	}
+	path := filepath.Join(dir, "backup.db")
+	if _, err = app.DB.Exec("VACUUM INTO ?", path); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	copied, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return copied, func() {
+		copied.Close()
+		_ = os.RemoveAll(dir)
+	}, nil
+}
+
+// dumpRows writes a record of kind for every row of query.
+func dumpRows(tx *sql.Tx, writer *backup.Writer, kind, query string, scan func(rows *sql.Rows) (interface{}, error)) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		record, err := scan(rows)
+		if err != nil {
+			return err
+		}
+		if err = writer.Write(kind, record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func dumpUsers(tx *sql.Tx, writer *backup.Writer) error {
+	return dumpRows(tx, writer, backup.KindUser, "SELECT user_id, user_name, time_zone, COALESCE(feed_token_hash, '') FROM users ORDER BY user_id", func(rows *sql.Rows) (interface{}, error) {
+		var user backup.User
+		var userID int
+		err := rows.Scan(&userID, &user.Name, &user.TimeZone, &user.FeedTokenHash)
+		user.ID = strconv.Itoa(userID)
+		return user, err
+	})
+}
+
+func dumpTasks(tx *sql.Tx, writer *backup.Writer) error {
+	return dumpRows(tx, writer, backup.KindTask, "SELECT "+taskColumns+", t.user_id FROM tasks t ORDER BY t.task_id", func(rows *sql.Rows) (interface{}, error) {
+		var task Task
+		var userID int
+		if err := scanTask(rows, &task, &userID); err != nil {
+			return nil, err
+		}
+		record := backup.Task{
+			ID:              strconv.Itoa(task.TaskID),
+			UserID:          strconv.Itoa(userID),
+			Name:            task.TaskName,
+			DueDate:         task.DueDate.UTC(),
+			Completed:       task.Completed,
+			RRule:           task.RRule,
+			TimeZone:        task.TimeZone,
+			Status:          string(task.Status),
+			StatusChangedBy: optionalID(task.StatusChangedBy),
+			StatusChangedAt: optionalUTC(task.StatusChangedAt),
+			Rank:            task.Rank,
+			Description:     task.Description,
+			Tags:            task.Tags,
+			ParentTaskID:    optionalID(task.ParentTaskID),
+		}
+		for _, item := range task.Checklist {
+			record.Checklist = append(record.Checklist, backup.ChecklistItem{Text: item.Text, Done: item.Done})
+		}
+		return record, nil
+	})
+}
+
+func dumpStatusChanges(tx *sql.Tx, writer *backup.Writer) error {
+	return dumpRows(tx, writer, backup.KindStatusChange, "SELECT change_id, task_id, user_id, from_status, to_status, changed_at FROM task_status_changes ORDER BY change_id", func(rows *sql.Rows) (interface{}, error) {
+		var change backup.StatusChange
+		var changeID, taskID int
+		var userID *int
+		err := rows.Scan(&changeID, &taskID, &userID, &change.FromStatus, &change.ToStatus, &change.ChangedAt)
+		change.ID, change.TaskID, change.UserID, change.ChangedAt = strconv.Itoa(changeID), strconv.Itoa(taskID), optionalID(userID), change.ChangedAt.UTC()
+		return change, err
+	})
+}
+
+func dumpComments(tx *sql.Tx, writer *backup.Writer) error {
+	// Mentions are gathered per comment in one column, as a comma separated
+	// list of user IDs.
+	query := "SELECT c.comment_id, c.task_id, c.user_id, c.content, c.created_at, c.updated_at, COALESCE((SELECT group_concat(cm.user_id) FROM comment_mentions cm WHERE cm.comment_id = c.comment_id), '') FROM comments c ORDER BY c.comment_id"
+	return dumpRows(tx, writer, backup.KindComment, query, func(rows *sql.Rows) (interface{}, error) {
+		var comment backup.Comment
+		var commentID, taskID, userID int
+		var mentions string
+		if err := rows.Scan(&commentID, &taskID, &userID, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt, &mentions); err != nil {
+			return nil, err
+		}
+		comment.ID, comment.TaskID, comment.UserID = strconv.Itoa(commentID), strconv.Itoa(taskID), strconv.Itoa(userID)
+		comment.CreatedAt, comment.UpdatedAt = comment.CreatedAt.UTC(), optionalUTC(comment.UpdatedAt)
+		if mentions != "" {
+			comment.Mentions = strings.Split(mentions, ",")
+		}
+		return comment, nil
+	})
+}
+
+// dumpAttachments returns the blobs of the attachments, without content.
+func dumpAttachments(tx *sql.Tx, writer *backup.Writer) ([]backup.Blob, error) {
+	var blobs []backup.Blob
+	err := dumpRows(tx, writer, backup.KindAttachment, "SELECT attachment_id, task_id, user_id, file_name, content_type, size, blob_key, created_at FROM attachments ORDER BY attachment_id", func(rows *sql.Rows) (interface{}, error) {
+		var attachment backup.Attachment
+		var attachmentID, taskID, userID int
+		err := rows.Scan(&attachmentID, &taskID, &userID, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.BlobKey, &attachment.CreatedAt)
+		attachment.ID, attachment.TaskID, attachment.UserID, attachment.CreatedAt = strconv.Itoa(attachmentID), strconv.Itoa(taskID), strconv.Itoa(userID), attachment.CreatedAt.UTC()
+		blobs = append(blobs, backup.Blob{Key: attachment.BlobKey, ContentType: attachment.ContentType})
+		return attachment, err
+	})
+	return blobs, err
+}
+
+// dumpBlobs reads blobs one at a time, so only one is held in memory.
+func (app *App) dumpBlobs(writer *backup.Writer, blobs []backup.Blob) error {
+	for _, blob := range blobs {
+		content, err := app.blobs().Open(context.Background(), blob.Key)
+		if err != nil {
+			return fmt.Errorf("opening blob %s: %w", blob.Key, err)
+		}
+		blob.Content, err = io.ReadAll(content)
+		content.Close()
+		if err != nil {
+			return fmt.Errorf("reading blob %s: %w", blob.Key, err)
+		}
+		if err = writer.Write(backup.KindBlob, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dumpTimeEntries(tx *sql.Tx, writer *backup.Writer) error {
+	return dumpRows(tx, writer, backup.KindTimeEntry, "SELECT entry_id, task_id, user_id, started_at, ended_at, note FROM time_entries ORDER BY entry_id", func(rows *sql.Rows) (interface{}, error) {
+		var entry backup.TimeEntry
+		var entryID, taskID, userID int
+		err := rows.Scan(&entryID, &taskID, &userID, &entry.StartedAt, &entry.EndedAt, &entry.Note)
+		entry.ID, entry.TaskID, entry.UserID = strconv.Itoa(entryID), strconv.Itoa(taskID), strconv.Itoa(userID)
+		entry.StartedAt, entry.EndedAt = entry.StartedAt.UTC(), optionalUTC(entry.EndedAt)
+		return entry, err
+	})
+}
+
+func dumpHistory(tx *sql.Tx, writer *backup.Writer) error {
+	return dumpRows(tx, writer, backup.KindHistory, "SELECT history_id, task_id, version, action, actor_id, changed_at, changes, snapshot FROM task_history ORDER BY history_id", func(rows *sql.Rows) (interface{}, error) {
+		var entry backup.History
+		var historyID, taskID int
+		var actorID *int
+		var changesJSON, snapshotJSON string
+		err := rows.Scan(&historyID, &taskID, &entry.Version, &entry.Action, &actorID, &entry.ChangedAt, &changesJSON, &snapshotJSON)
+		if err == nil {
+			err = json.Unmarshal([]byte(changesJSON), &entry.Changes)
+		}
+		if err == nil {
+			err = json.Unmarshal([]byte(snapshotJSON), &entry.Snapshot)
+		}
+		entry.ID, entry.TaskID, entry.ActorID, entry.ChangedAt = strconv.Itoa(historyID), strconv.Itoa(taskID), optionalID(actorID), entry.ChangedAt.UTC()
+		return entry, err
+	})
+}
+
+func dumpTemplates(tx *sql.Tx, writer *backup.Writer) error {
+	return dumpRows(tx, writer, backup.KindTemplate, "SELECT template_id, definition, created_by, created_at, updated_at FROM task_templates ORDER BY template_id", func(rows *sql.Rows) (interface{}, error) {
+		var template TaskTemplate
+		if err := scanTemplate(rows, &template); err != nil {
+			return nil, err
+		}
+		return backup.Template{
+			ID:         strconv.Itoa(template.TemplateID),
+			Definition: template.Template,
+			CreatedBy:  strconv.Itoa(template.CreatedBy),
+			CreatedAt:  template.CreatedAt.UTC(),
+			UpdatedAt:  optionalUTC(template.UpdatedAt),
+		}, nil
+	})
+}
+
+func optionalID(id *int) *string {
+	if id == nil {
+		return nil
+	}
+	value := strconv.Itoa(*id)
+	return &value
+}
+
+func optionalUTC(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	value := t.UTC()
+	return &value
+}
+
+func optionalTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return dates.Format(*t)
+}
+
+// Restore loads a dump into the database, which must be empty. Everything is
+// restored in one transaction, so a dump that turns out to be invalid halfway
+// leaves the database empty. IDs of a SQLite dump are kept; the IDs of other
+// dumps are replaced by new ones, references included.
+func (app *App) Restore(w http.ResponseWriter, body io.Reader) {
+	reader, err := backup.NewReader(body)
+	if err != nil {
+		log.Printf("Error reading backup: %v", err)
+		problem.Write(w, problem.BadRequest("invalid_backup", "Restore failed: "+err.Error()))
+		return
+	}
+
+	var notEmpty bool
+	err = app.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM users) OR EXISTS(SELECT 1 FROM tasks) OR EXISTS(SELECT 1 FROM task_status_changes)
+		OR EXISTS(SELECT 1 FROM comments) OR EXISTS(SELECT 1 FROM attachments) OR EXISTS(SELECT 1 FROM time_entries)
+		OR EXISTS(SELECT 1 FROM task_history) OR EXISTS(SELECT 1 FROM task_templates)`).Scan(&notEmpty)
+	if err != nil {
+		log.Printf("Error checking database contents: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if notEmpty {
+		log.Println("Restore refused: database is not empty")
+		problem.Write(w, problem.Conflict("database_not_empty", "Database is not empty"))
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	restorer := &sqliteRestorer{app: app, tx: tx, users: make(map[string]int64), tasks: make(map[string]int64), blobKeys: make(map[string]bool)}
+	err = restorer.run(reader)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		app.deleteBlobs(restorer.stored)
+		log.Printf("Error restoring backup: %v", err)
+		if errors.Is(err, backup.ErrInvalid) {
+			problem.Write(w, problem.BadRequest("invalid_backup", "Restore failed: "+err.Error()))
+			return
+		}
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(backup.Result{Source: reader.Header.Source, Restored: reader.Counts()})
+	if err != nil {
+		log.Printf("Error encoding restore result to JSON: %v", err)
+		return
+	}
+	log.Println("Backup restored successfully")
+}
+
+type sqliteRestorer struct {
+	app      *App
+	tx       *sql.Tx
+	users    map[string]int64
+	tasks    map[string]int64
+	parents  []pendingParent
+	blobKeys map[string]bool
+	stored   []string
+}
+
+type pendingParent struct {
+	taskID         int64
+	source, parent string
+}
+
+func (r *sqliteRestorer) run(reader *backup.Reader) error {
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return r.linkParents()
+		}
+		if err != nil {
+			return err
+		}
+		if err = r.restore(record); err != nil {
+			if isUniqueViolation(err) {
+				return fmt.Errorf("%w: duplicate %s record: %v", backup.ErrInvalid, record.Kind, err)
+			}
+			return err
+		}
+	}
+}
+
+func (r *sqliteRestorer) restore(record backup.Record) error {
+	switch record.Kind {
+	case backup.KindUser:
+		var user backup.User
+		if err := backup.Decode(record, &user); err != nil {
+			return err
+		}
+		var feedTokenHash interface{}
+		if user.FeedTokenHash != "" {
+			feedTokenHash = user.FeedTokenHash
+		}
+		id, err := r.insert(r.users, user.ID, "INSERT INTO users(user_id, user_name, time_zone, feed_token_hash) VALUES(?, ?, ?, ?)", user.Name, user.TimeZone, feedTokenHash)
+		if err != nil {
+			return err
+		}
+		r.users[user.ID] = id
+
+	case backup.KindTask:
+		var task backup.Task
+		if err := backup.Decode(record, &task); err != nil {
+			return err
+		}
+		userID, err := r.reference(r.users, "task", task.ID, "user", task.UserID)
+		if err != nil {
+			return err
+		}
+		var changedBy interface{}
+		if task.StatusChangedBy != nil {
+			if changedBy, err = r.reference(r.users, "task", task.ID, "user", *task.StatusChangedBy); err != nil {
+				return err
+			}
+		}
+		tags, checklist, err := encodeLists(task)
+		if err != nil {
+			return err
+		}
+		id, err := r.insert(r.tasks, task.ID, "INSERT INTO tasks(task_id, user_id, task_name, due_date, completed, rrule, time_zone, status, status_changed_by, status_changed_at, rank, description, tags, checklist) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			userID, task.Name, dates.Format(task.DueDate), task.Completed, task.RRule, task.TimeZone, task.Status, changedBy, optionalTime(task.StatusChangedAt), task.Rank, task.Description, tags, checklist)
+		if err != nil {
+			return err
+		}
+		r.tasks[task.ID] = id
+		// Parents may come after their subtasks, so they are linked once all
+		// tasks are in.
+		if task.ParentTaskID != nil {
+			r.parents = append(r.parents, pendingParent{taskID: id, source: task.ID, parent: *task.ParentTaskID})
+		}
+
+	case backup.KindStatusChange:
+		var change backup.StatusChange
+		if err := backup.Decode(record, &change); err != nil {
+			return err
+		}
+		taskID, err := r.reference(r.tasks, "status change", change.ID, "task", change.TaskID)
+		if err != nil {
+			return err
+		}
+		var userID interface{}
+		if change.UserID != nil {
+			if userID, err = r.reference(r.users, "status change", change.ID, "user", *change.UserID); err != nil {
+				return err
+			}
+		}
+		_, err = r.insert(nil, change.ID, "INSERT INTO task_status_changes(change_id, task_id, user_id, from_status, to_status, changed_at) VALUES(?, ?, ?, ?, ?, ?)",
+			taskID, userID, change.FromStatus, change.ToStatus, dates.Format(change.ChangedAt))
+		return err
+
+	case backup.KindComment:
+		var comment backup.Comment
+		if err := backup.Decode(record, &comment); err != nil {
+			return err
+		}
+		taskID, err := r.reference(r.tasks, "comment", comment.ID, "task", comment.TaskID)
+		if err != nil {
+			return err
+		}
+		userID, err := r.reference(r.users, "comment", comment.ID, "user", comment.UserID)
+		if err != nil {
+			return err
+		}
+		id, err := r.insert(nil, comment.ID, "INSERT INTO comments(comment_id, task_id, user_id, content, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)",
+			taskID, userID, comment.Content, dates.Format(comment.CreatedAt), optionalTime(comment.UpdatedAt))
+		if err != nil {
+			return err
+		}
+		for _, mention := range comment.Mentions {
+			mentionedID, err := r.reference(r.users, "comment", comment.ID, "user", mention)
+			if err != nil {
+				return err
+			}
+			if _, err = r.tx.Exec("INSERT OR IGNORE INTO comment_mentions(comment_id, user_id) VALUES(?, ?)", id, mentionedID); err != nil {
+				return err
+			}
+		}
+
+	case backup.KindAttachment:
+		var attachment backup.Attachment
+		if err := backup.Decode(record, &attachment); err != nil {
+			return err
+		}
+		taskID, err := r.reference(r.tasks, "attachment", attachment.ID, "task", attachment.TaskID)
+		if err != nil {
+			return err
+		}
+		userID, err := r.reference(r.users, "attachment", attachment.ID, "user", attachment.UserID)
+		if err != nil {
+			return err
+		}
+		_, err = r.insert(nil, attachment.ID, "INSERT INTO attachments(attachment_id, task_id, user_id, file_name, content_type, size, blob_key, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+			taskID, userID, attachment.FileName, attachment.ContentType, attachment.Size, attachment.BlobKey, dates.Format(attachment.CreatedAt))
+		if err != nil {
+			return err
+		}
+		r.blobKeys[attachment.BlobKey] = true
+
+	case backup.KindBlob:
+		var blob backup.Blob
+		if err := backup.Decode(record, &blob); err != nil {
+			return err
+		}
+		// Only the blobs of restored attachments are written, so a dump cannot
+		// put anything else into the store.
+		if !r.blobKeys[blob.Key] {
+			return backup.Dangling("blob", blob.Key, "attachment", blob.Key)
+		}
+		if _, err := r.app.blobs().Put(context.Background(), blob.Key, bytes.NewReader(blob.Content), blob.ContentType); err != nil {
+			return err
+		}
+		r.stored = append(r.stored, blob.Key)
+
+	case backup.KindTimeEntry:
+		var entry backup.TimeEntry
+		if err := backup.Decode(record, &entry); err != nil {
+			return err
+		}
+		taskID, err := r.reference(r.tasks, "time entry", entry.ID, "task", entry.TaskID)
+		if err != nil {
+			return err
+		}
+		userID, err := r.reference(r.users, "time entry", entry.ID, "user", entry.UserID)
+		if err != nil {
+			return err
+		}
+		_, err = r.insert(nil, entry.ID, "INSERT INTO time_entries(entry_id, task_id, user_id, started_at, ended_at, note) VALUES(?, ?, ?, ?, ?, ?)",
+			taskID, userID, dates.Format(entry.StartedAt), optionalTime(entry.EndedAt), entry.Note)
+		return err
+
+	case backup.KindHistory:
+		var entry backup.History
+		if err := backup.Decode(record, &entry); err != nil {
+			return err
+		}
+		taskID, err := r.reference(r.tasks, "history entry", entry.ID, "task", entry.TaskID)
+		if err != nil {
+			return err
+		}
+		var actorID interface{}
+		if entry.ActorID != nil {
+			if actorID, err = r.reference(r.users, "history entry", entry.ID, "user", *entry.ActorID); err != nil {
+				return err
+			}
+		}
+		changesJSON, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return err
+		}
+		snapshotJSON, err := json.Marshal(entry.Snapshot)
+		if err != nil {
+			return err
+		}
+		_, err = r.insert(nil, entry.ID, "INSERT INTO task_history(history_id, task_id, version, action, actor_id, changed_at, changes, snapshot) VALUES(?, ?, ?, ?, ?, ?, ?, ?)",
+			taskID, entry.Version, entry.Action, actorID, dates.Format(entry.ChangedAt), string(changesJSON), string(snapshotJSON))
+		return err
+
+	case backup.KindTemplate:
+		var template backup.Template
+		if err := backup.Decode(record, &template); err != nil {
+			return err
+		}
+		createdBy, err := r.reference(r.users, "template", template.ID, "user", template.CreatedBy)
+		if err != nil {
+			return err
+		}
+		definition, err := json.Marshal(template.Definition)
+		if err != nil {
+			return err
+		}
+		_, err = r.insert(nil, template.ID, "INSERT INTO task_templates(template_id, name, definition, created_by, created_at, updated_at) VALUES(?, ?, ?, ?, ?, ?)",
+			template.Definition.Name, string(definition), createdBy, dates.Format(template.CreatedAt), optionalTime(template.UpdatedAt))
+		return err
+	}
+	return nil
+}
+
+// insert runs an INSERT whose first value is the primary key: the ID from the
+// dump if it is a SQLite one, or NULL to have a new one assigned.
+func (r *sqliteRestorer) insert(seen map[string]int64, sourceID, query string, args ...interface{}) (int64, error) {
+	if _, ok := seen[sourceID]; ok {
+		return 0, fmt.Errorf("%w: duplicate ID %q", backup.ErrInvalid, sourceID)
+	}
+	var id interface{}
+	if value, err := strconv.ParseInt(sourceID, 10, 64); err == nil && value > 0 {
+		id = value
+	}
+	result, err := r.tx.Exec(query, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (r *sqliteRestorer) reference(ids map[string]int64, kind, id, field, target string) (int64, error) {
+	restored, ok := ids[target]
+	if !ok {
+		return 0, backup.Dangling(kind, id, field, target)
+	}
+	return restored, nil
+}
+
+func (r *sqliteRestorer) linkParents() error {
+	for _, pending := range r.parents {
+		parentID, ok := r.tasks[pending.parent]
+		if !ok {
+			return backup.Dangling("task", pending.source, "parent task", pending.parent)
+		}
+		if _, err := r.tx.Exec("UPDATE tasks SET parent_task_id=? WHERE task_id=?", parentID, pending.taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeLists(task backup.Task) (string, string, error) {
+	tags := task.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	checklist := []ChecklistItem{}
+	for _, item := range task.Checklist {
+		checklist = append(checklist, ChecklistItem{Text: item.Text, Done: item.Done})
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return "", "", err
+	}
+	checklistJSON, err := json.Marshal(checklist)
+	return string(tagsJSON), string(checklistJSON), err
+}
@@ -0,0 +1,64 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/backup"
+	"bytes"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// stalledClient stops reading a dump at its first user record until it is
+// released.
+type stalledClient struct {
+	*httptest.ResponseRecorder
+	stalled chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (c *stalledClient) Write(b []byte) (int, error) {
+	if bytes.Contains(b, []byte(`"kind":"user"`)) {
+		c.once.Do(func() {
+			close(c.stalled)
+			<-c.release
+		})
+	}
+	return c.ResponseRecorder.Write(b)
+}
+
+func TestBackupDoesNotBlockWriters(t *testing.T) {
+	app := newTestApp(t)
+	addTask(t, app, "")
+
+	client := &stalledClient{ResponseRecorder: httptest.NewRecorder(), stalled: make(chan struct{}), release: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		app.Backup(client, false)
+		close(done)
+	}()
+	<-client.stalled
+	_, err := app.DB.Exec("INSERT INTO users(user_name) VALUES('grace')")
+	close(client.release)
+	<-done
+	if err != nil {
+		t.Errorf("writing while a backup downloads: %v", err)
+	}
+
+	// The dump is complete and has the state from before the write.
+	reader, err := backup.NewReader(client.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for err == nil {
+		_, err = reader.Next()
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("reading the dump: %v", err)
+	}
+	if counts := reader.Counts(); counts[backup.KindUser] != 1 || counts[backup.KindTask] != 1 {
+		t.Errorf("dump counts %v, want 1 user and 1 task", counts)
+	}
+}
@@ -0,0 +1,235 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/etag"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/workflow"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type BoardColumn struct {
+	Status   workflow.Status `json:"status"`
+	WIPLimit int             `json:"wip_limit,omitempty"`
+	Tasks    []Task          `json:"tasks"`
+}
+
+type Board struct {
+	Columns []BoardColumn `json:"columns"`
+}
+
+var (
+	errWIPLimitReached = errors.New("WIP limit reached")
+	errInvalidNeighbor = errors.New("neighbor task is not in the target column")
+)
+
+func (app *App) GetBoard(w http.ResponseWriter, userID int) {
+	query := "SELECT " + taskColumns + " FROM tasks t WHERE t.due_date<>?"
+	args := []interface{}{deletedDueDate}
+	if userID != 0 {
+		query += " AND t.user_id=?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY t.rank, t.task_id"
+
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		log.Printf("Error querying tasks from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer rows.Close()
+
+	byStatus := make(map[workflow.Status][]Task)
+	for rows.Next() {
+		var task Task
+		if err = scanTask(rows, &task); err != nil {
+			log.Printf("Error scanning task row: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		byStatus[task.Status] = append(byStatus[task.Status], task)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("Error iterating over task rows: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	var board Board
+	for _, column := range app.workflow().BoardColumns() {
+		tasks := byStatus[column.Status]
+		if tasks == nil {
+			tasks = []Task{}
+		}
+		board.Columns = append(board.Columns, BoardColumn{
+			Status:   column.Status,
+			WIPLimit: column.WIPLimit,
+			Tasks:    tasks,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(board)
+	if err != nil {
+		log.Printf("Error encoding board to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Board retrieved successfully")
+}
+
+// MoveTask places a task into the column for status, between afterTaskID and
+// beforeTaskID. Either neighbor may be zero; without neighbors the task goes to
+// the end of the column. An empty status keeps the current column.
+func (app *App) MoveTask(w http.ResponseWriter, taskID, userID int, status string, afterTaskID, beforeTaskID int) {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	var task Task
+	err = scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.task_id=? AND t.user_id=?", taskID, userID), &task)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Task assignment not found or user does not have permission")
+		problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+		return
+	case err != nil:
+		log.Printf("Error checking task assignment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	before := snapshotOf(task)
+
+	target := workflow.Status(status)
+	if target == "" {
+		target = task.Status
+	}
+
+	boardRank, err := app.placeTask(tx, task, target, afterTaskID, beforeTaskID)
+	if err == nil {
+		err = claimVersion(tx, task)
+	}
+	if err == nil {
+		if target == task.Status {
+			_, err = tx.Exec("UPDATE tasks SET rank=? WHERE task_id=?", boardRank, taskID)
+		} else {
+			err = app.changeStatus(tx, task, userID, target, boardRank)
+		}
+	}
+	if err == nil {
+		err = app.recordHistory(tx, taskID, userID, audit.Updated, &before)
+	}
+	switch {
+	case errors.Is(err, workflow.ErrUnknownStatus):
+		log.Println("Invalid status:", err)
+		problem.Write(w, problem.Malformed("status", "Unknown status"))
+		return
+	case errors.Is(err, workflow.ErrInvalidTransition):
+		log.Println("Invalid status transition:", err)
+		problem.Write(w, problem.Conflict("status_transition_not_allowed", "Status transition not allowed"))
+		return
+	case errors.Is(err, errWIPLimitReached):
+		log.Println("WIP limit reached:", err)
+		problem.Write(w, problem.Conflict("wip_limit_reached", "WIP limit reached for column"))
+		return
+	case errors.Is(err, errVersionChanged):
+		log.Println("Concurrent task change:", err)
+		problem.Write(w, problem.Conflict("concurrent_change", "Task changed concurrently"))
+		return
+	case errors.Is(err, errInvalidNeighbor), errors.Is(err, rank.ErrInvalidRank):
+		log.Println("Invalid position:", err)
+		problem.Write(w, problem.Malformed("position", "Invalid position"))
+		return
+	case err != nil:
+		log.Printf("Error moving task: %v", err)
+		problem.Write(w, problem.Internal("Error moving task"))
+		return
+	}
+	moved, err := getTask(tx, taskID)
+	if err != nil {
+		log.Printf("Error retrieving moved task: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("ETag", etag.Version(moved.Version))
+	log.Println("Task moved successfully")
+	respond.Updated(w, moved, "Task moved successfully")
+}
+
+// placeTask checks the move against the workflow and the WIP limit of the
+// target column and returns the rank that puts the task between its neighbors.
+func (app *App) placeTask(tx *sql.Tx, task Task, target workflow.Status, afterTaskID, beforeTaskID int) (string, error) {
+	wf := app.workflow()
+	if err := wf.CheckTransition(task.Status, target); err != nil {
+		return "", err
+	}
+
+	if limit := wf.WIPLimit(target); limit > 0 && target != task.Status {
+		var count int
+		err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE status=? AND due_date<>?", target, deletedDueDate).Scan(&count)
+		if err != nil {
+			return "", err
+		}
+		if count >= limit {
+			return "", errWIPLimitReached
+		}
+	}
+
+	after, err := neighborRank(tx, afterTaskID, task.TaskID, target)
+	if err != nil {
+		return "", err
+	}
+	before, err := neighborRank(tx, beforeTaskID, task.TaskID, target)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case afterTaskID != 0 && beforeTaskID == 0:
+		err = tx.QueryRow("SELECT COALESCE(MIN(rank), '') FROM tasks WHERE status=? AND rank>? AND task_id<>?", target, after, task.TaskID).Scan(&before)
+	case afterTaskID == 0 && beforeTaskID != 0:
+		err = tx.QueryRow("SELECT COALESCE(MAX(rank), '') FROM tasks WHERE status=? AND rank<? AND task_id<>?", target, before, task.TaskID).Scan(&after)
+	case afterTaskID == 0 && beforeTaskID == 0:
+		err = tx.QueryRow("SELECT COALESCE(MAX(rank), '') FROM tasks WHERE status=? AND task_id<>?", target, task.TaskID).Scan(&after)
+	}
+	if err != nil {
+		return "", err
+	}
+	return rank.Between(after, before)
+}
+
+func neighborRank(tx *sql.Tx, neighborID, taskID int, target workflow.Status) (string, error) {
+	if neighborID == 0 {
+		return "", nil
+	}
+	if neighborID == taskID {
+		return "", errInvalidNeighbor
+	}
+
+	var neighborRank string
+	var neighborStatus workflow.Status
+	err := tx.QueryRow("SELECT rank, status FROM tasks WHERE task_id=?", neighborID).Scan(&neighborRank, &neighborStatus)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && neighborStatus != target) {
+		return "", errInvalidNeighbor
+	}
+	return neighborRank, err
+}
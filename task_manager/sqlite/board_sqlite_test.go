@@ -0,0 +1,70 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/workflow"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBoardLeavesOutDeletedTasks(t *testing.T) {
+	tests := []struct {
+		name       string
+		deleteTask bool
+		code       int
+		todo       []string
+	}{
+		{name: "task in the full column", code: http.StatusConflict, todo: []string{"water plants"}},
+		{name: "deleted task in the full column", deleteTask: true, code: http.StatusOK, todo: []string{"repot cactus"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApp(t)
+			app.Workflow = workflow.Default()
+			app.Workflow.Columns[0] = workflow.Column{Status: workflow.Todo, WIPLimit: 1}
+			task, userID := addTask(t, app, "")
+
+			tx, err := app.DB.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			otherID, err := app.insertTask(tx, userID, "repot cactus", time.Date(2024, time.March, 26, 9, 0, 0, 0, time.UTC), "", "UTC", workflow.InProgress, nil)
+			if err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.deleteTask {
+				recorder := httptest.NewRecorder()
+				app.DeleteTask(recorder, task.TaskID, userID, nil)
+				if recorder.Code != http.StatusNoContent {
+					t.Fatalf("DeleteTask: %d %s", recorder.Code, recorder.Body)
+				}
+			}
+
+			recorder := httptest.NewRecorder()
+			app.MoveTask(recorder, otherID, userID, string(workflow.Todo), 0, 0)
+			if recorder.Code != test.code {
+				t.Errorf("MoveTask: %d %s, want %d", recorder.Code, recorder.Body, test.code)
+			}
+
+			recorder = httptest.NewRecorder()
+			app.GetBoard(recorder, 0)
+			var board Board
+			if err = json.NewDecoder(recorder.Body).Decode(&board); err != nil {
+				t.Fatal(err)
+			}
+			var todo []string
+			for _, task := range board.Columns[0].Tasks {
+				todo = append(todo, task.TaskName)
+			}
+			if !reflect.DeepEqual(todo, test.todo) {
+				t.Errorf("to do: %q, want %q", todo, test.todo)
+			}
+		})
+	}
+}
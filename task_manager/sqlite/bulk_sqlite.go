@@ -0,0 +1,216 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/recurrence"
+	"Simple_Task_Manager/workflow"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (app *App) BulkTasks(w http.ResponseWriter, mode bulk.Mode, operations []bulk.Operation) {
+	response, err := app.runBulk(mode, operations)
+	if err != nil {
+		log.Printf("Error applying bulk operations: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	writeBulkResponse(w, response)
+}
+
+func writeBulkResponse(w http.ResponseWriter, response bulk.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode())
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding bulk response to JSON: %v", err)
+		return
+	}
+	log.Printf("Bulk operations applied: %d succeeded, %d failed", response.Succeeded, response.Failed)
+}
+
+// runBulk applies operations in a single transaction. In atomic mode the
+// first failure rolls back the whole batch; in partial mode every operation
+// runs in its own savepoint, so a failure only undoes that operation.
+func (app *App) runBulk(mode bulk.Mode, operations []bulk.Operation) (bulk.Response, error) {
+	response := bulk.Response{Mode: mode, Results: []bulk.Result{}}
+	tx, err := app.DB.Begin()
+	if err != nil {
+		return response, err
+	}
+	defer tx.Rollback()
+
+	users := make(map[string]User)
+	var blobKeys []string
+	for i, operation := range operations {
+		if response.Failed > 0 && mode == bulk.Atomic {
+			response.Fail(i, operation.Op, bulk.Fail(http.StatusFailedDependency, "Not attempted because another operation failed"))
+			continue
+		}
+
+		if mode == bulk.Partial {
+			if _, err = tx.Exec("SAVEPOINT bulk_operation"); err != nil {
+				return response, err
+			}
+		}
+
+		taskID, status, keys, opErr := app.applyOperation(tx, operation, users)
+		if opErr != nil {
+			failure := bulk.FailureOf(opErr)
+			if failure.Status == http.StatusInternalServerError {
+				log.Printf("Error applying bulk operation %d: %v", i, opErr)
+			}
+			response.Fail(i, operation.Op, failure)
+			if mode == bulk.Partial {
+				// Users created by the failed operation are gone again.
+				users = make(map[string]User)
+				_, err = tx.Exec("ROLLBACK TO bulk_operation; RELEASE bulk_operation")
+			}
+		} else {
+			response.Succeed(i, operation.Op, status, taskID)
+			blobKeys = append(blobKeys, keys...)
+			if mode == bulk.Partial {
+				_, err = tx.Exec("RELEASE bulk_operation")
+			}
+		}
+		if err != nil {
+			return response, err
+		}
+	}
+
+	if response.Failed > 0 && mode == bulk.Atomic {
+		response.Abort()
+		return response, nil
+	}
+	if err = tx.Commit(); err != nil {
+		return response, err
+	}
+	app.deleteBlobs(blobKeys)
+	return response, nil
+}
+
+// applyOperation runs a single operation of a batch and returns the affected
+// task, the status the single-task endpoint would have answered and the keys
+// of attachment blobs to delete after commit. users caches user lookups by
+// name for the rest of the batch.
+func (app *App) applyOperation(tx *sql.Tx, operation bulk.Operation, users map[string]User) (int, int, []string, error) {
+	if failure := operation.Validate(); failure != nil {
+		return 0, 0, nil, failure
+	}
+
+	if operation.Op == bulk.Create {
+		taskID, err := app.bulkCreate(tx, operation, users)
+		return taskID, http.StatusCreated, nil, err
+	}
+
+	taskID, err := strconv.Atoi(string(operation.TaskID))
+	if err != nil {
+		return 0, 0, nil, bulk.Fail(http.StatusBadRequest, "Invalid task ID")
+	}
+	userID, err := strconv.Atoi(string(operation.UserID))
+	if err != nil {
+		return 0, 0, nil, bulk.Fail(http.StatusBadRequest, "Invalid user ID")
+	}
+
+	var task Task
+	err = scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.task_id=? AND t.user_id=?", taskID, userID), &task)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return 0, 0, nil, bulk.Fail(http.StatusNotFound, "Task assignment not found or user does not have permission")
+	case err != nil:
+		return 0, 0, nil, err
+	}
+
+	if operation.Op == bulk.Delete {
+		blobKeys, err := app.anonymizeTask(tx, task, userID)
+		return taskID, http.StatusOK, blobKeys, err
+	}
+
+	target := workflow.Status(operation.Status)
+	if operation.Op == bulk.Complete {
+		target = app.workflow().CompletedStatus()
+	}
+	var due time.Time
+	if operation.Op == bulk.Update && operation.DueDate != "" {
+		location, err := dates.LoadLocation(task.TimeZone)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if due, err = dates.Resolve(operation.DueDate, app.now(), location); err != nil {
+			return 0, 0, nil, bulk.Fail(http.StatusBadRequest, "Invalid due date")
+		}
+	}
+
+	err = app.applyUpdate(tx, task, userID, due, target)
+	switch {
+	case errors.Is(err, workflow.ErrUnknownStatus):
+		return 0, 0, nil, bulk.Fail(http.StatusBadRequest, "Unknown status")
+	case errors.Is(err, workflow.ErrInvalidTransition):
+		return 0, 0, nil, bulk.Fail(http.StatusConflict, "Status transition not allowed")
+	}
+	return taskID, http.StatusOK, nil, err
+}
+
+// bulkCreate creates a task like CreateTask does, creating its user by name
+// when needed. Unlike CreateTask it can place the task in any status, so
+// exported tasks can be imported again as they were.
+func (app *App) bulkCreate(tx *sql.Tx, operation bulk.Operation, users map[string]User) (int, error) {
+	user, known := users[operation.UserName]
+	if !known {
+		user = User{UserName: operation.UserName, TimeZone: "UTC"}
+		err := tx.QueryRow("SELECT user_id, time_zone FROM users WHERE user_name = ?", operation.UserName).Scan(&user.UserID, &user.TimeZone)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, err
+		}
+	}
+
+	timeZone := operation.TimeZone
+	if timeZone == "" {
+		timeZone = user.TimeZone
+	}
+	location, err := dates.LoadLocation(timeZone)
+	if err != nil {
+		return 0, bulk.Fail(http.StatusBadRequest, "Invalid time zone")
+	}
+	due, err := dates.Resolve(operation.DueDate, app.now(), location)
+	if err != nil {
+		return 0, bulk.Fail(http.StatusBadRequest, "Invalid due date")
+	}
+	if operation.RRule != "" {
+		if err := recurrence.Validate(operation.RRule, timeZone); err != nil {
+			return 0, bulk.Fail(http.StatusBadRequest, "Invalid recurrence rule")
+		}
+	}
+	status := app.workflow().Initial
+	if operation.Completed {
+		status = app.workflow().CompletedStatus()
+	}
+	if operation.Status != "" {
+		status = workflow.Status(operation.Status)
+		if !app.workflow().Valid(status) {
+			return 0, bulk.Fail(http.StatusBadRequest, "Unknown status")
+		}
+	}
+
+	if user.UserID == 0 {
+		result, err := tx.Exec("INSERT INTO users(user_name) VALUES(?)", operation.UserName)
+		if err != nil {
+			return 0, err
+		}
+		lastInsertID, err := result.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		user.UserID = int(lastInsertID)
+	}
+	users[operation.UserName] = user
+
+	return app.insertTask(tx, user.UserID, operation.TaskName, due, operation.RRule, timeZone, status, operation.Tags)
+}
@@ -0,0 +1,132 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/ical"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/recurrence"
+	"Simple_Task_Manager/respond"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+type FeedToken struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// CreateFeedToken issues a new calendar feed token for a user. The previous
+// token, if any, stops working. The feed's URL in the answer is feedPath with
+// the token.
+func (app *App) CreateFeedToken(w http.ResponseWriter, userID int, feedPath string) {
+	if _, ok := app.findUser(w, userID); !ok {
+		return
+	}
+
+	token, hash, err := ical.NewToken()
+	if err != nil {
+		log.Printf("Error generating feed token: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	_, err = app.DB.Exec("UPDATE users SET feed_token_hash=? WHERE user_id=?", hash, userID)
+	if err != nil {
+		log.Printf("Error storing feed token: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(FeedToken{Token: token, URL: feedPath + "?token=" + url.QueryEscape(token)})
+	if err != nil {
+		log.Printf("Error encoding feed token to JSON: %v", err)
+		return
+	}
+	log.Println("Feed token created successfully")
+}
+
+func (app *App) DeleteFeedToken(w http.ResponseWriter, userID int) {
+	if _, ok := app.findUser(w, userID); !ok {
+		return
+	}
+
+	_, err := app.DB.Exec("UPDATE users SET feed_token_hash=NULL WHERE user_id=?", userID)
+	if err != nil {
+		log.Printf("Error revoking feed token: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Feed token revoked successfully")
+	respond.Deleted(w, "Feed token revoked successfully")
+}
+
+// GetCalendarFeed publishes the tasks with a due date of the user owning
+// token as an iCalendar feed.
+func (app *App) GetCalendarFeed(w http.ResponseWriter, token string, component ical.Component) {
+	var user User
+	err := app.DB.QueryRow("SELECT user_id, user_name FROM users WHERE feed_token_hash=?", ical.HashToken(token)).Scan(&user.UserID, &user.UserName)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Feed not found")
+		problem.Write(w, problem.NotFound("feed_not_found", "Feed not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving feed owner: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	tasks, err := app.queryTasks([]string{"t.user_id = ?"}, []interface{}{user.UserID}, "t.due_date, t.task_id")
+	if err != nil {
+		log.Printf("Error querying tasks from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	calendar := ical.Calendar{Name: "Tasks of " + user.UserName, Component: component, Items: []ical.Item{}}
+	for _, task := range tasks {
+		// Deleted tasks are kept without a due date.
+		if task.DueDate.IsZero() {
+			continue
+		}
+		calendar.Items = append(calendar.Items, app.calendarItem(task))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tasks.ics"`)
+	if err = ical.Write(w, calendar, app.now()); err != nil {
+		log.Printf("Error writing calendar feed: %v", err)
+		return
+	}
+	log.Println("Calendar feed gathered successfully")
+}
+
+func (app *App) calendarItem(task Task) ical.Item {
+	item := ical.Item{
+		UID:         "task-" + strconv.Itoa(task.TaskID) + "@simple-task-manager",
+		Summary:     task.TaskName,
+		Description: task.Description,
+		Due:         task.DueDate,
+		TimeZone:    task.TimeZone,
+		State:       ical.StateOf(app.workflow(), task.Status),
+		Priority:    ical.PriorityOf(task.Tags),
+		Categories:  task.Tags,
+	}
+	if item.State == ical.Completed {
+		item.CompletedAt = task.StatusChangedAt
+	}
+	// Completing a recurring task creates its next occurrence as a new task,
+	// so only open tasks repeat in the feed.
+	if task.RRule != "" && !app.workflow().IsCompleted(task.Status) {
+		if rule, err := recurrence.Parse(task.RRule); err == nil {
+			item.RRule = rule.String()
+		}
+	}
+	return item
+}
@@ -0,0 +1,345 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/mentions"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Comment struct {
+	CommentID  int        `json:"comment_id"`
+	TaskID     int        `json:"task_id"`
+	UserID     int        `json:"user_id"`
+	AuthorName string     `json:"author_name"`
+	Content    string     `json:"content"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	Mentions   []Mention  `json:"mentions"`
+}
+
+type Mention struct {
+	UserID   int    `json:"user_id"`
+	UserName string `json:"user_name"`
+}
+
+func (app *App) GetComments(w http.ResponseWriter, taskID, limit, offset int) {
+	var taskExists bool
+	var total int
+	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=?), (SELECT COUNT(*) FROM comments WHERE task_id=?)", taskID, taskID).Scan(&taskExists, &total)
+	if err != nil {
+		log.Printf("Error counting comments: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !taskExists {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return
+	}
+
+	rows, err := app.DB.Query("SELECT c.comment_id, c.task_id, c.user_id, u.user_name, c.content, c.created_at, c.updated_at FROM comments c INNER JOIN users u ON c.user_id = u.user_id WHERE c.task_id=? ORDER BY c.created_at, c.comment_id LIMIT ? OFFSET ?", taskID, limit, offset)
+	if err != nil {
+		log.Printf("Error querying comments from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	byID := make(map[int]int)
+	for rows.Next() {
+		var comment Comment
+		err = rows.Scan(&comment.CommentID, &comment.TaskID, &comment.UserID, &comment.AuthorName, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt)
+		if err != nil {
+			log.Printf("Error scanning comment row: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		comment.Mentions = []Mention{}
+		byID[comment.CommentID] = len(comments)
+		comments = append(comments, comment)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("Error iterating over comment rows: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	rows.Close()
+
+	if len(comments) > 0 {
+		placeholders := make([]string, 0, len(comments))
+		args := make([]interface{}, 0, len(comments))
+		for _, comment := range comments {
+			placeholders = append(placeholders, "?")
+			args = append(args, comment.CommentID)
+		}
+
+		rows, err = app.DB.Query("SELECT cm.comment_id, u.user_id, u.user_name FROM comment_mentions cm INNER JOIN users u ON cm.user_id = u.user_id WHERE cm.comment_id IN ("+strings.Join(placeholders, ", ")+") ORDER BY u.user_name", args...)
+		if err != nil {
+			log.Printf("Error querying mentions from database: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var commentID int
+			var mention Mention
+			if err = rows.Scan(&commentID, &mention.UserID, &mention.UserName); err != nil {
+				log.Printf("Error scanning mention row: %v", err)
+				problem.Write(w, problem.ErrInternal)
+				return
+			}
+			comment := &comments[byID[commentID]]
+			comment.Mentions = append(comment.Mentions, mention)
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("Error iterating over mention rows: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	err = json.NewEncoder(w).Encode(comments)
+	if err != nil {
+		log.Printf("Error encoding comments to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Comments gathered successfully")
+}
+
+func (app *App) CreateComment(w http.ResponseWriter, taskID, userID int, content string) {
+	var taskExists, userExists bool
+	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=?), EXISTS(SELECT 1 FROM users WHERE user_id=?)", taskID, userID).Scan(&taskExists, &userExists)
+	if err != nil {
+		log.Printf("Error checking task and user existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !taskExists {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return
+	}
+	if !userExists {
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO comments(task_id, user_id, content, created_at) VALUES(?, ?, ?, ?)", taskID, userID, content, dates.Format(app.now()))
+	if err != nil {
+		log.Printf("Error inserting comment: %v", err)
+		problem.Write(w, problem.Internal("Error inserting comment"))
+		return
+	}
+	commentID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting last inserted ID: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if err = saveMentions(tx, int(commentID), content); err != nil {
+		log.Printf("Error saving mentions: %v", err)
+		problem.Write(w, problem.Internal("Error inserting comment"))
+		return
+	}
+	comment, err := getComment(tx, int(commentID))
+	if err != nil {
+		log.Printf("Error retrieving created comment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Comment created successfully")
+	respond.Created(w, "/comments/"+strconv.FormatInt(commentID, 10), comment, "Comment created successfully")
+}
+
+func (app *App) UpdateComment(w http.ResponseWriter, commentID, userID int, content string) {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkCommentAuthor(w, tx, commentID, userID) {
+		return
+	}
+
+	_, err = tx.Exec("UPDATE comments SET content=?, updated_at=? WHERE comment_id=?", content, dates.Format(app.now()), commentID)
+	if err != nil {
+		log.Printf("Error updating comment: %v", err)
+		problem.Write(w, problem.Internal("Error updating comment"))
+		return
+	}
+
+	if _, err = tx.Exec("DELETE FROM comment_mentions WHERE comment_id=?", commentID); err == nil {
+		err = saveMentions(tx, commentID, content)
+	}
+	if err != nil {
+		log.Printf("Error saving mentions: %v", err)
+		problem.Write(w, problem.Internal("Error updating comment"))
+		return
+	}
+	comment, err := getComment(tx, commentID)
+	if err != nil {
+		log.Printf("Error retrieving updated comment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Comment updated successfully")
+	respond.Updated(w, comment, "Comment updated successfully")
+}
+
+func (app *App) DeleteComment(w http.ResponseWriter, commentID, userID int) {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkCommentAuthor(w, tx, commentID, userID) {
+		return
+	}
+
+	if _, err = tx.Exec("DELETE FROM comment_mentions WHERE comment_id=?", commentID); err == nil {
+		_, err = tx.Exec("DELETE FROM comments WHERE comment_id=?", commentID)
+	}
+	if err != nil {
+		log.Printf("Error deleting comment: %v", err)
+		problem.Write(w, problem.Internal("Error deleting comment"))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Comment deleted successfully")
+	respond.Deleted(w, "Comment deleted successfully")
+}
+
+// getComment returns a comment with its mentions.
+func getComment(tx *sql.Tx, commentID int) (Comment, error) {
+	var comment Comment
+	err := tx.QueryRow("SELECT c.comment_id, c.task_id, c.user_id, u.user_name, c.content, c.created_at, c.updated_at FROM comments c INNER JOIN users u ON c.user_id = u.user_id WHERE c.comment_id=?", commentID).
+		Scan(&comment.CommentID, &comment.TaskID, &comment.UserID, &comment.AuthorName, &comment.Content, &comment.CreatedAt, &comment.UpdatedAt)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	rows, err := tx.Query("SELECT u.user_id, u.user_name FROM comment_mentions cm INNER JOIN users u ON cm.user_id = u.user_id WHERE cm.comment_id=? ORDER BY u.user_name", commentID)
+	if err != nil {
+		return Comment{}, err
+	}
+	defer rows.Close()
+	comment.Mentions = []Mention{}
+	for rows.Next() {
+		var mention Mention
+		if err = rows.Scan(&mention.UserID, &mention.UserName); err != nil {
+			return Comment{}, err
+		}
+		comment.Mentions = append(comment.Mentions, mention)
+	}
+	return comment, rows.Err()
+}
+
+// checkCommentAuthor writes an error response and returns false unless the
+// comment exists and was written by userID.
+func checkCommentAuthor(w http.ResponseWriter, tx *sql.Tx, commentID, userID int) bool {
+	var authorID int
+	err := tx.QueryRow("SELECT user_id FROM comments WHERE comment_id=?", commentID).Scan(&authorID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Comment not found")
+		problem.Write(w, problem.NotFound("comment_not_found", "Comment not found"))
+		return false
+	case err != nil:
+		log.Printf("Error retrieving comment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return false
+	case authorID != userID:
+		log.Println("User is not the author of the comment")
+		problem.Write(w, problem.Forbidden("not_author", "Only the author can change a comment"))
+		return false
+	}
+	return true
+}
+
+// saveMentions resolves the @mentions in content against the users table.
+// Mentions of unknown users stay plain text.
+func saveMentions(tx *sql.Tx, commentID int, content string) error {
+	keys := mentions.Extract(content)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	rows, err := tx.Query("SELECT user_id, user_name FROM users")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var mentioned []int
+	for rows.Next() {
+		var userID int
+		var userName string
+		if err = rows.Scan(&userID, &userName); err != nil {
+			return err
+		}
+		if mentions.Matches(keys, userName) {
+			mentioned = append(mentioned, userID)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	for _, userID := range mentioned {
+		if _, err = tx.Exec("INSERT OR IGNORE INTO comment_mentions(comment_id, user_id) VALUES(?, ?)", commentID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/taskcsv"
+	"Simple_Task_Manager/todolist"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ExportTasks streams the tasks as CSV or as a todolist format, those of one
+// user if userID is not 0. Rows are written while they are read, so an error
+// after the first rows can only end the response early.
+func (app *App) ExportTasks(w http.ResponseWriter, format string, userID int) {
+	query := "SELECT " + taskColumns + ", u.user_id, u.user_name FROM tasks t INNER JOIN users u ON t.user_id = u.user_id"
+	var args []interface{}
+	if userID != 0 {
+		query += " WHERE t.user_id = ?"
+		args = append(args, userID)
+	}
+	rows, err := app.DB.Query(query+" ORDER BY t.task_id", args...)
+	if err != nil {
+		log.Printf("Error querying tasks from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer rows.Close()
+
+	writer, err := newExportWriter(w, format)
+	if err != nil {
+		log.Printf("Error writing task export: %v", err)
+		return
+	}
+
+	exported := 0
+	for rows.Next() {
+		var task Task
+		var user User
+		if err = scanTask(rows, &task, &user.UserID, &user.UserName); err != nil {
+			log.Printf("Error scanning task row: %v", err)
+			return
+		}
+		err = writer.Write(taskcsv.Row{
+			TaskID:      strconv.Itoa(task.TaskID),
+			TaskName:    task.TaskName,
+			UserID:      strconv.Itoa(user.UserID),
+			UserName:    user.UserName,
+			Status:      string(task.Status),
+			Completed:   task.Completed,
+			DueDate:     task.DueDate,
+			TimeZone:    task.TimeZone,
+			RRule:       task.RRule,
+			Description: task.Description,
+			Tags:        task.Tags,
+		})
+		if err != nil {
+			log.Printf("Error writing task export: %v", err)
+			return
+		}
+		exported++
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("Error iterating over task rows: %v", err)
+		return
+	}
+	if err = writer.Flush(); err != nil {
+		log.Printf("Error writing task export: %v", err)
+		return
+	}
+	log.Printf("Tasks exported successfully: %d tasks", exported)
+}
+
+func newExportWriter(w http.ResponseWriter, format string) (taskcsv.RowWriter, error) {
+	if todolist.ValidFormat(format) {
+		w.Header().Set("Content-Type", todolist.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="`+todolist.FileName(format)+`"`)
+		return todolist.NewWriter(w, format), nil
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+	return taskcsv.NewWriter(w)
+}
+
+// ImportTasks creates the tasks of an import like a batch of create
+// operations. In atomic mode nothing is imported if any row is invalid.
+func (app *App) ImportTasks(w http.ResponseWriter, mode bulk.Mode, rows []taskcsv.ImportRow) {
+	response := bulk.Response{Mode: mode}
+	if mode == bulk.Partial || !taskcsv.Failed(rows) {
+		var err error
+		response, err = app.runBulk(mode, taskcsv.Operations(rows))
+		if err != nil {
+			log.Printf("Error importing tasks: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+	}
+	writeImportResponse(w, taskcsv.Merge(rows, response))
+}
+
+func writeImportResponse(w http.ResponseWriter, response taskcsv.ImportResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode())
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error encoding import response to JSON: %v", err)
+		return
+	}
+	log.Printf("Tasks imported: %d imported, %d failed", response.Imported, response.Failed)
+}
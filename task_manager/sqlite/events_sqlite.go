@@ -0,0 +1,75 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/problem"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StreamEvents streams the changes to tasks matching filter as Server-Sent
+// Events.
+func (app *App) StreamEvents(w http.ResponseWriter, r *http.Request, filter events.Filter) {
+	if app.Events == nil {
+		log.Println("Events are not configured")
+		problem.Write(w, problem.NotFound("events_not_configured", "Events are not configured"))
+		return
+	}
+	events.Serve(w, r, app.Events, filter)
+}
+
+// RelayEvents publishes the changes recorded in task history from now on to
+// the event stream, checking for new ones every interval. History is only
+// read once committed, so changes that get rolled back, wholly or to a
+// savepoint, are never published.
+func (app *App) RelayEvents(interval time.Duration) {
+	var last int
+	if err := app.DB.QueryRow("SELECT COALESCE(MAX(history_id), 0) FROM task_history").Scan(&last); err != nil {
+		log.Printf("Error reading task history: %v", err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		var err error
+		if last, err = app.relayEvents(last); err != nil {
+			log.Printf("Error relaying events: %v", err)
+		}
+	}
+}
+
+// relayEvents publishes the changes recorded after the history entry last
+// and returns the latest entry it has published.
+func (app *App) relayEvents(last int) (int, error) {
+	rows, err := app.DB.Query("SELECT "+taskColumns+", h.history_id, h.action, t.user_id FROM task_history h INNER JOIN tasks t ON t.task_id = h.task_id WHERE h.history_id > ? ORDER BY h.history_id", last)
+	if err != nil {
+		return last, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var task Task
+		var historyID, userID int
+		var action audit.Action
+		if err = scanTask(rows, &task, &historyID, &action, &userID); err != nil {
+			return last, err
+		}
+		last = historyID
+
+		eventType, ok := events.TypeOf(action)
+		if !ok {
+			continue
+		}
+		var data []byte
+		if data, err = json.Marshal(task); err != nil {
+			return last, err
+		}
+		app.Events.Publish(events.Event{Type: eventType, UserID: strconv.Itoa(userID), Tags: task.Tags, Data: data})
+	}
+	return last, rows.Err()
+}
@@ -0,0 +1,294 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/etag"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/workflow"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+type HistoryEntry struct {
+	Version   int            `json:"version"`
+	Action    audit.Action   `json:"action"`
+	ActorID   *int           `json:"actor_id"`
+	ChangedAt time.Time      `json:"changed_at"`
+	Changes   []audit.Change `json:"changes"`
+	Snapshot  audit.Snapshot `json:"snapshot"`
+}
+
+var errUnrestorableStatus = errors.New("status of the version is not part of the workflow")
+
+func snapshotOf(task Task) audit.Snapshot {
+	snapshot := audit.Snapshot{
+		TaskName:    task.TaskName,
+		DueDate:     dates.Format(task.DueDate),
+		Completed:   task.Completed,
+		Status:      string(task.Status),
+		RRule:       task.RRule,
+		TimeZone:    task.TimeZone,
+		Rank:        task.Rank,
+		Description: task.Description,
+		Tags:        append([]string{}, task.Tags...),
+		Checklist:   []audit.ChecklistItem{},
+	}
+	for _, item := range task.Checklist {
+		snapshot.Checklist = append(snapshot.Checklist, audit.ChecklistItem{Text: item.Text, Done: item.Done})
+	}
+	return snapshot
+}
+
+// recordHistory appends the current state of a task to its history. before is
+// the state prior to the change, or nil for a new task. Tasks created before
+// history was kept get a baseline version first, so their original state can
+// still be restored. Changes that leave the snapshot untouched are not
+// recorded, and an update that completes the task is recorded as completion.
+func (app *App) recordHistory(tx *sql.Tx, taskID, actorID int, action audit.Action, before *audit.Snapshot) error {
+	var task Task
+	if err := scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.task_id=?", taskID), &task); err != nil {
+		return err
+	}
+	after := snapshotOf(task)
+
+	var version int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version), 0) FROM task_history WHERE task_id=?", taskID).Scan(&version); err != nil {
+		return err
+	}
+
+	previous := audit.Snapshot{}
+	if before != nil {
+		previous = *before
+		if version == 0 {
+			version++
+			if err := app.insertHistory(tx, taskID, version, audit.Baseline, 0, []audit.Change{}, previous); err != nil {
+				return err
+			}
+		}
+	}
+
+	changes := audit.Diff(previous, after)
+	if len(changes) == 0 && action != audit.Created {
+		return nil
+	}
+	if action == audit.Updated {
+		action = audit.UpdateAction(previous, after)
+	}
+	return app.insertHistory(tx, taskID, version+1, action, actorID, changes, after)
+}
+
+func (app *App) insertHistory(tx *sql.Tx, taskID, version int, action audit.Action, actorID int, changes []audit.Change, snapshot audit.Snapshot) error {
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	var actor interface{}
+	if actorID != 0 {
+		actor = actorID
+	}
+	_, err = tx.Exec("INSERT INTO task_history(task_id, version, action, actor_id, changed_at, changes, snapshot) VALUES(?, ?, ?, ?, ?, ?, ?)",
+		taskID, version, action, actor, dates.Format(app.now()), string(changesJSON), string(snapshotJSON))
+	return err
+}
+
+func (app *App) GetTaskHistory(w http.ResponseWriter, taskID int) {
+	var taskExists bool
+	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=?)", taskID).Scan(&taskExists)
+	if err != nil {
+		log.Printf("Error checking task existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !taskExists {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return
+	}
+
+	rows, err := app.DB.Query("SELECT version, action, actor_id, changed_at, changes, snapshot FROM task_history WHERE task_id=? ORDER BY version", taskID)
+	if err != nil {
+		log.Printf("Error querying task history from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer rows.Close()
+
+	history := []HistoryEntry{}
+	for rows.Next() {
+		var entry HistoryEntry
+		var changesJSON, snapshotJSON string
+		err = rows.Scan(&entry.Version, &entry.Action, &entry.ActorID, &entry.ChangedAt, &changesJSON, &snapshotJSON)
+		if err == nil {
+			err = json.Unmarshal([]byte(changesJSON), &entry.Changes)
+		}
+		if err == nil {
+			err = json.Unmarshal([]byte(snapshotJSON), &entry.Snapshot)
+		}
+		if err != nil {
+			log.Printf("Error scanning task history row: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		history = append(history, entry)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("Error iterating over task history rows: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(history)
+	if err != nil {
+		log.Printf("Error encoding task history to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Task history gathered successfully")
+}
+
+// RevertTask restores the state a task had at version. The revert itself is
+// appended to the history, so it can be undone the same way.
+func (app *App) RevertTask(w http.ResponseWriter, taskID, userID, version int) {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	var task Task
+	err = scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.task_id=? AND t.user_id=?", taskID, userID), &task)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Task assignment not found or user does not have permission")
+		problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+		return
+	case err != nil:
+		log.Printf("Error checking task assignment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	var snapshotJSON string
+	err = tx.QueryRow("SELECT snapshot FROM task_history WHERE task_id=? AND version=?", taskID, version).Scan(&snapshotJSON)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Task version not found")
+		problem.Write(w, problem.NotFound("version_not_found", "Version not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving task version: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	var target audit.Snapshot
+	if err = json.Unmarshal([]byte(snapshotJSON), &target); err != nil {
+		log.Printf("Error decoding task version: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	before := snapshotOf(task)
+	err = app.restoreSnapshot(tx, task, userID, target)
+	if err == nil {
+		err = app.recordHistory(tx, taskID, userID, audit.Reverted, &before)
+	}
+	switch {
+	case errors.Is(err, errVersionChanged):
+		log.Println("Concurrent task change:", err)
+		problem.Write(w, problem.Conflict("concurrent_change", "Task changed concurrently"))
+		return
+	case errors.Is(err, errUnrestorableStatus):
+		log.Println("Cannot revert task:", err)
+		problem.Write(w, problem.Conflict("version_not_restorable", "Version cannot be restored with the current workflow"))
+		return
+	case err != nil:
+		log.Printf("Error reverting task: %v", err)
+		problem.Write(w, problem.Internal("Error reverting task"))
+		return
+	}
+	reverted, err := getTask(tx, taskID)
+	if err != nil {
+		log.Printf("Error retrieving reverted task: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("ETag", etag.Version(reverted.Version))
+	log.Println("Task reverted successfully")
+	respond.Updated(w, reverted, "Task reverted successfully")
+}
+
+// restoreSnapshot writes target over task. A restored status bypasses the
+// transition rules, since a revert undoes changes rather than making one, but
+// it must still exist in the workflow. The rank is not restored: the task
+// keeps its place, or goes to the end of the column it returns to.
+func (app *App) restoreSnapshot(tx *sql.Tx, task Task, actorID int, target audit.Snapshot) error {
+	wf := app.workflow()
+	status := workflow.Status(target.Status)
+	if !wf.Valid(status) {
+		return errUnrestorableStatus
+	}
+
+	if err := claimVersion(tx, task); err != nil {
+		return err
+	}
+
+	boardRank := task.Rank
+	if status != task.Status {
+		var err error
+		if boardRank, err = lastRank(tx, status); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec("UPDATE tasks SET task_name=?, due_date=?, completed=?, status=?, rrule=?, time_zone=?, rank=? WHERE task_id=?",
+		target.TaskName, target.DueDate, wf.IsCompleted(status), status, target.RRule, target.TimeZone, boardRank, task.TaskID)
+	if err != nil {
+		return err
+	}
+	if target.HasContent() {
+		tags, err := json.Marshal(target.Tags)
+		if err != nil {
+			return err
+		}
+		checklist, err := json.Marshal(target.Checklist)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE tasks SET description=?, tags=?, checklist=? WHERE task_id=?", target.Description, string(tags), string(checklist), task.TaskID)
+		if err != nil {
+			return err
+		}
+	}
+	if status == task.Status {
+		return nil
+	}
+
+	changedAt := dates.Format(app.now())
+	_, err = tx.Exec("UPDATE tasks SET status_changed_by=?, status_changed_at=? WHERE task_id=?", actorID, changedAt, task.TaskID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO task_status_changes(task_id, user_id, from_status, to_status, changed_at) VALUES(?, ?, ?, ?, ?)", task.TaskID, actorID, task.Status, status, changedAt)
+	return err
+}
@@ -0,0 +1,107 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/dates"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRevertTaskRestoresContent(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        audit.Snapshot
+		description   string
+		tags          []string
+		checklistSize int
+	}{
+		{
+			name: "full snapshot",
+			target: audit.Snapshot{
+				Description: "before the move",
+				Tags:        []string{"+home"},
+				Checklist:   []audit.ChecklistItem{{Text: "fill the can"}},
+			},
+			description:   "before the move",
+			tags:          []string{"+home"},
+			checklistSize: 1,
+		},
+		{
+			name:        "snapshot without content",
+			target:      audit.Snapshot{},
+			description: "current",
+			tags:        []string{"+garden"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApp(t)
+			task, userID := addTask(t, app, "")
+			if _, err := app.DB.Exec(`UPDATE tasks SET description='current', tags='["+garden"]' WHERE task_id=?`, task.TaskID); err != nil {
+				t.Fatal(err)
+			}
+
+			target := test.target
+			target.TaskName = task.TaskName
+			target.DueDate = dates.Format(task.DueDate)
+			target.Status = string(task.Status)
+			target.TimeZone = task.TimeZone
+			tx, err := app.DB.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var version int
+			if err = tx.QueryRow("SELECT MAX(version)+1 FROM task_history WHERE task_id=?", task.TaskID).Scan(&version); err != nil {
+				t.Fatal(err)
+			}
+			if err = app.insertHistory(tx, task.TaskID, version, audit.Updated, userID, []audit.Change{}, target); err != nil {
+				t.Fatal(err)
+			}
+			if err = tx.Commit(); err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			app.RevertTask(w, task.TaskID, userID, version)
+			if w.Code != http.StatusOK {
+				t.Fatalf("RevertTask status %d: %s", w.Code, w.Body)
+			}
+			reverted, err := getTask(app.DB, task.TaskID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reverted.Description != test.description || !reflect.DeepEqual(reverted.Tags, test.tags) || len(reverted.Checklist) != test.checklistSize {
+				t.Errorf("reverted to %q, %v, %v, want %q, %v and %d checklist items",
+					reverted.Description, reverted.Tags, reverted.Checklist, test.description, test.tags, test.checklistSize)
+			}
+		})
+	}
+}
+
+func TestRecordHistoryRecordsRankChanges(t *testing.T) {
+	app := newTestApp(t)
+	task, userID := addTask(t, app, "")
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	before := snapshotOf(task)
+	if _, err = tx.Exec("UPDATE tasks SET rank='z' WHERE task_id=?", task.TaskID); err != nil {
+		t.Fatal(err)
+	}
+	if err = app.recordHistory(tx, task.TaskID, userID, audit.Updated, &before); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes string
+	if err = tx.QueryRow("SELECT changes FROM task_history WHERE task_id=? AND action=?", task.TaskID, audit.Updated).Scan(&changes); err != nil {
+		t.Fatalf("rank change not recorded: %v", err)
+	}
+	if want := `[{"field":"rank","before":"` + task.Rank + `","after":"z"}]`; changes != want {
+		t.Errorf("recorded changes %s, want %s", changes, want)
+	}
+}
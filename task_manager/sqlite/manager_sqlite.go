@@ -1,111 +1,489 @@
 package taskManagerSqlite
 
 import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/blobstore"
+	"Simple_Task_Manager/bulk"
+	databaseSqlite "Simple_Task_Manager/database/sqlite"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/etag"
+	"Simple_Task_Manager/events"
+	"Simple_Task_Manager/ical"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/rank"
+	"Simple_Task_Manager/recurrence"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/taskcsv"
+	"Simple_Task_Manager/templates"
+	"Simple_Task_Manager/workflow"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type TaskManager interface {
 	GetTaskByID(w http.ResponseWriter, taskID int)
-	UpdateTask(w http.ResponseWriter, taskID, userID int)
-	DeleteTask(w http.ResponseWriter, taskID, userID int)
+	UpdateTask(w http.ResponseWriter, taskID, userID int, dueDate, status string, ifMatch etag.Condition)
+	DeleteTask(w http.ResponseWriter, taskID, userID int, ifMatch etag.Condition)
 	GetTasks(w http.ResponseWriter, r *http.Request)
-	CreateTask(w http.ResponseWriter, userName, taskName, dueDate string)
+	CreateTask(w http.ResponseWriter, userName, taskName, dueDate, rrule, timeZone string)
+	GetUserByID(w http.ResponseWriter, userID int)
+	UpdateUserTimeZone(w http.ResponseWriter, userID int, timeZone string)
+	GetBoard(w http.ResponseWriter, userID int)
+	MoveTask(w http.ResponseWriter, taskID, userID int, status string, afterTaskID, beforeTaskID int)
+	GetComments(w http.ResponseWriter, taskID, limit, offset int)
+	CreateComment(w http.ResponseWriter, taskID, userID int, content string)
+	UpdateComment(w http.ResponseWriter, commentID, userID int, content string)
+	DeleteComment(w http.ResponseWriter, commentID, userID int)
+	GetAttachments(w http.ResponseWriter, taskID int)
+	CreateAttachment(w http.ResponseWriter, taskID, userID int, fileName string, content io.Reader)
+	GetAttachment(w http.ResponseWriter, r *http.Request, attachmentID int)
+	DeleteAttachment(w http.ResponseWriter, attachmentID, userID int)
+	StartTimer(w http.ResponseWriter, taskID, userID int)
+	StopTimer(w http.ResponseWriter, userID int)
+	GetRunningTimer(w http.ResponseWriter, userID int)
+	CreateTimeEntry(w http.ResponseWriter, taskID, userID int, startedAt, endedAt, note string)
+	DeleteTimeEntry(w http.ResponseWriter, entryID, userID int)
+	GetTimeEntries(w http.ResponseWriter, taskID, userID int, from, to, format string)
+	GetTimeTotals(w http.ResponseWriter, groupBy string, taskID, userID int, from, to string)
+	GetTaskHistory(w http.ResponseWriter, taskID int)
+	RevertTask(w http.ResponseWriter, taskID, userID, version int)
+	GetTemplates(w http.ResponseWriter, templateID int)
+	CreateTemplate(w http.ResponseWriter, userID int, template templates.Template)
+	UpdateTemplate(w http.ResponseWriter, templateID, userID int, template templates.Template)
+	DeleteTemplate(w http.ResponseWriter, templateID, userID int)
+	InstantiateTemplate(w http.ResponseWriter, templateID, userID int, instances []map[string]string)
+	BulkTasks(w http.ResponseWriter, mode bulk.Mode, operations []bulk.Operation)
+	ExportTasks(w http.ResponseWriter, format string, userID int)
+	StreamEvents(w http.ResponseWriter, r *http.Request, filter events.Filter)
+	ImportTasks(w http.ResponseWriter, mode bulk.Mode, rows []taskcsv.ImportRow)
+	CreateFeedToken(w http.ResponseWriter, userID int, feedPath string)
+	DeleteFeedToken(w http.ResponseWriter, userID int)
+	GetCalendarFeed(w http.ResponseWriter, token string, component ical.Component)
+	Backup(w http.ResponseWriter, includeBlobs bool)
+	Restore(w http.ResponseWriter, body io.Reader)
+	CreateSnapshot(w http.ResponseWriter)
+	GetSnapshots(w http.ResponseWriter)
 }
 
 type App struct {
-	DB *sql.DB
+	DB               *sql.DB
+	Clock            func() time.Time
+	Workflow         *workflow.Workflow
+	Blobs            blobstore.Store
+	AttachmentLimits *blobstore.Limits
+	Snapshots        *databaseSqlite.Snapshots
+	Events           *events.Log
 }
 
 type Task struct {
-	TaskID    int    `json:"task_id"`
-	TaskName  string `json:"task_name"`
-	DueDate   string `json:"due_date"`
-	Completed bool   `json:"completed"`
+	TaskID          int             `json:"task_id"`
+	TaskName        string          `json:"task_name"`
+	DueDate         time.Time       `json:"due_date"`
+	Completed       bool            `json:"completed"`
+	RRule           string          `json:"rrule,omitempty"`
+	TimeZone        string          `json:"time_zone,omitempty"`
+	Status          workflow.Status `json:"status"`
+	StatusChangedBy *int            `json:"status_changed_by,omitempty"`
+	StatusChangedAt *time.Time      `json:"status_changed_at,omitempty"`
+	Rank            string          `json:"rank"`
+	Description     string          `json:"description,omitempty"`
+	Tags            []string        `json:"tags,omitempty"`
+	Checklist       []ChecklistItem `json:"checklist,omitempty"`
+	ParentTaskID    *int            `json:"parent_task_id,omitempty"`
+	Version         int             `json:"version"`
+}
+
+type ChecklistItem struct {
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+const taskColumns = "t.task_id, t.task_name, t.due_date, t.completed, t.rrule, t.time_zone, t.status, t.status_changed_by, t.status_changed_at, t.rank, t.description, t.tags, t.checklist, t.parent_task_id, t.version"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row rowScanner, task *Task, extra ...interface{}) error {
+	var tags, checklist string
+	dest := []interface{}{&task.TaskID, &task.TaskName, &task.DueDate, &task.Completed, &task.RRule, &task.TimeZone, &task.Status, &task.StatusChangedBy, &task.StatusChangedAt, &task.Rank, &task.Description, &tags, &checklist, &task.ParentTaskID, &task.Version}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(tags), &task.Tags); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(checklist), &task.Checklist)
 }
 
 type User struct {
 	UserID   int    `json:"user_id"`
 	UserName string `json:"user_name"`
+	TimeZone string `json:"time_zone"`
 }
 
-func (app *App) GetTaskByID(w http.ResponseWriter, taskID int) {
+func (app *App) now() time.Time {
+	if app.Clock != nil {
+		return app.Clock()
+	}
+	return time.Now()
+}
+
+func (app *App) workflow() *workflow.Workflow {
+	if app.Workflow != nil {
+		return app.Workflow
+	}
+	return workflow.Default()
+}
+
+func getTask(q querier, taskID int) (Task, error) {
 	var task Task
-	err := app.DB.QueryRow("SELECT task_id, task_name, due_date, completed FROM tasks WHERE task_id=?", taskID).Scan(&task.TaskID, &task.TaskName, &task.DueDate, &task.Completed)
+	err := scanTask(q.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.task_id=?", taskID), &task)
+	return task, err
+}
+
+func (app *App) GetTaskByID(w http.ResponseWriter, taskID int) {
+	task, err := getTask(app.DB, taskID)
 	switch {
 	case errors.Is(err, sql.ErrNoRows):
 		log.Println("Task not found")
-		http.Error(w, "Task not found", http.StatusNotFound)
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
 		return
 	case err != nil:
 		log.Printf("Error retrieving task: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag.Version(task.Version))
 	err = json.NewEncoder(w).Encode(task)
 	if err != nil {
 		log.Printf("Error encoding task to JSON: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 	log.Println("Task retrieved successfully")
 }
 
-func (app *App) UpdateTask(w http.ResponseWriter, taskID, userID int) {
-	var exists bool
-	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=? AND user_id=?)", taskID, userID).Scan(&exists)
-	if err != nil {
+func (app *App) UpdateTask(w http.ResponseWriter, taskID, userID int, dueDate, status string, ifMatch etag.Condition) {
+	var task Task
+	err := scanTask(app.DB.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.task_id=? AND t.user_id=?", taskID, userID), &task)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Task assignment not found or user does not have permission")
+		problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+		return
+	case err != nil:
 		log.Printf("Error checking task assignment: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
-	if !exists {
-		log.Println("Task assignment not found or user does not have permission")
-		http.Error(w, "Task assignment not found or user does not have permission", http.StatusNotFound)
+	if !ifMatch.Allows(etag.Version(task.Version)) {
+		log.Println("Task version does not match If-Match")
+		problem.Write(w, errPreconditionFailed)
 		return
 	}
 
-	_, err = app.DB.Exec("UPDATE tasks SET completed=true WHERE task_id=?", taskID)
+	// A PATCH without a body completes the task, as it always has.
+	target := workflow.Status(status)
+	if dueDate == "" && status == "" {
+		target = app.workflow().CompletedStatus()
+	}
+
+	var due time.Time
+	if dueDate != "" {
+		location, err := dates.LoadLocation(task.TimeZone)
+		if err != nil {
+			log.Printf("Error loading task time zone: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		due, err = dates.Resolve(dueDate, app.now(), location)
+		if err != nil {
+			log.Println("Invalid due date:", err)
+			problem.Write(w, problem.Malformed("due_date", "Invalid due date"))
+			return
+		}
+	}
+
+	tx, err := app.DB.Begin()
 	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	err = app.applyUpdate(tx, task, userID, due, target)
+	switch {
+	case errors.Is(err, errVersionChanged) && ifMatch != nil:
+		log.Println("Task changed since it was read:", err)
+		problem.Write(w, errPreconditionFailed)
+		return
+	case errors.Is(err, errVersionChanged):
+		log.Println("Concurrent task change:", err)
+		problem.Write(w, problem.Conflict("concurrent_change", "Task changed concurrently"))
+		return
+	case errors.Is(err, workflow.ErrUnknownStatus):
+		log.Println("Invalid status:", err)
+		problem.Write(w, problem.Malformed("status", "Unknown status"))
+		return
+	case errors.Is(err, workflow.ErrInvalidTransition):
+		log.Println("Invalid status transition:", err)
+		problem.Write(w, problem.Conflict("status_transition_not_allowed", "Status transition not allowed"))
+		return
+	case err != nil:
 		log.Printf("Error updating task: %v", err)
-		http.Error(w, "Error updating task", http.StatusInternalServerError)
+		problem.Write(w, problem.Internal("Error updating task"))
+		return
+	}
+	if task, err = getTask(tx, taskID); err != nil {
+		log.Printf("Error retrieving updated task: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if dueDate != "" {
+		w.Header().Set("X-Resolved-Due-Date", dates.Format(due))
+	}
+	w.Header().Set("ETag", etag.Version(task.Version))
 	log.Println("Task updated successfully")
-	_, _ = w.Write([]byte("Task updated successfully"))
+	respond.Updated(w, task, "Task updated successfully")
+}
+
+// applyUpdate sets a new due date unless due is zero and moves the task to
+// target unless it is empty, recording both in the task's history.
+func (app *App) applyUpdate(tx *sql.Tx, task Task, actorID int, due time.Time, target workflow.Status) error {
+	if err := claimVersion(tx, task); err != nil {
+		return err
+	}
+	before := snapshotOf(task)
+	if !due.IsZero() {
+		if _, err := tx.Exec("UPDATE tasks SET due_date=? WHERE task_id=?", dates.Format(due), task.TaskID); err != nil {
+			return err
+		}
+		task.DueDate = due
+	}
+	if target != "" {
+		if err := app.changeStatus(tx, task, actorID, target, ""); err != nil {
+			return err
+		}
+	}
+	return app.recordHistory(tx, task.TaskID, actorID, audit.Updated, &before)
 }
 
-func (app *App) DeleteTask(w http.ResponseWriter, taskID, userID int) {
-	var exists bool
-	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=? AND user_id=?)", taskID, userID).Scan(&exists)
+var errVersionChanged = errors.New("task changed since it was read")
+
+var errPreconditionFailed = problem.New(http.StatusPreconditionFailed, "precondition_failed", "Task changed since the version in If-Match")
+
+// claimVersion moves task on to its next version, provided it is still at the
+// version it was read at. Every change to a task claims a version once, so
+// the version, and the ETag derived from it, changes with every change; the
+// conditional update makes a check against it atomic with the change.
+func claimVersion(tx *sql.Tx, task Task) error {
+	result, err := tx.Exec("UPDATE tasks SET version=version+1 WHERE task_id=? AND version=?", task.TaskID, task.Version)
 	if err != nil {
-		log.Printf("Error checking task assignment: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		return errVersionChanged
+	}
+	return nil
+}
+
+// changeStatus moves task to status on behalf of actorID, records the change
+// and schedules the next occurrence when a recurring task gets completed. The
+// task is placed at boardRank in its new column, or at the end if boardRank is
+// empty.
+func (app *App) changeStatus(tx *sql.Tx, task Task, actorID int, status workflow.Status, boardRank string) error {
+	wf := app.workflow()
+	if err := wf.CheckTransition(task.Status, status); err != nil {
+		return err
+	}
+	if task.Status == status {
+		return nil
+	}
+
+	var err error
+	if boardRank == "" {
+		boardRank, err = lastRank(tx, status)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Filtering on the status the task was read with makes the transition
+	// atomic, so two concurrent completions cannot both schedule a next
+	// occurrence.
+	changedAt := dates.Format(app.now())
+	result, err := tx.Exec("UPDATE tasks SET status=?, completed=?, status_changed_by=?, status_changed_at=?, rank=? WHERE task_id=? AND status=?", status, wf.IsCompleted(status), actorID, changedAt, boardRank, task.TaskID, task.Status)
+	if err != nil {
+		return err
+	}
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if changed != 1 {
+		return errVersionChanged
+	}
+	_, err = tx.Exec("INSERT INTO task_status_changes(task_id, user_id, from_status, to_status, changed_at) VALUES(?, ?, ?, ?, ?)", task.TaskID, actorID, task.Status, status, changedAt)
+	if err != nil {
+		return err
+	}
+
+	if task.RRule == "" || wf.IsCompleted(task.Status) || !wf.IsCompleted(status) {
+		return nil
+	}
+	nextDueDate, nextRRule, ok, err := recurrence.NextDueDate(task.RRule, task.TimeZone, task.DueDate)
+	if err != nil || !ok {
+		return err
+	}
+	nextRank, err := lastRank(tx, wf.Initial)
+	if err != nil {
+		return err
+	}
+	result, err = tx.Exec("INSERT INTO tasks(task_name, due_date, completed, user_id, rrule, time_zone, status, rank, description, tags) SELECT task_name, ?, ?, user_id, ?, time_zone, ?, ?, description, tags FROM tasks WHERE task_id=?", dates.Format(nextDueDate), false, nextRRule, wf.Initial, nextRank, task.TaskID)
+	if err != nil {
+		return err
+	}
+	nextTaskID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	if err = app.recordHistory(tx, int(nextTaskID), actorID, audit.Created, nil); err != nil {
+		return err
+	}
+	log.Printf("Next occurrence scheduled for %s", dates.Format(nextDueDate))
+	return nil
+}
+
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// lastRank returns a board rank after every task currently in the column for
+// status.
+func lastRank(q querier, status workflow.Status) (string, error) {
+	var last string
+	err := q.QueryRow("SELECT COALESCE(MAX(rank), '') FROM tasks WHERE status=?", status).Scan(&last)
+	if err != nil {
+		return "", err
+	}
+	return rank.After(last)
+}
+
+func (app *App) DeleteTask(w http.ResponseWriter, taskID, userID int, ifMatch etag.Condition) {
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
-	if !exists {
+	defer tx.Rollback()
+
+	var task Task
+	err = scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM tasks t WHERE t.task_id=? AND t.user_id=?", taskID, userID), &task)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
 		log.Println("Task assignment not found or user does not have permission")
-		http.Error(w, "Task assignment not found or user does not have permission", http.StatusNotFound)
+		problem.Write(w, problem.NotFound("task_not_found", "Task assignment not found or user does not have permission"))
+		return
+	case err != nil:
+		log.Printf("Error checking task assignment: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !ifMatch.Allows(etag.Version(task.Version)) {
+		log.Println("Task version does not match If-Match")
+		problem.Write(w, errPreconditionFailed)
 		return
 	}
 
-	_, err = app.DB.Exec("UPDATE tasks SET task_name='X', due_date='0001-01-01T00:00:00Z', completed=false WHERE task_id=?", taskID)
+	blobKeys, err := app.anonymizeTask(tx, task, userID)
 	if err != nil {
 		log.Printf("Error anonymizing task: %v", err)
-		http.Error(w, "Error anonymizing task", http.StatusInternalServerError)
+		problem.Write(w, problem.Internal("Error anonymizing task"))
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+	app.deleteBlobs(blobKeys)
+
 	log.Println("Task content anonymized successfully")
-	_, _ = w.Write([]byte("Task anonymized successfully"))
+	respond.Deleted(w, "Task anonymized successfully")
+}
+
+// deletedDueDate is the due date deleted tasks are kept with. They stay in
+// their column, but are left off the board and do not count toward its WIP
+// limit.
+var deletedDueDate = dates.Format(time.Time{})
+
+// anonymizeTask blanks the content of task and purges its attachments. It
+// returns the keys of the attachment blobs to delete once the transaction has
+// been committed.
+func (app *App) anonymizeTask(tx *sql.Tx, task Task, actorID int) ([]string, error) {
+	if err := claimVersion(tx, task); err != nil {
+		return nil, err
+	}
+	before := snapshotOf(task)
+	blobKeys, err := purgeAttachments(tx, task.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE tasks SET task_name='X', due_date=?, completed=false, status=? WHERE task_id=?", deletedDueDate, app.workflow().Initial, task.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	if err = app.recordHistory(tx, task.TaskID, actorID, audit.Deleted, &before); err != nil {
+		return nil, err
+	}
+	return blobKeys, nil
+}
+
+// queryTasks returns the tasks matching all conditions, ordered by orderBy.
+func (app *App) queryTasks(conditions []string, args []interface{}, orderBy string) ([]Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks t INNER JOIN users u ON t.user_id = u.user_id"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := app.DB.Query(query+" ORDER BY "+orderBy, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		var task Task
+		if err = scanTask(rows, &task); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
 }
 
 func (app *App) GetTasks(w http.ResponseWriter, r *http.Request) {
@@ -114,122 +492,185 @@ func (app *App) GetTasks(w http.ResponseWriter, r *http.Request) {
 		taskID, err := strconv.Atoi(taskIDStr)
 		if err != nil {
 			log.Println("Invalid task ID:", err)
-			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			problem.Write(w, problem.Malformed("task_id", "Invalid task ID"))
 			return
 		}
 		app.GetTaskByID(w, taskID)
 		return
 	}
-	var tasks []Task
-	rows, err := app.DB.Query("SELECT t.task_id, t.task_name, t.due_date, t.completed, u.user_id, u.user_name FROM tasks t INNER JOIN users u ON t.user_id = u.user_id")
-	if err != nil {
-		log.Printf("Error querying tasks from database: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var task Task
-		var user User
-		err = rows.Scan(&task.TaskID, &task.TaskName, &task.DueDate, &task.Completed, &user.UserID, &user.UserName)
+	var conditions []string
+	var args []interface{}
+	for _, bound := range []struct {
+		param    string
+		operator string
+	}{
+		{"due_after", ">="},
+		{"due_before", "<"},
+	} {
+		value := r.URL.Query().Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := dates.Parse(value, time.UTC)
 		if err != nil {
-			log.Printf("Error scanning task row: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Invalid %s parameter: %v", bound.param, err)
+			problem.Write(w, problem.InvalidParameter(bound.param))
 			return
 		}
-		tasks = append(tasks, task)
+		conditions = append(conditions, "t.due_date "+bound.operator+" ?")
+		args = append(args, dates.Format(parsed))
+	}
+
+	var orderBy string
+	switch r.URL.Query().Get("sort") {
+	case "":
+		orderBy = "t.task_id"
+	case "due_date":
+		orderBy = "t.due_date, t.task_id"
+	case "-due_date":
+		orderBy = "t.due_date DESC, t.task_id"
+	default:
+		log.Println("Invalid sort parameter")
+		problem.Write(w, problem.InvalidParameter("sort"))
+		return
 	}
 
-	err = rows.Err()
+	tasks, err := app.queryTasks(conditions, args, orderBy)
 	if err != nil {
-		log.Printf("Error iterating over task rows: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error querying tasks from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
 	responseBody, err := json.Marshal(tasks)
 	if err != nil {
 		log.Printf("Error encoding tasks to JSON: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	_, err = w.Write(responseBody)
 	if err != nil {
 		log.Printf("Error writing response body: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
 	log.Println("Tasks gathered successfully")
 }
 
-func (app *App) CreateTask(w http.ResponseWriter, userName, taskName, dueDate string) {
-	var requestBody = struct {
-		UserName string `json:"user_name"`
-		TaskName string `json:"task_name"`
-		DueDate  string `json:"due_date"`
-	}{
-		UserName: userName,
-		TaskName: taskName,
-		DueDate:  dueDate,
+func (app *App) CreateTask(w http.ResponseWriter, userName, taskName, dueDate, rrule, timeZone string) {
+	var userID int
+	userTimeZone := "UTC"
+	userExists := true
+	err := app.DB.QueryRow("SELECT user_id, time_zone FROM users WHERE user_name = ?", userName).Scan(&userID, &userTimeZone)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		userExists = false
+	case err != nil:
+		log.Printf("Error checking user existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
 	}
 
-	if requestBody.UserName == "" {
-		log.Println("Missing user name in request body")
-		http.Error(w, "Missing user name in request body", http.StatusBadRequest)
+	if timeZone == "" {
+		timeZone = userTimeZone
+	}
+	location, err := dates.LoadLocation(timeZone)
+	if err != nil {
+		log.Println("Invalid time zone:", err)
+		problem.Write(w, problem.Malformed("time_zone", "Invalid time zone"))
 		return
 	}
-	if requestBody.TaskName == "" {
-		log.Println("Missing task name in request body")
-		http.Error(w, "Missing task name in request body", http.StatusBadRequest)
+	due, err := dates.Resolve(dueDate, app.now(), location)
+	if err != nil {
+		log.Println("Invalid due date:", err)
+		problem.Write(w, problem.Malformed("due_date", "Invalid due date"))
 		return
 	}
-	if requestBody.DueDate == "" {
-		log.Println("Missing due date in request body")
-		http.Error(w, "Missing due date in request body", http.StatusBadRequest)
+	if rrule != "" {
+		if err := recurrence.Validate(rrule, timeZone); err != nil {
+			log.Println("Invalid recurrence:", err)
+			problem.Write(w, problem.Malformed("rrule", "Invalid recurrence rule"))
+			return
+		}
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
+	defer tx.Rollback()
 
-	var userID int
-	err := app.DB.QueryRow("SELECT user_id FROM users WHERE user_name = ?", requestBody.UserName).Scan(&userID)
-	switch {
-	case errors.Is(err, sql.ErrNoRows):
-		result, err := app.DB.Exec("INSERT INTO users(user_name) VALUES(?)", requestBody.UserName)
+	if !userExists {
+		result, err := tx.Exec("INSERT INTO users(user_name) VALUES(?)", userName)
 		if err != nil {
 			log.Printf("Error creating new user: %v", err)
-			http.Error(w, "Error creating new user", http.StatusInternalServerError)
+			problem.Write(w, problem.Internal("Error creating new user"))
 			return
 		}
 		lastInsertID, err := result.LastInsertId()
 		if err != nil {
 			log.Printf("Error getting last inserted ID: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			problem.Write(w, problem.ErrInternal)
 			return
 		}
 		userID = int(lastInsertID)
-	case err != nil:
-		log.Printf("Error checking user existence: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
 	}
 
-	result, err := app.DB.Exec("INSERT INTO tasks(task_name, due_date, completed, user_id) VALUES(?, ?, ?, ?)", requestBody.TaskName, requestBody.DueDate, false, userID)
+	taskID, err := app.insertTask(tx, userID, taskName, due, rrule, timeZone, app.workflow().Initial, nil)
 	if err != nil {
 		log.Printf("Error inserting task: %v", err)
-		http.Error(w, "Error inserting task", http.StatusInternalServerError)
+		problem.Write(w, problem.Internal("Error inserting task"))
 		return
 	}
-
-	_, err = result.LastInsertId()
+	task, err := getTask(tx, taskID)
 	if err != nil {
-		log.Printf("Error getting last inserted ID: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error retrieving created task: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("X-Resolved-Due-Date", dates.Format(due))
 	log.Println("Task created successfully")
-	_, _ = w.Write([]byte("Task created successfully"))
+	respond.Created(w, "/tasks/"+strconv.Itoa(taskID), task, "Task created successfully")
+}
+
+// insertTask adds a task for userID at the end of the column for status and
+// records its creation.
+func (app *App) insertTask(tx *sql.Tx, userID int, taskName string, due time.Time, rrule, timeZone string, status workflow.Status, tags []string) (int, error) {
+	boardRank, err := lastRank(tx, status)
+	if err != nil {
+		return 0, err
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("INSERT INTO tasks(task_name, due_date, completed, user_id, rrule, time_zone, status, status_changed_by, status_changed_at, rank, tags) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", taskName, dates.Format(due), app.workflow().IsCompleted(status), userID, rrule, timeZone, status, userID, dates.Format(app.now()), boardRank, string(tagsJSON))
+	if err != nil {
+		return 0, err
+	}
+	taskID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err = app.recordHistory(tx, int(taskID), userID, audit.Created, nil); err != nil {
+		return 0, err
+	}
+	return int(taskID), nil
 }
@@ -0,0 +1,122 @@
+package taskManagerSqlite
+
+import (
+	databaseSqlite "Simple_Task_Manager/database/sqlite"
+	"Simple_Task_Manager/workflow"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestApp returns an App on a fresh database.
+func newTestApp(t *testing.T) *App {
+	t.Helper()
+	dbManager := databaseSqlite.NewSQLiteDB(filepath.Join(t.TempDir(), "tasks.db"))
+	if err := dbManager.InitializeDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	db, err := dbManager.OpenDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	now := time.Date(2024, time.March, 25, 12, 0, 0, 0, time.UTC)
+	return &App{DB: db, Clock: func() time.Time { return now }}
+}
+
+// addTask stores a task for a new user and returns it with the user's ID.
+func addTask(t *testing.T, app *App, rrule string) (Task, int) {
+	t.Helper()
+	tx, err := app.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec("INSERT INTO users(user_name) VALUES('ada')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID, _ := result.LastInsertId()
+	due := time.Date(2024, time.March, 25, 9, 0, 0, 0, time.UTC)
+	taskID, err := app.insertTask(tx, int(userID), "water plants", due, rrule, "UTC", app.workflow().Initial, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := getTask(tx, taskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return task, int(userID)
+}
+
+func TestChangeStatusCompletesOnce(t *testing.T) {
+	tests := []struct {
+		name  string
+		rrule string
+		tasks int
+	}{
+		{name: "recurring", rrule: "FREQ=DAILY", tasks: 2},
+		{name: "single", tasks: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newTestApp(t)
+			task, userID := addTask(t, app, test.rrule)
+
+			// Both completions start from the task as it was read before
+			// either of them ran, as concurrent requests would.
+			for i, want := range []error{nil, errVersionChanged} {
+				tx, err := app.DB.Begin()
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = app.changeStatus(tx, task, userID, workflow.Done, "")
+				if !errors.Is(err, want) {
+					t.Fatalf("completion %d: error = %v, want %v", i+1, err, want)
+				}
+				if err == nil {
+					err = tx.Commit()
+				} else {
+					err = tx.Rollback()
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var tasks int
+			if err := app.DB.QueryRow("SELECT COUNT(*) FROM tasks").Scan(&tasks); err != nil {
+				t.Fatal(err)
+			}
+			if tasks != test.tasks {
+				t.Errorf("%d tasks after completing twice, want %d", tasks, test.tasks)
+			}
+		})
+	}
+}
+
+func TestCreateTaskKeepsNoUserWhenTheTaskFails(t *testing.T) {
+	app := newTestApp(t)
+	if _, err := app.DB.Exec("CREATE TRIGGER reject_tasks BEFORE INSERT ON tasks BEGIN SELECT RAISE(ABORT, 'rejected'); END"); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	app.CreateTask(recorder, "grace", "water plants", "2024-03-25", "", "UTC")
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("CreateTask: %d %s, want %d", recorder.Code, recorder.Body, http.StatusInternalServerError)
+	}
+	var users int
+	if err := app.DB.QueryRow("SELECT COUNT(*) FROM users WHERE user_name = 'grace'").Scan(&users); err != nil {
+		t.Fatal(err)
+	}
+	if users != 0 {
+		t.Errorf("%d users named grace after the task was rejected, want 0", users)
+	}
+}
@@ -0,0 +1,61 @@
+package taskManagerSqlite
+
+import (
+	databaseSqlite "Simple_Task_Manager/database/sqlite"
+	"Simple_Task_Manager/problem"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// CreateSnapshot takes a hot backup of the database file on demand.
+func (app *App) CreateSnapshot(w http.ResponseWriter) {
+	if app.Snapshots == nil {
+		log.Println("Snapshots are not configured")
+		problem.Write(w, problem.NotFound("snapshots_not_configured", "Snapshots are not configured"))
+		return
+	}
+
+	snapshot, err := app.Snapshots.Take()
+	if err != nil {
+		log.Printf("Error taking snapshot: %v", err)
+		if errors.Is(err, databaseSqlite.ErrSnapshotCorrupt) {
+			problem.Write(w, problem.New(http.StatusInternalServerError, "snapshot_corrupt", "Snapshot failed the integrity check"))
+			return
+		}
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err = json.NewEncoder(w).Encode(snapshot); err != nil {
+		log.Printf("Error encoding snapshot to JSON: %v", err)
+		return
+	}
+	log.Printf("Snapshot %s taken successfully", snapshot.Name)
+}
+
+func (app *App) GetSnapshots(w http.ResponseWriter) {
+	if app.Snapshots == nil {
+		log.Println("Snapshots are not configured")
+		problem.Write(w, problem.NotFound("snapshots_not_configured", "Snapshots are not configured"))
+		return
+	}
+
+	snapshots, err := app.Snapshots.List()
+	if err != nil {
+		log.Printf("Error listing snapshots: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(snapshots); err != nil {
+		log.Printf("Error encoding snapshots to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Snapshots gathered successfully")
+}
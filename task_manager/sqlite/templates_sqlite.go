@@ -0,0 +1,367 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/audit"
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/templates"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type TaskTemplate struct {
+	TemplateID int `json:"template_id"`
+	templates.Template
+	Variables []string   `json:"variables"`
+	CreatedBy int        `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func scanTemplate(row rowScanner, template *TaskTemplate) error {
+	var definition string
+	if err := row.Scan(&template.TemplateID, &definition, &template.CreatedBy, &template.CreatedAt, &template.UpdatedAt); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(definition), &template.Template); err != nil {
+		return err
+	}
+	template.Variables = template.Template.Variables()
+	return nil
+}
+
+func getTemplate(q querier, templateID int) (TaskTemplate, error) {
+	var template TaskTemplate
+	err := scanTemplate(q.QueryRow("SELECT template_id, definition, created_by, created_at, updated_at FROM task_templates WHERE template_id=?", templateID), &template)
+	return template, err
+}
+
+func (app *App) GetTemplates(w http.ResponseWriter, templateID int) {
+	var result interface{}
+	if templateID != 0 {
+		template, err := getTemplate(app.DB, templateID)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			log.Println("Template not found")
+			problem.Write(w, problem.NotFound("template_not_found", "Template not found"))
+			return
+		case err != nil:
+			log.Printf("Error retrieving template: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		result = template
+	} else {
+		rows, err := app.DB.Query("SELECT template_id, definition, created_by, created_at, updated_at FROM task_templates ORDER BY name")
+		if err != nil {
+			log.Printf("Error querying templates from database: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		defer rows.Close()
+
+		list := []TaskTemplate{}
+		for rows.Next() {
+			var template TaskTemplate
+			if err = scanTemplate(rows, &template); err != nil {
+				log.Printf("Error scanning template row: %v", err)
+				problem.Write(w, problem.ErrInternal)
+				return
+			}
+			list = append(list, template)
+		}
+		if err = rows.Err(); err != nil {
+			log.Printf("Error iterating over template rows: %v", err)
+			problem.Write(w, problem.ErrInternal)
+			return
+		}
+		result = list
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(result)
+	if err != nil {
+		log.Printf("Error encoding templates to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Templates gathered successfully")
+}
+
+func (app *App) CreateTemplate(w http.ResponseWriter, userID int, template templates.Template) {
+	if !validTemplate(w, template) {
+		return
+	}
+	if _, ok := app.findUser(w, userID); !ok {
+		return
+	}
+
+	definition, err := json.Marshal(template)
+	if err != nil {
+		log.Printf("Error encoding template: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	result, err := app.DB.Exec("INSERT INTO task_templates(name, definition, created_by, created_at) VALUES(?, ?, ?, ?)", template.Name, string(definition), userID, dates.Format(app.now()))
+	if isUniqueViolation(err) {
+		log.Println("Template name already taken:", template.Name)
+		problem.Write(w, problem.Conflict("template_exists", "A template with this name already exists"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error inserting template: %v", err)
+		problem.Write(w, problem.Internal("Error inserting template"))
+		return
+	}
+	templateID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting last inserted ID: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	created, err := getTemplate(app.DB, int(templateID))
+	if err != nil {
+		log.Printf("Error retrieving created template: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Template created successfully")
+	respond.Created(w, "/templates/"+strconv.FormatInt(templateID, 10), created, "Template created successfully")
+}
+
+func (app *App) UpdateTemplate(w http.ResponseWriter, templateID, userID int, template templates.Template) {
+	if !validTemplate(w, template) {
+		return
+	}
+	if !app.checkTemplateOwner(w, templateID, userID) {
+		return
+	}
+
+	definition, err := json.Marshal(template)
+	if err != nil {
+		log.Printf("Error encoding template: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	_, err = app.DB.Exec("UPDATE task_templates SET name=?, definition=?, updated_at=? WHERE template_id=?", template.Name, string(definition), dates.Format(app.now()), templateID)
+	if isUniqueViolation(err) {
+		log.Println("Template name already taken:", template.Name)
+		problem.Write(w, problem.Conflict("template_exists", "A template with this name already exists"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error updating template: %v", err)
+		problem.Write(w, problem.Internal("Error updating template"))
+		return
+	}
+	updated, err := getTemplate(app.DB, templateID)
+	if err != nil {
+		log.Printf("Error retrieving updated template: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Template updated successfully")
+	respond.Updated(w, updated, "Template updated successfully")
+}
+
+func (app *App) DeleteTemplate(w http.ResponseWriter, templateID, userID int) {
+	if !app.checkTemplateOwner(w, templateID, userID) {
+		return
+	}
+
+	_, err := app.DB.Exec("DELETE FROM task_templates WHERE template_id=?", templateID)
+	if err != nil {
+		log.Printf("Error deleting template: %v", err)
+		problem.Write(w, problem.Internal("Error deleting template"))
+		return
+	}
+
+	log.Println("Template deleted successfully")
+	respond.Deleted(w, "Template deleted successfully")
+}
+
+// InstantiateTemplate creates one task tree per entry of instances, each with
+// its own variables, for userID. Either every task is created or none is.
+func (app *App) InstantiateTemplate(w http.ResponseWriter, templateID, userID int, instances []map[string]string) {
+	var template TaskTemplate
+	err := scanTemplate(app.DB.QueryRow("SELECT template_id, definition, created_by, created_at, updated_at FROM task_templates WHERE template_id=?", templateID), &template)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Template not found")
+		problem.Write(w, problem.NotFound("template_not_found", "Template not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving template: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	user, ok := app.findUser(w, userID)
+	if !ok {
+		return
+	}
+	location, err := dates.LoadLocation(user.TimeZone)
+	if err != nil {
+		log.Printf("Error loading user time zone: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	now := app.now()
+	expanded := make([]templates.Instance, 0, len(instances))
+	for _, variables := range instances {
+		instance, err := template.Instantiate(variables, now, location)
+		if err != nil {
+			log.Println("Cannot instantiate template:", err)
+			code := "invalid_template"
+			if errors.Is(err, templates.ErrMissingVariable) {
+				code = "missing_variable"
+			}
+			problem.Write(w, problem.BadRequest(code, err.Error()))
+			return
+		}
+		expanded = append(expanded, instance)
+	}
+
+	tx, err := app.DB.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	defer tx.Rollback()
+
+	created := []Task{}
+	for _, instance := range expanded {
+		parentID, err := app.insertInstance(tx, instance, userID, user.TimeZone, 0)
+		if err != nil {
+			log.Printf("Error inserting task from template: %v", err)
+			problem.Write(w, problem.Internal("Error inserting task"))
+			return
+		}
+		taskIDs := []int{parentID}
+		for _, subtask := range instance.Subtasks {
+			subtaskID, err := app.insertInstance(tx, subtask, userID, user.TimeZone, parentID)
+			if err != nil {
+				log.Printf("Error inserting subtask from template: %v", err)
+				problem.Write(w, problem.Internal("Error inserting task"))
+				return
+			}
+			taskIDs = append(taskIDs, subtaskID)
+		}
+
+		for _, taskID := range taskIDs {
+			task, err := getTask(tx, taskID)
+			if err != nil {
+				log.Printf("Error retrieving created task: %v", err)
+				problem.Write(w, problem.ErrInternal)
+				return
+			}
+			created = append(created, task)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(created)
+	if err != nil {
+		log.Printf("Error encoding tasks to JSON: %v", err)
+		return
+	}
+	log.Printf("Template instantiated into %d tasks", len(created))
+}
+
+func (app *App) insertInstance(tx *sql.Tx, instance templates.Instance, userID int, timeZone string, parentID int) (int, error) {
+	tags := instance.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	checklist := make([]ChecklistItem, 0, len(instance.Checklist))
+	for _, text := range instance.Checklist {
+		checklist = append(checklist, ChecklistItem{Text: text})
+	}
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return 0, err
+	}
+	checklistJSON, err := json.Marshal(checklist)
+	if err != nil {
+		return 0, err
+	}
+
+	status := app.workflow().Initial
+	boardRank, err := lastRank(tx, status)
+	if err != nil {
+		return 0, err
+	}
+
+	var parent interface{}
+	if parentID != 0 {
+		parent = parentID
+	}
+	result, err := tx.Exec("INSERT INTO tasks(task_name, due_date, completed, user_id, rrule, time_zone, status, status_changed_by, status_changed_at, rank, description, tags, checklist, parent_task_id) VALUES(?, ?, ?, ?, '', ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		instance.TaskName, dates.Format(instance.DueDate), false, userID, timeZone, status, userID, dates.Format(app.now()), boardRank, instance.Description, string(tagsJSON), string(checklistJSON), parent)
+	if err != nil {
+		return 0, err
+	}
+	taskID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err = app.recordHistory(tx, int(taskID), userID, audit.Created, nil); err != nil {
+		return 0, err
+	}
+	return int(taskID), nil
+}
+
+func (app *App) checkTemplateOwner(w http.ResponseWriter, templateID, userID int) bool {
+	var createdBy int
+	err := app.DB.QueryRow("SELECT created_by FROM task_templates WHERE template_id=?", templateID).Scan(&createdBy)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Template not found")
+		problem.Write(w, problem.NotFound("template_not_found", "Template not found"))
+		return false
+	case err != nil:
+		log.Printf("Error retrieving template: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return false
+	}
+	if createdBy != userID {
+		log.Println("User is not the creator of the template")
+		problem.Write(w, problem.Forbidden("not_creator", "Only the creator can change this template"))
+		return false
+	}
+	return true
+}
+
+func validTemplate(w http.ResponseWriter, template templates.Template) bool {
+	if err := template.Validate(); err != nil {
+		log.Println("Invalid template:", err)
+		problem.Write(w, problem.BadRequest("invalid_template", err.Error()))
+		return false
+	}
+	return true
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,456 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"Simple_Task_Manager/timesheet"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TimeEntry struct {
+	EntryID   int        `json:"entry_id"`
+	TaskID    int        `json:"task_id"`
+	TaskName  string     `json:"task_name"`
+	UserID    int        `json:"user_id"`
+	UserName  string     `json:"user_name"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Note      string     `json:"note"`
+	Seconds   int64      `json:"seconds"`
+}
+
+type TimeTotal struct {
+	ID      int     `json:"id"`
+	Name    string  `json:"name"`
+	Seconds int64   `json:"seconds"`
+	Hours   float64 `json:"hours"`
+}
+
+type TimeTotals struct {
+	GroupBy      string      `json:"group_by"`
+	Totals       []TimeTotal `json:"totals"`
+	TotalSeconds int64       `json:"total_seconds"`
+	TotalHours   float64     `json:"total_hours"`
+}
+
+const timeEntryColumns = "e.entry_id, e.task_id, t.task_name, e.user_id, u.user_name, e.started_at, e.ended_at, e.note"
+
+const timeEntryJoins = " FROM time_entries e INNER JOIN tasks t ON e.task_id = t.task_id INNER JOIN users u ON e.user_id = u.user_id"
+
+func scanTimeEntry(row rowScanner, entry *TimeEntry) error {
+	return row.Scan(&entry.EntryID, &entry.TaskID, &entry.TaskName, &entry.UserID, &entry.UserName, &entry.StartedAt, &entry.EndedAt, &entry.Note)
+}
+
+func (app *App) getTimeEntry(entryID int64) (TimeEntry, error) {
+	var entry TimeEntry
+	if err := scanTimeEntry(app.DB.QueryRow("SELECT "+timeEntryColumns+timeEntryJoins+" WHERE e.entry_id=?", entryID), &entry); err != nil {
+		return TimeEntry{}, err
+	}
+	entry.Seconds = int64(timesheet.Duration(entry.StartedAt, entry.EndedAt, time.Time{}, time.Time{}, app.now()).Seconds())
+	return entry, nil
+}
+
+func (app *App) StartTimer(w http.ResponseWriter, taskID, userID int) {
+	var taskExists, userExists bool
+	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=?), EXISTS(SELECT 1 FROM users WHERE user_id=?)", taskID, userID).Scan(&taskExists, &userExists)
+	if err != nil {
+		log.Printf("Error checking task and user existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !taskExists {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return
+	}
+	if !userExists {
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	// The unique index on running entries rejects a second timer even when two
+	// requests race each other.
+	result, err := app.DB.Exec("INSERT INTO time_entries(task_id, user_id, started_at) VALUES(?, ?, ?)", taskID, userID, dates.Format(app.now()))
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		log.Println("Timer already running for user")
+		problem.Write(w, problem.Conflict("timer_running", "A timer is already running for this user"))
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting timer: %v", err)
+		problem.Write(w, problem.Internal("Error starting timer"))
+		return
+	}
+	entryID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting last inserted ID: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	entry, err := app.getTimeEntry(entryID)
+	if err != nil {
+		log.Printf("Error retrieving started timer: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Timer started successfully")
+	respond.Created(w, "/users/"+strconv.Itoa(userID)+"/timer", entry, "Timer started successfully")
+}
+
+func (app *App) StopTimer(w http.ResponseWriter, userID int) {
+	var entryID int64
+	err := app.DB.QueryRow("SELECT entry_id FROM time_entries WHERE user_id=? AND ended_at IS NULL", userID).Scan(&entryID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("No running timer")
+		problem.Write(w, problem.NotFound("no_running_timer", "No running timer"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving running timer: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	result, err := app.DB.Exec("UPDATE time_entries SET ended_at=? WHERE entry_id=? AND ended_at IS NULL", dates.Format(app.now()), entryID)
+	if err != nil {
+		log.Printf("Error stopping timer: %v", err)
+		problem.Write(w, problem.Internal("Error stopping timer"))
+		return
+	}
+	stopped, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if stopped == 0 {
+		log.Println("No running timer")
+		problem.Write(w, problem.NotFound("no_running_timer", "No running timer"))
+		return
+	}
+
+	entry, err := app.getTimeEntry(entryID)
+	if err != nil {
+		log.Printf("Error retrieving stopped timer: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Timer stopped successfully")
+	respond.Updated(w, entry, "Timer stopped successfully")
+}
+
+func (app *App) GetRunningTimer(w http.ResponseWriter, userID int) {
+	var entry TimeEntry
+	err := scanTimeEntry(app.DB.QueryRow("SELECT "+timeEntryColumns+timeEntryJoins+" WHERE e.user_id=? AND e.ended_at IS NULL", userID), &entry)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("No running timer")
+		problem.Write(w, problem.NotFound("no_running_timer", "No running timer"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving running timer: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	entry.Seconds = int64(timesheet.Duration(entry.StartedAt, entry.EndedAt, time.Time{}, time.Time{}, app.now()).Seconds())
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(entry)
+	if err != nil {
+		log.Printf("Error encoding timer to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Running timer retrieved successfully")
+}
+
+func (app *App) CreateTimeEntry(w http.ResponseWriter, taskID, userID int, startedAt, endedAt, note string) {
+	var taskExists bool
+	var timeZone sql.NullString
+	err := app.DB.QueryRow("SELECT EXISTS(SELECT 1 FROM tasks WHERE task_id=?), (SELECT time_zone FROM users WHERE user_id=?)", taskID, userID).Scan(&taskExists, &timeZone)
+	if err != nil {
+		log.Printf("Error checking task and user existence: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if !taskExists {
+		log.Println("Task not found")
+		problem.Write(w, problem.NotFound("task_not_found", "Task not found"))
+		return
+	}
+	if !timeZone.Valid {
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	location, err := dates.LoadLocation(timeZone.String)
+	if err != nil {
+		log.Printf("Invalid stored time zone %q: %v", timeZone.String, err)
+		location = time.UTC
+	}
+	start, err := dates.Parse(startedAt, location)
+	if err != nil {
+		log.Println("Invalid started_at:", err)
+		problem.Write(w, problem.Malformed("started_at", "Invalid started_at"))
+		return
+	}
+	end, err := dates.Parse(endedAt, location)
+	if err != nil {
+		log.Println("Invalid ended_at:", err)
+		problem.Write(w, problem.Malformed("ended_at", "Invalid ended_at"))
+		return
+	}
+	if err = timesheet.ValidateEntry(start, end); err != nil {
+		log.Println("Invalid time entry:", err)
+		problem.Write(w, problem.Malformed("ended_at", "Time entry must end after it starts"))
+		return
+	}
+
+	result, err := app.DB.Exec("INSERT INTO time_entries(task_id, user_id, started_at, ended_at, note) VALUES(?, ?, ?, ?, ?)",
+		taskID, userID, dates.Format(start), dates.Format(end), note)
+	if err != nil {
+		log.Printf("Error inserting time entry: %v", err)
+		problem.Write(w, problem.Internal("Error inserting time entry"))
+		return
+	}
+
+	entryID, err := result.LastInsertId()
+	if err != nil {
+		log.Printf("Error getting last inserted ID: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	entry, err := app.getTimeEntry(entryID)
+	if err != nil {
+		log.Printf("Error retrieving created time entry: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	log.Println("Time entry created successfully")
+	respond.Created(w, "/time/entries/"+strconv.FormatInt(entryID, 10), entry, "Time entry created successfully")
+}
+
+func (app *App) DeleteTimeEntry(w http.ResponseWriter, entryID, userID int) {
+	var ownerID int
+	err := app.DB.QueryRow("SELECT user_id FROM time_entries WHERE entry_id=?", entryID).Scan(&ownerID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("Time entry not found")
+		problem.Write(w, problem.NotFound("time_entry_not_found", "Time entry not found"))
+		return
+	case err != nil:
+		log.Printf("Error retrieving time entry: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	case ownerID != userID:
+		log.Println("User does not own the time entry")
+		problem.Write(w, problem.Forbidden("not_owner", "Only the owner can delete a time entry"))
+		return
+	}
+
+	_, err = app.DB.Exec("DELETE FROM time_entries WHERE entry_id=?", entryID)
+	if err != nil {
+		log.Printf("Error deleting time entry: %v", err)
+		problem.Write(w, problem.Internal("Error deleting time entry"))
+		return
+	}
+
+	log.Println("Time entry deleted successfully")
+	respond.Deleted(w, "Time entry deleted successfully")
+}
+
+// GetTimeEntries lists the entries overlapping [from, to). Durations are
+// clipped to the range. With format "csv" the entries are exported as a
+// timesheet.
+func (app *App) GetTimeEntries(w http.ResponseWriter, taskID, userID int, from, to, format string) {
+	if format != "" && format != "json" && format != "csv" {
+		log.Println("Invalid format:", format)
+		problem.Write(w, problem.InvalidParameter("format"))
+		return
+	}
+	rangeStart, rangeEnd, ok := parseTimeRange(w, from, to)
+	if !ok {
+		return
+	}
+
+	entries, err := app.queryTimeEntries(taskID, userID, rangeStart, rangeEnd)
+	if err != nil {
+		log.Printf("Error querying time entries from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	if format == "csv" {
+		rows := make([]timesheet.Row, 0, len(entries))
+		for _, entry := range entries {
+			rows = append(rows, timesheet.Row{
+				EntryID:   strconv.Itoa(entry.EntryID),
+				TaskID:    strconv.Itoa(entry.TaskID),
+				TaskName:  entry.TaskName,
+				UserID:    strconv.Itoa(entry.UserID),
+				UserName:  entry.UserName,
+				StartedAt: entry.StartedAt,
+				EndedAt:   entry.EndedAt,
+				Note:      entry.Note,
+				Duration:  time.Duration(entry.Seconds) * time.Second,
+			})
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="timesheet.csv"`)
+		if err = timesheet.WriteCSV(w, rows); err != nil {
+			log.Printf("Error writing timesheet: %v", err)
+			return
+		}
+		log.Println("Timesheet exported successfully")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(entries)
+	if err != nil {
+		log.Printf("Error encoding time entries to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Time entries gathered successfully")
+}
+
+// GetTimeTotals sums the tracked time in [from, to) per task or per user.
+func (app *App) GetTimeTotals(w http.ResponseWriter, groupBy string, taskID, userID int, from, to string) {
+	if groupBy == "" {
+		groupBy = "task"
+	}
+	if groupBy != "task" && groupBy != "user" {
+		log.Println("Invalid group_by:", groupBy)
+		problem.Write(w, problem.InvalidParameter("group_by"))
+		return
+	}
+	rangeStart, rangeEnd, ok := parseTimeRange(w, from, to)
+	if !ok {
+		return
+	}
+
+	entries, err := app.queryTimeEntries(taskID, userID, rangeStart, rangeEnd)
+	if err != nil {
+		log.Printf("Error querying time entries from database: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+
+	totals := TimeTotals{GroupBy: groupBy, Totals: []TimeTotal{}}
+	byID := make(map[int]int)
+	for _, entry := range entries {
+		id, name := entry.TaskID, entry.TaskName
+		if groupBy == "user" {
+			id, name = entry.UserID, entry.UserName
+		}
+		index, seen := byID[id]
+		if !seen {
+			index = len(totals.Totals)
+			byID[id] = index
+			totals.Totals = append(totals.Totals, TimeTotal{ID: id, Name: name})
+		}
+		totals.Totals[index].Seconds += entry.Seconds
+		totals.TotalSeconds += entry.Seconds
+	}
+	for i := range totals.Totals {
+		totals.Totals[i].Hours = timesheet.Hours(time.Duration(totals.Totals[i].Seconds) * time.Second)
+	}
+	sort.Slice(totals.Totals, func(i, j int) bool { return totals.Totals[i].ID < totals.Totals[j].ID })
+	totals.TotalHours = timesheet.Hours(time.Duration(totals.TotalSeconds) * time.Second)
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(totals)
+	if err != nil {
+		log.Printf("Error encoding time totals to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("Time totals gathered successfully")
+}
+
+func (app *App) queryTimeEntries(taskID, userID int, from, to time.Time) ([]TimeEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if taskID != 0 {
+		conditions = append(conditions, "e.task_id=?")
+		args = append(args, taskID)
+	}
+	if userID != 0 {
+		conditions = append(conditions, "e.user_id=?")
+		args = append(args, userID)
+	}
+	if !from.IsZero() {
+		conditions = append(conditions, "(e.ended_at IS NULL OR e.ended_at > ?)")
+		args = append(args, dates.Format(from))
+	}
+	if !to.IsZero() {
+		conditions = append(conditions, "e.started_at < ?")
+		args = append(args, dates.Format(to))
+	}
+
+	query := "SELECT " + timeEntryColumns + timeEntryJoins
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY e.started_at, e.entry_id"
+
+	rows, err := app.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := app.now()
+	entries := []TimeEntry{}
+	for rows.Next() {
+		var entry TimeEntry
+		if err = scanTimeEntry(rows, &entry); err != nil {
+			return nil, err
+		}
+		entry.Seconds = int64(timesheet.Duration(entry.StartedAt, entry.EndedAt, from, to, now).Seconds())
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func parseTimeRange(w http.ResponseWriter, from, to string) (time.Time, time.Time, bool) {
+	var rangeStart, rangeEnd time.Time
+	var err error
+	if from != "" {
+		if rangeStart, err = dates.Parse(from, time.UTC); err != nil {
+			log.Println("Invalid from parameter:", err)
+			problem.Write(w, problem.InvalidParameter("from"))
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	if to != "" {
+		if rangeEnd, err = dates.Parse(to, time.UTC); err != nil {
+			log.Println("Invalid to parameter:", err)
+			problem.Write(w, problem.InvalidParameter("to"))
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	if !rangeStart.IsZero() && !rangeEnd.IsZero() && !rangeEnd.After(rangeStart) {
+		log.Println("Empty time range")
+		problem.Write(w, problem.Malformed("to", "The to parameter must be after from"))
+		return time.Time{}, time.Time{}, false
+	}
+	return rangeStart, rangeEnd, true
+}
@@ -0,0 +1,77 @@
+package taskManagerSqlite
+
+import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/respond"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+func (app *App) GetUserByID(w http.ResponseWriter, userID int) {
+	user, ok := app.findUser(w, userID)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(user)
+	if err != nil {
+		log.Printf("Error encoding user to JSON: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	log.Println("User retrieved successfully")
+}
+
+func (app *App) UpdateUserTimeZone(w http.ResponseWriter, userID int, timeZone string) {
+	if _, err := dates.LoadLocation(timeZone); err != nil || timeZone == "" {
+		log.Println("Invalid time zone:", timeZone)
+		problem.Write(w, problem.Malformed("time_zone", "Invalid time zone"))
+		return
+	}
+
+	result, err := app.DB.Exec("UPDATE users SET time_zone=? WHERE user_id=?", timeZone, userID)
+	if err != nil {
+		log.Printf("Error updating user: %v", err)
+		problem.Write(w, problem.Internal("Error updating user"))
+		return
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting affected rows: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return
+	}
+	if affected == 0 {
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return
+	}
+
+	user, ok := app.findUser(w, userID)
+	if !ok {
+		return
+	}
+	log.Println("User updated successfully")
+	respond.Updated(w, user, "User updated successfully")
+}
+
+func (app *App) findUser(w http.ResponseWriter, userID int) (User, bool) {
+	var user User
+	err := app.DB.QueryRow("SELECT user_id, user_name, time_zone FROM users WHERE user_id=?", userID).Scan(&user.UserID, &user.UserName, &user.TimeZone)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		log.Println("User not found")
+		problem.Write(w, problem.NotFound("user_not_found", "User not found"))
+		return User{}, false
+	case err != nil:
+		log.Printf("Error retrieving user: %v", err)
+		problem.Write(w, problem.ErrInternal)
+		return User{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,367 @@
+package taskcsv
+
+import (
+	"Simple_Task_Manager/bulk"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRows bounds the number of data rows of a single import.
+const MaxRows = 10000
+
+// MaxImportBytes bounds the size of an uploaded import.
+const MaxImportBytes = 10 << 20
+
+// flushEvery is how many rows an export buffers before sending them on.
+const flushEvery = 100
+
+var (
+	ErrInvalidMapping = errors.New("invalid column mapping")
+	ErrMissingColumns = errors.New("missing columns")
+	ErrTooManyRows    = errors.New("too many rows")
+	ErrEmpty          = errors.New("no header row")
+)
+
+// Header is the first line of an export. Importing an export maps every
+// column it needs by name; the others are ignored.
+var Header = []string{"task_id", "task_name", "user_id", "user_name", "status", "completed", "due_date", "time_zone", "rrule", "description", "tags"}
+
+// Row is one task of an export. IDs are strings so both backends can share
+// the format.
+type Row struct {
+	TaskID      string
+	TaskName    string
+	UserID      string
+	UserName    string
+	Status      string
+	Completed   bool
+	DueDate     time.Time
+	TimeZone    string
+	RRule       string
+	Description string
+	Tags        []string
+}
+
+// RowWriter is implemented by every task export format.
+type RowWriter interface {
+	Write(row Row) error
+	Flush() error
+}
+
+type Writer struct {
+	out  io.Writer
+	csv  *csv.Writer
+	rows int
+}
+
+// NewWriter writes the header to out. Rows are flushed in batches, and out
+// is flushed along with them if it supports it, so large exports stream.
+func NewWriter(out io.Writer) (*Writer, error) {
+	writer := &Writer{out: out, csv: csv.NewWriter(out)}
+	if err := writer.csv.Write(Header); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *Writer) Write(row Row) error {
+	err := w.csv.Write([]string{
+		row.TaskID,
+		safeCell(row.TaskName),
+		row.UserID,
+		safeCell(row.UserName),
+		row.Status,
+		strconv.FormatBool(row.Completed),
+		row.DueDate.UTC().Format(time.RFC3339),
+		row.TimeZone,
+		row.RRule,
+		safeCell(row.Description),
+		safeCell(strings.Join(row.Tags, ",")),
+	})
+	if err != nil {
+		return err
+	}
+	w.rows++
+	if w.rows%flushEvery == 0 {
+		return w.Flush()
+	}
+	return nil
+}
+
+func (w *Writer) Flush() error {
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return err
+	}
+	if flusher, ok := w.out.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// safeCell keeps spreadsheets from evaluating user text as a formula. The
+// quote is dropped again on import.
+func safeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func unsafeCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@\t\r", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// Fields an import column can be mapped to.
+const (
+	FieldTaskName = "task_name"
+	FieldUserName = "user_name"
+	FieldDueDate  = "due_date"
+	FieldStatus   = "status"
+	FieldRRule    = "rrule"
+	FieldTimeZone = "time_zone"
+	FieldTags     = "tags"
+)
+
+var fields = map[string]bool{
+	FieldTaskName: true,
+	FieldUserName: true,
+	FieldDueDate:  true,
+	FieldStatus:   true,
+	FieldRRule:    true,
+	FieldTimeZone: true,
+	FieldTags:     true,
+}
+
+// aliases are header names spreadsheets commonly use for the fields, so most
+// files import without an explicit mapping.
+var aliases = map[string]string{
+	"task":     FieldTaskName,
+	"name":     FieldTaskName,
+	"title":    FieldTaskName,
+	"user":     FieldUserName,
+	"owner":    FieldUserName,
+	"assignee": FieldUserName,
+	"due":      FieldDueDate,
+	"deadline": FieldDueDate,
+	"timezone": FieldTimeZone,
+	"tz":       FieldTimeZone,
+}
+
+// ParseMapping reads a column mapping from "Header:field" pairs.
+func ParseMapping(pairs []string) (map[string]string, error) {
+	mapping := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		header, field, ok := strings.Cut(pair, ":")
+		header, field = strings.TrimSpace(header), strings.TrimSpace(field)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("%w: %q is not Header:field", ErrInvalidMapping, pair)
+		}
+		if !fields[field] {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidMapping, field)
+		}
+		mapping[normalizeHeader(header)] = field
+	}
+	return mapping, nil
+}
+
+func normalizeHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(header, "\ufeff")))
+	return strings.Join(strings.FieldsFunc(header, func(r rune) bool { return r == ' ' || r == '-' || r == '_' }), "_")
+}
+
+// ImportRow is a data line of an import, turned into the operation creating
+// its task. Failure is set when the line cannot become an operation.
+type ImportRow struct {
+	Line      int
+	Operation bulk.Operation
+	Failure   *bulk.Failure
+}
+
+// ReadImport reads a CSV file whose first line names the columns. Columns
+// are matched to fields through mapping first, then by field name or a
+// common alias; columns matching nothing are ignored.
+func ReadImport(r io.Reader, mapping map[string]string) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		key := normalizeHeader(name)
+		field, ok := mapping[key]
+		if !ok && fields[key] {
+			field, ok = key, true
+		}
+		if !ok {
+			field, ok = aliases[strings.ReplaceAll(key, "_", "")]
+		}
+		if ok {
+			if _, taken := columns[field]; !taken {
+				columns[field] = i
+			}
+		}
+	}
+	var missing []string
+	for _, field := range []string{FieldTaskName, FieldUserName, FieldDueDate} {
+		if _, ok := columns[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: %s", ErrMissingColumns, strings.Join(missing, ", "))
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if blank(record) {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, fmt.Errorf("%w: at most %d rows can be imported at once", ErrTooManyRows, MaxRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{Line: line, Operation: bulk.Operation{Op: bulk.Create}}
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return unsafeCell(strings.TrimSpace(record[i]))
+		}
+		row.Operation.TaskName = value(FieldTaskName)
+		row.Operation.UserName = value(FieldUserName)
+		row.Operation.DueDate = value(FieldDueDate)
+		row.Operation.Status = value(FieldStatus)
+		row.Operation.RRule = value(FieldRRule)
+		row.Operation.TimeZone = value(FieldTimeZone)
+		row.Operation.Tags = splitTags(value(FieldTags))
+		row.Failure = row.Operation.Validate()
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func blank(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Operations returns the operations of the rows that could be read.
+func Operations(rows []ImportRow) []bulk.Operation {
+	var operations []bulk.Operation
+	for _, row := range rows {
+		if row.Failure == nil {
+			operations = append(operations, row.Operation)
+		}
+	}
+	return operations
+}
+
+// Failed reports whether any row could not be read.
+func Failed(rows []ImportRow) bool {
+	for _, row := range rows {
+		if row.Failure != nil {
+			return true
+		}
+	}
+	return false
+}
+
+type RowResult struct {
+	Line   int         `json:"line"`
+	Status int         `json:"status"`
+	TaskID interface{} `json:"task_id,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+type ImportResponse struct {
+	Mode     bulk.Mode   `json:"mode"`
+	Imported int         `json:"imported"`
+	Failed   int         `json:"failed"`
+	Rows     []RowResult `json:"rows"`
+}
+
+// Merge reports the outcome of every row: the rows that could not be read
+// with their failure, the others with their result in response, which holds
+// the results of Operations(rows) in order. Rows without a result were not
+// imported because an atomic import was abandoned before it started.
+func Merge(rows []ImportRow, response bulk.Response) ImportResponse {
+	merged := ImportResponse{Mode: response.Mode, Rows: []RowResult{}}
+	next := 0
+	for _, row := range rows {
+		result := RowResult{Line: row.Line}
+		switch {
+		case row.Failure != nil:
+			result.Status, result.Error = row.Failure.Status, row.Failure.Message
+		case next < len(response.Results):
+			outcome := response.Results[next]
+			result.Status, result.TaskID, result.Error = outcome.Status, outcome.TaskID, outcome.Error
+			next++
+		default:
+			result.Status, result.Error = http.StatusFailedDependency, "Not imported because another row failed"
+		}
+		if result.Error == "" {
+			merged.Imported++
+		} else {
+			merged.Failed++
+		}
+		merged.Rows = append(merged.Rows, result)
+	}
+	return merged
+}
+
+// StatusCode is the HTTP status for the whole import: 200 unless an atomic
+// import was abandoned, in which case the status of its first real failure.
+func (r ImportResponse) StatusCode() int {
+	if r.Mode != bulk.Atomic || r.Failed == 0 {
+		return http.StatusOK
+	}
+	for _, row := range r.Rows {
+		if row.Error != "" && row.Status != http.StatusFailedDependency {
+			return row.Status
+		}
+	}
+	return http.StatusConflict
+}
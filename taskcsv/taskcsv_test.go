@@ -0,0 +1,167 @@
+package taskcsv
+
+import (
+	"Simple_Task_Manager/bulk"
+	"bytes"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRoundTrip(t *testing.T) {
+	due := time.Date(2024, time.March, 25, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		row  Row
+		want bulk.Operation
+	}{
+		{
+			name: "plain",
+			row:  Row{TaskID: "1", TaskName: "water plants", UserID: "2", UserName: "ada", Status: "todo", DueDate: due, TimeZone: "UTC", Tags: []string{"+home", "@garden"}},
+			want: bulk.Operation{Op: bulk.Create, TaskName: "water plants", UserName: "ada", DueDate: "2024-03-25T09:00:00Z", Status: "todo", TimeZone: "UTC", Tags: []string{"+home", "@garden"}},
+		},
+		{
+			name: "formulas",
+			row:  Row{TaskName: "=SUM(A1:A2)", UserName: "@ada", DueDate: due, RRule: "FREQ=DAILY", Tags: []string{"+home"}},
+			want: bulk.Operation{Op: bulk.Create, TaskName: "=SUM(A1:A2)", UserName: "@ada", DueDate: "2024-03-25T09:00:00Z", RRule: "FREQ=DAILY", Tags: []string{"+home"}},
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writer, err := NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Write(test.row); err != nil {
+			t.Fatal(err)
+		}
+		if err = writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), ",=") {
+			t.Errorf("%s: formula written unescaped: %s", test.name, buf.String())
+		}
+
+		rows, err := ReadImport(&buf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].Failure != nil || !reflect.DeepEqual(rows[0].Operation, test.want) {
+			t.Errorf("%s: export read back as %+v, want %+v", test.name, rows, test.want)
+		}
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	tests := []struct {
+		pairs []string
+		want  map[string]string
+		err   error
+	}{
+		{pairs: nil, want: map[string]string{}},
+		{pairs: []string{"Job Title:task_name", " Who : user_name"}, want: map[string]string{"job_title": FieldTaskName, "who": FieldUserName}},
+		{pairs: []string{"Title"}, err: ErrInvalidMapping},
+		{pairs: []string{":task_name"}, err: ErrInvalidMapping},
+		{pairs: []string{"Title:subject"}, err: ErrInvalidMapping},
+	}
+	for _, test := range tests {
+		mapping, err := ParseMapping(test.pairs)
+		if !errors.Is(err, test.err) || test.err == nil && !reflect.DeepEqual(mapping, test.want) {
+			t.Errorf("ParseMapping(%q) = %v, %v, want %v, %v", test.pairs, mapping, err, test.want, test.err)
+		}
+	}
+}
+
+func TestReadImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		mapping  map[string]string
+		want     []ImportRow
+		err      error
+		failures []string
+	}{
+		{
+			name: "aliases",
+			csv:  "\ufeffTitle,Owner,Deadline,Ignored\nwater plants,ada,tomorrow,x\n",
+			want: []ImportRow{{Line: 2, Operation: bulk.Operation{Op: bulk.Create, TaskName: "water plants", UserName: "ada", DueDate: "tomorrow"}}},
+		},
+		{
+			name:    "mapping",
+			csv:     "Job,Who,When,Labels\nwater plants,ada,tomorrow,\"+home, @garden\"\n",
+			mapping: map[string]string{"job": FieldTaskName, "who": FieldUserName, "when": FieldDueDate, "labels": FieldTags},
+			want:    []ImportRow{{Line: 2, Operation: bulk.Operation{Op: bulk.Create, TaskName: "water plants", UserName: "ada", DueDate: "tomorrow", Tags: []string{"+home", "@garden"}}}},
+		},
+		{
+			name:     "blank lines and invalid rows",
+			csv:      "task_name,user_name,due_date\n\nwater plants,,tomorrow\n,,\nrepot,ada\n",
+			failures: []string{"Missing user name", "Missing due date"},
+		},
+		{name: "empty", csv: "", err: ErrEmpty},
+		{name: "missing columns", csv: "task_name,notes\n", err: ErrMissingColumns},
+	}
+	for _, test := range tests {
+		rows, err := ReadImport(strings.NewReader(test.csv), test.mapping)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: ReadImport returned %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if test.failures != nil {
+			var failures []string
+			for _, row := range rows {
+				failures = append(failures, row.Failure.Message)
+			}
+			if !reflect.DeepEqual(failures, test.failures) {
+				t.Errorf("%s: failures %q, want %q", test.name, failures, test.failures)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(rows, test.want) {
+			t.Errorf("%s: ReadImport = %+v, want %+v", test.name, rows, test.want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	invalid := bulk.Fail(http.StatusBadRequest, "Missing due date")
+	rows := []ImportRow{{Line: 2}, {Line: 3, Failure: invalid}, {Line: 4}}
+	tests := []struct {
+		name     string
+		response bulk.Response
+		want     ImportResponse
+		status   int
+	}{
+		{
+			name: "partial",
+			response: bulk.Response{Mode: bulk.Partial, Results: []bulk.Result{
+				{Index: 0, Status: http.StatusCreated, TaskID: "1"},
+				{Index: 1, Status: http.StatusCreated, TaskID: "2"},
+			}},
+			want: ImportResponse{Mode: bulk.Partial, Imported: 2, Failed: 1, Rows: []RowResult{
+				{Line: 2, Status: http.StatusCreated, TaskID: "1"},
+				{Line: 3, Status: http.StatusBadRequest, Error: "Missing due date"},
+				{Line: 4, Status: http.StatusCreated, TaskID: "2"},
+			}},
+			status: http.StatusOK,
+		},
+		{
+			name:     "atomic, abandoned",
+			response: bulk.Response{Mode: bulk.Atomic},
+			want: ImportResponse{Mode: bulk.Atomic, Failed: 3, Rows: []RowResult{
+				{Line: 2, Status: http.StatusFailedDependency, Error: "Not imported because another row failed"},
+				{Line: 3, Status: http.StatusBadRequest, Error: "Missing due date"},
+				{Line: 4, Status: http.StatusFailedDependency, Error: "Not imported because another row failed"},
+			}},
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, test := range tests {
+		merged := Merge(rows, test.response)
+		if !reflect.DeepEqual(merged, test.want) || merged.StatusCode() != test.status {
+			t.Errorf("%s: Merge = %+v with status %d, want %+v with %d", test.name, merged, merged.StatusCode(), test.want, test.status)
+		}
+	}
+}
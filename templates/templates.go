@@ -0,0 +1,220 @@
+package templates
+
+import (
+	"Simple_Task_Manager/dates"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidTemplate = errors.New("invalid template")
+	ErrMissingVariable = errors.New("missing template variable")
+)
+
+const (
+	maxSubtasks       = 100
+	maxChecklistItems = 100
+	maxTags           = 20
+)
+
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// Template describes a set of tasks that gets created over and over again,
+// such as an onboarding or release checklist. Task names, descriptions, tags
+// and checklist items may contain variables written as {{name}}. DueIn is a
+// relative due date such as "+3 days", resolved when the template is
+// instantiated.
+type Template struct {
+	Name        string    `json:"name" bson:"name" validate:"required,max=200"`
+	TaskName    string    `json:"task_name" bson:"task_name" validate:"required,max=500"`
+	Description string    `json:"description,omitempty" bson:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty" bson:"tags,omitempty" validate:"max=20"`
+	Checklist   []string  `json:"checklist,omitempty" bson:"checklist,omitempty" validate:"max=100"`
+	DueIn       string    `json:"due_in" bson:"due_in" validate:"required,date"`
+	Subtasks    []Subtask `json:"subtasks,omitempty" bson:"subtasks,omitempty" validate:"max=100"`
+}
+
+// Subtask is a task created below the template's task. Without DueIn it is
+// due together with its parent.
+type Subtask struct {
+	TaskName    string   `json:"task_name" bson:"task_name" validate:"required,max=500"`
+	Description string   `json:"description,omitempty" bson:"description,omitempty"`
+	Checklist   []string `json:"checklist,omitempty" bson:"checklist,omitempty" validate:"max=100"`
+	DueIn       string   `json:"due_in,omitempty" bson:"due_in,omitempty" validate:"date"`
+}
+
+// Instance is a template with its variables substituted and due dates
+// resolved, ready to be stored as tasks.
+type Instance struct {
+	TaskName    string
+	Description string
+	Tags        []string
+	Checklist   []string
+	DueDate     time.Time
+	Subtasks    []Instance
+}
+
+// Validate checks that the template can be instantiated at all, leaving only
+// missing variables to be reported per call.
+func (t Template) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if strings.TrimSpace(t.TaskName) == "" {
+		return fmt.Errorf("%w: task_name is required", ErrInvalidTemplate)
+	}
+	if t.DueIn == "" {
+		return fmt.Errorf("%w: due_in is required", ErrInvalidTemplate)
+	}
+	if len(t.Tags) > maxTags {
+		return fmt.Errorf("%w: at most %d tags are allowed", ErrInvalidTemplate, maxTags)
+	}
+	if len(t.Subtasks) > maxSubtasks {
+		return fmt.Errorf("%w: at most %d subtasks are allowed", ErrInvalidTemplate, maxSubtasks)
+	}
+
+	patterns := append([]string{t.TaskName, t.Description}, t.Tags...)
+	patterns = append(patterns, t.Checklist...)
+	dueDates := []string{t.DueIn}
+	checklists := [][]string{t.Checklist}
+	for i, subtask := range t.Subtasks {
+		if strings.TrimSpace(subtask.TaskName) == "" {
+			return fmt.Errorf("%w: subtask %d has no task_name", ErrInvalidTemplate, i+1)
+		}
+		patterns = append(append(patterns, subtask.TaskName, subtask.Description), subtask.Checklist...)
+		if subtask.DueIn != "" {
+			dueDates = append(dueDates, subtask.DueIn)
+		}
+		checklists = append(checklists, subtask.Checklist)
+	}
+
+	for _, checklist := range checklists {
+		if len(checklist) > maxChecklistItems {
+			return fmt.Errorf("%w: at most %d checklist items are allowed", ErrInvalidTemplate, maxChecklistItems)
+		}
+	}
+	for _, pattern := range patterns {
+		if strings.Contains(variablePattern.ReplaceAllString(pattern, ""), "{{") {
+			return fmt.Errorf("%w: malformed variable in %q", ErrInvalidTemplate, pattern)
+		}
+	}
+	for _, dueIn := range dueDates {
+		if _, err := dates.Resolve(dueIn, time.Now(), time.UTC); err != nil {
+			return fmt.Errorf("%w: invalid due_in %q", ErrInvalidTemplate, dueIn)
+		}
+	}
+	return nil
+}
+
+// Variables returns the names of the variables the template uses, sorted.
+// Built-in variables are included.
+func (t Template) Variables() []string {
+	seen := make(map[string]bool)
+	collect := func(patterns ...string) {
+		for _, pattern := range patterns {
+			for _, match := range variablePattern.FindAllStringSubmatch(pattern, -1) {
+				seen[match[1]] = true
+			}
+		}
+	}
+	collect(t.TaskName, t.Description)
+	collect(t.Tags...)
+	collect(t.Checklist...)
+	for _, subtask := range t.Subtasks {
+		collect(subtask.TaskName, subtask.Description)
+		collect(subtask.Checklist...)
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Expand substitutes the variables in pattern. Every variable used must be
+// present in vars.
+func Expand(pattern string, vars map[string]string) (string, error) {
+	var missing []string
+	expanded := variablePattern.ReplaceAllStringFunc(pattern, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// Instantiate expands the template for one set of variables. Relative due
+// dates are resolved against now in location, and the built-in variable
+// "date" holds today's date there unless vars overrides it.
+func (t Template) Instantiate(vars map[string]string, now time.Time, location *time.Location) (Instance, error) {
+	values := map[string]string{"date": now.In(location).Format("2006-01-02")}
+	for name, value := range vars {
+		values[name] = value
+	}
+
+	instance, err := instantiate(t.TaskName, t.Description, t.Checklist, t.DueIn, values, now, location)
+	if err != nil {
+		return Instance{}, err
+	}
+	if instance.Tags, err = expandAll(t.Tags, values); err != nil {
+		return Instance{}, err
+	}
+
+	for _, subtask := range t.Subtasks {
+		dueIn := subtask.DueIn
+		if dueIn == "" {
+			dueIn = t.DueIn
+		}
+		child, err := instantiate(subtask.TaskName, subtask.Description, subtask.Checklist, dueIn, values, now, location)
+		if err != nil {
+			return Instance{}, err
+		}
+		instance.Subtasks = append(instance.Subtasks, child)
+	}
+	return instance, nil
+}
+
+func instantiate(taskName, description string, checklist []string, dueIn string, values map[string]string, now time.Time, location *time.Location) (Instance, error) {
+	var instance Instance
+	var err error
+	if instance.TaskName, err = Expand(taskName, values); err != nil {
+		return Instance{}, err
+	}
+	if strings.TrimSpace(instance.TaskName) == "" {
+		return Instance{}, fmt.Errorf("%w: task name %q expands to nothing", ErrMissingVariable, taskName)
+	}
+	if instance.Description, err = Expand(description, values); err != nil {
+		return Instance{}, err
+	}
+	if instance.Checklist, err = expandAll(checklist, values); err != nil {
+		return Instance{}, err
+	}
+	if instance.DueDate, err = dates.Resolve(dueIn, now, location); err != nil {
+		return Instance{}, fmt.Errorf("%w: invalid due_in %q", ErrInvalidTemplate, dueIn)
+	}
+	return instance, nil
+}
+
+func expandAll(patterns []string, values map[string]string) ([]string, error) {
+	expanded := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		value, err := Expand(pattern, values)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, value)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,149 @@
+package templates
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := Template{Name: "release", TaskName: "Release {{version}}", DueIn: "+3 days"}
+	tests := []struct {
+		name   string
+		change func(*Template)
+		valid  bool
+	}{
+		{name: "valid", change: func(*Template) {}, valid: true},
+		{name: "no name", change: func(t *Template) { t.Name = " " }},
+		{name: "no task name", change: func(t *Template) { t.TaskName = "" }},
+		{name: "no due date", change: func(t *Template) { t.DueIn = "" }},
+		{name: "invalid due date", change: func(t *Template) { t.DueIn = "someday" }},
+		{name: "malformed variable", change: func(t *Template) { t.Description = "for {{ version" }},
+		{name: "too many tags", change: func(t *Template) { t.Tags = make([]string, maxTags+1) }},
+		{name: "too many checklist items", change: func(t *Template) { t.Checklist = make([]string, maxChecklistItems+1) }},
+		{name: "unnamed subtask", change: func(t *Template) { t.Subtasks = []Subtask{{TaskName: ""}} }},
+		{name: "subtask with invalid due date", change: func(t *Template) { t.Subtasks = []Subtask{{TaskName: "tag", DueIn: "someday"}} }},
+		{name: "subtask with too many checklist items", change: func(t *Template) {
+			t.Subtasks = []Subtask{{TaskName: "tag", Checklist: make([]string, maxChecklistItems+1)}}
+		}},
+	}
+	for _, test := range tests {
+		template := valid
+		test.change(&template)
+		err := template.Validate()
+		if test.valid && err != nil || !test.valid && !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%s: Validate returned %v", test.name, err)
+		}
+	}
+}
+
+func TestVariables(t *testing.T) {
+	template := Template{
+		TaskName:  "Release {{version}}",
+		Tags:      []string{"+{{ project }}"},
+		Checklist: []string{"Announce on {{date}}"},
+		Subtasks:  []Subtask{{TaskName: "Tag {{version}}", Description: "by {{owner}}"}},
+	}
+	want := []string{"date", "owner", "project", "version"}
+	if got := template.Variables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables = %v, want %v", got, want)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	vars := map[string]string{"version": "1.2", "empty": ""}
+	tests := []struct {
+		pattern string
+		want    string
+		missing bool
+	}{
+		{pattern: "Release {{version}}", want: "Release 1.2"},
+		{pattern: "Release {{ version }}", want: "Release 1.2"},
+		{pattern: "no variables", want: "no variables"},
+		{pattern: "[{{empty}}]", want: "[]"},
+		{pattern: "Release {{version}} by {{owner}}", missing: true},
+	}
+	for _, test := range tests {
+		got, err := Expand(test.pattern, vars)
+		if test.missing {
+			if !errors.Is(err, ErrMissingVariable) || !strings.Contains(err.Error(), "owner") {
+				t.Errorf("Expand(%q) returned %q, %v, want a missing owner", test.pattern, got, err)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("Expand(%q) = %q, %v, want %q", test.pattern, got, err, test.want)
+		}
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	now := time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)
+	template := Template{
+		Name:      "release",
+		TaskName:  "Release {{version}}",
+		Tags:      []string{"+{{project}}"},
+		Checklist: []string{"Announce on {{date}}"},
+		DueIn:     "+3 days",
+		Subtasks: []Subtask{
+			{TaskName: "Tag {{version}}", DueIn: "tomorrow"},
+			{TaskName: "Write notes"},
+		},
+	}
+	tests := []struct {
+		name     string
+		taskName string
+		vars     map[string]string
+		want     Instance
+		missing  bool
+	}{
+		{
+			name: "all variables",
+			vars: map[string]string{"version": "1.2", "project": "app"},
+			want: Instance{
+				TaskName:  "Release 1.2",
+				Tags:      []string{"+app"},
+				Checklist: []string{"Announce on 2024-01-31"},
+				DueDate:   time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC),
+				Subtasks: []Instance{
+					{TaskName: "Tag 1.2", Checklist: []string{}, DueDate: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+					{TaskName: "Write notes", Checklist: []string{}, DueDate: time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+		{
+			name: "date overridden",
+			vars: map[string]string{"version": "1.2", "project": "app", "date": "launch day"},
+			want: Instance{
+				TaskName:  "Release 1.2",
+				Tags:      []string{"+app"},
+				Checklist: []string{"Announce on launch day"},
+				DueDate:   time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC),
+				Subtasks: []Instance{
+					{TaskName: "Tag 1.2", Checklist: []string{}, DueDate: time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+					{TaskName: "Write notes", Checklist: []string{}, DueDate: time.Date(2024, time.February, 3, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+		{name: "missing variable", vars: map[string]string{"version": "1.2"}, missing: true},
+		{name: "name expands to nothing", taskName: "{{version}}", vars: map[string]string{"version": " ", "project": "app"}, missing: true},
+	}
+	for _, test := range tests {
+		template := template
+		if test.taskName != "" {
+			template.TaskName = test.taskName
+		}
+		got, err := template.Instantiate(test.vars, now, time.UTC)
+		if test.missing {
+			if !errors.Is(err, ErrMissingVariable) {
+				t.Errorf("%s: Instantiate returned %v, want a missing variable", test.name, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Instantiate = %+v, %v, want %+v", test.name, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package timesheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidEntry = errors.New("time entry must end after it starts")
+
+// Row is one line of a timesheet export. IDs are strings so both backends can
+// share the format.
+type Row struct {
+	EntryID   string
+	TaskID    string
+	TaskName  string
+	UserID    string
+	UserName  string
+	StartedAt time.Time
+	EndedAt   *time.Time
+	Note      string
+	Duration  time.Duration
+}
+
+// Duration returns how much of an entry falls into [from, to). A zero from or
+// to leaves that side open, and a running entry (nil end) counts up to now.
+func Duration(start time.Time, end *time.Time, from, to, now time.Time) time.Duration {
+	stop := now
+	if end != nil {
+		stop = *end
+	}
+	if !from.IsZero() && start.Before(from) {
+		start = from
+	}
+	if !to.IsZero() && stop.After(to) {
+		stop = to
+	}
+	if !stop.After(start) {
+		return 0
+	}
+	return stop.Sub(start)
+}
+
+// Hours converts d to hours rounded to two decimals, the precision used on
+// invoices.
+func Hours(d time.Duration) float64 {
+	return math.Round(d.Hours()*100) / 100
+}
+
+// ValidateEntry checks a manually entered time span.
+func ValidateEntry(start, end time.Time) error {
+	if !end.After(start) {
+		return ErrInvalidEntry
+	}
+	return nil
+}
+
+// WriteCSV writes rows as a timesheet with a header line. Times are written in
+// UTC as RFC 3339; running entries have an empty end.
+func WriteCSV(w io.Writer, rows []Row) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"entry_id", "date", "task_id", "task_name", "user_id", "user_name", "started_at", "ended_at", "hours", "note"})
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		ended := ""
+		if row.EndedAt != nil {
+			ended = row.EndedAt.UTC().Format(time.RFC3339)
+		}
+		err = writer.Write([]string{
+			row.EntryID,
+			row.StartedAt.UTC().Format("2006-01-02"),
+			row.TaskID,
+			safeCell(row.TaskName),
+			row.UserID,
+			safeCell(row.UserName),
+			row.StartedAt.UTC().Format(time.RFC3339),
+			ended,
+			strconv.FormatFloat(Hours(row.Duration), 'f', 2, 64),
+			safeCell(row.Note),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// safeCell keeps spreadsheets from evaluating user text as a formula.
+func safeCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,103 @@
+package timesheet
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, time.March, 25, hour, 0, 0, 0, time.UTC) }
+	end := func(hour int) *time.Time { ended := at(hour); return &ended }
+	tests := []struct {
+		name     string
+		start    time.Time
+		end      *time.Time
+		from, to time.Time
+		want     time.Duration
+	}{
+		{name: "open range", start: at(9), end: end(11), want: 2 * time.Hour},
+		{name: "starts before the range", start: at(9), end: end(11), from: at(10), want: time.Hour},
+		{name: "ends after the range", start: at(9), end: end(11), to: at(10), want: time.Hour},
+		{name: "within the range", start: at(9), end: end(11), from: at(8), to: at(12), want: 2 * time.Hour},
+		{name: "before the range", start: at(9), end: end(11), from: at(12), want: 0},
+		{name: "after the range", start: at(9), end: end(11), to: at(8), want: 0},
+		{name: "running", start: at(9), want: 3 * time.Hour},
+		{name: "running, range ends earlier", start: at(9), to: at(10), want: time.Hour},
+	}
+	for _, test := range tests {
+		if got := Duration(test.start, test.end, test.from, test.to, at(12)); got != test.want {
+			t.Errorf("%s: Duration = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestHours(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		want     float64
+	}{
+		{0, 0},
+		{90 * time.Minute, 1.5},
+		{20 * time.Minute, 0.33},
+		{time.Hour + 36*time.Second, 1.01},
+	}
+	for _, test := range tests {
+		if got := Hours(test.duration); got != test.want {
+			t.Errorf("Hours(%v) = %v, want %v", test.duration, got, test.want)
+		}
+	}
+}
+
+func TestValidateEntry(t *testing.T) {
+	start := time.Date(2024, time.March, 25, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		end  time.Time
+		want error
+	}{
+		{end: start.Add(time.Minute)},
+		{end: start, want: ErrInvalidEntry},
+		{end: start.Add(-time.Minute), want: ErrInvalidEntry},
+	}
+	for _, test := range tests {
+		if err := ValidateEntry(start, test.end); err != test.want {
+			t.Errorf("ValidateEntry(%v, %v) = %v, want %v", start, test.end, err, test.want)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	started := time.Date(2024, time.March, 25, 9, 0, 0, 0, time.FixedZone("CET", 3600))
+	ended := started.Add(90 * time.Minute)
+	tests := []struct {
+		name string
+		row  Row
+		want string
+	}{
+		{
+			name: "finished entry",
+			row:  Row{EntryID: "1", TaskID: "2", TaskName: "water plants", UserID: "3", UserName: "ada", StartedAt: started, EndedAt: &ended, Note: "balcony", Duration: 90 * time.Minute},
+			want: "1,2024-03-25,2,water plants,3,ada,2024-03-25T08:00:00Z,2024-03-25T09:30:00Z,1.50,balcony\n",
+		},
+		{
+			name: "running entry",
+			row:  Row{EntryID: "1", TaskID: "2", TaskName: "water plants", UserID: "3", UserName: "ada", StartedAt: started, Duration: 30 * time.Minute},
+			want: "1,2024-03-25,2,water plants,3,ada,2024-03-25T08:00:00Z,,0.50,\n",
+		},
+		{
+			name: "formula",
+			row:  Row{EntryID: "1", TaskID: "2", TaskName: "=HYPERLINK(\"x\")", UserID: "3", UserName: "@ada", StartedAt: started, Note: "-1"},
+			want: "1,2024-03-25,2,\"'=HYPERLINK(\"\"x\"\")\",3,'@ada,2024-03-25T08:00:00Z,,0.00,'-1\n",
+		},
+	}
+	const header = "entry_id,date,task_id,task_name,user_id,user_name,started_at,ended_at,hours,note\n"
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := WriteCSV(&buf, []Row{test.row}); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != header+test.want {
+			t.Errorf("%s: WriteCSV wrote\n%s\nwant\n%s", test.name, got, header+test.want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package todolist
+
+import (
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/ical"
+	"Simple_Task_Manager/taskcsv"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Formats of personal task lists.
+const (
+	// TodoTxt is one task per line as described at todotxt.org.
+	TodoTxt = "todotxt"
+	// Markdown is a GitHub style "- [ ]" checklist.
+	Markdown = "markdown"
+)
+
+// maxLineLength bounds a single line of an imported list.
+const maxLineLength = 64 * 1024
+
+var ErrUnknownFormat = errors.New("unknown list format")
+
+var (
+	priorityPattern = regexp.MustCompile(`^\(([A-Z])\)$`)
+	datePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	checkboxPattern = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	headingPattern  = regexp.MustCompile(`^##\s+(.*?)\s*#*\s*$`)
+)
+
+func ValidFormat(format string) bool {
+	return format == TodoTxt || format == Markdown
+}
+
+// Defaults fill in what a list does not say: the user of tasks without a
+// user:name tag or Markdown heading, and the due date of tasks without due:.
+type Defaults struct {
+	UserName string
+	DueDate  string
+}
+
+// Read parses a list into rows that create its tasks. Both formats share the
+// todo.txt syntax for a task: a leading "(A)" priority, "+project" and
+// "@context" tags, and "due:", "user:", "tag:" and "pri:" key:value tags.
+// A todo.txt line starting with "x " is completed, as is a Markdown "- [x]"
+// item. Second level Markdown headings name the user of the items below
+// them, as in an export, and other lines are ignored.
+func Read(r io.Reader, format string, defaults Defaults) ([]taskcsv.ImportRow, error) {
+	if !ValidFormat(format) {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxLineLength)
+	var rows []taskcsv.ImportRow
+	userName := defaults.UserName
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		completed := false
+		if format == Markdown {
+			if heading := headingPattern.FindStringSubmatch(text); heading != nil {
+				userName = heading[1]
+				continue
+			}
+			item := checkboxPattern.FindStringSubmatch(text)
+			if item == nil {
+				continue
+			}
+			completed, text = item[1] != " ", item[2]
+		} else {
+			if text == "" {
+				continue
+			}
+			if rest, ok := strings.CutPrefix(text, "x "); ok {
+				completed, text = true, rest
+			}
+		}
+
+		if len(rows) == taskcsv.MaxRows {
+			return nil, fmt.Errorf("%w: at most %d rows can be imported at once", taskcsv.ErrTooManyRows, taskcsv.MaxRows)
+		}
+		operation := parseTask(text, completed)
+		if operation.UserName == "" {
+			operation.UserName = userName
+		}
+		if operation.DueDate == "" {
+			operation.DueDate = defaults.DueDate
+		}
+		rows = append(rows, taskcsv.ImportRow{Line: line, Operation: operation, Failure: operation.Validate()})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func parseTask(text string, completed bool) bulk.Operation {
+	operation := bulk.Operation{Op: bulk.Create, Completed: completed}
+	fields := strings.Fields(text)
+
+	// A completed todo.txt task may carry its completion and creation dates,
+	// an open one its priority and creation date. The dates are not kept.
+	if completed {
+		for i := 0; i < 2 && len(fields) > 0 && datePattern.MatchString(fields[0]); i++ {
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		if priority := priorityPattern.FindStringSubmatch(fields[0]); priority != nil {
+			operation.Tags = append(operation.Tags, priorityTag(priority[1]))
+			fields = fields[1:]
+		}
+	}
+	if !completed && len(fields) > 0 && datePattern.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+
+	var words []string
+	for _, field := range fields {
+		if len(field) > 1 && (field[0] == '+' || field[0] == '@') {
+			operation.Tags = append(operation.Tags, field)
+			continue
+		}
+		key, value, found := strings.Cut(field, ":")
+		if found && value != "" {
+			switch key {
+			case "due":
+				operation.DueDate = value
+				continue
+			case "user":
+				operation.UserName = unescape(value)
+				continue
+			case "tag":
+				operation.Tags = append(operation.Tags, unescape(value))
+				continue
+			case "pri":
+				if len(value) == 1 && value[0] >= 'A' && value[0] <= 'Z' {
+					operation.Tags = append(operation.Tags, priorityTag(value))
+					continue
+				}
+			}
+		}
+		words = append(words, field)
+	}
+	operation.TaskName = strings.Join(words, " ")
+	return operation
+}
+
+// priorityTag maps a todo.txt priority onto the tags the calendar feed reads:
+// A is high, B medium and everything below low.
+func priorityTag(letter string) string {
+	switch letter {
+	case "A":
+		return "priority:high"
+	case "B":
+		return "priority:medium"
+	}
+	return "priority:low"
+}
+
+func priorityLetter(tags []string) string {
+	switch priority := ical.PriorityOf(tags); {
+	case priority == 0:
+		return ""
+	case priority < 5:
+		return "A"
+	case priority == 5:
+		return "B"
+	}
+	return "C"
+}
+
+func unescape(value string) string {
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
+	return value
+}
+
+// ContentType and FileName describe an export in format.
+func ContentType(format string) string {
+	if format == Markdown {
+		return "text/markdown; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func FileName(format string) string {
+	if format == Markdown {
+		return "tasks.md"
+	}
+	return "todo.txt"
+}
+
+// Writer exports tasks in a list format. todo.txt lines are written as rows
+// arrive; a Markdown checklist is grouped under a heading per user and
+// written by Flush.
+type Writer struct {
+	out    *bufio.Writer
+	format string
+	users  []string
+	items  map[string][]string
+}
+
+func NewWriter(out io.Writer, format string) *Writer {
+	return &Writer{out: bufio.NewWriter(out), format: format, items: make(map[string][]string)}
+}
+
+func (w *Writer) Write(row taskcsv.Row) error {
+	// Deleted tasks are kept without a due date.
+	if row.DueDate.IsZero() {
+		return nil
+	}
+	if w.format == Markdown {
+		box := "[ ]"
+		if row.Completed {
+			box = "[x]"
+		}
+		if _, ok := w.items[row.UserName]; !ok {
+			w.users = append(w.users, row.UserName)
+		}
+		w.items[row.UserName] = append(w.items[row.UserName], "- "+box+" "+formatTask(row, false))
+		return nil
+	}
+
+	line := formatTask(row, true)
+	if row.Completed {
+		line = "x " + line
+	}
+	_, err := w.out.WriteString(line + "\n")
+	return err
+}
+
+func (w *Writer) Flush() error {
+	for i, user := range w.users {
+		if i > 0 {
+			if _, err := w.out.WriteString("\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.out.WriteString("## " + user + "\n\n" + strings.Join(w.items[user], "\n") + "\n"); err != nil {
+			return err
+		}
+	}
+	w.users, w.items = nil, make(map[string][]string)
+	return w.out.Flush()
+}
+
+// formatTask renders a task in the syntax Read parses.
+func formatTask(row taskcsv.Row, withUser bool) string {
+	var parts []string
+	priority := priorityLetter(row.Tags)
+	if priority != "" && !row.Completed {
+		parts = append(parts, "("+priority+")")
+	}
+	parts = append(parts, strings.Join(strings.Fields(row.TaskName), " "))
+	for _, tag := range row.Tags {
+		switch {
+		case strings.HasPrefix(strings.ToLower(tag), "priority:"):
+		case len(tag) > 1 && (tag[0] == '+' || tag[0] == '@') && !strings.ContainsAny(tag, " \t"):
+			parts = append(parts, tag)
+		default:
+			parts = append(parts, "tag:"+url.PathEscape(tag))
+		}
+	}
+	if priority != "" && row.Completed {
+		parts = append(parts, "pri:"+priority)
+	}
+	parts = append(parts, "due:"+formatDue(row.DueDate, row.TimeZone))
+	if withUser {
+		parts = append(parts, "user:"+url.PathEscape(row.UserName))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatDue renders a due date on the wall clock of its time zone, as a plain
+// date when it falls on midnight.
+func formatDue(due time.Time, timeZone string) string {
+	if location, err := time.LoadLocation(timeZone); err == nil && timeZone != "" {
+		due = due.In(location)
+	} else {
+		due = due.UTC()
+	}
+	if due.Hour() == 0 && due.Minute() == 0 {
+		return due.Format("2006-01-02")
+	}
+	return due.Format("2006-01-02T15:04")
+}
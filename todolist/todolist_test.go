@@ -0,0 +1,151 @@
+package todolist
+
+import (
+	"Simple_Task_Manager/bulk"
+	"Simple_Task_Manager/taskcsv"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	defaults := Defaults{UserName: "ada", DueDate: "tomorrow"}
+	tests := []struct {
+		name   string
+		format string
+		list   string
+		want   []bulk.Operation
+	}{
+		{
+			name:   "todo.txt task",
+			format: TodoTxt,
+			list:   "(A) 2024-03-01 Water plants +home @garden due:2024-03-25\n",
+			want: []bulk.Operation{
+				{Op: bulk.Create, TaskName: "Water plants", UserName: "ada", DueDate: "2024-03-25", Tags: []string{"priority:high", "+home", "@garden"}},
+			},
+		},
+		{
+			name:   "todo.txt completed task",
+			format: TodoTxt,
+			list:   "x 2024-03-02 2024-03-01 Repot cactus pri:B user:grace%20h tag:big%20pot\n",
+			want: []bulk.Operation{
+				{Op: bulk.Create, TaskName: "Repot cactus", UserName: "grace h", DueDate: "tomorrow", Completed: true, Tags: []string{"priority:medium", "big pot"}},
+			},
+		},
+		{
+			name:   "todo.txt blank lines and plain words",
+			format: TodoTxt,
+			list:   "\n   \nCall mom: soon pri:low + @\n",
+			want: []bulk.Operation{
+				{Op: bulk.Create, TaskName: "Call mom: soon pri:low + @", UserName: "ada", DueDate: "tomorrow"},
+			},
+		},
+		{
+			name:   "Markdown checklist",
+			format: Markdown,
+			list:   "# Tasks\n\nSome notes.\n- [ ] Water plants due:2024-03-25\n\n## grace\n\n* [x] Repot cactus\n+ [X] (C) Sweep\n",
+			want: []bulk.Operation{
+				{Op: bulk.Create, TaskName: "Water plants", UserName: "ada", DueDate: "2024-03-25"},
+				{Op: bulk.Create, TaskName: "Repot cactus", UserName: "grace", DueDate: "tomorrow", Completed: true},
+				{Op: bulk.Create, TaskName: "Sweep", UserName: "grace", DueDate: "tomorrow", Completed: true, Tags: []string{"priority:low"}},
+			},
+		},
+	}
+	for _, test := range tests {
+		rows, err := Read(strings.NewReader(test.list), test.format, defaults)
+		if err != nil {
+			t.Errorf("%s: Read returned %v", test.name, err)
+			continue
+		}
+		var operations []bulk.Operation
+		for _, row := range rows {
+			if row.Failure != nil {
+				t.Errorf("%s: line %d failed: %v", test.name, row.Line, row.Failure)
+			}
+			operations = append(operations, row.Operation)
+		}
+		if !reflect.DeepEqual(operations, test.want) {
+			t.Errorf("%s: Read =\n%+v\nwant\n%+v", test.name, operations, test.want)
+		}
+	}
+}
+
+func TestReadFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		defaults Defaults
+		err      error
+		failure  string
+	}{
+		{name: "unknown format", format: "org", err: ErrUnknownFormat},
+		{name: "no user", format: TodoTxt, defaults: Defaults{DueDate: "tomorrow"}, failure: "Missing user name"},
+		{name: "no due date", format: TodoTxt, defaults: Defaults{UserName: "ada"}, failure: "Missing due date"},
+	}
+	for _, test := range tests {
+		rows, err := Read(strings.NewReader("Water plants\n"), test.format, test.defaults)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: Read returned %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if test.failure != "" && (len(rows) != 1 || rows[0].Failure == nil || rows[0].Failure.Message != test.failure) {
+			t.Errorf("%s: Read = %+v, want a row failing with %q", test.name, rows, test.failure)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	due := time.Date(2024, time.March, 25, 0, 0, 0, 0, time.UTC)
+	rows := []taskcsv.Row{
+		{TaskName: "Water  plants", UserName: "ada", DueDate: due, Tags: []string{"priority:high", "+home", "big pot"}},
+		{TaskName: "Repot cactus", UserName: "grace h", DueDate: due.Add(9 * time.Hour), TimeZone: "Europe/Berlin", Completed: true, Tags: []string{"priority:5"}},
+		{TaskName: "X", UserName: "ada"},
+		{TaskName: "Sweep", UserName: "ada", DueDate: due},
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: TodoTxt,
+			want: "(A) Water plants +home tag:big%20pot due:2024-03-25 user:ada\n" +
+				"x Repot cactus pri:B due:2024-03-25T10:00 user:grace%20h\n" +
+				"Sweep due:2024-03-25 user:ada\n",
+		},
+		{
+			format: Markdown,
+			want: "## ada\n\n" +
+				"- [ ] (A) Water plants +home tag:big%20pot due:2024-03-25\n" +
+				"- [ ] Sweep due:2024-03-25\n\n" +
+				"## grace h\n\n" +
+				"- [x] Repot cactus pri:B due:2024-03-25T10:00\n",
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writer := NewWriter(&buf, test.format)
+		for _, row := range rows {
+			if err := writer.Write(row); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%s export:\n%s\nwant\n%s", test.format, buf.String(), test.want)
+		}
+
+		// What is exported reads back as the same tasks.
+		read, err := Read(&buf, test.format, Defaults{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read) != 3 || read[0].Operation.TaskName != "Water plants" || read[1].Operation.UserName == "" || read[2].Failure != nil {
+			t.Errorf("%s export read back as %+v", test.format, read)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package validate
+
+import (
+	"Simple_Task_Manager/dates"
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/workflow"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxBodySize bounds the JSON body of a request.
+const MaxBodySize = 1 << 20
+
+// Decode reads the JSON body of r into dst, a pointer to a struct, and checks
+// the fields against their validate tags. Unknown fields are rejected, and an
+// empty body is decoded as an empty object, leaving required fields to be
+// reported. All invalid fields are reported together in the returned
+// *problem.Error, whose detail joins their messages.
+//
+// The validate tag is a comma-separated list of rules:
+//
+//	required    the value is not empty, or for strings not blank
+//	max=N       strings have at most N characters, slices at most N items
+//	min=N       numbers are at least N
+//	oneof=a b   strings are one of the listed values
+//	date        strings are a date, possibly relative, like a due date
+//	datetime    strings are an RFC 3339 timestamp or a plain date and time
+//	timezone    strings are an IANA time zone
+//
+// Rules other than required skip empty values. Structs and slices of structs
+// are checked field by field. If dst is a Validator, the fields it reports are
+// added to those breaking a rule.
+func Decode(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxBodySize))
+	decoder.DisallowUnknownFields()
+	defer r.Body.Close()
+
+	if err := decoder.Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+		return decodeError(err)
+	}
+	if decoder.More() {
+		return problem.BadRequest("invalid_body", "Invalid request body")
+	}
+
+	fields := check(reflect.Indirect(reflect.ValueOf(dst)), "")
+	if validator, ok := dst.(Validator); ok {
+		fields = append(fields, validator.Validate()...)
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return problem.InvalidFields(fields...)
+}
+
+// Validator is a request body with checks that validate tags cannot express,
+// such as those that depend on the server's configuration.
+type Validator interface {
+	Validate() []problem.Field
+}
+
+// Status reports the field name unless its value, status, is empty or one of
+// the statuses of wf.
+func Status(wf *workflow.Workflow, name, status string) []problem.Field {
+	if status == "" || wf.Valid(workflow.Status(status)) {
+		return nil
+	}
+	allowed := make([]string, 0, len(wf.Transitions))
+	for _, status := range wf.Statuses() {
+		allowed = append(allowed, string(status))
+	}
+	return []problem.Field{{Field: name, Code: "invalid", Message: fmt.Sprintf("%s must be one of %s", name, strings.Join(allowed, ", "))}}
+}
+
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return problem.New(http.StatusRequestEntityTooLarge, "body_too_large", "Request body too large")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		message := fmt.Sprintf("%s must be %s", typeErr.Field, kind(typeErr.Type))
+		return problem.Invalid(message, problem.Field{Field: typeErr.Field, Code: "invalid_type", Message: message})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for unknown fields.
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		message := fmt.Sprintf("Unknown field %s", field)
+		return problem.Invalid(message, problem.Field{Field: field, Code: "unknown_field", Message: message})
+	default:
+		return problem.BadRequest("invalid_body", "Invalid request body")
+	}
+}
+
+// kind names a Go type as the JSON value it is decoded from.
+func kind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+func check(value reflect.Value, prefix string) []problem.Field {
+	var fields []problem.Field
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous {
+			fields = append(fields, check(reflect.Indirect(value.Field(i)), prefix)...)
+			continue
+		}
+		name := prefix + jsonName(field)
+		fieldValue := value.Field(i)
+		if tag := field.Tag.Get("validate"); tag != "" {
+			if failed, ok := checkRules(fieldValue, name, tag); !ok {
+				fields = append(fields, failed)
+				continue
+			}
+		}
+		switch fieldValue.Kind() {
+		case reflect.Struct:
+			fields = append(fields, check(fieldValue, name+".")...)
+		case reflect.Slice:
+			for j := 0; j < fieldValue.Len(); j++ {
+				fields = append(fields, check(reflect.Indirect(fieldValue.Index(j)), fmt.Sprintf("%s[%d].", name, j))...)
+			}
+		}
+	}
+	return fields
+}
+
+func jsonName(field reflect.StructField) string {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return field.Name
+}
+
+// checkRules applies the rules of tag to value and reports the first one it
+// breaks.
+func checkRules(value reflect.Value, name, tag string) (problem.Field, bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+		if rule == "required" {
+			if empty(value) {
+				return problem.Field{Field: name, Code: "required", Message: name + " is required"}, false
+			}
+			continue
+		}
+		if value.IsZero() {
+			continue
+		}
+
+		switch rule {
+		case "max":
+			limit, _ := strconv.Atoi(arg)
+			switch value.Kind() {
+			case reflect.String:
+				if utf8.RuneCountInString(value.String()) > limit {
+					return problem.Field{Field: name, Code: "too_long", Message: fmt.Sprintf("%s must be at most %d characters", name, limit)}, false
+				}
+			case reflect.Slice, reflect.Map:
+				if value.Len() > limit {
+					return problem.Field{Field: name, Code: "too_many", Message: fmt.Sprintf("%s must have at most %d items", name, limit)}, false
+				}
+			}
+		case "min":
+			limit, _ := strconv.ParseInt(arg, 10, 64)
+			if value.CanInt() && value.Int() < limit {
+				return problem.Field{Field: name, Code: "too_small", Message: fmt.Sprintf("%s must be at least %d", name, limit)}, false
+			}
+		case "oneof":
+			allowed := strings.Fields(arg)
+			if !contains(allowed, value.String()) {
+				return problem.Field{Field: name, Code: "invalid", Message: fmt.Sprintf("%s must be one of %s", name, strings.Join(allowed, ", "))}, false
+			}
+		case "date":
+			if _, err := dates.Resolve(value.String(), time.Now(), time.UTC); err != nil {
+				return problem.Field{Field: name, Code: "invalid", Message: name + " is not a valid date"}, false
+			}
+		case "datetime":
+			if _, err := dates.Parse(value.String(), time.UTC); err != nil {
+				return problem.Field{Field: name, Code: "invalid", Message: name + " is not a valid date and time"}, false
+			}
+		case "timezone":
+			if _, err := dates.LoadLocation(value.String()); err != nil {
+				return problem.Field{Field: name, Code: "invalid", Message: name + " is not a valid time zone"}, false
+			}
+		default:
+			panic("validate: unknown rule " + rule)
+		}
+	}
+	return problem.Field{}, true
+}
+
+func empty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(value.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package validate
+
+import (
+	"Simple_Task_Manager/problem"
+	"Simple_Task_Manager/workflow"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type item struct {
+	Name string `json:"name" validate:"required,max=5"`
+}
+
+type body struct {
+	Title    string `json:"title" validate:"required,max=10"`
+	Priority int    `json:"priority" validate:"min=1"`
+	Kind     string `json:"kind" validate:"oneof=bug feature"`
+	Due      string `json:"due" validate:"date"`
+	At       string `json:"at" validate:"datetime"`
+	Zone     string `json:"zone" validate:"timezone"`
+	Status   string `json:"status"`
+	Items    []item `json:"items" validate:"max=2"`
+
+	workflow *workflow.Workflow
+}
+
+func (b *body) Validate() []problem.Field {
+	return Status(b.workflow, "status", b.Status)
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		fields []string
+	}{
+		{name: "valid", body: `{"title":"ok","priority":2,"kind":"bug","due":"tomorrow","at":"2026-01-02T03:04:05Z","zone":"Europe/Paris","status":"in-review","items":[{"name":"a"}]}`},
+		{name: "empty body", body: ``, status: http.StatusBadRequest, fields: []string{"title"}},
+		{name: "blank", body: `{"title":"  "}`, status: http.StatusBadRequest, fields: []string{"title"}},
+		{name: "too long", body: `{"title":"ééééééééééé"}`, status: http.StatusBadRequest, fields: []string{"title"}},
+		{name: "every rule", body: `{"title":"ok","priority":-1,"kind":"chore","due":"someday","at":"noon","zone":"Mars/Base"}`, status: http.StatusBadRequest, fields: []string{"priority", "kind", "due", "at", "zone"}},
+		{name: "nested", body: `{"title":"ok","items":[{"name":"a"},{"name":""}]}`, status: http.StatusBadRequest, fields: []string{"items[1].name"}},
+		{name: "too many", body: `{"title":"ok","items":[{"name":"a"},{"name":"b"},{"name":"c"}]}`, status: http.StatusBadRequest, fields: []string{"items"}},
+		{name: "unknown status with tag errors", body: `{"title":"","status":"blocked"}`, status: http.StatusBadRequest, fields: []string{"title", "status"}},
+		{name: "wrong type", body: `{"title":1}`, status: http.StatusBadRequest, fields: []string{"title"}},
+		{name: "unknown field", body: `{"title":"ok","owner":"me"}`, status: http.StatusBadRequest, fields: []string{"owner"}},
+		{name: "trailing data", body: `{"title":"ok"} {}`, status: http.StatusBadRequest},
+		{name: "too large", body: `{"title":"` + strings.Repeat("x", MaxBodySize) + `"}`, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			dst := body{workflow: workflow.Default()}
+			err := Decode(httptest.NewRecorder(), r, &dst)
+			if test.status == 0 {
+				if err != nil {
+					t.Fatalf("Decode error: %v", err)
+				}
+				return
+			}
+			var invalid *problem.Error
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Decode error = %v, want a problem", err)
+			}
+			if invalid.Status != test.status {
+				t.Errorf("status %d, want %d", invalid.Status, test.status)
+			}
+			var fields []string
+			for _, field := range invalid.Errors {
+				fields = append(fields, field.Field)
+			}
+			if !reflect.DeepEqual(fields, test.fields) {
+				t.Errorf("fields %v, want %v", fields, test.fields)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	custom := &workflow.Workflow{
+		Initial:     "open",
+		Completed:   []workflow.Status{"closed"},
+		Transitions: map[workflow.Status][]workflow.Status{"open": {"closed"}, "closed": {"open"}},
+	}
+	tests := []struct {
+		wf      *workflow.Workflow
+		status  string
+		message string
+	}{
+		{workflow.Default(), "", ""},
+		{workflow.Default(), "in-review", ""},
+		{workflow.Default(), "open", "status must be one of cancelled, done, in-progress, in-review, todo"},
+		{custom, "open", ""},
+		{custom, "todo", "status must be one of closed, open"},
+	}
+	for _, test := range tests {
+		fields := Status(test.wf, "status", test.status)
+		message := ""
+		if len(fields) > 0 {
+			message = fields[0].Message
+		}
+		if message != test.message {
+			t.Errorf("Status(%q) = %q, want %q", test.status, message, test.message)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+type Status string
+
+const (
+	Todo       Status = "todo"
+	InProgress Status = "in-progress"
+	InReview   Status = "in-review"
+	Done       Status = "done"
+	Cancelled  Status = "cancelled"
+)
+
+var (
+	ErrUnknownStatus     = errors.New("unknown status")
+	ErrInvalidTransition = errors.New("status transition not allowed")
+)
+
+// Workflow defines the statuses a task can have and which status changes are
+// allowed. A task counts as completed while its status is one of Completed.
+// Columns orders the statuses on the board and sets their WIP limits.
+type Workflow struct {
+	Initial     Status              `json:"initial"`
+	Completed   []Status            `json:"completed"`
+	Transitions map[Status][]Status `json:"transitions"`
+	Columns     []Column            `json:"columns,omitempty"`
+}
+
+// Column is a board column. A WIPLimit of zero means unlimited.
+type Column struct {
+	Status   Status `json:"status"`
+	WIPLimit int    `json:"wip_limit,omitempty"`
+}
+
+func Default() *Workflow {
+	return &Workflow{
+		Initial:   Todo,
+		Completed: []Status{Done},
+		Transitions: map[Status][]Status{
+			Todo:       {InProgress, Done, Cancelled},
+			InProgress: {Todo, InReview, Done, Cancelled},
+			InReview:   {InProgress, Done, Cancelled},
+			Done:       {Todo},
+			Cancelled:  {Todo},
+		},
+		Columns: []Column{
+			{Status: Todo},
+			{Status: InProgress},
+			{Status: InReview},
+			{Status: Done},
+			{Status: Cancelled},
+		},
+	}
+}
+
+// Load reads a workflow definition from a JSON file.
+func Load(path string) (*Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var wf Workflow
+	if err = json.Unmarshal(data, &wf); err != nil {
+		return nil, fmt.Errorf("error decoding workflow: %w", err)
+	}
+	if err = wf.validate(); err != nil {
+		return nil, err
+	}
+	return &wf, nil
+}
+
+func (wf *Workflow) validate() error {
+	if !wf.Valid(wf.Initial) {
+		return fmt.Errorf("%w: initial status %q", ErrUnknownStatus, wf.Initial)
+	}
+	if len(wf.Completed) == 0 {
+		return errors.New("workflow needs at least one completed status")
+	}
+	for _, status := range wf.Completed {
+		if !wf.Valid(status) {
+			return fmt.Errorf("%w: completed status %q", ErrUnknownStatus, status)
+		}
+	}
+	for _, column := range wf.Columns {
+		if !wf.Valid(column.Status) {
+			return fmt.Errorf("%w: column %q", ErrUnknownStatus, column.Status)
+		}
+		if column.WIPLimit < 0 {
+			return fmt.Errorf("negative WIP limit for column %q", column.Status)
+		}
+	}
+	for from, targets := range wf.Transitions {
+		for _, to := range targets {
+			if !wf.Valid(to) {
+				return fmt.Errorf("%w: transition %q -> %q", ErrUnknownStatus, from, to)
+			}
+		}
+	}
+	return nil
+}
+
+// Valid reports whether status is part of the workflow.
+func (wf *Workflow) Valid(status Status) bool {
+	_, ok := wf.Transitions[status]
+	return ok
+}
+
+// Statuses returns the statuses of the workflow in alphabetical order.
+func (wf *Workflow) Statuses() []Status {
+	statuses := make([]Status, 0, len(wf.Transitions))
+	for status := range wf.Transitions {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+	return statuses
+}
+
+// IsCompleted reports whether tasks in status count as completed.
+func (wf *Workflow) IsCompleted(status Status) bool {
+	for _, completed := range wf.Completed {
+		if completed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CompletedStatus is the status a task moves to when a client completes it
+// without naming a status.
+func (wf *Workflow) CompletedStatus() Status {
+	return wf.Completed[0]
+}
+
+// CheckTransition returns an error unless a task may move from one status to
+// the other. Staying in the same status is always allowed.
+func (wf *Workflow) CheckTransition(from, to Status) error {
+	if !wf.Valid(to) {
+		return fmt.Errorf("%w: %q", ErrUnknownStatus, to)
+	}
+	if from == to {
+		return nil
+	}
+	for _, allowed := range wf.Transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q -> %q", ErrInvalidTransition, from, to)
+}
+
+// BoardColumns returns the board columns in display order. Workflows without
+// explicit columns get one unlimited column per status, initial status first.
+func (wf *Workflow) BoardColumns() []Column {
+	if len(wf.Columns) > 0 {
+		return wf.Columns
+	}
+
+	columns := make([]Column, 0, len(wf.Transitions))
+	for status := range wf.Transitions {
+		columns = append(columns, Column{Status: status})
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if columns[i].Status == wf.Initial || columns[j].Status == wf.Initial {
+			return columns[i].Status == wf.Initial
+		}
+		return columns[i].Status < columns[j].Status
+	})
+	return columns
+}
+
+// WIPLimit returns the WIP limit of the column for status, zero if unlimited.
+func (wf *Workflow) WIPLimit(status Status) int {
+	for _, column := range wf.BoardColumns() {
+		if column.Status == status {
+			return column.WIPLimit
+		}
+	}
+	return 0
+}
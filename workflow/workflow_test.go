@@ -0,0 +1,97 @@
+package workflow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to Status
+		err      error
+	}{
+		{Todo, InProgress, nil},
+		{Todo, Todo, nil},
+		{InProgress, InReview, nil},
+		{Done, Todo, nil},
+		{Todo, InReview, ErrInvalidTransition},
+		{Cancelled, Done, ErrInvalidTransition},
+		{Todo, "blocked", ErrUnknownStatus},
+		{"blocked", "blocked", ErrUnknownStatus},
+	}
+	wf := Default()
+	for _, test := range tests {
+		if err := wf.CheckTransition(test.from, test.to); !errors.Is(err, test.err) {
+			t.Errorf("CheckTransition(%q, %q) = %v, want %v", test.from, test.to, err, test.err)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	wf := Default()
+	if err := wf.validate(); err != nil {
+		t.Fatalf("default workflow is invalid: %v", err)
+	}
+	if !wf.IsCompleted(Done) || wf.IsCompleted(Cancelled) || wf.CompletedStatus() != Done {
+		t.Errorf("default workflow should complete tasks as done only")
+	}
+	want := []Status{Cancelled, Done, InProgress, InReview, Todo}
+	if got := wf.Statuses(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statuses() = %v, want %v", got, want)
+	}
+}
+
+func TestBoardColumns(t *testing.T) {
+	wf := &Workflow{
+		Initial:     "open",
+		Completed:   []Status{"closed"},
+		Transitions: map[Status][]Status{"open": {"closed"}, "closed": {"open"}, "blocked": {"open"}},
+	}
+	want := []Column{{Status: "open"}, {Status: "blocked"}, {Status: "closed"}}
+	if got := wf.BoardColumns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("BoardColumns() = %v, want %v", got, want)
+	}
+
+	wf.Columns = []Column{{Status: "closed"}, {Status: "open", WIPLimit: 3}}
+	if got := wf.BoardColumns(); !reflect.DeepEqual(got, wf.Columns) {
+		t.Errorf("BoardColumns() = %v, want %v", got, wf.Columns)
+	}
+	if got := wf.WIPLimit("open"); got != 3 {
+		t.Errorf("WIPLimit(open) = %d, want 3", got)
+	}
+	if got := wf.WIPLimit("blocked"); got != 0 {
+		t.Errorf("WIPLimit(blocked) = %d, want 0", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name       string
+		definition string
+		ok         bool
+	}{
+		{"valid", `{"initial":"open","completed":["closed"],"transitions":{"open":["closed"],"closed":["open"]}}`, true},
+		{"unknown initial", `{"initial":"new","completed":["closed"],"transitions":{"open":["closed"],"closed":[]}}`, false},
+		{"no completed", `{"initial":"open","completed":[],"transitions":{"open":[]}}`, false},
+		{"unknown completed", `{"initial":"open","completed":["gone"],"transitions":{"open":[]}}`, false},
+		{"unknown target", `{"initial":"open","completed":["open"],"transitions":{"open":["gone"]}}`, false},
+		{"unknown column", `{"initial":"open","completed":["open"],"transitions":{"open":[]},"columns":[{"status":"gone"}]}`, false},
+		{"negative limit", `{"initial":"open","completed":["open"],"transitions":{"open":[]},"columns":[{"status":"open","wip_limit":-1}]}`, false},
+		{"not json", `initial: open`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "workflow.json")
+			if err := os.WriteFile(path, []byte(test.definition), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Load(path)
+			if (err == nil) != test.ok {
+				t.Errorf("Load error = %v, want ok %v", err, test.ok)
+			}
+		})
+	}
+}